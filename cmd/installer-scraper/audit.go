@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/product"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+	"github.com/deploymenttheory/go-app-index/internal/vuln"
+)
+
+// newAuditCmd creates the command that reports known vulnerabilities in
+// stored installers
+func newAuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Report stored installers with known vulnerabilities",
+		Long: `Lists the vulnerable installer versions still in the index, with the
+version that fixes each vulnerability and the newest version of the product
+the index holds. Installers are reported with the vulnerabilities recorded
+when they were crawled, or matched afresh against --vulnerability-db.`,
+		RunE: runAudit,
+	}
+
+	cmd.Flags().String("storage", "", "storage backend URI to read, e.g. sqlite://installers.db (required)")
+	cmd.Flags().StringP("output", "o", "-", "file to write, or - for stdout")
+	cmd.Flags().String("format", "text", "report format: text or json")
+	cmd.Flags().StringSlice("vulnerability-db", []string{}, "OSV or NVD JSON files, zip exports or directories to match installers against instead of the stored results")
+	cmd.Flags().String("min-severity", vuln.SeverityUnknown, "only report vulnerabilities at least this severe (critical, high, medium, low, unknown)")
+	cmd.Flags().String("fail-on", "", "exit with an error if any vulnerability is at least this severe")
+	cmd.Flags().String("platform", "", "only audit installers for this platform")
+	cmd.Flags().String("file-type", "", "only audit installers of this file type")
+	cmd.Flags().String("arch", "", "only audit installers for this architecture")
+	cmd.Flags().String("domain", "", "only audit installers found on this domain")
+	cmd.Flags().String("tag", "", "only audit installers a detection rule gave this tag")
+	cmd.MarkFlagRequired("storage")
+
+	return cmd
+}
+
+// auditReport is the document written by the audit command
+type auditReport struct {
+	GeneratedAt          time.Time      `json:"generated_at"`
+	InstallersAudited    int            `json:"installers_audited"`
+	VulnerableInstallers int            `json:"vulnerable_installers"`
+	Findings             []auditFinding `json:"findings"`
+}
+
+// auditFinding is one vulnerability in one installer
+type auditFinding struct {
+	Filename      string              `json:"filename"`
+	SHA3Hash      string              `json:"sha3_hash"`
+	SourceURL     string              `json:"source_url"`
+	Product       string              `json:"product"`
+	Version       string              `json:"version,omitempty"`
+	Platform      string              `json:"platform"`
+	Architecture  string              `json:"architecture,omitempty"`
+	LatestVersion string              `json:"latest_version,omitempty"` // Newest version of the product in the index
+	Vulnerability types.Vulnerability `json:"vulnerability"`
+}
+
+func runAudit(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown report format %q (want text or json)", format)
+	}
+	minSeverityName, _ := cmd.Flags().GetString("min-severity")
+	minSeverity, err := vuln.ParseSeverity(minSeverityName)
+	if err != nil {
+		return err
+	}
+	failOn, _ := cmd.Flags().GetString("fail-on")
+	if failOn != "" {
+		if failOn, err = vuln.ParseSeverity(failOn); err != nil {
+			return err
+		}
+	}
+
+	var db *vuln.Database
+	if paths, _ := cmd.Flags().GetStringSlice("vulnerability-db"); len(paths) > 0 {
+		if db, err = vuln.Load(paths...); err != nil {
+			return fmt.Errorf("failed to load vulnerability database: %w", err)
+		}
+		logger.Infof("Loaded %d vulnerability advisories", db.Len())
+	}
+
+	store, err := openForExport(cmd)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	installers, err := readInstallers(cmd, store)
+	if err != nil {
+		return err
+	}
+	if db != nil {
+		for i := range installers {
+			installers[i].Vulnerabilities = db.Match(installers[i])
+		}
+	}
+
+	report := auditReport{
+		GeneratedAt:       time.Now(),
+		InstallersAudited: len(installers),
+		Findings:          make([]auditFinding, 0),
+	}
+	latest := latestVersions(product.Group(installers))
+	failures := 0
+	for _, installer := range installers {
+		vulnerable := false
+		for _, v := range installer.Vulnerabilities {
+			if !vuln.AtLeast(v.Severity, minSeverity) {
+				continue
+			}
+			vulnerable = true
+			if failOn != "" && vuln.AtLeast(v.Severity, failOn) {
+				failures++
+			}
+			p, ok := latest[installer.SHA3Hash]
+			if !ok {
+				p.name = installer.Filename
+			}
+			report.Findings = append(report.Findings, auditFinding{
+				Filename:      installer.Filename,
+				SHA3Hash:      installer.SHA3Hash,
+				SourceURL:     installer.SourceURL,
+				Product:       p.name,
+				Version:       installer.Version,
+				Platform:      installer.Platform,
+				Architecture:  installer.Architecture,
+				LatestVersion: p.latest,
+				Vulnerability: v,
+			})
+		}
+		if vulnerable {
+			report.VulnerableInstallers++
+		}
+	}
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i].Vulnerability, report.Findings[j].Vulnerability
+		if a.Severity != b.Severity {
+			return !vuln.AtLeast(b.Severity, a.Severity)
+		}
+		return a.Score > b.Score
+	})
+	logger.Infof("Found %d vulnerabilities in %d of %d installers",
+		len(report.Findings), report.VulnerableInstallers, report.InstallersAudited)
+
+	err = writeExport(cmd, func(w io.Writer) error {
+		if format == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(report)
+		}
+		return writeAuditText(w, report)
+	})
+	if err != nil {
+		return err
+	}
+
+	if failures > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("found %d vulnerabilities of %s severity or higher", failures, failOn)
+	}
+	return nil
+}
+
+// productVersion names an installer's product and the newest version of
+// it in the index
+type productVersion struct {
+	name   string
+	latest string
+}
+
+// latestVersions maps installer hashes to their product and the newest
+// version of it in the index for the installer's platform and architecture
+func latestVersions(products []product.Product) map[string]productVersion {
+	versions := make(map[string]productVersion)
+	for _, p := range products {
+		for _, release := range p.Releases {
+			v := productVersion{name: p.Name}
+			for _, latest := range p.Latest {
+				if latest.Platform == release.Platform && latest.Architecture == release.Architecture {
+					v.latest = latest.Version
+				}
+			}
+			for _, artifact := range release.Artifacts {
+				versions[artifact.SHA3Hash] = v
+			}
+		}
+	}
+	return versions
+}
+
+// writeAuditText writes a report as a table, most severe first
+func writeAuditText(w io.Writer, report auditReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEVERITY\tID\tPRODUCT\tVERSION\tFIXED IN\tLATEST INDEXED\tINSTALLER")
+	for _, f := range report.Findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			f.Vulnerability.Severity, f.Vulnerability.ID, f.Product, dash(f.Version),
+			dash(f.Vulnerability.FixedIn), dash(f.LatestVersion), f.Filename)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d vulnerabilities in %d of %d installers\n",
+		len(report.Findings), report.VulnerableInstallers, report.InstallersAudited)
+	return err
+}
+
+// dash stands in for an unknown value in a table
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
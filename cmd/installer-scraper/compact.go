@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/storage"
+)
+
+// newCompactCmd creates the command that merges JSON Lines output into an
+// installers.json document
+func newCompactCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compact <file.jsonl>...",
+		Short: "Merge and deduplicate JSON Lines output into an installers.json document",
+		Long: `Reads one or more JSON Lines files written by the jsonl storage backend,
+drops installers already seen by hash or URL, and writes a single sorted
+installers.json document with freshly computed stats. An incomplete final
+line left by an interrupted crawl is ignored.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runCompact,
+	}
+
+	cmd.Flags().StringP("output", "o", "installers.json", "JSON file to write")
+
+	return cmd
+}
+
+func runCompact(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+
+	stats, err := storage.Compact(args, output)
+	if err != nil {
+		return fmt.Errorf("failed to compact: %w", err)
+	}
+
+	logger.Infof("Wrote %d unique installers to %s", stats.FilesStored, output)
+	return nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deploymenttheory/go-app-index/internal/arch"
+	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/manifest"
+	"github.com/deploymenttheory/go-app-index/internal/product"
+	"github.com/deploymenttheory/go-app-index/internal/sbom"
+	"github.com/deploymenttheory/go-app-index/internal/storage"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// newExportCmd creates the command group that writes stored installers in
+// other formats
+func newExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export stored installers in another format",
+	}
+
+	cmd.PersistentFlags().String("storage", "", "storage backend URI to read, e.g. sqlite://installers.db (required)")
+	cmd.PersistentFlags().StringP("output", "o", "-", "file to write, or - for stdout")
+	cmd.PersistentFlags().String("platform", "", "only export installers for this platform")
+	cmd.PersistentFlags().String("file-type", "", "only export installers of this file type")
+	cmd.PersistentFlags().String("arch", "", "only export installers for this architecture (x86, x64, arm64, arm, universal, noarch)")
+	cmd.PersistentFlags().String("domain", "", "only export installers found on this domain")
+	cmd.PersistentFlags().Float64("below-score", 0, "only export installers with a detection score below this, e.g. to review uncertain detections")
+	cmd.PersistentFlags().String("tag", "", "only export installers a detection rule gave this tag")
+	cmd.MarkPersistentFlagRequired("storage")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "json",
+		Short: "Export stored installers as an installers.json document",
+		RunE:  runExportJSON,
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "products",
+		Short: "Export stored installers grouped into products with their version history",
+		RunE:  runExportProducts,
+	})
+
+	sbomCmd := &cobra.Command{
+		Use:   "sbom",
+		Short: "Export a CycloneDX or SPDX software bill of materials for stored installers",
+		RunE:  runExportSBOM,
+	}
+	sbomCmd.Flags().String("format", "cyclonedx", "SBOM format: cyclonedx (CycloneDX 1.5) or spdx (SPDX 2.3)")
+	sbomCmd.Flags().String("dir", "", "write one document per installer into this directory instead of one document to --output")
+	cmd.AddCommand(sbomCmd)
+
+	manifestsCmd := &cobra.Command{
+		Use:   "manifests",
+		Short: "Export package manager manifests and MDM payloads (winget, Chocolatey, Homebrew Cask, Munki, AutoPkg, Intune, Jamf Pro) for stored installers",
+		Long: `Writes the manifests a package manager or deployment tool installs each
+stored installer from. Installers the format cannot deploy are skipped;
+installers missing a field the format requires are reported, and the
+command fails once the rest are written.`,
+		RunE: runExportManifests,
+	}
+	manifestsCmd.Flags().String("format", "", "manifest format: "+strings.Join(manifest.Names(), ", ")+" (required)")
+	manifestsCmd.Flags().String("dir", "", "directory to write the manifests into (required)")
+	manifestsCmd.MarkFlagRequired("format")
+	manifestsCmd.MarkFlagRequired("dir")
+	cmd.AddCommand(manifestsCmd)
+
+	return cmd
+}
+
+func runExportJSON(cmd *cobra.Command, args []string) error {
+	store, err := openForExport(cmd)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	installers, err := readInstallers(cmd, store)
+	if err != nil {
+		return err
+	}
+
+	return writeExport(cmd, func(w io.Writer) error {
+		return storage.ExportJSON(w, installers, store.Stats())
+	})
+}
+
+func runExportProducts(cmd *cobra.Command, args []string) error {
+	store, err := openForExport(cmd)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	installers, err := readInstallers(cmd, store)
+	if err != nil {
+		return err
+	}
+
+	products := product.Group(installers)
+	logger.Infof("Grouped %d installers into %d products", len(installers), len(products))
+
+	return writeExport(cmd, func(w io.Writer) error {
+		return product.Export(w, products)
+	})
+}
+
+func runExportSBOM(cmd *cobra.Command, args []string) error {
+	formatName, _ := cmd.Flags().GetString("format")
+	format, err := sbom.ParseFormat(formatName)
+	if err != nil {
+		return err
+	}
+
+	store, err := openForExport(cmd)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	installers, err := readInstallers(cmd, store)
+	if err != nil {
+		return err
+	}
+	created := time.Now()
+
+	dir, _ := cmd.Flags().GetString("dir")
+	if dir == "" {
+		return writeExport(cmd, func(w io.Writer) error {
+			return sbom.Write(w, format, installers, created)
+		})
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	for _, installer := range installers {
+		path := filepath.Join(dir, sbomFileName(installer)+format.Extension())
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", path, err)
+		}
+		if err := sbom.Write(file, format, []types.ProcessedFile{installer}, created); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+
+	logger.Infof("Exported %d SBOMs to %s", len(installers), dir)
+	return nil
+}
+
+func runExportManifests(cmd *cobra.Command, args []string) error {
+	formatName, _ := cmd.Flags().GetString("format")
+	formatter, err := manifest.Lookup(formatName)
+	if err != nil {
+		return err
+	}
+	dir, _ := cmd.Flags().GetString("dir")
+
+	store, err := openForExport(cmd)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	summary, err := manifest.Export(store, exportFilter(cmd), formatter, dir)
+	if err != nil {
+		return err
+	}
+	for _, invalid := range summary.Invalid {
+		logger.Warningf("Skipped %v", invalid)
+	}
+	logger.Infof("Exported %s manifests for %d installers to %s (%d files, %d installers not supported by the format)",
+		formatter.Name(), summary.Exported, dir, summary.Files, summary.Unsupported)
+
+	if len(summary.Invalid) > 0 {
+		return fmt.Errorf("%d installers failed %s validation", len(summary.Invalid), formatter.Name())
+	}
+	return nil
+}
+
+// sbomFileName names an installer's SBOM after its filename and hash, so
+// installers with the same filename get separate documents
+func sbomFileName(installer types.ProcessedFile) string {
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(installer.Filename, "_"), "._")
+	hash := installer.SHA3Hash
+	if len(hash) > 12 {
+		hash = hash[:12]
+	}
+	switch {
+	case name == "":
+		return hash
+	case hash == "":
+		return name
+	default:
+		return name + "-" + hash
+	}
+}
+
+// unsafeFileChars matches characters kept out of exported file names
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// openForExport opens the --storage backend for reading
+func openForExport(cmd *cobra.Command) (storage.Storage, error) {
+	uri, _ := cmd.Flags().GetString("storage")
+
+	store, err := storage.Open(uri)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+	return store, nil
+}
+
+// exportFilter builds the storage filter selected by the filter flags
+func exportFilter(cmd *cobra.Command) storage.Filter {
+	var filter storage.Filter
+	filter.Platform, _ = cmd.Flags().GetString("platform")
+	filter.FileType, _ = cmd.Flags().GetString("file-type")
+	filter.Domain, _ = cmd.Flags().GetString("domain")
+	filter.BelowScore, _ = cmd.Flags().GetFloat64("below-score")
+	filter.Tag, _ = cmd.Flags().GetString("tag")
+	if architecture, _ := cmd.Flags().GetString("arch"); architecture != "" {
+		// Accept aliases such as x86_64 or aarch64
+		filter.Architecture = architecture
+		if normalized := arch.Normalize(architecture); normalized != "" {
+			filter.Architecture = normalized
+		}
+	}
+	return filter
+}
+
+// readInstallers returns the stored installers selected by the filter flags
+func readInstallers(cmd *cobra.Command, store storage.Storage) ([]types.ProcessedFile, error) {
+	var installers []types.ProcessedFile
+	err := store.Iterate(exportFilter(cmd), func(file types.ProcessedFile) error {
+		installers = append(installers, file)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read installers: %w", err)
+	}
+	return installers, nil
+}
+
+// writeExport runs write against the --output file, or stdout for "-"
+func writeExport(cmd *cobra.Command, write func(io.Writer) error) error {
+	output, _ := cmd.Flags().GetString("output")
+	if output == "-" {
+		return write(os.Stdout)
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	logger.Infof("Exported to %s", output)
+	return nil
+}
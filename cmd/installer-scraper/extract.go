@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deploymenttheory/go-app-index/internal/config"
+	"github.com/deploymenttheory/go-app-index/internal/crawler"
+)
+
+// newExtractCmd creates the command that runs link extraction rules against
+// a saved page, without touching the network
+func newExtractCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "extract",
+		Short: "Extract download links from a saved HTML page",
+		Long: `Runs the configured extraction rules (or the given extractors) against a
+saved HTML page and prints the absolute download URLs found. Useful for
+developing per-domain rules offline.`,
+		RunE: runExtract,
+	}
+
+	cmd.Flags().StringP("file", "f", "", "saved HTML page to read (required)")
+	cmd.Flags().StringP("url", "u", "", "URL the page was saved from (required)")
+	cmd.Flags().StringSlice("extractor", []string{},
+		"extractors to run, overriding config rules ("+strings.Join(crawler.ExtractorNames(), ", ")+")")
+	cmd.Flags().StringSlice("pattern", []string{}, "extra regex patterns to run, overriding config rules")
+	cmd.Flags().Bool("follow-all", false, "print every extracted URL, not only likely downloads")
+	cmd.MarkFlagRequired("file")
+	cmd.MarkFlagRequired("url")
+
+	return cmd
+}
+
+func runExtract(cmd *cobra.Command, args []string) error {
+	file, _ := cmd.Flags().GetString("file")
+	pageURL, _ := cmd.Flags().GetString("url")
+	extractors, _ := cmd.Flags().GetStringSlice("extractor")
+	patterns, _ := cmd.Flags().GetStringSlice("pattern")
+	followAll, _ := cmd.Flags().GetBool("follow-all")
+
+	body, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read page: %w", err)
+	}
+
+	cfg, err := loadConfigFile()
+	if err != nil {
+		return err
+	}
+
+	rules := cfg.ExtractionRules
+	if len(extractors) > 0 || len(patterns) > 0 || followAll || len(rules) == 0 {
+		rules = []config.ExtractionRule{{
+			Domain:     "*",
+			Extractors: extractors,
+			Patterns:   patterns,
+			FollowAll:  followAll,
+		}}
+	}
+
+	links, err := crawler.ExtractLinks(pageURL, body, rules)
+	if err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		fmt.Println(link)
+	}
+	return nil
+}
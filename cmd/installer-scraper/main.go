@@ -3,8 +3,14 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -13,9 +19,16 @@ import (
 	"github.com/deploymenttheory/go-app-index/internal/config"
 	"github.com/deploymenttheory/go-app-index/internal/crawler"
 	"github.com/deploymenttheory/go-app-index/internal/downloader"
+	"github.com/deploymenttheory/go-app-index/internal/fileanalyzer"
 	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/policy"
 	"github.com/deploymenttheory/go-app-index/internal/processor"
+	"github.com/deploymenttheory/go-app-index/internal/rules"
+	"github.com/deploymenttheory/go-app-index/internal/source"
 	"github.com/deploymenttheory/go-app-index/internal/storage"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+	"github.com/deploymenttheory/go-app-index/internal/urlutil"
+	"github.com/deploymenttheory/go-app-index/internal/vuln"
 )
 
 var (
@@ -41,26 +54,50 @@ from specified websites, computes their SHA3 hash, and stores metadata to a JSON
 	rootCmd.PersistentFlags().Bool("no-color", false, "disable colored output")
 	rootCmd.PersistentFlags().String("log-file", "", "log to file instead of stdout")
 
-	// Required flags
-	rootCmd.Flags().StringP("url", "u", "", "URL to start scraping (required)")
-	rootCmd.MarkFlagRequired("url")
+	// Discovery flags (at least one of --url or --source is required)
+	rootCmd.Flags().StringP("url", "u", "", "URL to start scraping")
+	rootCmd.Flags().StringSliceP("source", "s", []string{},
+		"release feeds to read as kind:target (github:owner/repo, appcast:URL, rss:URL, atom:URL, nuget:URL, chocolatey:id)")
+	rootCmd.Flags().StringSlice("github-hosts", []string{}, "GitHub Enterprise Server API hosts that may be sent GITHUB_TOKEN")
 
 	// Optional flags (same as before)
 	rootCmd.Flags().StringP("output", "o", "installers.json", "output JSON file")
+	rootCmd.Flags().StringSlice("storage", []string{},
+		"storage backend URIs (json, jsonl, csv, sqlite, postgres, s3), e.g. sqlite://installers.db; several write to all of them (default: the JSON output file)")
 	rootCmd.Flags().IntP("depth", "d", 3, "maximum crawl depth")
 	rootCmd.Flags().StringSliceP("extensions", "e",
-		[]string{".exe", ".msi", ".dmg", ".pkg", ".deb", ".rpm", ".AppImage"},
+		[]string{".exe", ".msi", ".msix", ".msixbundle", ".appx", ".appxbundle", ".dmg", ".pkg", ".deb", ".rpm", ".AppImage"},
 		"file extensions to look for")
 	rootCmd.Flags().StringSliceP("include", "i", []string{}, "regex patterns to include URLs")
 	rootCmd.Flags().StringSliceP("exclude", "x", []string{}, "regex patterns to exclude URLs")
 	rootCmd.Flags().String("temp-dir", os.TempDir(), "temporary directory for downloads")
+	rootCmd.Flags().String("queue-spill-dir", "", "overflow the download queue to this directory instead of pausing the crawl when downloads fall behind")
 	rootCmd.Flags().IntP("timeout", "t", 300, "HTTP request timeout in seconds")
+	rootCmd.Flags().Int("analysis-timeout", 120, "time limit in seconds for analyzing one downloaded file")
+	rootCmd.Flags().String("plugin-dir", "", "load every executable in this directory as an analyzer plugin")
+	rootCmd.Flags().StringSlice("rules", []string{}, "detection rule files or directories to apply after analysis")
+	rootCmd.Flags().StringSlice("vulnerability-db", []string{}, "OSV or NVD JSON files, zip exports or directories to match installers against")
+	rootCmd.Flags().Bool("sitemaps", false, "seed the crawl from sitemap.xml and sitemap indexes")
+	rootCmd.Flags().Bool("obey-robots", false, "honor robots.txt rules and Crawl-delay")
+	rootCmd.Flags().StringSlice("allowed-domains", []string{}, "domain globs to crawl (default: the start URL's host)")
+	rootCmd.Flags().StringSlice("download-domains", []string{}, "extra domain globs to download installers from without crawling them")
+	rootCmd.Flags().String("subdomains", "subdomains", "how allowed domains match hosts: exact, subdomains or any")
+	rootCmd.Flags().StringSlice("path-prefix", []string{}, "only crawl pages whose path starts with one of these prefixes")
+	rootCmd.Flags().String("fixture-dir", "", "crawl saved pages from this directory (<dir>/<host>/<path>) instead of the network")
 
 	// Concurrency flags (same as before)
 	rootCmd.Flags().IntP("crawler-workers", "w", 10, "number of crawler workers")
 	rootCmd.Flags().IntP("download-workers", "W", 5, "number of download workers")
 	rootCmd.Flags().IntP("processor-workers", "p", 3, "number of processor workers")
 	rootCmd.Flags().IntP("delay", "D", 200, "delay between requests in milliseconds")
+	rootCmd.Flags().String("user-agent", "", "User-Agent header for page and download requests")
+
+	// Subcommands
+	rootCmd.AddCommand(newExtractCmd())
+	rootCmd.AddCommand(newExportCmd())
+	rootCmd.AddCommand(newCompactCmd())
+	rootCmd.AddCommand(newMigrateCmd())
+	rootCmd.AddCommand(newAuditCmd())
 
 	// Execute
 	if err := rootCmd.Execute(); err != nil {
@@ -112,9 +149,62 @@ func runScraper(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	sources := make([]source.Source, 0, len(cfg.Sources))
+	for _, spec := range cfg.Sources {
+		src, err := source.Parse(spec, cfg.FileExtensions)
+		if err != nil {
+			logger.Errorf("Invalid source: %v", err)
+			os.Exit(1)
+		}
+		if github, ok := src.(*source.GitHubSource); ok {
+			github.TokenHosts = cfg.GitHubHosts
+		}
+		sources = append(sources, src)
+	}
+
+	weights, err := fileanalyzer.DefaultConfidenceWeights().WithOverrides(cfg.ConfidenceWeights)
+	if err != nil {
+		logger.Errorf("Invalid confidence weights: %v", err)
+		os.Exit(1)
+	}
+
+	plugins, err := analyzerPlugins(cfg)
+	if err != nil {
+		logger.Errorf("Failed to load analyzer plugins: %v", err)
+		os.Exit(1)
+	}
+
+	procOpts := []processor.Option{
+		processor.WithAnalysisTimeout(time.Duration(cfg.AnalysisTimeout) * time.Second),
+		processor.WithConfidenceWeights(weights), processor.WithPlugins(plugins...),
+	}
+	if len(cfg.Rules) > 0 {
+		engine, err := rules.Load(cfg.Rules...)
+		if err != nil {
+			logger.Errorf("Failed to load detection rules: %v", err)
+			os.Exit(1)
+		}
+		logger.Infof("Loaded %d detection rules", engine.Len())
+		procOpts = append(procOpts, processor.WithRules(engine))
+	}
+	if len(cfg.VulnerabilityDB) > 0 {
+		db, err := vuln.Load(cfg.VulnerabilityDB...)
+		if err != nil {
+			logger.Errorf("Failed to load vulnerability database: %v", err)
+			os.Exit(1)
+		}
+		logger.Infof("Loaded %d vulnerability advisories", db.Len())
+		procOpts = append(procOpts, processor.WithVulnerabilities(db))
+	}
+
 	overallStartTime := time.Now()
 
-	logger.Infof("Starting scraper for %s with depth %d", cfg.StartURL, cfg.MaxDepth)
+	if cfg.StartURL != "" {
+		logger.Infof("Starting scraper for %s with depth %d", cfg.StartURL, cfg.MaxDepth)
+	}
+	if len(sources) > 0 {
+		logger.Infof("Reading %d release feeds", len(sources))
+	}
 	logger.Infof("Looking for file extensions: %v", cfg.FileExtensions)
 
 	// Setup signal handling for graceful shutdown
@@ -122,44 +212,96 @@ func runScraper(cmd *cobra.Command, args []string) {
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 
 	// Initialize components
-	store, err := storage.New(cfg.OutputFile)
+	store, err := storage.OpenAll(storageURIs(cfg))
 	if err != nil {
 		logger.Errorf("Failed to initialize storage: %v", err)
 		os.Exit(1)
 	}
 
-	proc := processor.New(cfg.ProcessorWorkers, store, cfg.TempDir)
-	down := downloader.New(cfg.DownloadWorkers, proc.Queue(), cfg.FileExtensions, cfg.TempDir)
-	crawl := crawler.New(cfg.CrawlerWorkers, cfg.StartURL, cfg.MaxDepth,
-		cfg.IncludePatterns, cfg.ExcludePatterns, cfg.Delay, cfg.RequestTimeout, down.Queue())
+	proc := processor.New(cfg.ProcessorWorkers, store, cfg.TempDir, procOpts...)
+	policies := policySet(cfg)
+	down := downloader.New(cfg.DownloadWorkers, proc.Queue(), cfg.FileExtensions, cfg.TempDir,
+		downloader.WithPolicies(policies), downloader.WithSpillDir(cfg.QueueSpillDir))
+
+	// The crawler and feeds share one record of queued URLs, so an
+	// installer both of them find is downloaded once
+	queued := urlutil.NewSet()
+
+	var crawl *crawler.Crawler
+	if cfg.StartURL != "" {
+		crawl = crawler.New(cfg.CrawlerWorkers, cfg.StartURL, cfg.MaxDepth,
+			cfg.IncludePatterns, cfg.ExcludePatterns, cfg.Delay, cfg.RequestTimeout, down.Queue(),
+			append(crawlerOptions(cfg), crawler.WithPolicies(policies), crawler.WithQueuedURLs(queued))...)
+	}
+
+	var feeds *source.Runner
+	if len(sources) > 0 {
+		feeds = source.NewRunner(sources, cfg.RequestTimeout, down.Queue(), source.WithQueuedURLs(queued))
+	}
 
 	// Start components
 	proc.Start()
 	down.Start()
 
-	// Run crawler (blocking until complete or interrupted)
+	// Run discovery (crawler and release feeds) until complete or interrupted
+	var discovery sync.WaitGroup
+	if crawl != nil {
+		discovery.Add(1)
+		go func() {
+			defer discovery.Done()
+			if err := crawl.Run(); err != nil {
+				logger.Errorf("Crawler error: %v", err)
+			}
+		}()
+	}
+	if feeds != nil {
+		discovery.Add(1)
+		go func() {
+			defer discovery.Done()
+			if err := feeds.Run(); err != nil {
+				logger.Errorf("Source error: %v", err)
+			}
+		}()
+	}
+
+	discoveryDone := make(chan struct{})
 	go func() {
-		if err := crawl.Run(); err != nil {
-			logger.Errorf("Crawler error: %v", err)
-		}
+		discovery.Wait()
 		// Signal we're done discovering URLs
 		down.Done()
+		close(discoveryDone)
 	}()
 
 	// Wait for completion or interrupt
+	interrupted := false
 	select {
-	case <-crawl.Done():
-		logger.Infof("Crawling complete, waiting for processing to finish...")
+	case <-discoveryDone:
+		logger.Infof("Discovery complete, waiting for processing to finish...")
 		down.Wait()
 		proc.Done()
 		proc.Wait()
-		store.Close()
 	case sig := <-signalChan:
+		interrupted = true
 		logger.Infof("Received signal %v, shutting down gracefully...", sig)
-		crawl.Stop()
+		if crawl != nil {
+			crawl.Stop()
+		}
+		if feeds != nil {
+			feeds.Stop()
+		}
 		down.Stop()
 		proc.Stop()
-		store.Close()
+	}
+
+	// Add this run to the index's history before the final write
+	if recorder, ok := store.(storage.RunRecorder); ok {
+		run := runRecord(cfg, overallStartTime, interrupted, crawl, down, proc)
+		if err := recorder.RecordRun(run); err != nil {
+			logger.Errorf("Failed to record run history: %v", err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		logger.Errorf("Failed to close storage: %v", err)
 	}
 
 	overallDuration := time.Since(overallStartTime)
@@ -167,51 +309,239 @@ func runScraper(cmd *cobra.Command, args []string) {
 	// Final stats
 	logger.Infof("Scraper completed in %v", overallDuration)
 	logger.Infof("Component timing:")
-	logger.Infof("  - Crawler:    %v", crawl.Duration())
+	if crawl != nil {
+		logger.Infof("  - Crawler:    %v", crawl.Duration())
+	}
+	if feeds != nil {
+		logger.Infof("  - Sources:    %v", feeds.Duration())
+	}
 	logger.Infof("  - Downloader: %v", down.Duration())
 	logger.Infof("  - Processor:  %v", proc.Duration())
 
-	logger.Infof("URLs visited: %d", crawl.Stats().URLsVisited)
-	logger.Infof("URLs visited: %d", crawl.Stats().URLsVisited)
-	logger.Infof("URLs skipped: %d", crawl.Stats().URLsSkipped)
+	if crawl != nil {
+		logger.Infof("URLs visited: %d", crawl.Stats().URLsVisited)
+		logger.Infof("URLs skipped: %d", crawl.Stats().URLsSkipped)
+		logger.Infof("URLs skipped by robots: %d", crawl.Stats().URLsRobotsBlocked)
+		logger.Infof("URLs out of scope: %d", crawl.Stats().URLsOutOfScope)
+		logger.Infof("Installers detected from response content: %d", crawl.Stats().URLsDetected)
+		logger.Infof("Duplicate installer URLs skipped: %d", crawl.Stats().URLsDeduplicated)
+		if dropped := crawl.Stats().URLsDropped; dropped > 0 {
+			logger.Warningf("Installer URLs dropped at shutdown: %d", dropped)
+		}
+		if cfg.UseSitemaps {
+			logger.Infof("URLs seeded from sitemaps: %d", crawl.Stats().SitemapURLs)
+		}
+	}
+	if feeds != nil {
+		logger.Infof("Sources read: %d (%d failed)", feeds.Stats().SourcesRun, feeds.Stats().SourcesFailed)
+		logger.Infof("Feed downloads queued: %d (%d already queued)", feeds.Stats().URLsQueued, feeds.Stats().URLsDeduplicated)
+	}
 	logger.Infof("Files found: %d", down.Stats().FilesFound)
-	logger.Infof("Files processed: %d", proc.Stats().FilesProcessed)
-	logger.Infof("Results saved to: %s", cfg.OutputFile)
+	if spilled := down.Stats().Spilled; spilled > 0 {
+		logger.Infof("Download requests spilled to disk: %d", spilled)
+	}
+	logger.Infof("Files processed: %d (%d new, %d already indexed)",
+		proc.Stats().FilesProcessed, proc.Stats().FilesNew, proc.Stats().FilesExisting)
+	logger.Infof("Results saved to: %s", strings.Join(storageURIs(cfg), ", "))
 }
 
 func parseConfig(cmd *cobra.Command) (config.Config, error) {
 	// If config file is specified, load it first
-	if cfgFile != "" {
-		// TODO: Implement config file loading
-	}
-
-	// Command line flags override config file
-	url, _ := cmd.Flags().GetString("url")
-	output, _ := cmd.Flags().GetString("output")
-	depth, _ := cmd.Flags().GetInt("depth")
-	extensions, _ := cmd.Flags().GetStringSlice("extensions")
-	includes, _ := cmd.Flags().GetStringSlice("include")
-	excludes, _ := cmd.Flags().GetStringSlice("exclude")
-	tempDir, _ := cmd.Flags().GetString("temp-dir")
-
-	crawlerWorkers, _ := cmd.Flags().GetInt("crawler-workers")
-	downloadWorkers, _ := cmd.Flags().GetInt("download-workers")
-	processorWorkers, _ := cmd.Flags().GetInt("processor-workers")
-	delay, _ := cmd.Flags().GetInt("delay")
-	timeout, _ := cmd.Flags().GetInt("timeout")
-
-	return config.Config{
-		StartURL:         url,
-		OutputFile:       output,
-		MaxDepth:         depth,
-		FileExtensions:   extensions,
-		IncludePatterns:  includes,
-		ExcludePatterns:  excludes,
-		TempDir:          tempDir,
-		CrawlerWorkers:   crawlerWorkers,
-		DownloadWorkers:  downloadWorkers,
-		ProcessorWorkers: processorWorkers,
-		Delay:            delay,
-		RequestTimeout:   timeout,
-	}, nil
+	cfg, err := loadConfigFile()
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	// Command line flags override config file. Unset flags only fill in
+	// values the config file left empty.
+	flags := cmd.Flags()
+	setString := func(name string, dst *string) {
+		if flags.Changed(name) || *dst == "" {
+			*dst, _ = flags.GetString(name)
+		}
+	}
+	setInt := func(name string, dst *int) {
+		if flags.Changed(name) || *dst == 0 {
+			*dst, _ = flags.GetInt(name)
+		}
+	}
+	setBool := func(name string, dst *bool) {
+		if flags.Changed(name) {
+			*dst, _ = flags.GetBool(name)
+		}
+	}
+	setSlice := func(name string, dst *[]string) {
+		if flags.Changed(name) || len(*dst) == 0 {
+			*dst, _ = flags.GetStringSlice(name)
+		}
+	}
+
+	setString("url", &cfg.StartURL)
+	setString("output", &cfg.OutputFile)
+	setSlice("storage", &cfg.Storage)
+	setInt("depth", &cfg.MaxDepth)
+	setSlice("extensions", &cfg.FileExtensions)
+	setSlice("include", &cfg.IncludePatterns)
+	setSlice("exclude", &cfg.ExcludePatterns)
+	setString("temp-dir", &cfg.TempDir)
+	setString("queue-spill-dir", &cfg.QueueSpillDir)
+	setSlice("source", &cfg.Sources)
+	setSlice("github-hosts", &cfg.GitHubHosts)
+	setString("fixture-dir", &cfg.FixtureDir)
+	setSlice("allowed-domains", &cfg.AllowedDomains)
+	setSlice("download-domains", &cfg.DownloadDomains)
+	setString("subdomains", &cfg.SubdomainMode)
+	setSlice("path-prefix", &cfg.PathPrefixes)
+
+	setInt("crawler-workers", &cfg.CrawlerWorkers)
+	setInt("download-workers", &cfg.DownloadWorkers)
+	setInt("processor-workers", &cfg.ProcessorWorkers)
+	setInt("delay", &cfg.Delay)
+	setString("user-agent", &cfg.UserAgent)
+	setInt("timeout", &cfg.RequestTimeout)
+	setInt("analysis-timeout", &cfg.AnalysisTimeout)
+	setString("plugin-dir", &cfg.PluginDir)
+	setSlice("rules", &cfg.Rules)
+	setSlice("vulnerability-db", &cfg.VulnerabilityDB)
+	setBool("sitemaps", &cfg.UseSitemaps)
+	setBool("obey-robots", &cfg.ObeyRobots)
+
+	if cfg.StartURL == "" && len(cfg.Sources) == 0 {
+		return config.Config{}, fmt.Errorf("at least one of --url or --source is required")
+	}
+
+	mode, err := crawler.ParseSubdomainMode(cfg.SubdomainMode)
+	if err != nil {
+		return config.Config{}, err
+	}
+	cfg.SubdomainMode = string(mode)
+
+	return cfg, nil
+}
+
+// loadConfigFile loads the --config file, or ./config.yaml if present
+func loadConfigFile() (config.Config, error) {
+	path := cfgFile
+	if path == "" {
+		if _, err := os.Stat("config.yaml"); err != nil {
+			return config.Config{}, nil
+		}
+		path = "config.yaml"
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		return config.Config{}, err
+	}
+	logger.Infof("Loaded configuration from %s", path)
+	return cfg, nil
+}
+
+// storageURIs returns the configured storage backends, falling back to the
+// JSON output file
+func storageURIs(cfg config.Config) []string {
+	if len(cfg.Storage) > 0 {
+		return cfg.Storage
+	}
+	return []string{cfg.OutputFile}
+}
+
+// runRecord summarizes the run from the component stats
+func runRecord(cfg config.Config, startTime time.Time, interrupted bool,
+	crawl *crawler.Crawler, down *downloader.Downloader, proc *processor.Processor) types.RunRecord {
+	run := types.RunRecord{
+		StartTime:   startTime,
+		EndTime:     time.Now(),
+		StartURL:    cfg.StartURL,
+		Sources:     cfg.Sources,
+		ConfigHash:  configHash(cfg),
+		Interrupted: interrupted,
+
+		FilesFound:      down.Stats().FilesFound,
+		FilesDownloaded: down.Stats().FilesDownloaded,
+		BytesDownloaded: down.Stats().BytesDownloaded,
+		FilesProcessed:  proc.Stats().FilesProcessed,
+		FilesNew:        proc.Stats().FilesNew,
+		FilesExisting:   proc.Stats().FilesExisting,
+		Errors:          down.Stats().Errors + proc.Stats().Errors,
+	}
+	if crawl != nil {
+		run.URLsVisited = crawl.Stats().URLsVisited
+		run.URLsSkipped = crawl.Stats().URLsSkipped
+	}
+	return run
+}
+
+// configHash identifies the effective configuration of a run, so runs with
+// different settings can be told apart in the history
+func configHash(cfg config.Config) string {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// analyzerPlugins returns the plugins in the plugin directory followed by
+// those configured individually
+func analyzerPlugins(cfg config.Config) ([]fileanalyzer.Plugin, error) {
+	var plugins []fileanalyzer.Plugin
+	if cfg.PluginDir != "" {
+		discovered, err := fileanalyzer.DiscoverPlugins(cfg.PluginDir)
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, discovered...)
+	}
+	for _, p := range cfg.Plugins {
+		if p.Path == "" {
+			return nil, fmt.Errorf("plugin %q has no path", p.Name)
+		}
+		plugins = append(plugins, fileanalyzer.Plugin{
+			Name:        p.Name,
+			Path:        p.Path,
+			Args:        p.Args,
+			Timeout:     time.Duration(p.Timeout) * time.Second,
+			MemoryLimit: p.MemoryLimit,
+		})
+	}
+	for _, p := range plugins {
+		logger.Infof("Loaded analyzer plugin %s", p.Path)
+	}
+	return plugins, nil
+}
+
+// policySet builds the per-domain politeness policies, with the global
+// worker, delay and User-Agent settings as the defaults
+func policySet(cfg config.Config) *policy.Set {
+	defaults := config.DomainPolicy{
+		CrawlParallelism: cfg.CrawlerWorkers,
+		Delay:            cfg.Delay,
+		UserAgent:        cfg.UserAgent,
+	}
+	for _, p := range cfg.Policies {
+		logger.Debugf("Domain policy %s: crawl=%d download=%d delay=%dms jitter=%dms bandwidth=%dB/s",
+			p.Domain, p.CrawlParallelism, p.DownloadParallelism, p.Delay, p.RandomDelay, p.BandwidthLimit)
+	}
+	return policy.NewSet(defaults, cfg.Policies)
+}
+
+// crawlerOptions builds the optional crawler settings from the configuration
+func crawlerOptions(cfg config.Config) []crawler.Option {
+	opts := []crawler.Option{
+		crawler.WithSitemaps(cfg.UseSitemaps),
+		crawler.WithRobots(cfg.ObeyRobots),
+		crawler.WithExtractionRules(cfg.ExtractionRules),
+		crawler.WithScope(crawler.Scope{
+			CrawlDomains:    cfg.AllowedDomains,
+			DownloadDomains: cfg.DownloadDomains,
+			Subdomains:      crawler.SubdomainMode(cfg.SubdomainMode),
+			PathPrefixes:    cfg.PathPrefixes,
+		}),
+	}
+	if cfg.FixtureDir != "" {
+		opts = append(opts, crawler.WithFetcher(&crawler.FixtureFetcher{Dir: cfg.FixtureDir}))
+	}
+	return opts
 }
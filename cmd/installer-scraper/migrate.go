@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/storage"
+)
+
+// newMigrateCmd creates the command that upgrades JSON output files to the
+// current schema version
+func newMigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate [file or directory]...",
+		Short: "Upgrade JSON index files to the current schema version",
+		Long: fmt.Sprintf(`Rewrites JSON output files in place in the current format (schema
+version %d). Directories are searched for *.json files; with no arguments
+the index directory is migrated. Files already at the current version are
+left untouched unless --force is given.`, storage.CurrentSchemaVersion),
+		RunE: runMigrate,
+	}
+
+	cmd.Flags().Bool("force", false, "rewrite files that are already at the current version")
+
+	return cmd
+}
+
+func runMigrate(cmd *cobra.Command, args []string) error {
+	force, _ := cmd.Flags().GetBool("force")
+	if len(args) == 0 {
+		args = []string{"index"}
+	}
+
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*.json"))
+		if err != nil {
+			return err
+		}
+		paths = append(paths, matches...)
+	}
+
+	failed := 0
+	for _, path := range paths {
+		version, err := storage.MigrateFile(path, force)
+		switch {
+		case err != nil:
+			logger.Errorf("Failed to migrate %s: %v", path, err)
+			failed++
+		case version == storage.CurrentSchemaVersion:
+			logger.Infof("%s is already at schema version %d", path, version)
+		default:
+			logger.Infof("Migrated %s from schema version %d to %d", path, version, storage.CurrentSchemaVersion)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to migrate %d of %d files", failed, len(paths))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package arch
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Normalized architecture names
+const (
+	X86       = "x86"
+	X64       = "x64"
+	ARM64     = "arm64"
+	ARM       = "arm"
+	Universal = "universal" // Runs natively on more than one architecture
+	NoArch    = "noarch"    // Architecture independent
+)
+
+// aliases maps the names packaging formats and vendors use to the
+// normalized names
+var aliases = map[string]string{
+	"x86": X86, "i386": X86, "i486": X86, "i586": X86, "i686": X86, "ia32": X86, "386": X86, "intel": X86,
+	"x64": X64, "x86_64": X64, "x86-64": X64, "amd64": X64, "em64t": X64,
+	"arm64": ARM64, "aarch64": ARM64, "arm64ec": ARM64, "arm64x": ARM64,
+	"arm": ARM, "armhf": ARM, "armel": ARM, "armv6l": ARM, "armv7": ARM, "armv7l": ARM, "armv7hl": ARM, "armhfp": ARM,
+	"universal": Universal, "universal2": Universal,
+	"noarch": NoArch, "all": NoArch, "any": NoArch, "neutral": NoArch,
+}
+
+// Normalize maps an architecture name such as "x86_64", "aarch64" or
+// "armhf" to one of the normalized names, or "" if it is not recognized
+func Normalize(name string) string {
+	return aliases[strings.ToLower(strings.TrimSpace(name))]
+}
+
+// Combine reduces the architectures a multi-architecture file supports to
+// one name: the architecture itself when there is only one, otherwise
+// Universal. Unrecognized and empty names are ignored.
+func Combine(names ...string) string {
+	result := ""
+	for _, name := range names {
+		normalized := Normalize(name)
+		switch {
+		case normalized == "" || normalized == result:
+		case result == "":
+			result = normalized
+		default:
+			return Universal
+		}
+	}
+	return result
+}
+
+var (
+	// filenameHint matches architecture words in a filename
+	filenameHint = regexp.MustCompile(`(?i)(?:^|[^a-z0-9])(x86[_-]64|amd64|x64|aarch64|arm64|armhf|armv7l?|i[3-6]86|x86|universal2?|noarch|apple[_-]?silicon|win64|win32)(?:$|[^a-z0-9])`)
+	// weakHints name a Windows build rather than a CPU, so "win32-x64"
+	// means x64
+	weakHints = map[string]string{"win64": X64, "win32": X86}
+)
+
+// FromFilename returns the architecture a download's filename names, such
+// as "arm64" for app_arm64.dmg or "x64" for tool-x86_64.rpm, or "" if it
+// names none
+func FromFilename(filename string) string {
+	weak := ""
+	rest := filename
+	for {
+		loc := filenameHint.FindStringSubmatchIndex(rest)
+		if loc == nil {
+			return weak
+		}
+		hint := strings.ToLower(rest[loc[2]:loc[3]])
+		// Continue from the end of the word so adjacent hints share a separator
+		rest = rest[loc[3]:]
+
+		if normalized, ok := weakHints[hint]; ok {
+			if weak == "" {
+				weak = normalized
+			}
+			continue
+		}
+		if strings.HasPrefix(hint, "apple") {
+			return ARM64
+		}
+		return Normalize(strings.Replace(hint, "-", "_", 1))
+	}
+}
@@ -0,0 +1,86 @@
+package arch
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"x86_64":     X64,
+		"AMD64":      X64,
+		" x86-64 ":   X64,
+		"aarch64":    ARM64,
+		"arm64ec":    ARM64,
+		"i686":       X86,
+		"i386":       X86,
+		"armhf":      ARM,
+		"armv7hl":    ARM,
+		"universal2": Universal,
+		"noarch":     NoArch,
+		"all":        NoArch,
+		"":           "",
+		"sparc64":    "",
+		"x64dbg":     "",
+	}
+	for name, want := range tests {
+		if got := Normalize(name); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCombine(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"x86_64"}, X64},
+		{[]string{"x86_64", "amd64"}, X64},
+		{[]string{"x86_64", "arm64"}, Universal},
+		{[]string{"", "unknown", "aarch64"}, ARM64},
+		{[]string{"ppc", "sparc"}, ""},
+	}
+	for _, tt := range tests {
+		if got := Combine(tt.names...); got != tt.want {
+			t.Errorf("Combine(%q) = %q, want %q", tt.names, got, tt.want)
+		}
+	}
+}
+
+func TestFromFilename(t *testing.T) {
+	tests := map[string]string{
+		"app_arm64.dmg":                        ARM64,
+		"tool-aarch64.AppImage":                ARM64,
+		"tool-1.2.3-1.el9.x86_64.rpm":          X64,
+		"tool_1.2.3_amd64.deb":                 X64,
+		"Setup-X64.exe":                        X64,
+		"tool-1.0.i686.rpm":                    X86,
+		"tool-x86.msi":                         X86,
+		"tool_armhf.deb":                       ARM,
+		"Tool-universal.dmg":                   Universal,
+		"tool-1.0-noarch.rpm":                  NoArch,
+		"Tool-AppleSilicon.zip":                ARM64,
+		"tool-apple_silicon.pkg":               ARM64,
+		"tool-win64.zip":                       X64,
+		"tool-win32.zip":                       X86,
+		"tool-win32-x64.zip":                   X64, // The CPU beats the Windows build name
+		"tool-win32-arm64-setup.exe":           ARM64,
+		"tool-1.0.tar.gz":                      "",
+		"x64dbg_snapshot.zip":                  "", // Part of the product name
+		"Fox64Installer.exe":                   "",
+		"bitx86tool.exe":                       "",
+		"universalis-setup.exe":                "",
+		"SmallTalk-amd64ish.exe":               "",
+		"https://example.com/x86/tool.msi":     X86,
+		"linux-arm64_1.2.tar.gz":               ARM64,
+		"node-v20.11.0-darwin-x64.tar.gz":      X64,
+		"python-3.12.1-amd64.exe":              X64,
+		"VSCodeUserSetup-arm64-1.85.1.exe":     ARM64,
+		"Firefox Setup 121.0 (x86_64).dmg":     X64,
+		"googlechromestandaloneenterprise.msi": "",
+	}
+	for filename, want := range tests {
+		if got := FromFilename(filename); got != want {
+			t.Errorf("FromFilename(%q) = %q, want %q", filename, got, want)
+		}
+	}
+}
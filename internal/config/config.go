@@ -1,22 +1,120 @@
 package config
 
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
 // Config holds the application configuration
 type Config struct {
 	// Main settings
-	StartURL        string
-	OutputFile      string
-	MaxDepth        int
-	FileExtensions  []string
-	IncludePatterns []string
-	ExcludePatterns []string
-	TempDir         string
+	StartURL        string   `yaml:"url"`
+	OutputFile      string   `yaml:"output"`
+	Storage         []string `yaml:"storage"` // Storage URIs, e.g. sqlite://index.db; defaults to the JSON output file
+	MaxDepth        int      `yaml:"depth"`
+	FileExtensions  []string `yaml:"extensions"`
+	IncludePatterns []string `yaml:"include"`
+	ExcludePatterns []string `yaml:"exclude"`
+	TempDir         string   `yaml:"temp_dir"`
+	QueueSpillDir   string   `yaml:"queue_spill_dir"` // Overflow the download queue to disk instead of pausing discovery
+
+	// Structured release feeds to read in addition to (or instead of) crawling,
+	// as "kind:target" specifications
+	Sources []string `yaml:"sources"`
+	// GitHub Enterprise Server API hosts that may be sent GITHUB_TOKEN,
+	// besides api.github.com
+	GitHubHosts []string `yaml:"github_hosts"`
+
+	// Discovery settings
+	UseSitemaps bool `yaml:"sitemaps"`    // Seed the crawl from sitemap.xml and sitemap indexes
+	ObeyRobots  bool `yaml:"obey_robots"` // Honor robots.txt rules and Crawl-delay
+
+	// Scope settings
+	AllowedDomains  []string `yaml:"allowed_domains"`  // Domain globs to crawl; defaults to the start URL's host
+	DownloadDomains []string `yaml:"download_domains"` // Extra domain globs to download from without crawling; empty allows any
+	SubdomainMode   string   `yaml:"subdomains"`       // exact, subdomains or any
+	PathPrefixes    []string `yaml:"path_prefixes"`    // URL path prefixes pages must start with
+
+	// Per-domain link extraction rules for script-driven download pages
+	ExtractionRules []ExtractionRule `yaml:"extraction_rules"`
+
+	// Directory of saved pages to crawl instead of the live site
+	FixtureDir string `yaml:"fixture_dir"`
 
 	// Concurrency settings
-	CrawlerWorkers   int
-	DownloadWorkers  int
-	ProcessorWorkers int
-	Delay            int // in milliseconds
+	CrawlerWorkers   int `yaml:"crawler_workers"`
+	DownloadWorkers  int `yaml:"download_workers"`
+	ProcessorWorkers int `yaml:"processor_workers"`
+	Delay            int `yaml:"delay"` // in milliseconds
+
+	// Politeness settings, applied to both crawling and downloading
+	UserAgent string         `yaml:"user_agent"`
+	Policies  []DomainPolicy `yaml:"policies"` // First matching policy wins
 
 	// Timeout settings
-	RequestTimeout int // in seconds
+	RequestTimeout  int `yaml:"timeout"`          // in seconds
+	AnalysisTimeout int `yaml:"analysis_timeout"` // in seconds, per downloaded file
+
+	// Detection confidence weights by factor name, overriding the defaults
+	ConfidenceWeights map[string]float64 `yaml:"confidence_weights"`
+
+	// Out-of-process analyzer plugins
+	PluginDir string         `yaml:"plugin_dir"` // Every executable in this directory is loaded as a plugin
+	Plugins   []PluginConfig `yaml:"plugins"`
+
+	// Detection rule files, or directories of them, applied after analysis
+	Rules []string `yaml:"rules"`
+
+	// Offline vulnerability database: OSV JSON files or zip exports, NVD
+	// CVE API 2.0 JSON files, or directories of them
+	VulnerabilityDB []string `yaml:"vulnerability_db"`
+}
+
+// PluginConfig configures an analyzer plugin executable
+type PluginConfig struct {
+	Name        string   `yaml:"name"` // Defaults to the executable's name
+	Path        string   `yaml:"path"`
+	Args        []string `yaml:"args"`
+	Timeout     int      `yaml:"timeout"`      // Seconds per call; defaults to 30
+	MemoryLimit int64    `yaml:"memory_limit"` // Bytes of address space, enforced on Linux
+}
+
+// ExtractionRule selects the link extractors used for matching domains
+type ExtractionRule struct {
+	Domain     string   `yaml:"domain"`     // Domain glob, e.g. "*.adobe.com"
+	Extractors []string `yaml:"extractors"` // Built-in extractor names; empty selects all
+	Patterns   []string `yaml:"patterns"`   // Extra regexes; the first capture group (or whole match) is the URL
+	FollowAll  bool     `yaml:"follow_all"` // Keep every extracted URL, not only likely downloads
+}
+
+// DomainPolicy holds politeness settings for hosts matching a domain glob.
+// Zero values fall back to the global settings.
+type DomainPolicy struct {
+	Domain              string            `yaml:"domain"`               // Domain glob, e.g. "*.example.com"
+	CrawlParallelism    int               `yaml:"crawl_parallelism"`    // Concurrent page requests
+	DownloadParallelism int               `yaml:"download_parallelism"` // Concurrent downloads
+	Delay               int               `yaml:"delay"`                // Delay between requests in milliseconds
+	RandomDelay         int               `yaml:"random_delay"`         // Extra random jitter in milliseconds
+	BandwidthLimit      int64             `yaml:"bandwidth_limit"`      // Download bytes per second, shared by all downloads from the host
+	UserAgent           string            `yaml:"user_agent"`
+	Headers             map[string]string `yaml:"headers"`
+	Cookies             map[string]string `yaml:"cookies"`
+}
+
+// Load reads a YAML configuration file
+func Load(path string) (Config, error) {
+	var cfg Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return cfg, nil
 }
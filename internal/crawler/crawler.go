@@ -1,23 +1,40 @@
 package crawler
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/deploymenttheory/go-app-index/internal/config"
+	"github.com/deploymenttheory/go-app-index/internal/detect"
 	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/policy"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+	"github.com/deploymenttheory/go-app-index/internal/urlutil"
 	"github.com/gocolly/colly/v2"
 )
 
 // Stats holds crawler statistics
 type Stats struct {
-	URLsVisited int
-	URLsSkipped int
-	URLsQueued  int
-	StartTime   time.Time
-	EndTime     time.Time
+	URLsVisited       int
+	URLsSkipped       int
+	URLsQueued        int
+	URLsRobotsBlocked int // URLs skipped because robots.txt disallows them
+	SitemapURLs       int // URLs seeded from sitemaps
+	URLsExtracted     int // URLs found by script-aware link extractors
+	URLsThrottled     int // Responses with 429 or 503 that triggered backoff
+	URLsOutOfScope    int // Links outside the crawl or download scope
+	URLsDetected      int // Installers recognised from response headers or content rather than the link
+	URLsDeduplicated  int // Installer links skipped because the same normalized URL was already queued
+	URLsDropped       int // Installer links not queued because the crawler was stopped
+	StartTime         time.Time
+	EndTime           time.Time
 }
 
 // Crawler handles the website crawling and URL discovery
@@ -28,22 +45,37 @@ type Crawler struct {
 	includePatterns []*regexp.Regexp
 	excludePatterns []*regexp.Regexp
 	delay           int
-	downloadQueue   chan<- string
+	downloadQueue   chan<- types.DownloadRequest
 	requestTimeout  int
+	useSitemaps     bool
+	obeyRobots      bool
+	fetcher         PageFetcher
+	extractionRules []config.ExtractionRule
+	policies        *policy.Set
+	scope           Scope
 
 	collector    *colly.Collector
-	visited      map[string]bool
+	httpClient   *http.Client
+	robots       *robotsPolicy
+	limiter      *policy.Limiter
+	extractors   []compiledRule
+	visited      map[string]bool // Normalized URLs of followed pages
+	queued       *urlutil.Set    // Installer URLs sent to the downloader, shared with other producers
 	visitedMutex sync.RWMutex
 	stats        Stats
 	statsMutex   sync.RWMutex
 
 	done      chan struct{}
+	ctx       context.Context // Cancelled by Stop, ending politeness waits and probes
+	cancel    context.CancelFunc
+	stopCh    chan struct{}
 	stopped   bool
 	stopMutex sync.RWMutex
 }
 
 // New creates a new Crawler
-func New(workers int, startURL string, maxDepth int, includePatterns, excludePatterns []string, delay int, requestTimeout int, downloadQueue chan<- string) *Crawler {
+func New(workers int, startURL string, maxDepth int, includePatterns, excludePatterns []string, delay int, requestTimeout int, downloadQueue chan<- types.DownloadRequest, opts ...Option) *Crawler {
+	ctx, cancel := context.WithCancel(context.Background())
 	c := &Crawler{
 		workers:        workers,
 		startURL:       startURL,
@@ -52,10 +84,28 @@ func New(workers int, startURL string, maxDepth int, includePatterns, excludePat
 		downloadQueue:  downloadQueue,
 		visited:        make(map[string]bool),
 		done:           make(chan struct{}),
+		ctx:            ctx,
+		cancel:         cancel,
+		stopCh:         make(chan struct{}),
 		stopped:        false,
 		requestTimeout: requestTimeout,
 	}
 
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.queued == nil {
+		c.queued = urlutil.NewSet()
+	}
+	if c.policies == nil {
+		c.policies = policy.NewSet(config.DomainPolicy{
+			CrawlParallelism: workers,
+			Delay:            delay,
+		}, nil)
+	}
+	c.limiter = policy.NewLimiter(c.policies, policy.ModeCrawl)
+
 	// Compile regex patterns
 	c.includePatterns = make([]*regexp.Regexp, 0, len(includePatterns))
 	for _, pattern := range includePatterns {
@@ -101,12 +151,59 @@ func (c *Crawler) Run() error {
 
 	c.collector.SetRequestTimeout(time.Duration(c.requestTimeout) * time.Second)
 
-	// Limit parallelism
-	err := c.collector.Limit(&colly.LimitRule{
-		DomainGlob:  "*",
-		Parallelism: c.workers,
-		Delay:       time.Duration(c.delay) * time.Millisecond,
+	start, err := url.Parse(c.startURL)
+	if err != nil {
+		return fmt.Errorf("invalid start URL: %w", err)
+	}
+	c.scope = c.scope.withDefaults(start)
+
+	// Only follow redirects that stay in scope, or that lead to an installer
+	c.collector.SetRedirectHandler(func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("too many redirects")
+		}
+		// Don't leak credentials to another host
+		if req.URL.Host != via[len(via)-1].URL.Host {
+			req.Header.Del("Authorization")
+		}
+		if c.isPotentialInstallerURL(req.URL.String()) {
+			if c.scope.allowsDownload(req.URL) {
+				return nil
+			}
+		} else if c.scope.allowsCrawl(req.URL) {
+			return nil
+		}
+		c.incrementOutOfScope()
+		return fmt.Errorf("redirect to out-of-scope URL %s", req.URL)
 	})
+	if ua := c.policies.Defaults().UserAgent; ua != "" {
+		c.collector.UserAgent = ua
+	}
+
+	// robots.txt is enforced by the crawler itself so blocked URLs are counted
+	c.collector.IgnoreRobotsTxt = true
+
+	// Pages, robots.txt, sitemaps and probes all go through the fetcher
+	if c.fetcher == nil {
+		c.fetcher = &HTTPFetcher{}
+	}
+	transport := fetcherTransport{fetcher: c.fetcher}
+	c.collector.WithTransport(transport)
+	c.httpClient = &http.Client{
+		Timeout:   time.Duration(c.requestTimeout) * time.Second,
+		Transport: transport,
+	}
+
+	c.extractors, err = compileExtractionRules(c.extractionRules)
+	if err != nil {
+		return fmt.Errorf("invalid extraction rules: %w", err)
+	}
+	if c.obeyRobots {
+		c.robots = newRobotsPolicy(c.httpClient, c.collector.UserAgent)
+	}
+
+	// Limit parallelism, one rule per domain policy followed by the default
+	err = c.collector.Limits(c.limitRules())
 	if err != nil {
 		return fmt.Errorf("failed to set limit rule: %w", err)
 	}
@@ -120,61 +217,56 @@ func (c *Crawler) Run() error {
 		}
 
 		logger.Debugf("Found link: %s", link)
+		c.handleLink(link, e.Request.Visit)
+	})
 
-		// Skip if already visited
-		c.visitedMutex.RLock()
-		visited := c.visited[link]
-		c.visitedMutex.RUnlock()
-		if visited {
-			logger.Debugf("Already visited: %s, skipping", link)
+	c.collector.OnRequest(func(r *colly.Request) {
+		if c.isStopped() {
+			r.Abort()
 			return
 		}
 
-		// Check URL against filter patterns
-		if c.shouldSkipURL(link) {
-			logger.Debugf("Filtered out by patterns: %s, skipping", link)
-			c.incrementSkipped()
-			return
-		}
+		// Respect the host's Crawl-delay and any backoff from throttling responses
+		_ = c.limiter.Wait(c.ctx, r.URL.Host, c.crawlDelay(r.URL))
 
-		// Check if it's a potential installer file
-		if c.isPotentialInstallerURL(link) {
-			logger.Infof("Potential installer: %s, sending to downloader", link)
-			c.sendToDownloader(link)
-			return
-		}
+		c.policies.ApplyHeaders(r.URL.Hostname(), *r.Headers)
 
-		// Visit the link
-		c.visitedMutex.Lock()
-		c.visited[link] = true
-		c.visitedMutex.Unlock()
-
-		c.incrementVisited()
+		logger.Infof("Visiting %s (Depth: %d)", r.URL.String(), r.Depth)
+	})
 
-		// Check if we should stop
-		if c.isStopped() {
+	// Recognise installers behind extensionless or redirecting links before
+	// their body is downloaded
+	c.collector.OnResponseHeaders(func(r *colly.Response) {
+		if r.StatusCode != http.StatusOK {
 			return
 		}
 
-		// Follow the link
-		logger.Debugf("Following link: %s", link)
-		err := e.Request.Visit(link)
-		if err != nil {
-			logger.Warningf("Failed to visit %s: %v", link, err)
+		info := detect.FromHeaders(r.Request.URL, *r.Headers, installerExtensions)
+		if info.NeedsProbe {
+			info = c.probe(info)
 		}
-	})
 
-	c.collector.OnRequest(func(r *colly.Request) {
-		if c.isStopped() {
-			r.Abort()
+		if info.IsInstaller {
+			logger.Infof("Installer detected by %s: %s (%s)", info.DetectedBy, info.URL, info.FileName)
+			if !c.isPotentialInstallerURL(info.URL) {
+				c.incrementDetected()
+			}
+			c.sendToDownloader(types.DownloadRequest{URL: info.URL, FileName: info.FileName, Detected: true})
+			r.Request.Abort()
 			return
 		}
-		logger.Infof("Visiting %s (Depth: %d)", r.URL.String(), r.Depth)
+
+		// Don't pull other binary content into memory
+		if !detect.IsPageContentType(info.ContentType) {
+			logger.Debugf("Skipping non-page content %s (%s)", info.URL, info.ContentType)
+			r.Request.Abort()
+		}
 	})
 
 	c.collector.OnResponse(func(r *colly.Response) {
 		logger.Debugf("Got response from %s: status=%d, length=%d",
 			r.Request.URL, r.StatusCode, len(r.Body))
+		c.limiter.Success(r.Request.URL.Host)
 
 		// Print first 200 chars of body in debug mode
 		if logger.LogLevel >= logger.LevelDebug {
@@ -185,15 +277,36 @@ func (c *Crawler) Run() error {
 			logger.Debugf("Response preview: %s", preview)
 		}
 
-		// Check if final URL (after redirects) is an installer
 		finalURL := r.Request.URL.String()
-		if c.isPotentialInstallerURL(finalURL) {
-			logger.Infof("Potential installer detected from response: %s", finalURL)
-			c.sendToDownloader(finalURL)
+
+		// Look for links that are built by scripts rather than <a href>
+		if len(c.extractors) > 0 && strings.Contains(strings.ToLower(r.Headers.Get("Content-Type")), "html") {
+			for _, link := range extractLinks(finalURL, r.Body, c.extractors) {
+				logger.Debugf("Extracted link: %s", link)
+				c.incrementExtracted()
+				c.handleLink(link, r.Request.Visit)
+			}
 		}
 	})
 
 	c.collector.OnError(func(r *colly.Response, err error) {
+		if errors.Is(err, colly.ErrAbortedAfterHeaders) {
+			return
+		}
+		if policy.IsThrottleStatus(r.StatusCode) {
+			c.incrementThrottled()
+			delay := c.limiter.Backoff(r.Request.URL.Host, policy.RetryAfter(r.Headers.Get("Retry-After")))
+
+			retries, _ := r.Ctx.GetAny("throttleRetries").(int)
+			if retries < maxThrottleRetries && !c.isStopped() {
+				r.Ctx.Put("throttleRetries", retries+1)
+				logger.Warningf("Throttled by %s (status %d), retrying %s in %v", r.Request.URL.Host, r.StatusCode, r.Request.URL, delay)
+				if err := r.Request.Retry(); err != nil {
+					logger.Warningf("Failed to retry %s: %v", r.Request.URL, err)
+				}
+				return
+			}
+		}
 		logger.Warningf("Error on %s: %v", r.Request.URL, err)
 	})
 
@@ -203,12 +316,37 @@ func (c *Crawler) Run() error {
 	logger.Infof("  Max depth: %d", c.maxDepth)
 	logger.Debugf("  Include patterns: %d patterns", len(c.includePatterns))
 	logger.Debugf("  Exclude patterns: %d patterns", len(c.excludePatterns))
+	logger.Infof("  Sitemaps: %v, robots.txt: %v", c.useSitemaps, c.obeyRobots)
+	logger.Infof("  Scope: domains %v (%s), download domains %v, path prefixes %v",
+		c.scope.CrawlDomains, c.scope.Subdomains, c.scope.DownloadDomains, c.scope.PathPrefixes)
 
 	// Start the crawler
 	logger.Infof("Starting the crawl at %s", c.startURL)
-	err = c.collector.Visit(c.startURL)
-	if err != nil {
-		return fmt.Errorf("failed to start crawler: %w", err)
+	if c.robots != nil && !c.robots.Allowed(start) {
+		logger.Warningf("Start URL %s is disallowed by robots.txt", c.startURL)
+		c.incrementRobotsBlocked()
+	} else {
+		c.visitedMutex.Lock()
+		c.visited[normalizeURL(c.startURL)] = true
+		c.visitedMutex.Unlock()
+
+		err = c.collector.Visit(c.startURL)
+		if err != nil {
+			return fmt.Errorf("failed to start crawler: %w", err)
+		}
+	}
+
+	// Seed the crawl from sitemaps
+	if c.useSitemaps {
+		seeds := c.discoverSitemapURLs(start)
+		logger.Infof("Seeding %d URLs from sitemaps", len(seeds))
+		for _, link := range seeds {
+			if c.isStopped() {
+				break
+			}
+			c.incrementSitemapURLs()
+			c.handleLink(link, c.collector.Visit)
+		}
 	}
 
 	// Wait for crawling to complete
@@ -234,7 +372,11 @@ func (c *Crawler) Done() <-chan struct{} {
 // Stop signals the crawler to stop
 func (c *Crawler) Stop() {
 	c.stopMutex.Lock()
-	c.stopped = true
+	if !c.stopped {
+		c.stopped = true
+		close(c.stopCh)
+		c.cancel()
+	}
 	c.stopMutex.Unlock()
 	c.collector.Wait()
 }
@@ -246,6 +388,114 @@ func (c *Crawler) Stats() Stats {
 	return c.stats
 }
 
+// handleLink filters a discovered link and either queues it for download or
+// follows it with the given visit function
+func (c *Crawler) handleLink(link string, visit func(string) error) {
+	// Skip if already visited
+	key := normalizeURL(link)
+	c.visitedMutex.RLock()
+	visited := c.visited[key]
+	c.visitedMutex.RUnlock()
+	if visited {
+		logger.Debugf("Already visited: %s, skipping", link)
+		return
+	}
+
+	// Check URL against filter patterns
+	if c.shouldSkipURL(link) {
+		logger.Debugf("Filtered out by patterns: %s, skipping", link)
+		c.incrementSkipped()
+		return
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		logger.Debugf("Invalid link %s: %v, skipping", link, err)
+		c.incrementSkipped()
+		return
+	}
+	isInstaller := c.isPotentialInstallerURL(link)
+
+	// Check the link is in scope
+	if (isInstaller && !c.scope.allowsDownload(u)) || (!isInstaller && !c.scope.allowsCrawl(u)) {
+		logger.Debugf("Out of scope: %s, skipping", link)
+		c.incrementOutOfScope()
+		return
+	}
+
+	// Check robots.txt
+	if c.robots != nil && !c.robots.Allowed(u) {
+		logger.Debugf("Disallowed by robots.txt: %s, skipping", link)
+		c.incrementRobotsBlocked()
+		return
+	}
+
+	// Check if it's a potential installer file
+	if isInstaller {
+		logger.Infof("Potential installer: %s, sending to downloader", link)
+		c.sendToDownloader(types.DownloadRequest{URL: link})
+		return
+	}
+
+	// Visit the link
+	c.visitedMutex.Lock()
+	if c.visited[key] {
+		c.visitedMutex.Unlock()
+		return
+	}
+	c.visited[key] = true
+	c.visitedMutex.Unlock()
+
+	c.incrementVisited()
+
+	// Check if we should stop
+	if c.isStopped() {
+		return
+	}
+
+	// Follow the link
+	logger.Debugf("Following link: %s", link)
+	if err := visit(link); err != nil {
+		logger.Warningf("Failed to visit %s: %v", link, err)
+	}
+}
+
+// maxThrottleRetries is how often a page answered with 429 or 503 is retried
+const maxThrottleRetries = 3
+
+// limitRules builds colly limit rules from the domain policies. colly uses
+// the first matching rule, so the catch-all default comes last.
+func (c *Crawler) limitRules() []*colly.LimitRule {
+	defaults := c.policies.Defaults()
+	if defaults.CrawlParallelism == 0 {
+		defaults.CrawlParallelism = c.workers
+	}
+
+	var rules []*colly.LimitRule
+	for _, p := range c.policies.Policies() {
+		if p.CrawlParallelism == 0 && p.Delay == 0 && p.RandomDelay == 0 {
+			continue
+		}
+		effective := c.policies.For(p.Domain)
+		if effective.CrawlParallelism == 0 {
+			effective.CrawlParallelism = defaults.CrawlParallelism
+		}
+		rules = append(rules, &colly.LimitRule{
+			DomainGlob:  p.Domain,
+			Parallelism: effective.CrawlParallelism,
+			Delay:       time.Duration(effective.Delay) * time.Millisecond,
+			RandomDelay: time.Duration(effective.RandomDelay) * time.Millisecond,
+		})
+	}
+
+	return append(rules, &colly.LimitRule{
+		DomainGlob:  "*",
+		Parallelism: defaults.CrawlParallelism,
+		Delay:       time.Duration(defaults.Delay) * time.Millisecond,
+		RandomDelay: time.Duration(defaults.RandomDelay) * time.Millisecond,
+	})
+}
+
 // Check if crawler has been stopped
 func (c *Crawler) isStopped() bool {
 	c.stopMutex.RLock()
@@ -282,14 +532,15 @@ func (c *Crawler) shouldSkipURL(url string) bool {
 	return false
 }
 
+// installerExtensions are the URL suffixes treated as installer downloads
+var installerExtensions = []string{".exe", ".msi", ".msix", ".msixbundle", ".appx", ".appxbundle", ".dmg", ".pkg", ".deb", ".rpm", ".AppImage"}
+
 // Check if URL potentially points to an installer file
 func (c *Crawler) isPotentialInstallerURL(url string) bool {
 	// Will be implemented in the downloadQueue component
 	// This is a basic implementation for now
 	// Checking common installer file extensions in URL
-	knownExtensions := []string{".exe", ".msi", ".dmg", ".pkg", ".deb", ".rpm", ".AppImage"}
-
-	for _, ext := range knownExtensions {
+	for _, ext := range installerExtensions {
 		if strings.HasSuffix(strings.ToLower(url), ext) {
 			logger.Debugf("URL %s has installer extension %s", url, ext)
 			return true
@@ -299,16 +550,80 @@ func (c *Crawler) isPotentialInstallerURL(url string) bool {
 	return false
 }
 
-// Send URL to the download queue
-func (c *Crawler) sendToDownloader(url string) {
-	// Send non-blocking, if queue is full, just log it
+// crawlDelay returns the robots.txt Crawl-delay that applies to a URL
+func (c *Crawler) crawlDelay(u *url.URL) time.Duration {
+	if c.robots == nil {
+		return 0
+	}
+	return c.robots.CrawlDelay(u)
+}
+
+// probe reads the first bytes of a binary response whose headers were not
+// conclusive and checks them against installer signatures. The probe is a
+// request of its own, so it waits its turn with the host like a page.
+func (c *Crawler) probe(info detect.Info) detect.Info {
+	u, err := url.Parse(info.URL)
+	if err != nil {
+		return info
+	}
+	if err := c.limiter.Wait(c.ctx, u.Host, c.crawlDelay(u)); err != nil {
+		return info
+	}
+
+	probed, err := detect.Probe(c.ctx, c.httpClient, info.URL, installerExtensions, func(req *http.Request) {
+		if c.collector.UserAgent != "" {
+			req.Header.Set("User-Agent", c.collector.UserAgent)
+		}
+		c.policies.ApplyHeaders(u.Hostname(), req.Header)
+	})
+	var statusErr *detect.StatusError
+	if errors.As(err, &statusErr) && policy.IsThrottleStatus(statusErr.StatusCode) {
+		c.incrementThrottled()
+		delay := c.limiter.Backoff(u.Host, policy.RetryAfter(statusErr.RetryAfter))
+		logger.Warningf("Throttled by %s (status %d) while probing %s, backing off %v", u.Host, statusErr.StatusCode, info.URL, delay)
+		return info
+	}
+	if err != nil {
+		logger.Debugf("Failed to probe %s: %v", info.URL, err)
+		return info
+	}
+	c.limiter.Success(u.Host)
+	return probed
+}
+
+// Send a download request to the download queue, once per normalized URL.
+// When the queue is full this blocks, slowing the crawl down to the pace
+// of the downloader rather than losing the URL.
+func (c *Crawler) sendToDownloader(req types.DownloadRequest) {
+	if !c.queued.Add(req.URL) {
+		logger.Debugf("Already queued: %s, skipping", req.URL)
+		c.incrementDeduplicated()
+		return
+	}
+
 	select {
-	case c.downloadQueue <- url:
-		c.incrementQueued()
-		logger.Debugf("Sent to download queue: %s", url)
+	case c.downloadQueue <- req:
 	default:
-		logger.Warningf("Download queue is full, skipping %s", url)
+		logger.Debugf("Download queue is full, waiting to queue %s", req.URL)
+		select {
+		case c.downloadQueue <- req:
+		case <-c.stopCh:
+			logger.Warningf("Crawler stopped, dropping %s", req.URL)
+			c.incrementDropped()
+			return
+		}
+	}
+
+	c.incrementQueued()
+	logger.Debugf("Sent to download queue: %s", req.URL)
+}
+
+// normalizeURL returns the key used to deduplicate a URL
+func normalizeURL(link string) string {
+	if normalized, err := urlutil.Normalize(link); err == nil {
+		return normalized
 	}
+	return link
 }
 
 // Increment URLs visited counter
@@ -325,6 +640,62 @@ func (c *Crawler) incrementSkipped() {
 	c.statsMutex.Unlock()
 }
 
+// Increment URLs blocked by robots.txt counter
+func (c *Crawler) incrementRobotsBlocked() {
+	c.statsMutex.Lock()
+	c.stats.URLsRobotsBlocked++
+	c.statsMutex.Unlock()
+}
+
+// Increment sitemap URLs counter
+func (c *Crawler) incrementSitemapURLs() {
+	c.statsMutex.Lock()
+	c.stats.SitemapURLs++
+	c.statsMutex.Unlock()
+}
+
+// Increment extracted URLs counter
+func (c *Crawler) incrementExtracted() {
+	c.statsMutex.Lock()
+	c.stats.URLsExtracted++
+	c.statsMutex.Unlock()
+}
+
+// Increment throttled responses counter
+func (c *Crawler) incrementThrottled() {
+	c.statsMutex.Lock()
+	c.stats.URLsThrottled++
+	c.statsMutex.Unlock()
+}
+
+// Increment out-of-scope URLs counter
+func (c *Crawler) incrementOutOfScope() {
+	c.statsMutex.Lock()
+	c.stats.URLsOutOfScope++
+	c.statsMutex.Unlock()
+}
+
+// Increment content-detected installers counter
+func (c *Crawler) incrementDetected() {
+	c.statsMutex.Lock()
+	c.stats.URLsDetected++
+	c.statsMutex.Unlock()
+}
+
+// Increment deduplicated URLs counter
+func (c *Crawler) incrementDeduplicated() {
+	c.statsMutex.Lock()
+	c.stats.URLsDeduplicated++
+	c.statsMutex.Unlock()
+}
+
+// Increment dropped URLs counter
+func (c *Crawler) incrementDropped() {
+	c.statsMutex.Lock()
+	c.stats.URLsDropped++
+	c.statsMutex.Unlock()
+}
+
 // Increment URLs queued counter
 func (c *Crawler) incrementQueued() {
 	c.statsMutex.Lock()
@@ -0,0 +1,60 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+func TestCrawlProbesBinaryLinks(t *testing.T) {
+	var probes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/get/tool">Tool</a> <a href="/get/busy">Busy</a>`))
+		case "/get/tool", "/get/busy":
+			if r.Header.Get("Range") != "" {
+				probes.Add(1)
+				if r.URL.Path == "/get/busy" {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+			}
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(append([]byte("MZ"), make([]byte, 510)...))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	downloads := make(chan types.DownloadRequest, 10)
+	c := New(1, server.URL+"/", 2, nil, nil, 0, 5, downloads)
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	close(downloads)
+
+	var queued []types.DownloadRequest
+	for req := range downloads {
+		queued = append(queued, req)
+	}
+	if len(queued) != 1 || queued[0].URL != server.URL+"/get/tool" || queued[0].FileName != "tool.exe" {
+		t.Errorf("queued = %+v, want only the probed tool.exe", queued)
+	}
+	if n := probes.Load(); n != 2 {
+		t.Errorf("probed %d times, want 2", n)
+	}
+	stats := c.Stats()
+	if stats.URLsDetected != 1 {
+		t.Errorf("URLsDetected = %d, want 1", stats.URLsDetected)
+	}
+	if stats.URLsThrottled != 1 {
+		t.Errorf("URLsThrottled = %d, want the throttled probe counted", stats.URLsThrottled)
+	}
+}
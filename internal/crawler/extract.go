@@ -0,0 +1,368 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/deploymenttheory/go-app-index/internal/config"
+	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/urlutil"
+)
+
+// LinkExtractor finds candidate URLs in a page that plain <a href>
+// scanning misses, such as links built by JavaScript
+type LinkExtractor interface {
+	// Name returns the name used to select the extractor in rules
+	Name() string
+
+	// Extract returns raw (possibly relative) URLs found in the page
+	Extract(doc *goquery.Document, body []byte) []string
+}
+
+// builtinExtractors lists the extractors available to extraction rules, in
+// the order they run
+var builtinExtractors = []LinkExtractor{
+	metaRefreshExtractor{},
+	dataAttributeExtractor{},
+	onclickExtractor{},
+	scriptJSONExtractor{},
+	initialStateExtractor{},
+}
+
+// ExtractorNames returns the names of the built-in extractors
+func ExtractorNames() []string {
+	names := make([]string, 0, len(builtinExtractors))
+	for _, e := range builtinExtractors {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+// compiledRule is an extraction rule with its extractors and patterns resolved
+type compiledRule struct {
+	domain     string
+	extractors []LinkExtractor
+	patterns   []*regexp.Regexp
+	followAll  bool
+}
+
+// compileExtractionRules resolves extractor names and compiles patterns
+func compileExtractionRules(rules []config.ExtractionRule) ([]compiledRule, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		cr := compiledRule{
+			domain:    rule.Domain,
+			followAll: rule.FollowAll,
+		}
+		if cr.domain == "" {
+			cr.domain = "*"
+		}
+
+		if len(rule.Extractors) == 0 {
+			cr.extractors = builtinExtractors
+		}
+		for _, name := range rule.Extractors {
+			extractor := findExtractor(name)
+			if extractor == nil {
+				return nil, fmt.Errorf("unknown link extractor %q (available: %s)", name, strings.Join(ExtractorNames(), ", "))
+			}
+			cr.extractors = append(cr.extractors, extractor)
+		}
+
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid extraction pattern %q: %w", pattern, err)
+			}
+			cr.patterns = append(cr.patterns, re)
+		}
+
+		compiled = append(compiled, cr)
+	}
+	return compiled, nil
+}
+
+func findExtractor(name string) LinkExtractor {
+	for _, e := range builtinExtractors {
+		if e.Name() == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// ExtractLinks applies the matching extraction rules to a page and returns
+// the absolute URLs found, sorted and deduplicated. It needs no network
+// access, so it can be run against saved pages.
+func ExtractLinks(pageURL string, body []byte, rules []config.ExtractionRule) ([]string, error) {
+	compiled, err := compileExtractionRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	return extractLinks(pageURL, body, compiled), nil
+}
+
+// extractLinks runs every rule matching the page's host
+func extractLinks(pageURL string, body []byte, rules []compiledRule) []string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+
+	var doc *goquery.Document
+	seen := make(map[string]bool)
+
+	for _, rule := range rules {
+		if !urlutil.MatchDomain(rule.domain, base.Host) {
+			continue
+		}
+
+		if doc == nil {
+			doc, err = goquery.NewDocumentFromReader(bytes.NewReader(body))
+			if err != nil {
+				logger.Debugf("Failed to parse %s for link extraction: %v", pageURL, err)
+				return nil
+			}
+		}
+
+		var raw []string
+		for _, extractor := range rule.extractors {
+			found := extractor.Extract(doc, body)
+			logger.Debugf("Extractor %s found %d links on %s", extractor.Name(), len(found), pageURL)
+			raw = append(raw, found...)
+		}
+		for _, re := range rule.patterns {
+			for _, m := range re.FindAllSubmatch(body, -1) {
+				if len(m) > 1 {
+					raw = append(raw, string(m[1]))
+				} else {
+					raw = append(raw, string(m[0]))
+				}
+			}
+		}
+
+		for _, link := range raw {
+			abs := resolveLink(base, link)
+			if abs == "" || seen[abs] {
+				continue
+			}
+			if !rule.followAll && !isLikelyDownloadLink(abs) {
+				continue
+			}
+			seen[abs] = true
+		}
+	}
+
+	links := make([]string, 0, len(seen))
+	for link := range seen {
+		links = append(links, link)
+	}
+	sort.Strings(links)
+	return links
+}
+
+// resolveLink turns a raw extracted string into an absolute http(s) URL
+func resolveLink(base *url.URL, raw string) string {
+	raw = strings.TrimSpace(raw)
+	raw = strings.ReplaceAll(raw, `\/`, "/")
+	if raw == "" || strings.HasPrefix(raw, "#") || strings.HasPrefix(strings.ToLower(raw), "javascript:") {
+		return ""
+	}
+
+	ref, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	abs := base.ResolveReference(ref)
+	if abs.Scheme != "http" && abs.Scheme != "https" {
+		return ""
+	}
+	abs.Fragment = ""
+	return abs.String()
+}
+
+var downloadHintPattern = regexp.MustCompile(`(?i)(download|installer|setup|release|/get/|/dl/)`)
+
+// isLikelyDownloadLink filters script-extracted URLs down to ones that look
+// like downloads, so that JSON blobs full of asset URLs do not flood the crawl
+func isLikelyDownloadLink(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	lowerPath := strings.ToLower(u.Path)
+	for _, ext := range installerExtensions {
+		if strings.HasSuffix(lowerPath, strings.ToLower(ext)) {
+			return true
+		}
+	}
+	return downloadHintPattern.MatchString(u.Path) || downloadHintPattern.MatchString(u.Host)
+}
+
+// quotedURLPattern matches quoted absolute or root-relative URLs in script text
+var quotedURLPattern = regexp.MustCompile(`["']((?:https?:)?//[^"'\s<>]+|/[^"'\s<>]*)["']`)
+
+// metaRefreshExtractor reads <meta http-equiv="refresh" content="0; url=...">
+type metaRefreshExtractor struct{}
+
+func (metaRefreshExtractor) Name() string { return "meta-refresh" }
+
+func (metaRefreshExtractor) Extract(doc *goquery.Document, _ []byte) []string {
+	var links []string
+	doc.Find("meta[http-equiv]").Each(func(_ int, s *goquery.Selection) {
+		if !strings.EqualFold(s.AttrOr("http-equiv", ""), "refresh") {
+			return
+		}
+		content := s.AttrOr("content", "")
+		idx := strings.Index(strings.ToLower(content), "url=")
+		if idx < 0 {
+			return
+		}
+		target := strings.Trim(strings.TrimSpace(content[idx+4:]), `'"`)
+		if target != "" {
+			links = append(links, target)
+		}
+	})
+	return links
+}
+
+// dataAttributeExtractor reads URL-valued data-* attributes such as
+// data-href, data-url or data-download-url
+type dataAttributeExtractor struct{}
+
+func (dataAttributeExtractor) Name() string { return "data-attributes" }
+
+func (dataAttributeExtractor) Extract(doc *goquery.Document, _ []byte) []string {
+	var links []string
+	doc.Find("*").Each(func(_ int, s *goquery.Selection) {
+		for _, node := range s.Nodes {
+			for _, attr := range node.Attr {
+				if !strings.HasPrefix(attr.Key, "data-") {
+					continue
+				}
+				value := strings.TrimSpace(attr.Val)
+				if looksLikeURL(value) {
+					links = append(links, value)
+				}
+			}
+		}
+	})
+	return links
+}
+
+// onclickExtractor reads URLs from inline onclick handlers, e.g.
+// onclick="window.location='/dl/setup.exe'"
+type onclickExtractor struct{}
+
+func (onclickExtractor) Name() string { return "onclick" }
+
+func (onclickExtractor) Extract(doc *goquery.Document, _ []byte) []string {
+	var links []string
+	doc.Find("[onclick]").Each(func(_ int, s *goquery.Selection) {
+		handler := s.AttrOr("onclick", "")
+		for _, m := range quotedURLPattern.FindAllStringSubmatch(handler, -1) {
+			links = append(links, m[1])
+		}
+		for _, m := range quotedFilePattern.FindAllStringSubmatch(handler, -1) {
+			links = append(links, m[1])
+		}
+	})
+	return links
+}
+
+// quotedFilePattern matches quoted relative paths ending in an installer extension
+var quotedFilePattern = regexp.MustCompile(`(?i)["']([^"'\s<>]+\.(?:exe|msi|dmg|pkg|deb|rpm|appimage|zip))["']`)
+
+// scriptJSONExtractor walks inline <script> JSON blobs (application/json,
+// application/ld+json, __NEXT_DATA__ and similar) for URL strings, and
+// scans other inline scripts for quoted URLs
+type scriptJSONExtractor struct{}
+
+func (scriptJSONExtractor) Name() string { return "script-json" }
+
+func (scriptJSONExtractor) Extract(doc *goquery.Document, _ []byte) []string {
+	var links []string
+	doc.Find("script").Each(func(_ int, s *goquery.Selection) {
+		if _, external := s.Attr("src"); external {
+			return
+		}
+		text := strings.TrimSpace(s.Text())
+		if text == "" {
+			return
+		}
+
+		if strings.Contains(strings.ToLower(s.AttrOr("type", "")), "json") {
+			var value interface{}
+			if err := json.Unmarshal([]byte(text), &value); err == nil {
+				links = append(links, collectJSONStrings(value)...)
+				return
+			}
+		}
+
+		for _, m := range quotedURLPattern.FindAllStringSubmatch(text, -1) {
+			links = append(links, m[1])
+		}
+	})
+	return links
+}
+
+// initialStateExtractor decodes state objects assigned to window globals,
+// e.g. window.__INITIAL_STATE__ = {...};
+type initialStateExtractor struct{}
+
+var initialStatePattern = regexp.MustCompile(`window\.(__[A-Z_]+__|__INITIAL_STATE__)\s*=\s*`)
+
+func (initialStateExtractor) Name() string { return "initial-state" }
+
+func (initialStateExtractor) Extract(_ *goquery.Document, body []byte) []string {
+	var links []string
+	for _, loc := range initialStatePattern.FindAllIndex(body, -1) {
+		decoder := json.NewDecoder(bytes.NewReader(body[loc[1]:]))
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			continue
+		}
+		links = append(links, collectJSONStrings(value)...)
+	}
+	return links
+}
+
+// collectJSONStrings returns every URL-like string in a decoded JSON value
+func collectJSONStrings(value interface{}) []string {
+	var links []string
+	switch v := value.(type) {
+	case string:
+		if looksLikeURL(v) {
+			links = append(links, v)
+		}
+	case []interface{}:
+		for _, item := range v {
+			links = append(links, collectJSONStrings(item)...)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			links = append(links, collectJSONStrings(v[k])...)
+		}
+	}
+	return links
+}
+
+// looksLikeURL reports whether a string is an absolute or root-relative URL
+func looksLikeURL(value string) bool {
+	lower := strings.ToLower(value)
+	return strings.HasPrefix(lower, "http://") ||
+		strings.HasPrefix(lower, "https://") ||
+		strings.HasPrefix(lower, "//") ||
+		(strings.HasPrefix(lower, "/") && len(lower) > 1 && !strings.ContainsAny(lower, " \t\n"))
+}
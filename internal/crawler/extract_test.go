@@ -0,0 +1,205 @@
+package crawler
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/deploymenttheory/go-app-index/internal/config"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// fixturePage fetches a saved page from testdata through FixtureFetcher
+func fixturePage(t *testing.T, pageURL string) []byte {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, pageURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&FixtureFetcher{Dir: "testdata"}).Fetch(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("no fixture for %s", pageURL)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestExtractLinksFixtures(t *testing.T) {
+	tests := []struct {
+		name  string
+		page  string
+		rules []config.ExtractionRule
+		want  []string
+	}{
+		{
+			name:  "next.js data and data attributes",
+			page:  "https://www.jetbrains.com/idea/download/",
+			rules: []config.ExtractionRule{{Domain: "*.jetbrains.com"}},
+			want: []string{
+				"https://download.jetbrains.com/idea/ideaIC-2024.1.4-aarch64.dmg",
+				"https://download.jetbrains.com/idea/ideaIC-2024.1.4.dmg",
+				"https://download.jetbrains.com/idea/ideaIC-2024.1.4.exe",
+				"https://download.jetbrains.com/idea/ideaIC-2024.1.4.tar.gz",
+				// The page path in the Next.js data looks like a download page
+				"https://www.jetbrains.com/idea/download",
+				"https://www.jetbrains.com/idea/download/download-thanks.html?platform=mac",
+			},
+		},
+		{
+			name:  "only the selected extractors run",
+			page:  "https://www.jetbrains.com/idea/download/",
+			rules: []config.ExtractionRule{{Domain: "www.jetbrains.com", Extractors: []string{"data-attributes"}}},
+			want: []string{
+				"https://download.jetbrains.com/idea/ideaIC-2024.1.4.exe",
+				"https://www.jetbrains.com/idea/download/download-thanks.html?platform=mac",
+			},
+		},
+		{
+			name:  "follow all keeps non-download links",
+			page:  "https://www.jetbrains.com/idea/download/",
+			rules: []config.ExtractionRule{{Extractors: []string{"data-attributes"}, FollowAll: true}},
+			want: []string{
+				"https://download.jetbrains.com/idea/ideaIC-2024.1.4.exe",
+				"https://resources.jetbrains.com/storage/products/idea/img/hero.png",
+				"https://www.jetbrains.com/idea/download/download-thanks.html?platform=mac",
+			},
+		},
+		{
+			name:  "meta refresh",
+			page:  "https://get.adobe.com/reader/",
+			rules: []config.ExtractionRule{{Domain: "get.adobe.com", Extractors: []string{"meta-refresh"}}},
+			want:  []string{"https://get.adobe.com/reader/download/"},
+		},
+		{
+			name:  "onclick handlers",
+			page:  "https://get.adobe.com/reader/download/",
+			rules: []config.ExtractionRule{{Domain: "get.adobe.com", Extractors: []string{"onclick"}}},
+			want: []string{
+				"https://ardownload2.adobe.com/pub/adobe/reader/win/AcrobatDC/2400220857/AcroRdrDC2400220857_en_US.exe",
+				"https://get.adobe.com/reader/download/AcroRdrDC2400220857_MUI.msi",
+			},
+		},
+		{
+			name:  "window initial state",
+			page:  "https://www.microsoft.com/en-us/edge/business/download",
+			rules: []config.ExtractionRule{{Domain: "*.microsoft.com", Extractors: []string{"initial-state"}}},
+			want: []string{
+				"https://msedge.sf.dl.delivery.mp.microsoft.com/filestreamingservice/files/4a1bd5b1/MicrosoftEdgeEnterpriseX64.msi",
+				"https://msedge.sf.dl.delivery.mp.microsoft.com/filestreamingservice/files/9c2e8b57/MicrosoftEdge-126.0.2592.87.pkg",
+			},
+		},
+		{
+			name:  "custom pattern",
+			page:  "https://downloads.example.com/tool",
+			rules: []config.ExtractionRule{{Domain: "downloads.example.com", Extractors: []string{"meta-refresh"}, Patterns: []string{`file: "([^"]+\.exe)"`}}},
+			want:  []string{"https://downloads.example.com/files/tool-setup-3.2.0.exe"},
+		},
+		{
+			name:  "rules for other domains do not apply",
+			page:  "https://www.microsoft.com/en-us/edge/business/download",
+			rules: []config.ExtractionRule{{Domain: "*.jetbrains.com"}},
+			want:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractLinks(tt.page, fixturePage(t, tt.page), tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractLinks() =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileExtractionRulesErrors(t *testing.T) {
+	for _, rule := range []config.ExtractionRule{
+		{Extractors: []string{"no-such-extractor"}},
+		{Patterns: []string{"("}},
+	} {
+		if _, err := compileExtractionRules([]config.ExtractionRule{rule}); err == nil {
+			t.Errorf("compileExtractionRules(%+v) succeeded, want error", rule)
+		}
+	}
+}
+
+func TestCrawlFixtures(t *testing.T) {
+	downloads := make(chan types.DownloadRequest, 100)
+	c := New(2, "https://get.adobe.com/reader/", 3, nil, nil, 0, 5, downloads,
+		WithFetcher(&FixtureFetcher{Dir: "testdata"}),
+		WithExtractionRules([]config.ExtractionRule{{Domain: "get.adobe.com"}}),
+		WithScope(Scope{Subdomains: SubdomainsExact}),
+	)
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	close(downloads)
+
+	var got []string
+	for req := range downloads {
+		got = append(got, req.URL)
+	}
+	sort.Strings(got)
+
+	// The download page is only reachable through the meta refresh, and
+	// its installers only through onclick handlers
+	want := []string{
+		"https://ardownload2.adobe.com/pub/adobe/reader/win/AcrobatDC/2400220857/AcroRdrDC2400220857_en_US.exe",
+		"https://get.adobe.com/reader/download/AcroRdrDC2400220857_MUI.msi",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("queued downloads = %v, want %v", got, want)
+	}
+	if stats := c.Stats(); stats.URLsExtracted == 0 {
+		t.Errorf("Stats().URLsExtracted = 0")
+	}
+}
+
+func TestFixtureFetcher(t *testing.T) {
+	fetcher := &FixtureFetcher{Dir: "testdata"}
+	tests := []struct {
+		method string
+		url    string
+		status int
+	}{
+		{http.MethodGet, "https://get.adobe.com/reader/", http.StatusOK},
+		{http.MethodGet, "https://GET.ADOBE.COM/reader", http.StatusOK},
+		{http.MethodGet, "https://www.microsoft.com/en-us/edge/business/download", http.StatusOK},
+		{http.MethodGet, "https://www.microsoft.com/en-us/edge/business/download.html", http.StatusOK},
+		{http.MethodHead, "https://downloads.example.com/tool", http.StatusOK},
+		{http.MethodGet, "https://get.adobe.com/reader/missing", http.StatusNotFound},
+		{http.MethodGet, "https://get.adobe.com/../../fetcher.go", http.StatusNotFound},
+		{http.MethodGet, "https://unknown.example.com/", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.url, nil)
+		resp, err := fetcher.Fetch(req)
+		if err != nil {
+			t.Errorf("%s %s: %v", tt.method, tt.url, err)
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s status = %d, want %d", tt.method, tt.url, resp.StatusCode, tt.status)
+		}
+		if tt.method == http.MethodHead && (len(body) != 0 || resp.ContentLength == 0) {
+			t.Errorf("HEAD %s returned %d body bytes, content length %d", tt.url, len(body), resp.ContentLength)
+		}
+		if tt.status == http.StatusOK && resp.Header.Get("Content-Type") != "text/html; charset=utf-8" {
+			t.Errorf("%s Content-Type = %q", tt.url, resp.Header.Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package crawler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// PageFetcher retrieves pages on behalf of the crawler. It is installed as
+// the collector's HTTP transport, so colly's depth limits, deduplication and
+// callbacks behave the same whichever backend serves the pages.
+type PageFetcher interface {
+	Fetch(req *http.Request) (*http.Response, error)
+}
+
+// fetcherTransport adapts a PageFetcher to an http.RoundTripper
+type fetcherTransport struct {
+	fetcher PageFetcher
+}
+
+func (t fetcherTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.fetcher.Fetch(req)
+}
+
+// HTTPFetcher fetches pages over the network. It is the crawler's default
+// fetcher.
+type HTTPFetcher struct {
+	Transport http.RoundTripper // Defaults to http.DefaultTransport
+}
+
+// Fetch performs the request over HTTP
+func (f *HTTPFetcher) Fetch(req *http.Request) (*http.Response, error) {
+	transport := f.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return transport.RoundTrip(req)
+}
+
+// FixtureFetcher serves saved pages from a directory laid out as
+// <dir>/<host>/<path>, so crawls and extraction rules can be exercised
+// offline. Directory paths are served from index.html, and a missing
+// file is retried with a .html suffix.
+type FixtureFetcher struct {
+	Dir string
+}
+
+// Fetch serves the saved page matching the request URL
+func (f *FixtureFetcher) Fetch(req *http.Request) (*http.Response, error) {
+	filePath, err := f.resolve(req)
+	if err != nil {
+		return fixtureResponse(req, http.StatusNotFound, "text/plain", []byte(err.Error())), nil
+	}
+
+	body, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(filePath))
+	if contentType == "" {
+		contentType = "text/html; charset=utf-8"
+	}
+
+	if req.Method == http.MethodHead {
+		resp := fixtureResponse(req, http.StatusOK, contentType, nil)
+		resp.ContentLength = int64(len(body))
+		return resp, nil
+	}
+	return fixtureResponse(req, http.StatusOK, contentType, body), nil
+}
+
+// resolve maps a request URL to a file in the fixture directory
+func (f *FixtureFetcher) resolve(req *http.Request) (string, error) {
+	urlPath := path.Clean("/" + req.URL.Path)
+	base := filepath.Join(f.Dir, strings.ToLower(req.URL.Hostname()), filepath.FromSlash(urlPath))
+
+	candidates := []string{base, filepath.Join(base, "index.html"), base + ".html"}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no fixture for %s", req.URL)
+}
+
+func fixtureResponse(req *http.Request, status int, contentType string, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{contentType}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,66 @@
+package crawler
+
+import (
+	"github.com/deploymenttheory/go-app-index/internal/config"
+	"github.com/deploymenttheory/go-app-index/internal/policy"
+	"github.com/deploymenttheory/go-app-index/internal/urlutil"
+)
+
+// Option configures optional Crawler behaviour
+type Option func(*Crawler)
+
+// WithSitemaps seeds the crawl with the URLs listed in the start host's
+// sitemaps (/sitemap.xml and any Sitemap entries in robots.txt)
+func WithSitemaps(enabled bool) Option {
+	return func(c *Crawler) {
+		c.useSitemaps = enabled
+	}
+}
+
+// WithRobots makes the crawler honor robots.txt rules and Crawl-delay
+// directives for every host it visits
+func WithRobots(enabled bool) Option {
+	return func(c *Crawler) {
+		c.obeyRobots = enabled
+	}
+}
+
+// WithFetcher replaces the default HTTPFetcher, e.g. with a FixtureFetcher
+// to crawl saved pages offline
+func WithFetcher(fetcher PageFetcher) Option {
+	return func(c *Crawler) {
+		c.fetcher = fetcher
+	}
+}
+
+// WithExtractionRules enables script-aware link extraction for matching domains
+func WithExtractionRules(rules []config.ExtractionRule) Option {
+	return func(c *Crawler) {
+		c.extractionRules = rules
+	}
+}
+
+// WithPolicies applies per-domain parallelism, delays and request headers.
+// The set's defaults replace the workers and delay passed to New.
+func WithPolicies(policies *policy.Set) Option {
+	return func(c *Crawler) {
+		c.policies = policies
+	}
+}
+
+// WithScope restricts which domains and paths are crawled and which hosts
+// installers are downloaded from
+func WithScope(scope Scope) Option {
+	return func(c *Crawler) {
+		c.scope = scope
+	}
+}
+
+// WithQueuedURLs shares the record of queued installer URLs with the other
+// producers feeding the same download queue, so an installer they also
+// find is only downloaded once
+func WithQueuedURLs(queued *urlutil.Set) Option {
+	return func(c *Crawler) {
+		c.queued = queued
+	}
+}
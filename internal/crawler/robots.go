@@ -0,0 +1,117 @@
+package crawler
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/temoto/robotstxt"
+)
+
+// robotsPolicy fetches and caches robots.txt for each host
+type robotsPolicy struct {
+	client    *http.Client
+	userAgent string
+
+	entries map[string]*robotsEntry
+	mutex   sync.Mutex
+}
+
+// robotsEntry holds the parsed robots.txt of a single host
+type robotsEntry struct {
+	once sync.Once
+	data *robotstxt.RobotsData
+}
+
+// newRobotsPolicy creates a robots.txt policy for the given user agent
+func newRobotsPolicy(client *http.Client, userAgent string) *robotsPolicy {
+	return &robotsPolicy{
+		client:    client,
+		userAgent: userAgent,
+		entries:   make(map[string]*robotsEntry),
+	}
+}
+
+// Allowed reports whether robots.txt permits fetching the URL
+func (p *robotsPolicy) Allowed(u *url.URL) bool {
+	data := p.get(u)
+	if data == nil {
+		return true
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return data.TestAgent(path, p.userAgent)
+}
+
+// CrawlDelay returns the Crawl-delay that applies to the URL's host
+func (p *robotsPolicy) CrawlDelay(u *url.URL) time.Duration {
+	data := p.get(u)
+	if data == nil {
+		return 0
+	}
+	if group := data.FindGroup(p.userAgent); group != nil {
+		return group.CrawlDelay
+	}
+	return 0
+}
+
+// Sitemaps returns the sitemap URLs advertised in the host's robots.txt
+func (p *robotsPolicy) Sitemaps(u *url.URL) []string {
+	data := p.get(u)
+	if data == nil {
+		return nil
+	}
+	return data.Sitemaps
+}
+
+// get returns the robots.txt data for the URL's host, fetching it once
+func (p *robotsPolicy) get(u *url.URL) *robotstxt.RobotsData {
+	key := u.Scheme + "://" + u.Host
+
+	p.mutex.Lock()
+	entry, ok := p.entries[key]
+	if !ok {
+		entry = &robotsEntry{}
+		p.entries[key] = entry
+	}
+	p.mutex.Unlock()
+
+	entry.once.Do(func() {
+		entry.data = p.fetch(key + "/robots.txt")
+	})
+	return entry.data
+}
+
+// fetch downloads and parses a robots.txt file. Failures are treated as
+// "no restrictions" so an unreachable robots.txt does not stop a crawl.
+func (p *robotsPolicy) fetch(robotsURL string) *robotstxt.RobotsData {
+	req, err := http.NewRequest(http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("User-Agent", p.userAgent)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		logger.Debugf("Failed to fetch %s: %v", robotsURL, err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	data, err := robotstxt.FromResponse(resp)
+	if err != nil {
+		logger.Debugf("Failed to parse %s: %v", robotsURL, err)
+		return nil
+	}
+
+	logger.Debugf("Loaded %s (status %d)", robotsURL, resp.StatusCode)
+	return data
+}
@@ -0,0 +1,114 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testRobots = `User-agent: *
+Disallow: /private/
+Allow: /private/public.html
+Crawl-delay: 2
+
+User-agent: installer-scraper
+Disallow: /downloads/beta/
+Disallow: /*?session=
+Crawl-delay: 5
+
+Sitemap: https://example.com/sitemap-pages.xml
+Sitemap: https://example.com/sitemap-downloads.xml.gz
+`
+
+func TestRobotsPolicy(t *testing.T) {
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		fetches.Add(1)
+		w.Write([]byte(testRobots))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		userAgent string
+		path      string
+		allowed   bool
+	}{
+		{"allowed page", "Mozilla/5.0", "/downloads/", true},
+		{"disallowed prefix", "Mozilla/5.0", "/private/page.html", false},
+		{"longer allow wins", "Mozilla/5.0", "/private/public.html", true},
+		{"specific agent ignores wildcard group", "installer-scraper", "/private/page.html", true},
+		{"specific agent disallow", "installer-scraper", "/downloads/beta/setup.exe", false},
+		{"wildcard with query", "installer-scraper", "/downloads/?session=abc", false},
+		{"root", "installer-scraper", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newRobotsPolicy(server.Client(), tt.userAgent)
+			u, _ := url.Parse(server.URL + tt.path)
+			if got := p.Allowed(u); got != tt.allowed {
+				t.Errorf("Allowed(%s) = %v, want %v", tt.path, got, tt.allowed)
+			}
+		})
+	}
+
+	fetches.Store(0)
+	p := newRobotsPolicy(server.Client(), "installer-scraper")
+	u, _ := url.Parse(server.URL + "/")
+	if delay := p.CrawlDelay(u); delay != 5*time.Second {
+		t.Errorf("CrawlDelay() = %v, want 5s", delay)
+	}
+	if sitemaps := p.Sitemaps(u); len(sitemaps) != 2 || sitemaps[1] != "https://example.com/sitemap-downloads.xml.gz" {
+		t.Errorf("Sitemaps() = %v", sitemaps)
+	}
+	p.Allowed(u)
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("robots.txt fetched %d times, want once per host", n)
+	}
+
+	other := newRobotsPolicy(server.Client(), "OtherBot")
+	if delay := other.CrawlDelay(u); delay != 2*time.Second {
+		t.Errorf("wildcard CrawlDelay() = %v, want 2s", delay)
+	}
+}
+
+func TestRobotsPolicyUnavailable(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		allowed bool
+	}{
+		// A missing robots.txt allows everything
+		{"not found", http.StatusNotFound, true},
+		// A server error is treated as a full disallow by robots.txt rules
+		{"server error", http.StatusInternalServerError, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			p := newRobotsPolicy(server.Client(), "installer-scraper")
+			u, _ := url.Parse(server.URL + "/page")
+			if got := p.Allowed(u); got != tt.allowed {
+				t.Errorf("Allowed() = %v, want %v", got, tt.allowed)
+			}
+		})
+	}
+
+	// An unreachable host does not stop the crawl
+	p := newRobotsPolicy(&http.Client{Timeout: time.Second}, "installer-scraper")
+	u, _ := url.Parse("http://127.0.0.1:1/page")
+	if !p.Allowed(u) || p.CrawlDelay(u) != 0 || p.Sitemaps(u) != nil {
+		t.Error("unreachable robots.txt should impose no restrictions")
+	}
+}
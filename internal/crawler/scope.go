@@ -0,0 +1,130 @@
+package crawler
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/deploymenttheory/go-app-index/internal/urlutil"
+	"golang.org/x/net/publicsuffix"
+)
+
+// SubdomainMode controls how crawl domains match hosts
+type SubdomainMode string
+
+const (
+	// SubdomainsExact only crawls hosts that match a crawl domain exactly
+	SubdomainsExact SubdomainMode = "exact"
+	// SubdomainsInclude also crawls any subdomain of a crawl domain
+	SubdomainsInclude SubdomainMode = "subdomains"
+	// SubdomainsAny crawls every host, ignoring the crawl domains
+	SubdomainsAny SubdomainMode = "any"
+)
+
+// ParseSubdomainMode validates a subdomain mode name, defaulting to
+// SubdomainsInclude when empty
+func ParseSubdomainMode(name string) (SubdomainMode, error) {
+	switch mode := SubdomainMode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case "":
+		return SubdomainsInclude, nil
+	case SubdomainsExact, SubdomainsInclude, SubdomainsAny:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown subdomain mode %q (expected exact, subdomains or any)", name)
+	}
+}
+
+// Scope decides which discovered URLs the crawler may follow and which
+// installer links it may hand to the downloader
+type Scope struct {
+	// CrawlDomains are domain globs whose pages are crawled. Empty means
+	// the start URL's host, or in subdomains mode its registrable domain,
+	// so starting at www.example.com also covers downloads.example.com.
+	CrawlDomains []string
+	// DownloadDomains are extra domain globs installers may be downloaded
+	// from without being crawled, e.g. "*.cloudfront.net". Empty allows
+	// downloads from any host.
+	DownloadDomains []string
+	// Subdomains controls how CrawlDomains match hosts
+	Subdomains SubdomainMode
+	// PathPrefixes restrict crawled pages to these URL path prefixes
+	PathPrefixes []string
+}
+
+// withDefaults fills in the crawl domain and subdomain mode from the start URL
+func (s Scope) withDefaults(start *url.URL) Scope {
+	if s.Subdomains == "" {
+		s.Subdomains = SubdomainsInclude
+	}
+	if len(s.CrawlDomains) == 0 && start != nil && start.Hostname() != "" {
+		host := strings.ToLower(start.Hostname())
+		if s.Subdomains == SubdomainsInclude {
+			host = registrableDomain(host)
+		}
+		s.CrawlDomains = []string{host}
+	}
+	return s
+}
+
+// registrableDomain returns the domain under a public suffix that host
+// belongs to, e.g. example.co.uk for www.example.co.uk. IP addresses and
+// hosts without a registrable domain are returned unchanged.
+func registrableDomain(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// allowsCrawl reports whether the page at u may be visited
+func (s Scope) allowsCrawl(u *url.URL) bool {
+	if !s.matchesCrawlHost(u.Host) {
+		return false
+	}
+	if len(s.PathPrefixes) == 0 {
+		return true
+	}
+	for _, prefix := range s.PathPrefixes {
+		if strings.HasPrefix(u.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsDownload reports whether the installer at u may be downloaded
+func (s Scope) allowsDownload(u *url.URL) bool {
+	if len(s.DownloadDomains) == 0 || s.matchesCrawlHost(u.Host) {
+		return true
+	}
+	for _, domain := range s.DownloadDomains {
+		if urlutil.MatchDomain(domain, u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesCrawlHost checks a host against the crawl domains
+func (s Scope) matchesCrawlHost(host string) bool {
+	if s.Subdomains == SubdomainsAny {
+		return true
+	}
+
+	hostname := strings.ToLower(urlutil.Hostname(host))
+	for _, domain := range s.CrawlDomains {
+		if urlutil.MatchDomain(domain, hostname) {
+			return true
+		}
+		if s.Subdomains == SubdomainsInclude &&
+			strings.HasSuffix(hostname, "."+strings.TrimPrefix(strings.ToLower(domain), "*.")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package crawler
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseSubdomainMode(t *testing.T) {
+	tests := []struct {
+		name string
+		want SubdomainMode
+		ok   bool
+	}{
+		{"", SubdomainsInclude, true},
+		{"exact", SubdomainsExact, true},
+		{" Subdomains ", SubdomainsInclude, true},
+		{"ANY", SubdomainsAny, true},
+		{"wildcard", "", false},
+	}
+	for _, tt := range tests {
+		got, err := ParseSubdomainMode(tt.name)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParseSubdomainMode(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestScopeDefaults(t *testing.T) {
+	tests := []struct {
+		start   string
+		scope   Scope
+		domains []string
+	}{
+		{"https://www.example.com/downloads", Scope{}, []string{"example.com"}},
+		{"https://www.example.co.uk/", Scope{Subdomains: SubdomainsInclude}, []string{"example.co.uk"}},
+		{"https://www.example.com/", Scope{Subdomains: SubdomainsExact}, []string{"www.example.com"}},
+		{"https://WWW.Example.com/", Scope{CrawlDomains: []string{"www.example.com"}}, []string{"www.example.com"}},
+		{"http://127.0.0.1:8080/", Scope{}, []string{"127.0.0.1"}},
+		{"http://localhost:8080/", Scope{}, []string{"localhost"}},
+	}
+	for _, tt := range tests {
+		start, _ := url.Parse(tt.start)
+		scope := tt.scope.withDefaults(start)
+		if !reflect.DeepEqual(scope.CrawlDomains, tt.domains) || scope.Subdomains == "" {
+			t.Errorf("withDefaults(%s) = %v (%s), want %v", tt.start, scope.CrawlDomains, scope.Subdomains, tt.domains)
+		}
+	}
+}
+
+func TestScopeAllows(t *testing.T) {
+	start, _ := url.Parse("https://www.example.com/")
+	subdomains := Scope{}.withDefaults(start)
+	exact := Scope{Subdomains: SubdomainsExact}.withDefaults(start)
+	prefixed := Scope{PathPrefixes: []string{"/downloads/", "/releases"}}.withDefaults(start)
+	globbed := Scope{CrawlDomains: []string{"*.example.org"}, Subdomains: SubdomainsExact}.withDefaults(start)
+	allowlist := Scope{DownloadDomains: []string{"*.cloudfront.net", "github.com"}}.withDefaults(start)
+	any := Scope{Subdomains: SubdomainsAny, DownloadDomains: []string{"cdn.example.net"}}.withDefaults(start)
+
+	tests := []struct {
+		name            string
+		scope           Scope
+		url             string
+		crawl, download bool
+	}{
+		{"start host", subdomains, "https://www.example.com/page", true, true},
+		{"sibling subdomain", subdomains, "https://downloads.example.com/tool.exe", true, true},
+		{"apex", subdomains, "https://EXAMPLE.com:443/", true, true},
+		{"lookalike", subdomains, "https://notexample.com/", false, true},
+		{"other site", subdomains, "https://example.org/", false, true},
+		{"exact start host", exact, "https://www.example.com/page", true, true},
+		{"exact sibling", exact, "https://downloads.example.com/", false, true},
+		{"exact deeper subdomain", exact, "https://a.www.example.com/", false, true},
+		{"path prefix", prefixed, "https://www.example.com/downloads/tool", true, true},
+		{"second path prefix", prefixed, "https://cdn.example.com/releases/v2", true, true},
+		{"outside path prefixes", prefixed, "https://www.example.com/blog/", false, true},
+		{"glob domain", globbed, "https://www.example.org/", true, true},
+		{"glob domain apex", globbed, "https://example.org/", false, true},
+		{"allowlisted download", allowlist, "https://d1.cloudfront.net/tool.msi", false, true},
+		{"allowlisted exact download", allowlist, "https://github.com/x/y/releases/tool.msi", false, true},
+		{"crawl domain download", allowlist, "https://downloads.example.com/tool.msi", true, true},
+		{"download outside the allowlist", allowlist, "https://evil.example.net/tool.msi", false, false},
+		{"any host", any, "https://anything.test/", true, true},
+		{"any host downloads", any, "https://evil.example.net/tool.msi", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.scope.allowsCrawl(u); got != tt.crawl {
+				t.Errorf("allowsCrawl(%s) = %v, want %v", tt.url, got, tt.crawl)
+			}
+			if got := tt.scope.allowsDownload(u); got != tt.download {
+				t.Errorf("allowsDownload(%s) = %v, want %v", tt.url, got, tt.download)
+			}
+		})
+	}
+}
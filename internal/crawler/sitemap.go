@@ -0,0 +1,116 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/deploymenttheory/go-app-index/internal/logger"
+)
+
+const (
+	// maxSitemaps bounds how many sitemap documents are read per crawl
+	maxSitemaps = 100
+	// maxSitemapURLs bounds how many URLs are seeded from sitemaps
+	maxSitemapURLs = 50000
+	// maxSitemapSize is the largest (uncompressed) sitemap we will read
+	maxSitemapSize = 50 * 1024 * 1024
+)
+
+// sitemapDocument covers both <urlset> sitemaps and <sitemapindex> files
+type sitemapDocument struct {
+	XMLName xml.Name `xml:""`
+	URLs    []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// discoverSitemapURLs returns the page URLs listed in the start host's
+// sitemaps, following sitemap indexes
+func (c *Crawler) discoverSitemapURLs(start *url.URL) []string {
+	queue := []string{start.Scheme + "://" + start.Host + "/sitemap.xml"}
+	if c.robots != nil {
+		queue = append(queue, c.robots.Sitemaps(start)...)
+	}
+
+	seen := make(map[string]bool)
+	var urls []string
+
+	for len(queue) > 0 && len(seen) < maxSitemaps && len(urls) < maxSitemapURLs {
+		sitemapURL := strings.TrimSpace(queue[0])
+		queue = queue[1:]
+		if sitemapURL == "" || seen[sitemapURL] {
+			continue
+		}
+		seen[sitemapURL] = true
+
+		doc, err := c.fetchSitemap(sitemapURL)
+		if err != nil {
+			logger.Debugf("Skipping sitemap %s: %v", sitemapURL, err)
+			continue
+		}
+
+		for _, s := range doc.Sitemaps {
+			queue = append(queue, s.Loc)
+		}
+		for _, u := range doc.URLs {
+			if loc := strings.TrimSpace(u.Loc); loc != "" && len(urls) < maxSitemapURLs {
+				urls = append(urls, loc)
+			}
+		}
+		logger.Infof("Read sitemap %s: %d URLs, %d nested sitemaps", sitemapURL, len(doc.URLs), len(doc.Sitemaps))
+	}
+
+	return urls
+}
+
+// fetchSitemap downloads and parses a sitemap, transparently handling gzip
+func (c *Crawler) fetchSitemap(sitemapURL string) (*sitemapDocument, error) {
+	req, err := http.NewRequest(http.MethodGet, sitemapURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.collector.UserAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSitemapSize))
+	if err != nil {
+		return nil, err
+	}
+
+	// Servers often send .xml.gz without Content-Encoding, so check the magic
+	if len(body) >= 2 && body[0] == 0x1F && body[1] == 0x8B {
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip sitemap: %w", err)
+		}
+		defer gz.Close()
+		body, err = io.ReadAll(io.LimitReader(gz, maxSitemapSize))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip sitemap: %w", err)
+		}
+	}
+
+	var doc sitemapDocument
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("invalid sitemap XML: %w", err)
+	}
+	return &doc, nil
+}
@@ -0,0 +1,130 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+)
+
+func gzipBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(s))
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDiscoverSitemapURLs(t *testing.T) {
+	var server *httptest.Server
+	documents := map[string]func() []byte{
+		"/robots.txt": func() []byte {
+			return []byte("User-agent: *\nSitemap: " + server.URL + "/extra.xml\nSitemap: " + server.URL + "/sitemap.xml\n")
+		},
+		// A sitemap index pointing at a plain, a gzipped and a broken sitemap
+		"/sitemap.xml": func() []byte {
+			return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>` + server.URL + `/pages.xml</loc></sitemap>
+  <sitemap><loc> ` + server.URL + `/downloads.xml.gz </loc></sitemap>
+  <sitemap><loc>` + server.URL + `/broken.xml</loc></sitemap>
+  <sitemap><loc>` + server.URL + `/missing.xml</loc></sitemap>
+  <sitemap><loc>` + server.URL + `/pages.xml</loc></sitemap>
+</sitemapindex>`)
+		},
+		"/pages.xml": func() []byte {
+			return []byte(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/</loc></url>
+  <url><loc>  https://example.com/download  </loc></url>
+  <url><loc></loc></url>
+</urlset>`)
+		},
+		"/downloads.xml.gz": func() []byte {
+			return gzipBytes(t, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/releases/1.0</loc></url>
+</urlset>`)
+		},
+		"/broken.xml": func() []byte { return []byte("<urlset><url><loc>") },
+		"/extra.xml": func() []byte {
+			return []byte(`<urlset><url><loc>https://example.com/extra</loc></url></urlset>`)
+		},
+	}
+	var userAgents []string
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents = append(userAgents, r.UserAgent())
+		document, ok := documents[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(document())
+	}))
+	defer server.Close()
+
+	for _, obeyRobots := range []bool{false, true} {
+		c := New(1, server.URL, 1, nil, nil, 0, 5, nil)
+		c.collector = colly.NewCollector(colly.UserAgent("test-agent"))
+		c.httpClient = server.Client()
+		if obeyRobots {
+			c.robots = newRobotsPolicy(c.httpClient, "test-agent")
+		}
+		start, _ := url.Parse(server.URL + "/")
+
+		want := []string{
+			"https://example.com/",
+			"https://example.com/download",
+			"https://example.com/releases/1.0",
+		}
+		// Sitemaps are read breadth first, so robots.txt sitemaps come
+		// before those nested in the index
+		if obeyRobots {
+			want = append([]string{"https://example.com/extra"}, want...)
+		}
+		if got := c.discoverSitemapURLs(start); !reflect.DeepEqual(got, want) {
+			t.Errorf("robots %v: discoverSitemapURLs() = %v, want %v", obeyRobots, got, want)
+		}
+	}
+
+	for _, ua := range userAgents {
+		if !strings.HasPrefix(ua, "test-agent") {
+			t.Errorf("sitemap requested with user agent %q", ua)
+		}
+	}
+}
+
+func TestFetchSitemapErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bad-gzip.xml.gz":
+			w.Write([]byte{0x1F, 0x8B, 0x00})
+		case "/not-xml":
+			w.Write([]byte("<html><body>"))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	c := New(1, server.URL, 1, nil, nil, 0, 5, nil)
+	c.collector = colly.NewCollector()
+	c.httpClient = server.Client()
+	for path, want := range map[string]string{
+		"/bad-gzip.xml.gz": "invalid gzip sitemap",
+		"/not-xml":         "invalid sitemap XML",
+		"/forbidden":       "unexpected status code: 403",
+	} {
+		_, err := c.fetchSitemap(server.URL + path)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("fetchSitemap(%s) error = %v, want %q", path, err, want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package detect
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/deploymenttheory/go-app-index/internal/fileanalyzer"
+)
+
+// Info describes what a response says about the file behind a URL
+type Info struct {
+	URL         string // Final URL after redirects
+	FileName    string // From Content-Disposition, else the unescaped URL path
+	ContentType string
+	IsInstaller bool
+	NeedsProbe  bool   // Binary content that only its leading bytes can identify
+	DetectedBy  string // extension, content-disposition, content-type or signature
+}
+
+// installerContentTypes are content types only used for installer formats,
+// mapped to the extension a file of that type gets when its name does not
+// already end in an installer extension
+var installerContentTypes = map[string]string{
+	"application/x-msdownload":                      ".exe",
+	"application/vnd.microsoft.portable-executable": ".exe",
+	"application/x-dosexec":                         ".exe",
+	"application/x-msi":                             ".msi",
+	"application/x-ms-installer":                    ".msi",
+	"application/msix":                              ".msix",
+	"application/msixbundle":                        ".msixbundle",
+	"application/appx":                              ".appx",
+	"application/appxbundle":                        ".appxbundle",
+	"application/x-apple-diskimage":                 ".dmg",
+	"application/vnd.apple.installer+xml":           ".pkg",
+	"application/vnd.debian.binary-package":         ".deb",
+	"application/x-debian-package":                  ".deb",
+	"application/x-rpm":                             ".rpm",
+	"application/x-redhat-package-manager":          ".rpm",
+	"application/x-executable":                      "",
+}
+
+// IsInstallerContentType reports whether a content type names an installer format
+func IsInstallerContentType(contentType string) bool {
+	_, ok := installerContentTypes[mediaType(contentType)]
+	return ok
+}
+
+// IsPageContentType reports whether a content type is something the
+// crawler parses rather than downloads
+func IsPageContentType(contentType string) bool {
+	mt := mediaType(contentType)
+	return mt == "" ||
+		strings.HasPrefix(mt, "text/") ||
+		strings.HasSuffix(mt, "+xml") ||
+		strings.HasSuffix(mt, "/xml") ||
+		strings.HasSuffix(mt, "/json") ||
+		mt == "application/xhtml+xml" ||
+		mt == "application/javascript"
+}
+
+// FileName returns the name a download should be saved under: the
+// Content-Disposition filename when present, else the last element of the
+// unescaped URL path
+func FileName(header http.Header, u *url.URL) string {
+	if disposition := header.Get("Content-Disposition"); disposition != "" {
+		if _, params, err := mime.ParseMediaType(disposition); err == nil {
+			if name := CleanFileName(params["filename"]); name != "" {
+				return name
+			}
+		}
+	}
+	if u != nil {
+		if name := CleanFileName(path.Base(u.Path)); name != "" {
+			return name
+		}
+	}
+	return "download"
+}
+
+// FromHeaders classifies a response from its final URL and headers
+func FromHeaders(u *url.URL, header http.Header, extensions []string) Info {
+	info := Info{
+		URL:         u.String(),
+		FileName:    FileName(header, u),
+		ContentType: header.Get("Content-Type"),
+	}
+
+	if hasExtension(info.FileName, extensions) {
+		info.IsInstaller = true
+		info.DetectedBy = "content-disposition"
+		if hasExtension(u.Path, extensions) {
+			info.DetectedBy = "extension"
+		}
+		return info
+	}
+
+	if ext, ok := installerContentTypes[mediaType(info.ContentType)]; ok {
+		info.IsInstaller = true
+		info.DetectedBy = "content-type"
+		info.FileName += ext
+		return info
+	}
+
+	info.NeedsProbe = !IsPageContentType(info.ContentType)
+	return info
+}
+
+// FromSignature classifies a response by its leading bytes, updating info
+// in place. It returns true when the bytes match an installer signature.
+func FromSignature(info *Info, header []byte) bool {
+	sig, ok := fileanalyzer.DetectSignature(header)
+	if !ok || !fileanalyzer.IsInstallerType(sig.Type) {
+		return false
+	}
+
+	info.IsInstaller = true
+	info.NeedsProbe = false
+	info.DetectedBy = "signature"
+	if !strings.EqualFold(path.Ext(info.FileName), sig.Extension) {
+		info.FileName += sig.Extension
+	}
+	return true
+}
+
+// StatusError is returned by Probe when the server answers with an
+// unexpected status, so callers can back off from throttling responses
+type StatusError struct {
+	StatusCode int
+	RetryAfter string // Retry-After header, if any
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// Probe fetches the start of a URL with a ranged GET and classifies it
+// from the response headers and leading bytes. prepare, if set, can add
+// headers to the request.
+func Probe(ctx context.Context, client *http.Client, rawURL string, extensions []string, prepare func(*http.Request)) (Info, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return Info{}, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", fileanalyzer.SignatureHeaderSize-1))
+	if prepare != nil {
+		prepare(req)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Info{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return Info{}, &StatusError{StatusCode: resp.StatusCode, RetryAfter: resp.Header.Get("Retry-After")}
+	}
+
+	info := FromHeaders(resp.Request.URL, resp.Header, extensions)
+	if info.IsInstaller {
+		return info, nil
+	}
+
+	header := make([]byte, fileanalyzer.SignatureHeaderSize)
+	n, _ := io.ReadFull(resp.Body, header)
+	FromSignature(&info, header[:n])
+	return info, nil
+}
+
+func hasExtension(name string, extensions []string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range extensions {
+		if strings.HasSuffix(lower, strings.ToLower(ext)) {
+			return true
+		}
+	}
+	return false
+}
+
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mt
+}
+
+// CleanFileName strips directories and characters that are unsafe in file
+// names, returning "" when nothing usable remains
+func CleanFileName(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = path.Base(strings.TrimSpace(name))
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,66 @@
+package detect
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestCleanFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Setup-1.0.exe", "Setup-1.0.exe"},
+		{"  Setup.msi ", "Setup.msi"},
+		{"../../etc/passwd", "passwd"},
+		{`..\..\Windows\evil.dll`, "evil.dll"},
+		{"Tool.1.0.0.nupkg/../../../x.nupkg", "x.nupkg"},
+		{"a<b>:c|d?.exe", "a_b__c_d_.exe"},
+		{"bad\x00name.exe", "bad_name.exe"},
+		{"", ""},
+		{".", ""},
+		{"..", ""},
+		{"/", ""},
+		{"dir/", "dir"},
+	}
+	for _, tt := range tests {
+		if got := CleanFileName(tt.name); got != tt.want {
+			t.Errorf("CleanFileName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFromHeaders(t *testing.T) {
+	extensions := []string{".exe", ".msi", ".dmg"}
+	tests := []struct {
+		rawURL      string
+		disposition string
+		contentType string
+		installer   bool
+		fileName    string
+		detectedBy  string
+	}{
+		{"https://example.com/Setup.exe", "", "application/octet-stream", true, "Setup.exe", "extension"},
+		{"https://example.com/get", `attachment; filename="Tool.msi"`, "application/octet-stream", true, "Tool.msi", "content-disposition"},
+		{"https://example.com/get/tool", "", "application/x-msdownload", true, "tool.exe", "content-type"},
+		// A name that is not an installer's gets the content type's extension
+		{"https://example.com/download.php", "", "application/x-msdownload", true, "download.php.exe", "content-type"},
+		{"https://example.com/download.php?id=7", "", "application/x-apple-diskimage; charset=binary", true, "download.php.dmg", "content-type"},
+		// Content types without an extension still mark the file as an installer
+		{"https://example.com/get/tool", "", "application/x-executable", true, "tool", "content-type"},
+		{"https://example.com/page.php", "", "text/html; charset=utf-8", false, "page.php", ""},
+		{"https://example.com/blob", "", "application/octet-stream", false, "blob", ""},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.rawURL)
+		header := http.Header{"Content-Type": {tt.contentType}}
+		if tt.disposition != "" {
+			header.Set("Content-Disposition", tt.disposition)
+		}
+		info := FromHeaders(u, header, extensions)
+		if info.IsInstaller != tt.installer || info.FileName != tt.fileName || info.DetectedBy != tt.detectedBy {
+			t.Errorf("FromHeaders(%s, %s) = %+v", tt.rawURL, tt.contentType, info)
+		}
+	}
+}
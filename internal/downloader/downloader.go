@@ -1,14 +1,24 @@
 package downloader
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/config"
+	"github.com/deploymenttheory/go-app-index/internal/detect"
+	"github.com/deploymenttheory/go-app-index/internal/fileanalyzer"
+	"github.com/deploymenttheory/go-app-index/internal/policy"
+	"github.com/deploymenttheory/go-app-index/internal/types"
 )
 
 // Stats holds downloader statistics
@@ -17,6 +27,8 @@ type Stats struct {
 	FilesDownloaded int
 	BytesDownloaded int64
 	Errors          int
+	Throttled       int // Responses with 429 or 503 that triggered backoff
+	Spilled         int // Requests written to the spill file while the workers were busy
 	StartTime       time.Time
 	EndTime         time.Time
 }
@@ -27,7 +39,11 @@ type Downloader struct {
 	fileExtensions []string
 	tempDir        string
 	processorQueue chan<- DownloadResult
-	urlQueue       chan string
+	urlQueue       chan types.DownloadRequest
+	workQueue      chan types.DownloadRequest // Read by workers; urlQueue unless spilling
+	spillDir       string
+	policies       *policy.Set
+	limiter        *policy.Limiter
 
 	wg         sync.WaitGroup
 	stats      Stats
@@ -37,6 +53,27 @@ type Downloader struct {
 	done      bool
 	doneMutex sync.RWMutex
 	stop      chan struct{}
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// Option configures optional Downloader behaviour
+type Option func(*Downloader)
+
+// WithPolicies applies per-domain download parallelism, delays, bandwidth
+// limits and request headers
+func WithPolicies(policies *policy.Set) Option {
+	return func(d *Downloader) {
+		d.policies = policies
+	}
+}
+
+// WithSpillDir lets the download queue overflow to a file in dir instead of
+// making discovery wait for the workers
+func WithSpillDir(dir string) Option {
+	return func(d *Downloader) {
+		d.spillDir = dir
+	}
 }
 
 // DownloadResult represents a downloaded file ready for processing
@@ -47,16 +84,17 @@ type DownloadResult struct {
 	FileSize     int64
 	ContentType  string
 	DownloadedAt time.Time
+	Release      *types.ReleaseInfo // Feed metadata, if discovered through a source
 }
 
 // New creates a new Downloader
-func New(workers int, processorQueue chan<- DownloadResult, fileExtensions []string, tempDir string) *Downloader {
-	return &Downloader{
+func New(workers int, processorQueue chan<- DownloadResult, fileExtensions []string, tempDir string, opts ...Option) *Downloader {
+	d := &Downloader{
 		workers:        workers,
 		fileExtensions: fileExtensions,
 		tempDir:        tempDir,
 		processorQueue: processorQueue,
-		urlQueue:       make(chan string, 1000), // Buffer for 1000 URLs
+		urlQueue:       make(chan types.DownloadRequest, 1000), // Buffer for 1000 URLs
 		client: &http.Client{
 			Timeout: 5 * time.Minute, // Generous timeout for large files
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
@@ -68,6 +106,19 @@ func New(workers int, processorQueue chan<- DownloadResult, fileExtensions []str
 		},
 		stop: make(chan struct{}),
 	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	if d.policies == nil {
+		d.policies = policy.NewSet(config.DomainPolicy{}, nil)
+	}
+	d.limiter = policy.NewLimiter(d.policies, policy.ModeDownload)
+	d.workQueue = d.urlQueue
+
+	return d
 }
 
 // Start begins the download workers
@@ -83,6 +134,17 @@ func (d *Downloader) Start() {
 		return
 	}
 
+	// Overflow to disk if requested
+	if d.spillDir != "" {
+		spill, err := newSpillQueue(d.spillDir)
+		if err != nil {
+			fmt.Printf("Failed to create download spill queue, queueing in memory: %v\n", err)
+		} else {
+			d.workQueue = make(chan types.DownloadRequest, cap(d.urlQueue))
+			go d.dispatch(spill)
+		}
+	}
+
 	// Start worker goroutines
 	for i := 0; i < d.workers; i++ {
 		d.wg.Add(1)
@@ -98,22 +160,24 @@ func (d *Downloader) worker(id int) {
 		select {
 		case <-d.stop:
 			return
-		case url, ok := <-d.urlQueue:
+		case req, ok := <-d.workQueue:
 			if !ok {
 				return
 			}
 
-			// Check if URL potentially points to an installer
-			if !d.isInstallerURL(url) {
+			// Check if URL potentially points to an installer. Feed
+			// entries are trusted to point at downloads, as are requests
+			// the crawler recognised from response content.
+			if !trusted(req) && !d.isInstallerURL(req.URL) {
 				continue
 			}
 
 			d.incrementFilesFound()
 
 			// Download the file
-			result, err := d.downloadFile(url)
+			result, err := d.downloadFile(req)
 			if err != nil {
-				fmt.Printf("Worker %d: Failed to download %s: %v\n", id, url, err)
+				fmt.Printf("Worker %d: Failed to download %s: %v\n", id, req.URL, err)
 				d.incrementErrors()
 				continue
 			}
@@ -131,42 +195,124 @@ func (d *Downloader) worker(id int) {
 	}
 }
 
-// downloadFile downloads a file from the given URL
-func (d *Downloader) downloadFile(url string) (DownloadResult, error) {
+// dispatch moves requests from the intake queue to the workers, spilling
+// them to disk in arrival order whenever the workers fall behind
+func (d *Downloader) dispatch(spill *spillQueue) {
+	defer close(d.workQueue)
+	defer spill.Close()
+
+	intake := d.urlQueue
+	var next *types.DownloadRequest // Oldest request waiting for a worker
+
+	for {
+		if next == nil && spill.Len() > 0 {
+			req, ok, err := spill.Pop()
+			var readErr *spillReadError
+			switch {
+			case errors.As(err, &readErr):
+				// The queued requests are gone; count them and carry on
+				// without spilling
+				fmt.Printf("Download spill queue failed: %v\n", err)
+				d.addErrors(readErr.lost)
+			case err != nil:
+				fmt.Printf("Download spill queue failed: %v\n", err)
+				d.incrementErrors()
+			case ok:
+				next = &req
+			}
+		}
+		if intake == nil && next == nil && spill.Len() == 0 {
+			return
+		}
+
+		var out chan types.DownloadRequest
+		var head types.DownloadRequest
+		if next != nil {
+			out = d.workQueue
+			head = *next
+		}
+
+		select {
+		case req, ok := <-intake:
+			if !ok {
+				intake = nil
+				continue
+			}
+			if next != nil {
+				select {
+				case d.workQueue <- *next:
+					next = nil
+				default:
+				}
+			}
+			if next == nil && spill.Len() == 0 {
+				next = &req
+				continue
+			}
+			if err := spill.Push(req); err != nil {
+				// Fall back to waiting for a worker rather than losing the
+				// request, keeping it behind the one already waiting
+				fmt.Printf("Download spill queue failed: %v\n", err)
+				if next != nil {
+					select {
+					case d.workQueue <- *next:
+					case <-d.stop:
+						return
+					}
+				}
+				next = &req
+				continue
+			}
+			d.incrementSpilled()
+		case out <- head:
+			next = nil
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// downloadFile downloads the file referenced by a download request
+func (d *Downloader) downloadFile(req types.DownloadRequest) (DownloadResult, error) {
+	url := req.URL
 	fmt.Printf("Downloading %s\n", url)
 
-	// Make a HEAD request first to check content type and size
-	resp, err := d.client.Head(url)
+	resp, release, err := d.request(http.MethodGet, url)
 	if err != nil {
-		return DownloadResult{}, fmt.Errorf("head request failed: %w", err)
+		return DownloadResult{}, fmt.Errorf("get request failed: %w", err)
 	}
+	defer release()
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		return DownloadResult{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	contentType := resp.Header.Get("Content-Type")
-
-	// Skip if not a likely binary file
-	if !d.isLikelyInstallerContentType(contentType) && !d.hasInstallerExtension(url) {
-		return DownloadResult{}, fmt.Errorf("not a likely installer file based on content type: %s", contentType)
+	// Classify the response from its headers, then from its leading bytes
+	info := detect.FromHeaders(resp.Request.URL, resp.Header, d.fileExtensions)
+	body := bufio.NewReaderSize(d.limiter.Reader(d.ctx, resp.Request.URL.Host, resp.Body), 32*1024)
+	if !info.IsInstaller {
+		header, _ := body.Peek(fileanalyzer.SignatureHeaderSize)
+		detect.FromSignature(&info, header)
 	}
 
-	// Create a temporary file
-	fileName := filepath.Base(url)
-	filePath := filepath.Join(d.tempDir, fmt.Sprintf("%d-%s", time.Now().UnixNano(), fileName))
-
-	// Download the file
-	fileResp, err := d.client.Get(url)
-	if err != nil {
-		return DownloadResult{}, fmt.Errorf("get request failed: %w", err)
+	// Skip if not a likely installer. Feed entries and detected requests
+	// are trusted to point at downloads.
+	if !trusted(req) && !info.IsInstaller {
+		return DownloadResult{}, fmt.Errorf("not a likely installer file based on content type: %s", info.ContentType)
 	}
-	defer fileResp.Body.Close()
 
-	if fileResp.StatusCode != http.StatusOK {
-		return DownloadResult{}, fmt.Errorf("unexpected status code: %d", fileResp.StatusCode)
+	// Create a temporary file
+	// Feeds name files from release data, so their names are cleaned like
+	// the server's before they reach the file system
+	fileName := info.FileName
+	if req.FileName != "" {
+		fileName = detect.CleanFileName(req.FileName)
+		if fileName == "" {
+			return DownloadResult{}, fmt.Errorf("unusable file name: %q", req.FileName)
+		}
 	}
+	filePath := filepath.Join(d.tempDir, fmt.Sprintf("%d-%s", time.Now().UnixNano(), fileName))
 
 	file, err := os.Create(filePath)
 	if err != nil {
@@ -174,7 +320,7 @@ func (d *Downloader) downloadFile(url string) (DownloadResult, error) {
 	}
 
 	// Copy the body to the file
-	written, err := io.Copy(file, fileResp.Body)
+	written, err := io.Copy(file, body)
 	file.Close()
 
 	if err != nil {
@@ -190,13 +336,73 @@ func (d *Downloader) downloadFile(url string) (DownloadResult, error) {
 		FilePath:     filePath,
 		FileName:     fileName,
 		FileSize:     written,
-		ContentType:  contentType,
+		ContentType:  info.ContentType,
 		DownloadedAt: time.Now(),
+		Release:      req.Release,
 	}, nil
 }
 
+// maxThrottleRetries is how often a request answered with 429 or 503 is retried
+const maxThrottleRetries = 3
+
+// request performs a request under the target host's policy: it takes a
+// host slot, waits out the policy delay and any backoff, applies the
+// policy headers and retries throttled responses. The returned release
+// function frees the host slot once the body has been consumed.
+func (d *Downloader) request(method, rawURL string) (*http.Response, func(), error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	host := u.Host
+
+	release, err := d.limiter.Acquire(d.ctx, host)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := d.limiter.Wait(d.ctx, host, 0); err != nil {
+			release()
+			return nil, nil, err
+		}
+
+		req, err := http.NewRequestWithContext(d.ctx, method, rawURL, nil)
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+		d.policies.ApplyHeaders(u.Hostname(), req.Header)
+
+		resp, err := d.client.Do(req)
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+
+		if !policy.IsThrottleStatus(resp.StatusCode) {
+			d.limiter.Success(host)
+			return resp, release, nil
+		}
+
+		d.incrementThrottled()
+		delay := d.limiter.Backoff(host, policy.RetryAfter(resp.Header.Get("Retry-After")))
+		if attempt >= maxThrottleRetries {
+			return resp, release, nil
+		}
+		resp.Body.Close()
+		fmt.Printf("Throttled by %s (status %d), retrying in %v\n", host, resp.StatusCode, delay)
+	}
+}
+
+// trusted reports whether a request is known to point at an installer
+// without checking its URL
+func trusted(req types.DownloadRequest) bool {
+	return req.Release != nil || req.Detected
+}
+
 // Queue returns the URL queue channel
-func (d *Downloader) Queue() chan<- string {
+func (d *Downloader) Queue() chan<- types.DownloadRequest {
 	return d.urlQueue
 }
 
@@ -211,6 +417,7 @@ func (d *Downloader) Done() {
 // Stop signals the downloader to stop
 func (d *Downloader) Stop() {
 	close(d.stop)
+	d.cancel()
 }
 
 // Wait waits for all downloads to complete
@@ -245,30 +452,6 @@ func (d *Downloader) hasInstallerExtension(url string) bool {
 	return false
 }
 
-// isLikelyInstallerContentType checks if the content type suggests a binary/installer file
-func (d *Downloader) isLikelyInstallerContentType(contentType string) bool {
-	contentType = strings.ToLower(contentType)
-
-	// Common content types for installers
-	knownTypes := []string{
-		"application/octet-stream",
-		"application/x-msdownload",
-		"application/x-msi",
-		"application/x-apple-diskimage",
-		"application/vnd.debian.binary-package",
-		"application/x-rpm",
-		"application/x-executable",
-	}
-
-	for _, t := range knownTypes {
-		if strings.Contains(contentType, t) {
-			return true
-		}
-	}
-
-	return false
-}
-
 // Increment files found counter
 func (d *Downloader) incrementFilesFound() {
 	d.statsMutex.Lock()
@@ -290,10 +473,29 @@ func (d *Downloader) incrementBytesDownloaded(bytes int64) {
 	d.statsMutex.Unlock()
 }
 
+// Increment throttled responses counter
+func (d *Downloader) incrementThrottled() {
+	d.statsMutex.Lock()
+	d.stats.Throttled++
+	d.statsMutex.Unlock()
+}
+
+// Increment spilled requests counter
+func (d *Downloader) incrementSpilled() {
+	d.statsMutex.Lock()
+	d.stats.Spilled++
+	d.statsMutex.Unlock()
+}
+
 // Increment errors counter
 func (d *Downloader) incrementErrors() {
+	d.addErrors(1)
+}
+
+// Add to errors counter
+func (d *Downloader) addErrors(n int) {
 	d.statsMutex.Lock()
-	d.stats.Errors++
+	d.stats.Errors += n
 	d.statsMutex.Unlock()
 }
 
@@ -0,0 +1,58 @@
+package downloader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/deploymenttheory/go-app-index/internal/crawler"
+)
+
+func TestCrawlDetectDownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/download.php?id=1">Windows</a> <a href="/get/linux">Linux</a> <a href="/about.php">About</a>`))
+		case "/download.php":
+			w.Header().Set("Content-Type", "application/x-msdownload")
+			w.Write(append([]byte("MZ"), make([]byte, 510)...))
+		case "/get/linux":
+			w.Header().Set("Content-Type", "application/x-executable")
+			w.Write(append([]byte("\x7fELF"), make([]byte, 508)...))
+		case "/about.php":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("about"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	results := make(chan DownloadResult, 10)
+	d := New(2, results, []string{".exe", ".msi"}, t.TempDir())
+	d.Start()
+
+	c := crawler.New(1, server.URL+"/", 2, nil, nil, 0, 5, d.Queue())
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	d.Done()
+	d.Wait()
+	close(results)
+
+	// Both are only recognisable by their content type, and neither
+	// name ends in an installer extension
+	var names []string
+	for result := range results {
+		names = append(names, result.FileName)
+	}
+	sort.Strings(names)
+	if want := []string{"download.php.exe", "linux"}; len(names) != 2 || names[0] != want[0] || names[1] != want[1] {
+		t.Errorf("downloaded %v, want %v", names, want)
+	}
+	if stats := d.Stats(); stats.FilesDownloaded != 2 || stats.Errors != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
@@ -1,6 +1,7 @@
 package downloader
 
 import (
+	"context"
 	"mime"
 	"path/filepath"
 	"strings"
@@ -23,6 +24,11 @@ var knownFileTypes = []FileType{
 		MimeTypes:  []string{"application/x-msdownload", "application/x-msi", "application/octet-stream"},
 		Platform:   "windows",
 	},
+	{
+		Extensions: []string{".msix", ".msixbundle", ".appx", ".appxbundle"},
+		MimeTypes:  []string{"application/msix", "application/msixbundle", "application/appx", "application/appxbundle"},
+		Platform:   "windows",
+	},
 	{
 		Extensions: []string{".dmg", ".pkg"},
 		MimeTypes:  []string{"application/x-apple-diskimage", "application/vnd.apple.installer+xml", "application/octet-stream"},
@@ -44,7 +50,7 @@ func DetectFileType(fileName, contentType string, filePath string) (fileType, pl
 		analyzer := fileanalyzer.NewManager()
 
 		// Perform analysis
-		result, err := analyzer.Analyze(filePath, contentType)
+		result, err := analyzer.Analyze(context.Background(), filePath, contentType)
 		if err == nil && result.Confidence > 0.5 {
 			logger.Debugf("Enhanced file detection result: %s, %s, confidence: %.2f",
 				result.FileType, result.Platform, result.Confidence)
@@ -81,7 +87,7 @@ func DetectFileType(fileName, contentType string, filePath string) (fileType, pl
 
 	// If all else fails, try to determine from extension
 	switch ext {
-	case ".exe", ".msi":
+	case ".exe", ".msi", ".msix", ".msixbundle", ".appx", ".appxbundle":
 		return strings.TrimPrefix(ext, "."), "windows", 0.6
 	case ".dmg", ".pkg":
 		return strings.TrimPrefix(ext, "."), "macos", 0.6
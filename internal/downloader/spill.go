@@ -0,0 +1,128 @@
+package downloader
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// spillQueue is an unbounded FIFO of download requests backed by a JSONL
+// file, used to hold requests the workers cannot take yet
+type spillQueue struct {
+	path    string
+	writer  *os.File
+	reader  *bufio.Reader
+	file    *os.File
+	pending int
+	err     error // Set once the file cannot be read; the queue is then empty for good
+	mutex   sync.Mutex
+}
+
+// spillReadError reports that the spill file could not be read, losing
+// every request still queued in it
+type spillReadError struct {
+	lost int
+	err  error
+}
+
+func (e *spillReadError) Error() string {
+	return fmt.Sprintf("failed to read spill file, losing %d requests: %v", e.lost, e.err)
+}
+
+func (e *spillReadError) Unwrap() error {
+	return e.err
+}
+
+// newSpillQueue creates an empty spill file in dir
+func newSpillQueue(dir string) (*spillQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spill directory: %w", err)
+	}
+
+	writer, err := os.CreateTemp(dir, "download-queue-*.jsonl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spill file: %w", err)
+	}
+
+	file, err := os.Open(writer.Name())
+	if err != nil {
+		writer.Close()
+		os.Remove(writer.Name())
+		return nil, fmt.Errorf("failed to open spill file: %w", err)
+	}
+
+	return &spillQueue{
+		path:   writer.Name(),
+		writer: writer,
+		reader: bufio.NewReader(file),
+		file:   file,
+	}, nil
+}
+
+// Push appends a request to the end of the queue
+func (q *spillQueue) Push(req types.DownloadRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.err != nil {
+		return q.err
+	}
+	if _, err := q.writer.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write spill file: %w", err)
+	}
+	q.pending++
+	return nil
+}
+
+// Pop removes the request at the front of the queue. A corrupt entry only
+// loses that request, but failing to read the file loses all of them: the
+// queue is emptied, later pushes fail and a *spillReadError is returned.
+func (q *spillQueue) Pop() (types.DownloadRequest, bool, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var req types.DownloadRequest
+	if q.pending == 0 {
+		return req, false, nil
+	}
+
+	line, err := q.reader.ReadBytes('\n')
+	if err != nil {
+		readErr := &spillReadError{lost: q.pending, err: err}
+		q.pending = 0
+		q.err = readErr
+		return req, false, readErr
+	}
+	q.pending--
+
+	if err := json.Unmarshal(line, &req); err != nil {
+		return req, false, fmt.Errorf("corrupt spill file entry: %w", err)
+	}
+	return req, true, nil
+}
+
+// Len returns the number of requests waiting in the queue
+func (q *spillQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.pending
+}
+
+// Close closes and removes the spill file
+func (q *spillQueue) Close() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.file.Close()
+	q.writer.Close()
+	return os.Remove(q.path)
+}
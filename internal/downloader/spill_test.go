@@ -0,0 +1,141 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+func TestSpillQueueOrder(t *testing.T) {
+	q, err := newSpillQueue(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	// Interleave pushes and pops so reads follow writes
+	next := 0
+	for i := 0; i < 10; i++ {
+		if err := q.Push(types.DownloadRequest{URL: fmt.Sprintf("https://example.com/%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+		if i%3 == 2 {
+			req, ok, err := q.Pop()
+			if err != nil || !ok || req.URL != fmt.Sprintf("https://example.com/%d", next) {
+				t.Fatalf("Pop() = %v, %v, %v, want request %d", req.URL, ok, err, next)
+			}
+			next++
+		}
+	}
+	for ; next < 10; next++ {
+		req, ok, err := q.Pop()
+		if err != nil || !ok || req.URL != fmt.Sprintf("https://example.com/%d", next) {
+			t.Fatalf("Pop() = %v, %v, %v, want request %d", req.URL, ok, err, next)
+		}
+	}
+	if _, ok, err := q.Pop(); ok || err != nil || q.Len() != 0 {
+		t.Errorf("Pop() of an empty queue = %v, %v, Len() = %d", ok, err, q.Len())
+	}
+}
+
+func TestSpillQueueReadError(t *testing.T) {
+	q, err := newSpillQueue(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := q.Push(types.DownloadRequest{URL: fmt.Sprintf("https://example.com/%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.file.Close()
+
+	_, ok, err := q.Pop()
+	var readErr *spillReadError
+	if ok || !errors.As(err, &readErr) || readErr.lost != 3 {
+		t.Fatalf("Pop() = %v, %v, want a *spillReadError losing 3 requests", ok, err)
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len() = %d after a read error, want 0", q.Len())
+	}
+	if err := q.Push(types.DownloadRequest{URL: "https://example.com/later"}); err == nil {
+		t.Error("Push() after a read error succeeded")
+	}
+}
+
+// drain reads the work queue until dispatch closes it
+func drain(t *testing.T, queue <-chan types.DownloadRequest) []string {
+	t.Helper()
+	var urls []string
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case req, ok := <-queue:
+			if !ok {
+				return urls
+			}
+			urls = append(urls, req.URL)
+		case <-timeout:
+			t.Fatalf("work queue not closed after %d requests", len(urls))
+		}
+	}
+}
+
+func TestDispatchOverflow(t *testing.T) {
+	d := New(0, nil, nil, t.TempDir(), WithSpillDir(t.TempDir()))
+	d.Start()
+
+	// With no workers, everything past the work queue's capacity spills
+	total := 3 * cap(d.urlQueue)
+	for i := 0; i < total; i++ {
+		d.Queue() <- types.DownloadRequest{URL: fmt.Sprintf("https://example.com/%d", i)}
+	}
+	d.Done()
+
+	urls := drain(t, d.workQueue)
+	if len(urls) != total {
+		t.Fatalf("dispatched %d requests, want %d", len(urls), total)
+	}
+	for i, url := range urls {
+		if want := fmt.Sprintf("https://example.com/%d", i); url != want {
+			t.Fatalf("request %d = %s, want %s", i, url, want)
+		}
+	}
+	if stats := d.Stats(); stats.Spilled < total-2*cap(d.urlQueue) || stats.Errors != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestDispatchSpillReadError(t *testing.T) {
+	d := New(0, nil, nil, t.TempDir())
+	d.workQueue = make(chan types.DownloadRequest, 1)
+	spill, err := newSpillQueue(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := spill.Push(types.DownloadRequest{URL: fmt.Sprintf("https://example.com/%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	spill.file.Close()
+
+	// Requests arriving after the failure bypass the spill file
+	d.urlQueue <- types.DownloadRequest{URL: "https://example.com/a"}
+	d.urlQueue <- types.DownloadRequest{URL: "https://example.com/b"}
+	d.Done()
+	go d.dispatch(spill)
+
+	urls := drain(t, d.workQueue)
+	if len(urls) != 2 || urls[0] != "https://example.com/a" || urls[1] != "https://example.com/b" {
+		t.Errorf("dispatched %v", urls)
+	}
+	if stats := d.Stats(); stats.Errors != 3 {
+		t.Errorf("Errors = %d, want the 3 lost requests", stats.Errors)
+	}
+}
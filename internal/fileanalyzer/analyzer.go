@@ -1,10 +1,16 @@
 package fileanalyzer
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/types"
 )
 
 // Result represents the analysis result for a file
@@ -14,28 +20,152 @@ type Result struct {
 	Confidence   float64                // Confidence score (0.0-1.0)
 	IsInstaller  bool                   // Whether this is an installer
 	Metadata     map[string]interface{} // Extended metadata
-	AnalyzedAt   time.Time              // When analysis was performed
+	AnalyzedAt   time.Time              // When analysis was performed, set by Manager
 	NestedResult *Result                // Analysis result for a file inside a container (e.g. zip)
+
+	// Factors an analyzer verified. When set, Manager computes Confidence
+	// from them and records each factor's contribution in ScoreFactors.
+	Factors      *ConfidenceFactors
+	ScoreFactors map[string]float64
+
+	// Set on results merged by Manager
+	Provenance map[string]types.FieldSource // Analyzer that supplied each field
+	Conflicts  []types.FieldConflict        // Fields analyzers reported different values for
+	Runs       []AnalyzerRun                // Every analyzer that ran, in registration order
+}
+
+// AnalyzerRun records the outcome of one analyzer on a file
+type AnalyzerRun struct {
+	Analyzer   string
+	Confidence float64 // Zero if the analyzer failed
+	Duration   time.Duration
+	Err        error
 }
 
 // Analyzer defines the interface for file analyzers
 type Analyzer interface {
-	// Analyze performs analysis on a file path
+	// Name identifies the analyzer in merged results
+	Name() string
+
+	// Analyze performs analysis on a file path. It cannot be interrupted:
+	// an analyzer still running when Manager gives up on it is left to
+	// finish in the background, and only WithMaxAbandoned of those may run
+	// at once, so analyzers should bound their own work or implement
+	// ContextAnalyzer.
 	Analyze(filePath string) (*Result, error)
 
 	// CanHandle checks if this analyzer can handle this file type
 	CanHandle(filePath string, contentType string) bool
 }
 
+// ContextAnalyzer is implemented by analyzers that stop when ctx is done.
+// Manager calls AnalyzeContext and CanHandleContext instead of Analyze and
+// CanHandle, with the context bounding the whole analysis of the file, and
+// waits for AnalyzeContext to return rather than abandoning it.
+type ContextAnalyzer interface {
+	Analyzer
+	AnalyzeContext(ctx context.Context, filePath string) (*Result, error)
+	CanHandleContext(ctx context.Context, filePath string, contentType string) bool
+}
+
+const (
+	// DefaultTimeout bounds how long all analyzers may spend on one file
+	DefaultTimeout = 2 * time.Minute
+
+	// DefaultMaxAbandoned bounds how many timed-out analyzers may still be
+	// running in the background
+	DefaultMaxAbandoned = 4
+
+	// minMergeConfidence is the confidence below which a result only says
+	// the analyzer did not recognize the file, so it is not merged
+	minMergeConfidence = 0.2
+)
+
+// linkedFields are metadata fields that describe another field, so they
+// are only taken from the analyzer that supplied it
+var linkedFields = map[string]string{
+	"version_source": "version",
+	"bundle_version": "version",
+}
+
+// Clock supplies the time analysis results are stamped with
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock reads the system time
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
 // Manager orchestrates the file analysis process
 type Manager struct {
 	analyzers []Analyzer
+	timeout   time.Duration
+	weights   ConfidenceWeights
+	clock     Clock
+	abandoned chan struct{} // Semaphore held by each analyzer still running after its deadline
+}
+
+// ManagerOption configures optional Manager behaviour
+type ManagerOption func(*Manager)
+
+// WithTimeout bounds how long all analyzers may spend on one file. Zero
+// disables the timeout.
+func WithTimeout(timeout time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.timeout = timeout
+	}
+}
+
+// WithMaxAbandoned bounds how many analyzers that outlived their deadline
+// may keep running in the background. Analyzers cannot be interrupted, so
+// once the limit is reached Analyze waits for one of them to finish before
+// abandoning another. Sizing it to the number of workers calling Analyze
+// keeps a file that hangs an analyzer from piling up goroutines.
+func WithMaxAbandoned(n int) ManagerOption {
+	return func(m *Manager) {
+		m.abandoned = make(chan struct{}, max(n, 1))
+	}
+}
+
+// WithConfidenceWeights sets the weights used to score analyzer results
+func WithConfidenceWeights(weights ConfidenceWeights) ManagerOption {
+	return func(m *Manager) {
+		m.weights = weights
+	}
+}
+
+// WithClock sets the clock analysis results are stamped with, so that
+// analysis can be reproduced exactly
+func WithClock(clock Clock) ManagerOption {
+	return func(m *Manager) {
+		m.clock = clock
+	}
+}
+
+// WithPlugins registers out-of-process analyzer plugins. They come before
+// the built-in analyzers, so they win ties with them.
+func WithPlugins(plugins ...Plugin) ManagerOption {
+	return func(m *Manager) {
+		analyzers := make([]Analyzer, 0, len(plugins)+len(m.analyzers))
+		for _, plugin := range plugins {
+			analyzers = append(analyzers, NewPluginAnalyzer(plugin))
+		}
+		m.analyzers = append(analyzers, m.analyzers...)
+	}
 }
 
 // NewManager creates a new analyzer manager with all available analyzers
-func NewManager() *Manager {
+func NewManager(opts ...ManagerOption) *Manager {
 	m := &Manager{
 		analyzers: make([]Analyzer, 0),
+		timeout:   DefaultTimeout,
+		weights:   DefaultConfidenceWeights(),
+		clock:     systemClock{},
+		abandoned: make(chan struct{}, DefaultMaxAbandoned),
 	}
 
 	// Register all analyzers - specialized analyzers first
@@ -47,12 +177,17 @@ func NewManager() *Manager {
 	// Then register general analyzers
 	m.RegisterAnalyzer(&PEAnalyzer{})
 	m.RegisterAnalyzer(&MacOSAnalyzer{})
+	m.RegisterAnalyzer(&MSIXAnalyzer{})
 	m.RegisterAnalyzer(&ZipAnalyzer{})
 	m.RegisterAnalyzer(&LinuxAnalyzer{})
 
 	// ContentAnalyzer is the fallback
 	m.RegisterAnalyzer(&ContentAnalyzer{})
 
+	for _, opt := range opts {
+		opt(m)
+	}
+
 	return m
 }
 
@@ -61,152 +196,389 @@ func (m *Manager) RegisterAnalyzer(analyzer Analyzer) {
 	m.analyzers = append(m.analyzers, analyzer)
 }
 
-// Analyze performs analysis on a file using all registered analyzers
-func (m *Manager) Analyze(filePath string, contentType string) (*Result, error) {
+// Analyze runs every applicable analyzer on a file concurrently and merges
+// their results field by field, preferring the most confident analyzer for
+// each field. Analyzers still running when ctx is done or the timeout
+// expires are abandoned, up to the WithMaxAbandoned limit.
+func (m *Manager) Analyze(ctx context.Context, filePath string, contentType string) (*Result, error) {
 	logger.Debugf("Analyzing file: %s", filePath)
+	return m.analyze(ctx, filePath, contentType, false)
+}
 
-	// Default result with low confidence
-	defaultResult := &Result{
-		FileType:    "unknown",
-		Platform:    "unknown",
-		Confidence:  0.1,
-		IsInstaller: false,
-		Metadata:    make(map[string]interface{}),
-		AnalyzedAt:  time.Now(),
+// AnalyzeNestedFile is a helper method to analyze a file within a container
+func (m *Manager) AnalyzeNestedFile(ctx context.Context, filePath string, contentType string) (*Result, error) {
+	// Use a different log prefix for nested analysis
+	logger.Debugf("Analyzing nested file: %s", filePath)
+	return m.analyze(ctx, filePath, contentType, true)
+}
+
+func (m *Manager) analyze(ctx context.Context, filePath string, contentType string, nested bool) (*Result, error) {
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
 	}
 
 	// Find analyzers that can handle this file
 	var applicableAnalyzers []Analyzer
 	for _, analyzer := range m.analyzers {
-		if analyzer.CanHandle(filePath, contentType) {
+		// Skip the package analyzer for nested files to prevent recursion
+		if _, isPackageAnalyzer := analyzer.(*ZipAnalyzer); nested && isPackageAnalyzer {
+			continue
+		}
+		if canHandle(ctx, analyzer, filePath, contentType) {
 			applicableAnalyzers = append(applicableAnalyzers, analyzer)
 		}
 	}
 
 	if len(applicableAnalyzers) == 0 {
 		logger.Debugf("No applicable analyzers found for file: %s", filePath)
-		return defaultResult, nil
+		return defaultResult(m.clock.Now()), nil
 	}
 
-	// Run all applicable analyzers and select the result with highest confidence
-	var bestResult *Result
-	var bestConfidence float64
-
-	for _, analyzer := range applicableAnalyzers {
-		result, err := analyzer.Analyze(filePath)
-		if err != nil {
-			logger.Debugf("Analyzer error: %v", err)
-			continue
+	results, runs := m.runAnalyzers(ctx, applicableAnalyzers, filePath)
+	analyzedAt := m.clock.Now()
+	for _, r := range results {
+		r.result.AnalyzedAt = analyzedAt
+		if r.result.NestedResult != nil {
+			r.result.NestedResult.AnalyzedAt = analyzedAt
 		}
+	}
+	for _, run := range runs {
+		if run.Err != nil {
+			logger.Debugf("Analyzer %s failed on %s: %v", run.Analyzer, filePath, run.Err)
+		}
+	}
 
-		if result != nil {
-			// Handle nested results - give preference to container files with confirmed installers inside
-			if result.NestedResult != nil && result.NestedResult.IsInstaller {
-				// Boost confidence for archives that contain confirmed installers
-				adjustedConfidence := result.Confidence + 0.1
-				if adjustedConfidence > 1.0 {
-					adjustedConfidence = 1.0
-				}
-
-				// If this is a container with a high-confidence installer inside, prefer it
-				if adjustedConfidence > bestConfidence {
-					result.Confidence = adjustedConfidence
-					bestResult = result
-					bestConfidence = adjustedConfidence
-				}
-			} else if result.Confidence > bestConfidence {
-				// Standard confidence comparison
-				bestResult = result
-				bestConfidence = result.Confidence
-			}
+	bestResult := mergeResults(results, m.weights)
+	if bestResult == nil {
+		result := defaultResult(analyzedAt)
+		result.Runs = runs
+		if err := ctx.Err(); err != nil {
+			return result, fmt.Errorf("analysis of %s stopped: %w", filePath, err)
 		}
+		return result, errors.New("no successful analysis")
+	}
+	bestResult.Runs = runs
+	bestResult.AnalyzedAt = analyzedAt
+
+	// Log the result and any nested results
+	if bestResult.NestedResult != nil {
+		logger.Debugf("Best analysis result for %s: type=%s, platform=%s, confidence=%.2f (contains: %s/%s)",
+			filePath, bestResult.FileType, bestResult.Platform, bestResult.Confidence,
+			bestResult.NestedResult.FileType, bestResult.NestedResult.Platform)
+	} else {
+		logger.Debugf("Best analysis result for %s: type=%s, platform=%s, confidence=%.2f",
+			filePath, bestResult.FileType, bestResult.Platform, bestResult.Confidence)
+	}
+	if len(bestResult.Conflicts) > 0 {
+		logger.Debugf("Analyzers disagreed on %d fields of %s", len(bestResult.Conflicts), filePath)
 	}
 
-	if bestResult != nil {
-		// Log the result and any nested results
-		if bestResult.NestedResult != nil {
-			logger.Debugf("Best analysis result for %s: type=%s, platform=%s, confidence=%.2f (contains: %s/%s)",
-				filePath, bestResult.FileType, bestResult.Platform, bestResult.Confidence,
-				bestResult.NestedResult.FileType, bestResult.NestedResult.Platform)
-		} else {
-			logger.Debugf("Best analysis result for %s: type=%s, platform=%s, confidence=%.2f",
-				filePath, bestResult.FileType, bestResult.Platform, bestResult.Confidence)
+	// Add installer metadata shortcuts to top level (for backward compatibility)
+	if installerName, ok := bestResult.Metadata["name"]; ok {
+		if _, exists := bestResult.Metadata["product_name"]; !exists {
+			bestResult.Metadata["product_name"] = installerName
+			bestResult.Provenance["product_name"] = bestResult.Provenance["name"]
 		}
+	}
 
-		// Add installer metadata shortcuts to top level (for backward compatibility)
-		if installerName, ok := bestResult.Metadata["name"]; ok {
-			if _, exists := bestResult.Metadata["product_name"]; !exists {
-				bestResult.Metadata["product_name"] = installerName
-			}
+	if installerVersion, ok := bestResult.Metadata["version"]; ok {
+		if _, exists := bestResult.Metadata["product_version"]; !exists {
+			bestResult.Metadata["product_version"] = installerVersion
+			bestResult.Provenance["product_version"] = bestResult.Provenance["version"]
 		}
+	}
 
-		if installerVersion, ok := bestResult.Metadata["version"]; ok {
-			if _, exists := bestResult.Metadata["product_version"]; !exists {
-				bestResult.Metadata["product_version"] = installerVersion
+	return bestResult, nil
+}
+
+// namedResult is a successful analyzer result
+type namedResult struct {
+	analyzer string
+	result   *Result
+}
+
+// runAnalyzers runs analyzers concurrently and scores their results,
+// returning them in registration order together with a record of every run
+func (m *Manager) runAnalyzers(ctx context.Context, analyzers []Analyzer, filePath string) ([]namedResult, []AnalyzerRun) {
+	results := make([]*Result, len(analyzers))
+	runs := make([]AnalyzerRun, len(analyzers))
+
+	var wg sync.WaitGroup
+	for i, analyzer := range analyzers {
+		wg.Add(1)
+		go func(i int, analyzer Analyzer) {
+			defer wg.Done()
+			start := time.Now()
+			results[i], runs[i].Err = m.runAnalyzer(ctx, analyzer, filePath)
+			runs[i].Analyzer = analyzer.Name()
+			runs[i].Duration = time.Since(start)
+			if results[i] != nil && runs[i].Err == nil {
+				m.score(filePath, results[i])
+				runs[i].Confidence = results[i].Confidence
 			}
-		}
+		}(i, analyzer)
+	}
+	wg.Wait()
 
-		return bestResult, nil
+	var named []namedResult
+	for i, result := range results {
+		if result != nil && runs[i].Err == nil {
+			named = append(named, namedResult{analyzer: runs[i].Analyzer, result: result})
+		}
 	}
+	return named, runs
+}
 
-	return defaultResult, fmt.Errorf("no successful analysis")
+// score computes a result's confidence from the factors its analyzer
+// verified. Results without factors keep the analyzer's own confidence.
+func (m *Manager) score(filePath string, result *Result) {
+	if result.Factors == nil {
+		return
+	}
+	result.Factors.checkResult(filePath, result)
+	result.Confidence, result.ScoreFactors = CalculateConfidence(*result.Factors, m.weights)
 }
 
-// AnalyzeNestedFile is a helper method to analyze a file within a container
-func (m *Manager) AnalyzeNestedFile(filePath string, contentType string) (*Result, error) {
-	// Use a different log prefix for nested analysis
-	logger.Debugf("Analyzing nested file: %s", filePath)
+// runAnalyzer runs one analyzer, giving up when ctx is done. Analyzers
+// parse untrusted files, so a panic is returned as an error. A
+// ContextAnalyzer is passed ctx and waited for, since it stops on its own.
+//
+// An analyzer that is given up on keeps running until it returns, holding
+// a slot of the abandoned semaphore; when every slot is taken, runAnalyzer
+// waits for a slot or for the analyzer to finish.
+func (m *Manager) runAnalyzer(ctx context.Context, analyzer Analyzer, filePath string) (*Result, error) {
+	type outcome struct {
+		result *Result
+		err    error
+	}
+	done := make(chan outcome, 1)
 
-	// Run analysis with all analyzers except the one for the container type
-	// to avoid potential infinite recursion
-	var applicableAnalyzers []Analyzer
-	for _, analyzer := range m.analyzers {
-		// Skip the package analyzer to prevent recursion
-		if _, isPackageAnalyzer := analyzer.(*ZipAnalyzer); !isPackageAnalyzer {
-			if analyzer.CanHandle(filePath, contentType) {
-				applicableAnalyzers = append(applicableAnalyzers, analyzer)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("analyzer %s panicked: %v", analyzer.Name(), r)}
 			}
+		}()
+		var o outcome
+		if a, ok := analyzer.(ContextAnalyzer); ok {
+			o.result, o.err = a.AnalyzeContext(ctx, filePath)
+		} else {
+			o.result, o.err = analyzer.Analyze(filePath)
+		}
+		done <- o
+	}()
+
+	_, contextAware := analyzer.(ContextAnalyzer)
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-ctx.Done():
+		if contextAware {
+			o := <-done
+			return o.result, o.err
 		}
 	}
 
-	// If no applicable analyzers, return a default result
-	if len(applicableAnalyzers) == 0 {
-		return &Result{
-			FileType:    "unknown",
-			Platform:    "unknown",
-			Confidence:  0.1,
-			IsInstaller: false,
-			Metadata:    make(map[string]interface{}),
-			AnalyzedAt:  time.Now(),
-		}, nil
-	}
-
-	// Find the best result
-	var bestResult *Result
-	var bestConfidence float64
-
-	for _, analyzer := range applicableAnalyzers {
-		result, err := analyzer.Analyze(filePath)
-		if err != nil {
+	select {
+	case m.abandoned <- struct{}{}:
+		logger.Debugf("Abandoning analyzer %s on %s: %v", analyzer.Name(), filePath, ctx.Err())
+		go func() {
+			<-done
+			<-m.abandoned
+		}()
+	case <-done:
+	}
+	return nil, ctx.Err()
+}
+
+// canHandle asks an analyzer whether it handles a file, passing ctx to
+// analyzers that take one
+func canHandle(ctx context.Context, analyzer Analyzer, filePath string, contentType string) bool {
+	if a, ok := analyzer.(ContextAnalyzer); ok {
+		return a.CanHandleContext(ctx, filePath, contentType)
+	}
+	return analyzer.CanHandle(filePath, contentType)
+}
+
+// mergeResults combines analyzer results field by field. The most
+// confident result supplies the file type, platform and installer verdict
+// (falling back to the next result for an unknown type or platform); each
+// metadata field comes from the most confident result that has it, and
+// other results' differing values are kept as conflicts. It returns nil if
+// no result recognized the file.
+func mergeResults(results []namedResult, weights ConfidenceWeights) *Result {
+	var candidates []namedResult
+	var confidences []float64
+	for _, r := range results {
+		if r.result.Confidence < minMergeConfidence {
 			continue
 		}
+		// Prefer containers with confirmed installers inside
+		confidence := r.result.Confidence
+		if hasNestedInstaller(r.result) {
+			confidence = clampConfidence(confidence + weights[FactorNestedInstaller])
+		}
+		candidates = append(candidates, r)
+		confidences = append(confidences, confidence)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// Most confident first; ties keep registration order, so specialized
+	// analyzers win
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return confidences[order[a]] > confidences[order[b]]
+	})
+
+	primary := candidates[order[0]]
+	source := func(i int) types.FieldSource {
+		return types.FieldSource{Analyzer: candidates[i].analyzer, Confidence: confidences[i]}
+	}
+	merged := &Result{
+		FileType:     primary.result.FileType,
+		Platform:     primary.result.Platform,
+		Confidence:   confidences[order[0]],
+		IsInstaller:  primary.result.IsInstaller,
+		Metadata:     make(map[string]interface{}),
+		NestedResult: primary.result.NestedResult,
+		ScoreFactors: scoreFactors(primary.result, weights),
+		Provenance: map[string]types.FieldSource{
+			"file_type":    source(order[0]),
+			"platform":     source(order[0]),
+			"is_installer": source(order[0]),
+		},
+	}
+
+	for _, i := range order {
+		r := candidates[i].result
+		if isUnknown(merged.FileType) && !isUnknown(r.FileType) {
+			merged.FileType = r.FileType
+			merged.Provenance["file_type"] = source(i)
+		}
+		if isUnknown(merged.Platform) && !isUnknown(r.Platform) {
+			merged.Platform = r.Platform
+			merged.Provenance["platform"] = source(i)
+		}
+	}
+
+	conflicts := make(map[string]*types.FieldConflict)
+	var conflictOrder []string
+	// Fields describing another field are merged once it has been, so
+	// they follow the analyzer that supplied it
+	for _, linked := range []bool{false, true} {
+		for _, i := range order {
+			for _, key := range sortedKeys(candidates[i].result.Metadata) {
+				parent, isLinked := linkedFields[key]
+				if isLinked != linked {
+					continue
+				}
+				value := candidates[i].result.Metadata[key]
+				if isEmptyValue(value) {
+					continue
+				}
+				if isLinked && merged.Provenance[parent].Analyzer != candidates[i].analyzer {
+					continue
+				}
 
-		if result != nil && result.Confidence > bestConfidence {
-			bestResult = result
-			bestConfidence = result.Confidence
+				existing, found := merged.Metadata[key]
+				if !found {
+					merged.Metadata[key] = value
+					merged.Provenance[key] = source(i)
+					continue
+				}
+				if sameValue(existing, value) {
+					continue
+				}
+
+				c, ok := conflicts[key]
+				if !ok {
+					c = &types.FieldConflict{
+						Field:  key,
+						Values: []types.FieldValue{{Value: existing, FieldSource: merged.Provenance[key]}},
+					}
+					conflicts[key] = c
+					conflictOrder = append(conflictOrder, key)
+				}
+				c.Values = append(c.Values, types.FieldValue{Value: value, FieldSource: source(i)})
+			}
 		}
 	}
+	for _, key := range conflictOrder {
+		merged.Conflicts = append(merged.Conflicts, *conflicts[key])
+	}
+
+	return merged
+}
 
-	if bestResult != nil {
-		return bestResult, nil
+// hasNestedInstaller reports whether a container result found an installer
+// inside it
+func hasNestedInstaller(result *Result) bool {
+	return result.NestedResult != nil && result.NestedResult.IsInstaller
+}
+
+// scoreFactors returns the factor contributions behind a merged result's
+// confidence, including the boost for a nested installer
+func scoreFactors(result *Result, weights ConfidenceWeights) map[string]float64 {
+	if result.ScoreFactors == nil {
+		return nil
+	}
+	factors := make(map[string]float64, len(result.ScoreFactors)+1)
+	for name, weight := range result.ScoreFactors {
+		factors[name] = weight
+	}
+	if hasNestedInstaller(result) && weights[FactorNestedInstaller] != 0 {
+		factors[FactorNestedInstaller] = weights[FactorNestedInstaller]
 	}
+	return factors
+}
 
+func defaultResult(analyzedAt time.Time) *Result {
 	return &Result{
 		FileType:    "unknown",
 		Platform:    "unknown",
 		Confidence:  0.1,
 		IsInstaller: false,
 		Metadata:    make(map[string]interface{}),
-		AnalyzedAt:  time.Now(),
-	}, fmt.Errorf("no successful nested analysis")
+		AnalyzedAt:  analyzedAt,
+	}
+}
+
+func isUnknown(s string) bool {
+	return s == "" || s == "unknown"
+}
+
+// isEmptyValue reports whether a metadata value carries no information
+func isEmptyValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []string:
+		return len(v) == 0
+	case []byte:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
+
+func sameValue(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b) || fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
@@ -0,0 +1,65 @@
+package fileanalyzer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingAnalyzer handles every file and blocks until released
+type blockingAnalyzer struct {
+	release chan struct{}
+	running atomic.Int32
+}
+
+func (a *blockingAnalyzer) Name() string { return "blocking" }
+
+func (a *blockingAnalyzer) CanHandle(string, string) bool { return true }
+
+func (a *blockingAnalyzer) Analyze(string) (*Result, error) {
+	a.running.Add(1)
+	defer a.running.Add(-1)
+	<-a.release
+	return &Result{FileType: "exe", Confidence: 1}, nil
+}
+
+func TestManagerBoundsAbandonedAnalyzers(t *testing.T) {
+	analyzer := &blockingAnalyzer{release: make(chan struct{})}
+	m := &Manager{timeout: 10 * time.Millisecond, weights: DefaultConfidenceWeights(), clock: systemClock{}}
+	WithMaxAbandoned(1)(m)
+	m.RegisterAnalyzer(analyzer)
+
+	if _, err := m.Analyze(context.Background(), "stuck.exe", ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Analyze() error = %v, want a deadline error", err)
+	}
+
+	// The only slot is taken, so the next file waits for the first
+	// analyzer instead of abandoning a second one
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Analyze(context.Background(), "stuck-too.exe", "")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Analyze() returned %v with no abandoned slot free", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if n := analyzer.running.Load(); n != 2 {
+		t.Errorf("%d analyzers running, want 2", n)
+	}
+
+	close(analyzer.release)
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("second Analyze() error = %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for (analyzer.running.Load() != 0 || len(m.abandoned) != 0) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := analyzer.running.Load(); n != 0 || len(m.abandoned) != 0 {
+		t.Errorf("%d analyzers running and %d slots held after release", n, len(m.abandoned))
+	}
+}
@@ -0,0 +1,83 @@
+package fileanalyzer
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+
+	"github.com/deploymenttheory/go-app-index/internal/arch"
+)
+
+// executableArchitecture reads the CPU architecture from the header of a
+// PE, ELF or Mach-O executable, or returns "" if the file is none of these
+func executableArchitecture(filePath string) string {
+	if f, err := pe.Open(filePath); err == nil {
+		defer f.Close()
+		return peArchitecture(f.Machine)
+	}
+	if f, err := elf.Open(filePath); err == nil {
+		defer f.Close()
+		return elfArchitecture(f.Machine)
+	}
+	return machoArchitecture(filePath)
+}
+
+// elfArchitecture maps an ELF machine type to a normalized architecture
+func elfArchitecture(machine elf.Machine) string {
+	switch machine {
+	case elf.EM_386:
+		return arch.X86
+	case elf.EM_X86_64:
+		return arch.X64
+	case elf.EM_AARCH64:
+		return arch.ARM64
+	case elf.EM_ARM:
+		return arch.ARM
+	default:
+		return ""
+	}
+}
+
+// machoArchitecture reads the architecture of a Mach-O executable. A fat
+// (universal) binary reports each slice, so x86_64 plus arm64 is universal.
+func machoArchitecture(filePath string) string {
+	if fat, err := macho.OpenFat(filePath); err == nil {
+		defer fat.Close()
+		names := make([]string, 0, len(fat.Arches))
+		for _, a := range fat.Arches {
+			names = append(names, machoCPU(a.Cpu))
+		}
+		return arch.Combine(names...)
+	}
+	if f, err := macho.Open(filePath); err == nil {
+		defer f.Close()
+		return machoCPU(f.Cpu)
+	}
+	return ""
+}
+
+// machoCPU maps a Mach-O CPU type to a normalized architecture
+func machoCPU(cpu macho.Cpu) string {
+	switch cpu {
+	case macho.Cpu386:
+		return arch.X86
+	case macho.CpuAmd64:
+		return arch.X64
+	case macho.CpuArm64:
+		return arch.ARM64
+	case macho.CpuArm:
+		return arch.ARM
+	default:
+		return ""
+	}
+}
+
+// isFatMachO tells a universal Mach-O header from a Java class file, which
+// share the magic 0xCAFEBABE: a fat header is followed by a small slice
+// count, a class file by its version, which is at least 45
+func isFatMachO(data []byte) bool {
+	return len(data) >= 8 &&
+		binary.BigEndian.Uint32(data) == macho.MagicFat &&
+		binary.BigEndian.Uint32(data[4:]) < 45
+}
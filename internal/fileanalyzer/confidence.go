@@ -1,70 +1,154 @@
 package fileanalyzer
 
 import (
+	"bytes"
+	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-// ConfidenceFactors stores data used to calculate confidence scores
-type ConfidenceFactors struct {
-	// Base confidence from primary detection method
-	BaseConfidence float64
+// Confidence factor names, used to configure weights and to explain scores
+const (
+	FactorBase             = "base"
+	FactorMagicMatch       = "magic_match"
+	FactorExtensionMatch   = "extension_match"
+	FactorParsedStructure  = "parsed_structure"
+	FactorSignaturePresent = "signature_present"
+	FactorVersionFound     = "version_found"
+	FactorSizeInRange      = "size_in_range"
+	FactorTooSmall         = "too_small"
+	FactorMalformed        = "malformed_structure"
+	FactorNestedInstaller  = "nested_installer"
+)
+
+// renamedFactors maps former factor names to their current ones. The
+// signature factor was renamed because analyzers only see that a
+// signature is present; none of them verify its chain.
+var renamedFactors = map[string]string{
+	"valid_signature": FactorSignaturePresent,
+}
+
+// factorName resolves a factor name that may have been renamed
+func factorName(name string) string {
+	if renamed, ok := renamedFactors[name]; ok {
+		return renamed
+	}
+	return name
+}
 
-	// Additional factors that can increase confidence
-	ExtensionMatch        bool // If detected type matches file extension
-	ContentMatch          bool // If content analysis confirms type
-	ValidSignature        bool // If file has a valid digital signature
-	ContainsInstallerText bool // If file contains installer-related text
-	HasVersionInfo        bool // If version information was extracted
+// ConfidenceFactors records what an analyzer verified about a file
+type ConfidenceFactors struct {
+	// Factors that increase confidence
+	MagicMatch       bool // File starts with the format's magic bytes
+	ExtensionMatch   bool // Detected type matches the file extension
+	ParsedStructure  bool // The format's structure was parsed successfully
+	SignaturePresent bool // File carries a digital signature, which is not verified
+	VersionFound     bool // Version information was extracted
+	SizeInRange      bool // File size is in the expected range for its type
 
-	// Penalty factors that can decrease confidence
+	// Factors that decrease confidence
 	TooSmall           bool // File is too small to be an installer
-	UnexpectedContent  bool // Content doesn't match expected format
-	MalformedStructure bool // File structure is not as expected
+	MalformedStructure bool // Magic bytes matched but the structure could not be parsed
 }
 
-// CalculateConfidence computes a confidence score based on various factors
-func CalculateConfidence(factors ConfidenceFactors) float64 {
-	confidence := factors.BaseConfidence
+// ConfidenceWeights maps factor names to the score each adds when present.
+// Penalties have negative weights.
+type ConfidenceWeights map[string]float64
 
-	// Apply bonuses for positive indicators
-	if factors.ExtensionMatch {
-		confidence += 0.1
-	}
-	if factors.ContentMatch {
-		confidence += 0.15
-	}
-	if factors.ValidSignature {
-		confidence += 0.2
+// DefaultConfidenceWeights returns the built-in weights. A fully parsed
+// installer with a matching extension and version scores 0.95; one that
+// also carries a signature scores 1.0.
+func DefaultConfidenceWeights() ConfidenceWeights {
+	return ConfidenceWeights{
+		FactorBase:             0.3,
+		FactorMagicMatch:       0.2,
+		FactorExtensionMatch:   0.1,
+		FactorParsedStructure:  0.25,
+		FactorSignaturePresent: 0.1,
+		FactorVersionFound:     0.05,
+		FactorSizeInRange:      0.05,
+		FactorTooSmall:         -0.3,
+		FactorMalformed:        -0.2,
+		FactorNestedInstaller:  0.1,
 	}
-	if factors.ContainsInstallerText {
-		confidence += 0.1
+}
+
+// WithOverrides returns a copy of the weights with overrides applied.
+// Factors may be given by a former name.
+func (w ConfidenceWeights) WithOverrides(overrides map[string]float64) (ConfidenceWeights, error) {
+	weights := make(ConfidenceWeights, len(w))
+	for name, weight := range w {
+		weights[name] = weight
 	}
-	if factors.HasVersionInfo {
-		confidence += 0.05
+	for name, weight := range overrides {
+		name = factorName(name)
+		if _, ok := weights[name]; !ok {
+			return nil, fmt.Errorf("unknown confidence factor %q", name)
+		}
+		weights[name] = weight
 	}
+	return weights, nil
+}
 
-	// Apply penalties for negative indicators
-	if factors.TooSmall {
-		confidence -= 0.3
-	}
-	if factors.UnexpectedContent {
-		confidence -= 0.2
+// CalculateConfidence computes a confidence score from the factors present,
+// together with each factor's contribution to it
+func CalculateConfidence(factors ConfidenceFactors, weights ConfidenceWeights) (float64, map[string]float64) {
+	// Summed in a fixed order so the score never depends on map iteration
+	present := []struct {
+		name string
+		ok   bool
+	}{
+		{FactorBase, true},
+		{FactorMagicMatch, factors.MagicMatch},
+		{FactorExtensionMatch, factors.ExtensionMatch},
+		{FactorParsedStructure, factors.ParsedStructure},
+		{FactorSignaturePresent, factors.SignaturePresent},
+		{FactorVersionFound, factors.VersionFound},
+		{FactorSizeInRange, factors.SizeInRange},
+		{FactorTooSmall, factors.TooSmall},
+		{FactorMalformed, factors.MalformedStructure},
 	}
-	if factors.MalformedStructure {
-		confidence -= 0.2
+
+	confidence := 0.0
+	contributions := make(map[string]float64)
+	for _, factor := range present {
+		if factor.ok && weights[factor.name] != 0 {
+			confidence += weights[factor.name]
+			contributions[factor.name] = weights[factor.name]
+		}
 	}
 
-	// Ensure confidence stays within valid range
-	if confidence > 1.0 {
-		confidence = 1.0
+	return clampConfidence(confidence), contributions
+}
+
+// checkResult fills the factors any analyzer's result can be checked for:
+// the file's extension and size against the detected type, and whether a
+// version and signature were found
+func (f *ConfidenceFactors) checkResult(filePath string, result *Result) {
+	f.ExtensionMatch = IsExtensionMatch(filePath, result.FileType)
+	f.TooSmall = !CheckMinimumSize(filePath, result.FileType)
+	f.SizeInRange = IsExpectedSize(filePath, result.FileType)
+
+	if v, ok := result.Metadata["version"].(string); ok && v != "" {
+		f.VersionFound = true
 	}
-	if confidence < 0.0 {
-		confidence = 0.0
+	if signed, ok := result.Metadata["is_signed"].(bool); ok && signed {
+		f.SignaturePresent = true
 	}
+}
 
-	return confidence
+// hasMagic reports whether a file starts with the given magic bytes
+func hasMagic(filePath string, magic []byte) bool {
+	sample, err := readFileSample(filePath, len(magic))
+	return err == nil && bytes.Equal(sample, magic)
+}
+
+// clampConfidence keeps a confidence score within 0.0-1.0, rounded so sums
+// of weights do not carry floating point noise
+func clampConfidence(confidence float64) float64 {
+	return math.Max(0, math.Min(1, math.Round(confidence*1e4)/1e4))
 }
 
 // IsExtensionMatch checks if the detected file type matches the file extension
@@ -91,6 +175,7 @@ func IsExtensionMatch(filePath string, detectedType string) bool {
 		"deb":      {"deb"},
 		"rpm":      {"rpm"},
 		"appimage": {"appimage"},
+		"pe/exe":   {"exe", "dll"},
 		"zip":      {"zip", "jar"},
 		"jar":      {"jar", "zip"},
 		"apk":      {"apk"},
@@ -116,6 +201,11 @@ func CheckMinimumSize(filePath string, fileType string) bool {
 		return false
 	}
 
+	// Bundles such as .app are directories
+	if fileInfo.IsDir() {
+		return true
+	}
+
 	size := fileInfo.Size()
 
 	// Minimum sizes for different file types (in bytes)
@@ -141,63 +231,29 @@ func CheckMinimumSize(filePath string, fileType string) bool {
 	return size >= 10*1024
 }
 
-// AdjustConfidenceBySize adjusts confidence based on file size
-func AdjustConfidenceBySize(filePath string, fileType string, baseConfidence float64) float64 {
-	// Get file size
+// IsExpectedSize checks if a file's size is within the expected range for
+// its type. Types without an expected range never match.
+func IsExpectedSize(filePath string, fileType string) bool {
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
-		return baseConfidence
+		return false
 	}
 
 	size := fileInfo.Size()
 
-	// Expected size ranges for different file types
-	// Maps file type to (min size, lower typical, upper typical)
-	sizeRanges := map[string][3]int64{
-		"exe":      {50 * 1024, 1 * 1024 * 1024, 100 * 1024 * 1024},             // 50KB to 100MB
-		"msi":      {100 * 1024, 5 * 1024 * 1024, 500 * 1024 * 1024},            // 100KB to 500MB
-		"dmg":      {1 * 1024 * 1024, 10 * 1024 * 1024, 2 * 1024 * 1024 * 1024}, // 1MB to 2GB
-		"pkg":      {500 * 1024, 5 * 1024 * 1024, 1 * 1024 * 1024 * 1024},       // 500KB to 1GB
-		"deb":      {10 * 1024, 1 * 1024 * 1024, 500 * 1024 * 1024},             // 10KB to 500MB
-		"rpm":      {10 * 1024, 1 * 1024 * 1024, 500 * 1024 * 1024},             // 10KB to 500MB
-		"appimage": {1 * 1024 * 1024, 10 * 1024 * 1024, 1 * 1024 * 1024 * 1024}, // 1MB to 1GB
-	}
-
-	// If we have size expectations for this file type
-	if ranges, ok := sizeRanges[fileType]; ok {
-		minSize := ranges[0]
-		lowerTypical := ranges[1]
-		upperTypical := ranges[2]
-
-		// Too small: significant confidence reduction
-		if size < minSize {
-			return baseConfidence * 0.5
-		}
-
-		// Within typical range: small confidence boost
-		if size >= lowerTypical && size <= upperTypical {
-			bonus := baseConfidence * 0.1
-			if baseConfidence+bonus > 1.0 {
-				return 1.0
-			}
-			return baseConfidence + bonus
-		}
-
-		// Slightly below typical: no change
-		if size >= minSize && size < lowerTypical {
-			return baseConfidence
-		}
-
-		// Above typical but not outrageous: small penalty
-		if size > upperTypical && size <= upperTypical*10 {
-			return baseConfidence * 0.9
-		}
-
-		// Extremely large: bigger penalty
-		if size > upperTypical*10 {
-			return baseConfidence * 0.7
-		}
+	// Expected size ranges for different file types, as (min, max)
+	sizeRanges := map[string][2]int64{
+		"exe":        {50 * 1024, 1024 * 1024 * 1024},            // 50KB to 1GB
+		"msi":        {100 * 1024, 5 * 1024 * 1024 * 1024},       // 100KB to 5GB
+		"msix":       {100 * 1024, 5 * 1024 * 1024 * 1024},       // 100KB to 5GB
+		"msixbundle": {100 * 1024, 10 * 1024 * 1024 * 1024},      // 100KB to 10GB
+		"dmg":        {1 * 1024 * 1024, 20 * 1024 * 1024 * 1024}, // 1MB to 20GB
+		"pkg":        {500 * 1024, 10 * 1024 * 1024 * 1024},      // 500KB to 10GB
+		"deb":        {10 * 1024, 5 * 1024 * 1024 * 1024},        // 10KB to 5GB
+		"rpm":        {10 * 1024, 5 * 1024 * 1024 * 1024},        // 10KB to 5GB
+		"appimage":   {1 * 1024 * 1024, 10 * 1024 * 1024 * 1024}, // 1MB to 10GB
 	}
 
-	return baseConfidence
+	ranges, ok := sizeRanges[fileType]
+	return ok && size >= ranges[0] && size <= ranges[1]
 }
@@ -0,0 +1,116 @@
+package fileanalyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCalculateConfidence(t *testing.T) {
+	weights := DefaultConfidenceWeights()
+	parsed := ConfidenceFactors{MagicMatch: true, ExtensionMatch: true, ParsedStructure: true, VersionFound: true, SizeInRange: true}
+	signed := parsed
+	signed.SignaturePresent = true
+
+	tests := []struct {
+		name          string
+		factors       ConfidenceFactors
+		weights       ConfidenceWeights
+		want          float64
+		contributions map[string]float64
+	}{
+		{"base only", ConfidenceFactors{}, weights, 0.3, map[string]float64{FactorBase: 0.3}},
+		{"parsed installer", parsed, weights, 0.95, map[string]float64{
+			FactorBase: 0.3, FactorMagicMatch: 0.2, FactorExtensionMatch: 0.1, FactorParsedStructure: 0.25,
+			FactorVersionFound: 0.05, FactorSizeInRange: 0.05,
+		}},
+		{"signature present", signed, weights, 1, map[string]float64{
+			FactorBase: 0.3, FactorMagicMatch: 0.2, FactorExtensionMatch: 0.1, FactorParsedStructure: 0.25,
+			FactorSignaturePresent: 0.1, FactorVersionFound: 0.05, FactorSizeInRange: 0.05,
+		}},
+		{"penalties", ConfidenceFactors{MagicMatch: true, MalformedStructure: true}, weights, 0.3, map[string]float64{
+			FactorBase: 0.3, FactorMagicMatch: 0.2, FactorMalformed: -0.2,
+		}},
+		// Scores are clamped, but contributions are reported as weighted
+		{"clamped at zero", ConfidenceFactors{TooSmall: true, MalformedStructure: true}, weights, 0, map[string]float64{
+			FactorBase: 0.3, FactorTooSmall: -0.3, FactorMalformed: -0.2,
+		}},
+		{"clamped at one", signed, ConfidenceWeights{FactorBase: 0.9, FactorSignaturePresent: 0.5}, 1, map[string]float64{
+			FactorBase: 0.9, FactorSignaturePresent: 0.5,
+		}},
+		{"zero weights are left out", parsed, ConfidenceWeights{FactorBase: 0.5, FactorMagicMatch: 0}, 0.5, map[string]float64{
+			FactorBase: 0.5,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, contributions := CalculateConfidence(tt.factors, tt.weights)
+			if got != tt.want {
+				t.Errorf("CalculateConfidence() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(contributions, tt.contributions) {
+				t.Errorf("contributions = %v, want %v", contributions, tt.contributions)
+			}
+		})
+	}
+}
+
+func TestWithOverrides(t *testing.T) {
+	defaults := DefaultConfidenceWeights()
+
+	weights, err := defaults.WithOverrides(map[string]float64{FactorSizeInRange: 0, FactorMagicMatch: 0.4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weights[FactorSizeInRange] != 0 || weights[FactorMagicMatch] != 0.4 || weights[FactorBase] != 0.3 {
+		t.Errorf("WithOverrides() = %v", weights)
+	}
+	// The receiver is not modified
+	if !reflect.DeepEqual(defaults, DefaultConfidenceWeights()) {
+		t.Errorf("WithOverrides() changed the defaults to %v", defaults)
+	}
+
+	// The signature factor's former name still configures it
+	weights, err = defaults.WithOverrides(map[string]float64{"valid_signature": 0.2})
+	if err != nil || weights[FactorSignaturePresent] != 0.2 {
+		t.Errorf("WithOverrides(valid_signature) = %v, %v", weights[FactorSignaturePresent], err)
+	}
+	if _, ok := weights["valid_signature"]; ok {
+		t.Error("WithOverrides() added the former factor name")
+	}
+
+	if _, err := defaults.WithOverrides(map[string]float64{"signed": 0.2}); err == nil {
+		t.Error("WithOverrides() accepted an unknown factor")
+	}
+}
+
+func TestCheckResultSignature(t *testing.T) {
+	tests := []struct {
+		metadata map[string]interface{}
+		want     bool
+	}{
+		{map[string]interface{}{"is_signed": true}, true},
+		{map[string]interface{}{"is_signed": false}, false},
+		{map[string]interface{}{"is_signed": "true"}, false},
+		{map[string]interface{}{}, false},
+	}
+	for _, tt := range tests {
+		var factors ConfidenceFactors
+		factors.checkResult("setup.exe", &Result{FileType: "exe", Metadata: tt.metadata})
+		if factors.SignaturePresent != tt.want {
+			t.Errorf("checkResult(%v) SignaturePresent = %v, want %v", tt.metadata, factors.SignaturePresent, tt.want)
+		}
+	}
+}
+
+func TestPluginFactors(t *testing.T) {
+	factors, err := pluginFactors([]string{FactorMagicMatch, "valid_signature"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ConfidenceFactors{MagicMatch: true, SignaturePresent: true}); *factors != want {
+		t.Errorf("pluginFactors() = %+v, want %+v", *factors, want)
+	}
+	if _, err := pluginFactors([]string{"signed"}); err == nil {
+		t.Error("pluginFactors() accepted an unknown factor")
+	}
+}
@@ -9,10 +9,17 @@ import (
 	"strconv"
 	"strings"
 	"unicode/utf8"
+
+	"github.com/deploymenttheory/go-app-index/internal/version"
 )
 
 type ContentAnalyzer struct{}
 
+// Name identifies the analyzer in merged results
+func (a *ContentAnalyzer) Name() string {
+	return "content"
+}
+
 // CanHandle returns true as content analysis can handle any file
 func (a *ContentAnalyzer) CanHandle(filePath string, contentType string) bool {
 	return true
@@ -23,7 +30,7 @@ func (a *ContentAnalyzer) Analyze(filePath string) (*Result, error) {
 	metadata := make(map[string]interface{})
 
 	// Try to determine file format from content
-	fileFormat, confidence := detectFileFormat(filePath)
+	fileFormat, magicMatch := detectFileFormat(filePath)
 
 	// Check for executable bits
 	if detectExecutableBits(filePath) {
@@ -40,8 +47,9 @@ func (a *ContentAnalyzer) Analyze(filePath string) (*Result, error) {
 	// Try to extract version info
 	sample, err := readFileSample(filePath, 8192)
 	if err == nil {
-		if version := extractCommonVersionPattern(sample); version != "" {
-			metadata["version"] = version
+		if v, ok := version.FromText(sample); ok {
+			metadata["version"] = v.Raw
+			metadata["version_source"] = string(version.SourceContent)
 		}
 	}
 
@@ -55,14 +63,24 @@ func (a *ContentAnalyzer) Analyze(filePath string) (*Result, error) {
 	case "macho":
 		platform = "macos"
 	}
+	if platform != "unknown" {
+		if architecture := executableArchitecture(filePath); architecture != "" {
+			metadata["architecture"] = architecture
+		}
+	}
+
+	// A file that could not be read has nothing to score
+	var factors *ConfidenceFactors
+	if fileFormat != "unknown" {
+		factors = &ConfidenceFactors{MagicMatch: magicMatch}
+	}
 
 	return &Result{
 		FileType:    fileFormat,
 		Platform:    platform,
-		Confidence:  confidence,
 		IsInstaller: hasInstallerStrings,
 		Metadata:    metadata,
-		AnalyzedAt:  timeNow(),
+		Factors:     factors,
 	}, nil
 }
 
@@ -120,25 +138,6 @@ func isTextFile(filePath string) (bool, error) {
 	return utf8.Valid(sample), nil
 }
 
-// extractCommonVersionPattern extracts a version string from content
-func extractCommonVersionPattern(content []byte) string {
-	// Common version patterns
-	versionPatterns := []*regexp.Regexp{
-		regexp.MustCompile(`(?i)version[\s="':]+([0-9]+\.[0-9]+(\.[0-9]+)?)`),
-		regexp.MustCompile(`(?i)v([0-9]+\.[0-9]+(\.[0-9]+)?)`),
-		regexp.MustCompile(`(?i)([0-9]+\.[0-9]+\.[0-9]+)\b`),
-	}
-
-	for _, pattern := range versionPatterns {
-		matches := pattern.FindSubmatch(content)
-		if len(matches) > 1 {
-			return string(matches[1])
-		}
-	}
-
-	return ""
-}
-
 // searchForStringsInFile scans a file for specific string patterns
 func searchForStringsInFile(filePath string, patterns []*regexp.Regexp) map[string]string {
 	results := make(map[string]string)
@@ -248,45 +247,54 @@ func detectExecutableBits(filePath string) bool {
 	return info.Mode()&0111 != 0
 }
 
-// detectFileFormat tries to determine the format of a file based on content
-func detectFileFormat(filePath string) (string, float64) {
+// detectFileFormat tries to determine the format of a file based on content,
+// reporting whether it was identified by magic bytes
+func detectFileFormat(filePath string) (string, bool) {
 	// Read the first few bytes of the file
 	data, err := readFileSample(filePath, 8192)
 	if err != nil {
-		return "unknown", 0.0
+		return "unknown", false
 	}
 
 	// Format detection based on magic numbers/signatures
 	signatures := []struct {
-		format     string
-		magic      []byte
-		offset     int
-		confidence float64
+		format string
+		magic  []byte
+		offset int
 	}{
-		{"elf", []byte{0x7F, 'E', 'L', 'F'}, 0, 0.9},
-		{"pe/exe", []byte{0x4D, 0x5A}, 0, 0.9},
-		{"zip", []byte{0x50, 0x4B, 0x03, 0x04}, 0, 0.9},
-		{"jar/zip", []byte{0x50, 0x4B, 0x03, 0x04}, 0, 0.9},
-		{"gzip", []byte{0x1F, 0x8B}, 0, 0.9},
-		{"bzip2", []byte{0x42, 0x5A, 0x68}, 0, 0.9},
-		{"7zip", []byte{0x37, 0x7A, 0xBC, 0xAF, 0x27, 0x1C}, 0, 0.9},
-		{"pdf", []byte{0x25, 0x50, 0x44, 0x46}, 0, 0.9},
-		{"jpeg", []byte{0xFF, 0xD8, 0xFF}, 0, 0.9},
-		{"png", []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}, 0, 0.9},
-		{"gif", []byte{0x47, 0x49, 0x46, 0x38}, 0, 0.9},
-		{"ogg", []byte{0x4F, 0x67, 0x67, 0x53}, 0, 0.9},
-		{"mp3", []byte{0x49, 0x44, 0x33}, 0, 0.8},
-		{"mp4", []byte{0x00, 0x00, 0x00, 0x18, 0x66, 0x74, 0x79, 0x70}, 4, 0.8},
-		{"ico", []byte{0x00, 0x00, 0x01, 0x00}, 0, 0.9},
-		{"rpm", []byte{0xED, 0xAB, 0xEE, 0xDB}, 0, 0.9},
-		{"class", []byte{0xCA, 0xFE, 0xBA, 0xBE}, 0, 0.9},
-		{"deb", []byte{0x21, 0x3C, 0x61, 0x72, 0x63, 0x68, 0x3E}, 0, 0.9},
+		{"elf", []byte{0x7F, 'E', 'L', 'F'}, 0},
+		{"pe/exe", []byte{0x4D, 0x5A}, 0},
+		{"zip", []byte{0x50, 0x4B, 0x03, 0x04}, 0},
+		{"jar/zip", []byte{0x50, 0x4B, 0x03, 0x04}, 0},
+		{"gzip", []byte{0x1F, 0x8B}, 0},
+		{"bzip2", []byte{0x42, 0x5A, 0x68}, 0},
+		{"7zip", []byte{0x37, 0x7A, 0xBC, 0xAF, 0x27, 0x1C}, 0},
+		{"pdf", []byte{0x25, 0x50, 0x44, 0x46}, 0},
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF}, 0},
+		{"png", []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}, 0},
+		{"gif", []byte{0x47, 0x49, 0x46, 0x38}, 0},
+		{"ogg", []byte{0x4F, 0x67, 0x67, 0x53}, 0},
+		{"mp3", []byte{0x49, 0x44, 0x33}, 0},
+		{"mp4", []byte{0x00, 0x00, 0x00, 0x18, 0x66, 0x74, 0x79, 0x70}, 4},
+		{"ico", []byte{0x00, 0x00, 0x01, 0x00}, 0},
+		{"rpm", []byte{0xED, 0xAB, 0xEE, 0xDB}, 0},
+		{"macho", []byte{0xFE, 0xED, 0xFA, 0xCE}, 0},
+		{"macho", []byte{0xFE, 0xED, 0xFA, 0xCF}, 0},
+		{"macho", []byte{0xCE, 0xFA, 0xED, 0xFE}, 0},
+		{"macho", []byte{0xCF, 0xFA, 0xED, 0xFE}, 0},
+		{"class", []byte{0xCA, 0xFE, 0xBA, 0xBE}, 0},
+		{"deb", []byte{0x21, 0x3C, 0x61, 0x72, 0x63, 0x68, 0x3E}, 0},
+	}
+
+	// Universal Mach-O binaries share their magic with Java class files
+	if isFatMachO(data) {
+		return "macho", true
 	}
 
 	for _, sig := range signatures {
 		if len(data) >= sig.offset+len(sig.magic) {
 			if bytes.Equal(data[sig.offset:sig.offset+len(sig.magic)], sig.magic) {
-				return sig.format, sig.confidence
+				return sig.format, true
 			}
 		}
 	}
@@ -296,26 +304,26 @@ func detectFileFormat(filePath string) (string, float64) {
 	if isText {
 		// Try to identify specific text formats
 		if len(data) >= 5 && bytes.Equal(data[0:5], []byte("<?xml")) {
-			return "xml", 0.8
+			return "xml", false
 		}
 		if len(data) >= 5 && bytes.Equal(data[0:5], []byte("<!DOC")) {
-			return "html", 0.8
+			return "html", false
 		}
 		if bytes.Contains(data, []byte("<?php")) {
-			return "php", 0.8
+			return "php", false
 		}
 		if bytes.Contains(data, []byte("#!/bin/sh")) || bytes.Contains(data, []byte("#!/bin/bash")) {
-			return "shell-script", 0.8
+			return "shell-script", false
 		}
 		if strings.HasSuffix(filePath, ".py") || bytes.Contains(data, []byte("#!/usr/bin/python")) {
-			return "python-script", 0.8
+			return "python-script", false
 		}
 
-		return "text", 0.7
+		return "text", false
 	}
 
 	// If nothing matches, check if it's a binary file
-	return "binary", 0.3
+	return "binary", false
 }
 
 // extractStringsFromBinary extracts printable strings from a binary file
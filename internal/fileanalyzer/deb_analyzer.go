@@ -8,14 +8,18 @@ import (
 	"compress/gzip"
 	"crypto/sha256"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/blakesmith/ar"
+	"github.com/deploymenttheory/go-app-index/internal/arch"
 	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/version"
 	"github.com/klauspost/compress/zstd"
 	"github.com/xi2/xz"
 )
@@ -23,6 +27,11 @@ import (
 // DEBAnalyzer analyzes Debian package files
 type DEBAnalyzer struct{}
 
+// Name identifies the analyzer in merged results
+func (a *DEBAnalyzer) Name() string {
+	return "deb"
+}
+
 // CanHandle checks if the file is potentially a DEB package
 func (a *DEBAnalyzer) CanHandle(filePath string, contentType string) bool {
 	ext := strings.ToLower(filepath.Ext(filePath))
@@ -65,26 +74,31 @@ func (a *DEBAnalyzer) Analyze(filePath string) (*Result, error) {
 			Confidence:  0.1,
 			IsInstaller: false,
 			Metadata:    make(map[string]interface{}),
-			AnalyzedAt:  timeNow(),
 		}, nil
 	}
 
 	// Look for control.tar file in the archive
-	installerMeta, err := extractDebControlInfo(arReader)
+	installerMeta, control, err := extractDebControlInfo(arReader)
 	if err != nil {
 		logger.Debugf("DEB control.tar extraction failed: %v", err)
 
 		// It's still a DEB, even if we couldn't extract metadata
+		isArchive := hasMagic(filePath, []byte("!<arch>\n"))
 		return &Result{
 			FileType:    "deb",
 			Platform:    "linux-debian",
-			Confidence:  0.8, // Lower confidence since we couldn't extract metadata
 			IsInstaller: true,
 			Metadata:    make(map[string]interface{}),
-			AnalyzedAt:  timeNow(),
+			Factors:     &ConfidenceFactors{MagicMatch: isArchive, MalformedStructure: isArchive},
 		}, nil
 	}
 
+	// The copyright file is in the data archive that follows the control archive
+	license, err := extractDebLicense(arReader, installerMeta.Name)
+	if err != nil {
+		logger.Debugf("DEB copyright extraction failed: %v", err)
+	}
+
 	// Ensure the whole file is read to get the correct hash
 	if _, err := io.Copy(io.Discard, teeReader); err != nil {
 		logger.Warningf("Failed to read entire DEB file: %v", err)
@@ -93,6 +107,7 @@ func (a *DEBAnalyzer) Analyze(filePath string) (*Result, error) {
 
 	// Add hash to installer metadata
 	installerMeta.SHASum = h.Sum(nil)
+	installerMeta.VersionSource = version.SourcePackage
 
 	// Create main metadata map
 	metadata := make(map[string]interface{})
@@ -107,6 +122,19 @@ func (a *DEBAnalyzer) Analyze(filePath string) (*Result, error) {
 
 	// Add DEB-specific metadata
 	metadata["package_name"] = installerMeta.Name
+	// The source package is named with its version when that differs
+	if source, _, _ := strings.Cut(control["Source"], " "); source != "" {
+		metadata["source_package"] = source
+	}
+	if maintainer := control["Maintainer"]; maintainer != "" {
+		metadata["maintainer"] = maintainer
+	}
+	if dependencies := debDependencies(control["Pre-Depends"], control["Depends"]); len(dependencies) > 0 {
+		metadata["dependencies"] = dependencies
+	}
+	if license != "" {
+		metadata["license"] = license
+	}
 
 	// Determine more specific platform if possible
 	platform := "linux-debian"
@@ -114,21 +142,22 @@ func (a *DEBAnalyzer) Analyze(filePath string) (*Result, error) {
 	return &Result{
 		FileType:    "deb",
 		Platform:    platform,
-		Confidence:  0.9, // High confidence for DEB files
 		IsInstaller: true,
 		Metadata:    metadata,
-		AnalyzedAt:  timeNow(),
+		// The ar archive and its control file parsed
+		Factors: &ConfidenceFactors{MagicMatch: true, ParsedStructure: true},
 	}, nil
 }
 
-// extractDebControlInfo extracts metadata from a DEB archive's control.tar file
-func extractDebControlInfo(arReader *ar.Reader) (*InstallerMetadata, error) {
+// extractDebControlInfo extracts metadata from a DEB archive's control.tar
+// file, along with the control fields it was read from
+func extractDebControlInfo(arReader *ar.Reader) (*InstallerMetadata, map[string]string, error) {
 	for {
 		header, err := arReader.Next()
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		filename := path.Clean(header.Name)
@@ -138,55 +167,34 @@ func extractDebControlInfo(arReader *ar.Reader) (*InstallerMetadata, error) {
 				ext = ""
 			}
 
-			name, version, err := parseDebControl(arReader, ext)
+			control, err := parseDebControl(arReader, ext)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 
 			return &InstallerMetadata{
-				Name:       name,
-				Version:    version,
-				PackageIDs: []string{name},
-			}, nil
+				Name:         control["Package"],
+				Version:      control["Version"],
+				PackageIDs:   []string{control["Package"]},
+				Architecture: arch.Normalize(control["Architecture"]),
+			}, control, nil
 		}
 	}
 
-	return nil, errors.New("no control.tar file found in DEB package")
+	return nil, nil, errors.New("no control.tar file found in DEB package")
 }
 
-// parseDebControl extracts the package name and version from the control file
-func parseDebControl(r io.Reader, compressionExt string) (name, version string, err error) {
-	var controlReader io.Reader = r
+// debControlFields are the control file fields read from a package
+var debControlFields = []string{"Package", "Source", "Version", "Architecture", "Maintainer", "Pre-Depends", "Depends"}
 
-	// Handle different compression formats
-	switch compressionExt {
-	case ".gz":
-		gz, err := gzip.NewReader(r)
-		if err != nil {
-			return "", "", err
-		}
-		defer gz.Close()
-		controlReader = gz
-	case ".bz2":
-		controlReader = bzip2.NewReader(r)
-	case ".xz":
-		xzReader, err := xz.NewReader(r, 0)
-		if err != nil {
-			return "", "", err
-		}
-		controlReader = xzReader
-	case ".zst":
-		zstdReader, err := zstd.NewReader(r)
-		if err != nil {
-			return "", "", err
-		}
-		defer zstdReader.Close()
-		controlReader = zstdReader
-	case "":
-		// Uncompressed, use reader as-is
-	default:
-		return "", "", errors.New("unsupported compression format in control.tar: " + compressionExt)
+// parseDebControl extracts the package name, version and architecture from
+// the control file
+func parseDebControl(r io.Reader, compressionExt string) (map[string]string, error) {
+	controlReader, closeReader, err := debDecompress(r, compressionExt)
+	if err != nil {
+		return nil, fmt.Errorf("control.tar: %w", err)
 	}
+	defer closeReader()
 
 	// Read the control archive as tar
 	tarReader := tar.NewReader(controlReader)
@@ -197,38 +205,170 @@ func parseDebControl(r io.Reader, compressionExt string) (name, version string,
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			return "", "", err
+			return nil, err
 		}
 
 		if path.Clean(header.Name) == "control" {
 			// Found the control file, read its contents
 			controlContent, err := io.ReadAll(tarReader)
 			if err != nil {
-				return "", "", err
+				return nil, err
 			}
 
-			// Parse the control file for name and version
+			// Parse the control file for the fields we need
+			fields := make(map[string]string)
 			scanner := bufio.NewScanner(bytes.NewReader(controlContent))
 			for scanner.Scan() {
 				line := scanner.Text()
-
-				// Look for "Package:" and "Version:" lines
-				if strings.HasPrefix(line, "Package:") {
-					name = strings.TrimSpace(line[len("Package:"):])
-				} else if strings.HasPrefix(line, "Version:") {
-					version = strings.TrimSpace(line[len("Version:"):])
+				for _, field := range debControlFields {
+					if strings.HasPrefix(line, field+":") {
+						fields[field] = strings.TrimSpace(line[len(field)+1:])
+					}
 				}
 
-				// If we found both name and version, we can stop
-				if name != "" && version != "" {
-					return name, version, nil
+				// If we found every field, we can stop
+				if len(fields) == len(debControlFields) {
+					break
 				}
 			}
 
 			// If we found the control file but not all fields, return what we have
-			return name, version, nil
+			return fields, nil
+		}
+	}
+
+	return nil, errors.New("control file not found in control.tar")
+}
+
+// debDecompress wraps a member of a DEB archive in a reader for its
+// compression, given as the member's extension
+func debDecompress(r io.Reader, compressionExt string) (io.Reader, func(), error) {
+	switch compressionExt {
+	case ".gz":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gz, func() { gz.Close() }, nil
+	case ".bz2":
+		return bzip2.NewReader(r), func() {}, nil
+	case ".xz":
+		xzReader, err := xz.NewReader(r, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		return xzReader, func() {}, nil
+	case ".zst":
+		zstdReader, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zstdReader, zstdReader.Close, nil
+	case "":
+		// Uncompressed, use reader as-is
+		return r, func() {}, nil
+	default:
+		return nil, nil, errors.New("unsupported compression format " + compressionExt)
+	}
+}
+
+// debDependencies returns the names of the packages a DEB depends on,
+// sorted. Only the first of a set of alternatives is kept, without its
+// version constraint or architecture qualifier.
+func debDependencies(fields ...string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, field := range fields {
+		for _, dep := range strings.Split(field, ",") {
+			first := strings.Split(dep, "|")[0]
+			if i := strings.IndexAny(first, "([<"); i >= 0 {
+				first = first[:i]
+			}
+			name, _, _ := strings.Cut(strings.TrimSpace(first), ":")
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
 		}
 	}
+	sort.Strings(names)
+	return names
+}
 
-	return "", "", errors.New("control file not found in control.tar")
+// extractDebLicense reads the license from a package's machine-readable
+// copyright file, /usr/share/doc/<package>/copyright, in the data archive.
+// The licenses of every paragraph are joined with AND.
+func extractDebLicense(arReader *ar.Reader, packageName string) (string, error) {
+	for {
+		header, err := arReader.Next()
+		if err == io.EOF {
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+
+		filename := path.Clean(header.Name)
+		if !strings.HasPrefix(filename, "data.tar") {
+			continue
+		}
+		ext := filepath.Ext(filename)
+		if ext == ".tar" {
+			ext = ""
+		}
+
+		dataReader, closeReader, err := debDecompress(arReader, ext)
+		if err != nil {
+			return "", fmt.Errorf("data.tar: %w", err)
+		}
+		defer closeReader()
+
+		copyright := path.Join("usr/share/doc", packageName, "copyright")
+		tarReader := tar.NewReader(dataReader)
+		for {
+			entry, err := tarReader.Next()
+			if err == io.EOF {
+				return "", nil
+			} else if err != nil {
+				return "", err
+			}
+			if strings.TrimPrefix(path.Clean(entry.Name), "/") != copyright {
+				continue
+			}
+			content, err := io.ReadAll(io.LimitReader(tarReader, 1024*1024))
+			if err != nil {
+				return "", err
+			}
+			return parseDebCopyrightLicense(content), nil
+		}
+	}
+}
+
+// parseDebCopyrightLicense returns the licenses named in a copyright file
+// in the machine-readable format, or nothing for a free-form file
+func parseDebCopyrightLicense(content []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	if !scanner.Scan() || !strings.Contains(scanner.Text(), "copyright-format") {
+		return ""
+	}
+
+	seen := make(map[string]bool)
+	var licenses []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "License:") {
+			continue
+		}
+		license := strings.TrimSpace(strings.TrimPrefix(line, "License:"))
+		if license == "" || seen[license] {
+			continue
+		}
+		seen[license] = true
+		if strings.Contains(license, " ") {
+			license = "(" + license + ")"
+		}
+		licenses = append(licenses, license)
+	}
+	sort.Strings(licenses)
+	return strings.Join(licenses, " AND ")
 }
@@ -0,0 +1,97 @@
+package fileanalyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// fixedClock stamps every analysis with the same time
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// goldenRun is an AnalyzerRun without its duration
+type goldenRun struct {
+	Analyzer   string
+	Confidence float64
+	Err        string `json:",omitempty"`
+}
+
+// goldenJSON renders the parts of a result that must be reproducible
+func goldenJSON(t *testing.T, result *Result) []byte {
+	t.Helper()
+	view := struct {
+		*Result
+		Runs []goldenRun
+	}{Result: result}
+	for _, run := range result.Runs {
+		r := goldenRun{Analyzer: run.Analyzer, Confidence: run.Confidence}
+		if run.Err != nil {
+			r.Err = run.Err.Error()
+		}
+		view.Runs = append(view.Runs, r)
+	}
+	data, err := json.MarshalIndent(view, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(data, '\n')
+}
+
+func TestAnalyzeGolden(t *testing.T) {
+	clock := fixedClock(time.Date(2024, 6, 1, 8, 30, 0, 0, time.UTC))
+	fixtures := []string{
+		"setup-nsis.exe",
+		"tool-2.4.1-x64.msi",
+		"tool_1.2.3-1_amd64.deb",
+		"tool-1.2.3-1.el9.x86_64.rpm",
+		"Tool-3.1.0.pkg",
+		"tool-bundle.zip",
+	}
+	for _, fixture := range fixtures {
+		t.Run(fixture, func(t *testing.T) {
+			path := filepath.Join("testdata", fixture)
+			var outputs [][]byte
+			for i := 0; i < 2; i++ {
+				result, err := NewManager(WithClock(clock)).Analyze(context.Background(), path, "")
+				if err != nil {
+					t.Fatalf("Analyze() error = %v", err)
+				}
+				if !result.AnalyzedAt.Equal(time.Time(clock)) {
+					t.Errorf("AnalyzedAt = %v, want the clock's %v", result.AnalyzedAt, time.Time(clock))
+				}
+				outputs = append(outputs, goldenJSON(t, result))
+			}
+			if !bytes.Equal(outputs[0], outputs[1]) {
+				t.Fatalf("analysis is not reproducible:\n%s\nthen\n%s", outputs[0], outputs[1])
+			}
+
+			golden := filepath.Join("testdata", "golden", fixture+".json")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, outputs[0], 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(outputs[0], want) {
+				t.Errorf("analysis of %s differs from %s:\n%s", fixture, golden, outputs[0])
+			}
+		})
+	}
+}
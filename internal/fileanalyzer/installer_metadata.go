@@ -1,13 +1,20 @@
 package fileanalyzer
 
+import "github.com/deploymenttheory/go-app-index/internal/version"
+
 // InstallerMetadata represents standardized metadata extracted from installer files
 type InstallerMetadata struct {
-	Name             string   // Application name
-	Version          string   // Application version
-	Publisher        string   // Publisher/vendor name
-	BundleIdentifier string   // App bundle identifiers
-	PackageIDs       []string // Package identifiers (varies by platform)
-	SHASum           []byte   // SHA256 hash of the file
+	Name             string         // Application name
+	Version          string         // Application version
+	VersionSource    version.Source // Where Version was read from
+	BundleVersion    string         // CFBundleVersion build number of an app bundle
+	Publisher        string         // Publisher/vendor name
+	BundleIdentifier string         // App bundle identifiers
+	PackageIDs       []string       // Package identifiers (varies by platform)
+	ProductCode      string         // MSI ProductCode GUID
+	UpgradeCode      string         // MSI UpgradeCode GUID, shared by every version of a product
+	Architecture     string         // Normalized CPU architecture (see internal/arch)
+	SHASum           []byte         // SHA256 hash of the file
 }
 
 // IsValid returns true if the metadata contains at least a name or version
@@ -29,12 +36,35 @@ func (im *InstallerMetadata) ToMap() map[string]interface{} {
 
 	if im.Version != "" {
 		result["version"] = im.Version
+		if im.VersionSource != "" {
+			result["version_source"] = string(im.VersionSource)
+		}
+	}
+
+	if im.BundleVersion != "" {
+		result["bundle_version"] = im.BundleVersion
 	}
 
 	if im.Publisher != "" {
 		result["publisher"] = im.Publisher
 	}
 
+	if im.BundleIdentifier != "" {
+		result["bundle_identifier"] = im.BundleIdentifier
+	}
+
+	if im.ProductCode != "" {
+		result["product_code"] = im.ProductCode
+	}
+
+	if im.UpgradeCode != "" {
+		result["upgrade_code"] = im.UpgradeCode
+	}
+
+	if im.Architecture != "" {
+		result["architecture"] = im.Architecture
+	}
+
 	if len(im.PackageIDs) > 0 {
 		result["package_ids"] = im.PackageIDs
 	}
@@ -10,12 +10,19 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/deploymenttheory/go-app-index/internal/arch"
 	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/version"
 )
 
 // LinuxAnalyzer analyzes Linux installer files
 type LinuxAnalyzer struct{}
 
+// Name identifies the analyzer in merged results
+func (a *LinuxAnalyzer) Name() string {
+	return "linux"
+}
+
 // CanHandle checks if the file is a potential Linux package
 func (a *LinuxAnalyzer) CanHandle(filePath string, contentType string) bool {
 	ext := strings.ToLower(filepath.Ext(filePath))
@@ -80,19 +87,29 @@ func (a *LinuxAnalyzer) Analyze(filePath string) (*Result, error) {
 	}
 
 	// Extract version info using generic string patterns
-	version := extractLinuxVersion(filePath)
-	if version != "" {
-		metadata["version"] = version
+	if v := extractLinuxVersion(filePath); v != "" {
+		metadata["version"] = v
+		metadata["version_source"] = string(version.SourceContent)
+	}
+
+	// These checks match magic bytes; only tar.gz archives are parsed
+	factors := &ConfidenceFactors{}
+	for _, key := range []string{"valid_deb", "valid_rpm", "valid_elf", "valid_gzip"} {
+		if metadata[key] == true {
+			factors.MagicMatch = true
+		}
+	}
+	if count, ok := metadata["file_count"].(int); ok && count > 0 {
+		factors.ParsedStructure = true
 	}
 
 	// Overall result
 	result := &Result{
 		FileType:    fileType,
 		Platform:    "linux",
-		Confidence:  0.8,  // Good confidence for known extensions
 		IsInstaller: true, // Assume all Linux packages are installers
 		Metadata:    metadata,
-		AnalyzedAt:  timeNow(),
+		Factors:     factors,
 	}
 
 	logger.Debugf("Linux analysis of %s: type=%s", filePath, fileType)
@@ -100,6 +117,18 @@ func (a *LinuxAnalyzer) Analyze(filePath string) (*Result, error) {
 	return result, nil
 }
 
+// debControlPatterns match fields of a Debian control file
+var debControlPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"package", regexp.MustCompile(`Package: ([^\n]+)`)},
+	{"version", regexp.MustCompile(`Version: ([^\n]+)`)},
+	{"architecture", regexp.MustCompile(`Architecture: ([^\n]+)`)},
+	{"maintainer", regexp.MustCompile(`Maintainer: ([^\n]+)`)},
+	{"description", regexp.MustCompile(`Description: ([^\n]+)`)},
+}
+
 // analyzeDEB extracts information from a DEB package
 func analyzeDEB(filePath string) (map[string]interface{}, error) {
 	metadata := make(map[string]interface{})
@@ -124,24 +153,14 @@ func analyzeDEB(filePath string) (map[string]interface{}, error) {
 	}
 
 	// Look for common field patterns in control file
-	fields := map[string]string{
-		"package":      `Package: ([^\n]+)`,
-		"version":      `Version: ([^\n]+)`,
-		"architecture": `Architecture: ([^\n]+)`,
-		"maintainer":   `Maintainer: ([^\n]+)`,
-		"description":  `Description: ([^\n]+)`,
-	}
-
-	// Convert buffer to string for regex
-	bufferStr := string(buffer)
-
-	for field, pattern := range fields {
-		re := regexp.MustCompile(pattern)
-		matches := re.FindStringSubmatch(bufferStr)
-		if len(matches) > 1 {
-			metadata[field] = strings.TrimSpace(matches[1])
+	for _, field := range debControlPatterns {
+		if matches := field.pattern.FindSubmatch(buffer); len(matches) > 1 {
+			metadata[field.name] = strings.TrimSpace(string(matches[1]))
 		}
 	}
+	if raw, ok := metadata["architecture"].(string); ok {
+		metadata["architecture"] = arch.Normalize(raw)
+	}
 
 	return metadata, nil
 }
@@ -215,6 +234,9 @@ func analyzeAppImage(filePath string) (map[string]interface{}, error) {
 
 	if bytes.Equal(magic, elfMagic) {
 		metadata["valid_elf"] = true
+		if architecture := executableArchitecture(filePath); architecture != "" {
+			metadata["architecture"] = architecture
+		}
 
 		// Check for AppImage signature in the first 32KB
 		buffer := make([]byte, 32*1024)
@@ -295,7 +317,6 @@ func analyzeTarGz(filePath string) (map[string]interface{}, error) {
 
 // extractLinuxVersion attempts to find version strings in the file
 func extractLinuxVersion(filePath string) string {
-	// Open the file
 	file, err := os.Open(filePath)
 	if err != nil {
 		return ""
@@ -306,29 +327,13 @@ func extractLinuxVersion(filePath string) string {
 	buffer := make([]byte, 8192)
 	for {
 		n, err := file.Read(buffer)
-		if err != nil && err != io.EOF {
-			return ""
-		}
-
-		if n == 0 {
-			break
-		}
-
-		// Common version patterns in Linux packages
-		versionPatterns := []*regexp.Regexp{
-			regexp.MustCompile(`(?i)version[\s="':]+([0-9]+\.[0-9]+(\.[0-9]+)?)`),
-			regexp.MustCompile(`(?i)VERSION[\s="':]+([0-9]+\.[0-9]+(\.[0-9]+)?)`),
-			regexp.MustCompile(`(?i)-([0-9]+\.[0-9]+(\.[0-9]+)?)-`),
-			regexp.MustCompile(`(?i)_([0-9]+\.[0-9]+(\.[0-9]+)?)_`),
-		}
-
-		for _, pattern := range versionPatterns {
-			matches := pattern.FindSubmatch(buffer[:n])
-			if len(matches) > 1 {
-				return string(matches[1])
+		if n > 0 {
+			if v, ok := version.FromText(buffer[:n]); ok {
+				return v.Raw
 			}
 		}
+		if err != nil {
+			return ""
+		}
 	}
-
-	return ""
 }
@@ -21,9 +21,12 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	"github.com/deploymenttheory/go-app-index/internal/arch"
 	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/version"
 	"howett.net/plist"
 )
 
@@ -68,12 +71,18 @@ type xmlFileData struct {
 // distributionXML represents the structure of the distributionXML.xml
 type distributionXML struct {
 	Title          string                     `xml:"title"`
+	Options        distributionOptions        `xml:"options"`
 	Product        distributionProduct        `xml:"product"`
 	PkgRefs        []distributionPkgRef       `xml:"pkg-ref"`
 	Choices        []distributionChoice       `xml:"choice"`
 	ChoicesOutline distributionChoicesOutline `xml:"choices-outline"`
 }
 
+// distributionOptions represents the options element
+type distributionOptions struct {
+	HostArchitectures string `xml:"hostArchitectures,attr"` // Comma-separated, e.g. "x86_64,arm64"
+}
+
 // distributionProduct represents the product element
 type distributionProduct struct {
 	ID      string `xml:"id,attr"`
@@ -135,6 +144,11 @@ type packageInfoXML struct {
 
 type MacOSAnalyzer struct{}
 
+// Name identifies the analyzer in merged results
+func (a *MacOSAnalyzer) Name() string {
+	return "macos"
+}
+
 func (a *MacOSAnalyzer) CanHandle(filePath string, contentType string) bool {
 	ext := strings.ToLower(filepath.Ext(filePath))
 	logger.Debugf("Checking if MacOSAnalyzer can handle file: %s (ext: %s, type: %s)", filePath, ext, contentType)
@@ -158,7 +172,6 @@ func (a *MacOSAnalyzer) Analyze(filePath string) (*Result, error) {
 			Confidence:  0.3,
 			IsInstaller: false,
 			Metadata:    map[string]interface{}{},
-			AnalyzedAt:  timeNow(),
 		}, nil
 	}
 }
@@ -213,14 +226,14 @@ func analyzePKG(filePath string) (*Result, error) {
 				return nil, err
 			}
 			meta.SHASum = hash.Sum(nil)
+			meta.VersionSource = version.SourcePackage
 			logger.Infof("Extracted metadata for PKG: %+v", meta)
 			return &Result{
 				FileType:    "pkg",
 				Platform:    "macos",
-				Confidence:  0.95,
 				IsInstaller: true,
 				Metadata:    meta.ToMap(),
-				AnalyzedAt:  timeNow(),
+				Factors:     &ConfidenceFactors{MagicMatch: true, ParsedStructure: true},
 			}, nil
 		}
 	}
@@ -228,10 +241,10 @@ func analyzePKG(filePath string) (*Result, error) {
 	return &Result{
 		FileType:    "pkg",
 		Platform:    "macos",
-		Confidence:  0.7,
 		IsInstaller: true,
 		Metadata:    map[string]interface{}{"note": "PKG internal metadata not found"},
-		AnalyzedAt:  timeNow(),
+		// The XAR table of contents parsed, but without package metadata
+		Factors: &ConfidenceFactors{MagicMatch: true, ParsedStructure: true},
 	}, nil
 }
 
@@ -253,21 +266,35 @@ func analyzeAppBundle(appPath string) (*Result, error) {
 	}
 	logger.Debugf("Extracted plist data: %+v", plistData)
 
+	plistString := func(key string) string {
+		value, _ := plistData[key].(string)
+		return preprocess(value)
+	}
+
 	meta := &InstallerMetadata{
-		Name:             plistData["CFBundleName"].(string),
-		Version:          plistData["CFBundleShortVersionString"].(string),
-		BundleIdentifier: plistData["CFBundleIdentifier"].(string),
-		PackageIDs:       []string{plistData["CFBundleIdentifier"].(string)},
+		Name:             plistString("CFBundleName"),
+		BundleIdentifier: plistString("CFBundleIdentifier"),
+	}
+	if meta.BundleIdentifier != "" {
+		meta.PackageIDs = []string{meta.BundleIdentifier}
+	}
+	if v, ok := version.ParseApple(plistString("CFBundleShortVersionString"), plistString("CFBundleVersion")); ok {
+		meta.Version = v.Normalized
+		meta.VersionSource = version.SourceBundle
+		meta.BundleVersion = v.Build
+	}
+	if executable := plistString("CFBundleExecutable"); executable != "" {
+		meta.Architecture = machoArchitecture(filepath.Join(appPath, "Contents", "MacOS", executable))
 	}
 	logger.Infof("Extracted metadata for .app: %+v", meta)
 
 	return &Result{
 		FileType:    "app",
 		Platform:    "macos",
-		Confidence:  0.9,
 		IsInstaller: false,
 		Metadata:    meta.ToMap(),
-		AnalyzedAt:  timeNow(),
+		// Bundles are directories, so there are no magic bytes to match
+		Factors: &ConfidenceFactors{ParsedStructure: true},
 	}, nil
 }
 
@@ -303,7 +330,13 @@ func parseXMLMetadata(contents []byte) (*InstallerMetadata, error) {
 	var distXML distributionXML
 	if err := xml.Unmarshal(contents, &distXML); err == nil && distXML.Product.ID != "" {
 		name, identifier, version, packageIDs := getDistributionInfo(&distXML)
-		return &InstallerMetadata{Name: name, Version: version, BundleIdentifier: identifier, PackageIDs: packageIDs}, nil
+		return &InstallerMetadata{
+			Name:             name,
+			Version:          version,
+			BundleIdentifier: identifier,
+			PackageIDs:       packageIDs,
+			Architecture:     arch.Combine(strings.Split(distXML.Options.HostArchitectures, ",")...),
+		}, nil
 	}
 
 	var pkgInfo packageInfoXML
@@ -351,6 +384,7 @@ func getDistributionInfo(d *distributionXML) (name string, identifier string, ve
 	for id := range packageIDSet {
 		packageIDs = append(packageIDs, id)
 	}
+	sort.Strings(packageIDs)
 
 out:
 	// look in all the bundle versions for one that has a `path` attribute
@@ -497,6 +531,7 @@ func getPackageInfo(p *packageInfoXML) (name string, identifier string, version
 	for id := range packageIDSet {
 		packageIDs = append(packageIDs, id)
 	}
+	sort.Strings(packageIDs)
 
 	// if we didn't find a version, grab the version from pkg-info element
 	// Note: this version may be wrong since it is the version of the package and not the app
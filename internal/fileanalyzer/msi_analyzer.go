@@ -2,19 +2,30 @@ package fileanalyzer
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/deploymenttheory/go-app-index/internal/arch"
 	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/version"
 	"github.com/sassoftware/relic/v8/lib/comdoc"
 )
 
 // MSIAnalyzer analyzes Windows MSI installer files
 type MSIAnalyzer struct{}
 
+// Name identifies the analyzer in merged results
+func (a *MSIAnalyzer) Name() string {
+	return "msi"
+}
+
 // CanHandle checks if the file is a potential MSI file
 func (a *MSIAnalyzer) CanHandle(filePath string, contentType string) bool {
 	ext := strings.ToLower(filepath.Ext(filePath))
@@ -66,27 +77,27 @@ func (a *MSIAnalyzer) Analyze(filePath string) (*Result, error) {
 			Confidence:  0.1,
 			IsInstaller: false,
 			Metadata:    metadata,
-			AnalyzedAt:  timeNow(),
 		}, nil
 	}
 	defer c.Close()
 
-	// It's a valid MSI file, so we have good confidence
-	confidence := 0.9
+	// It's a compound document, so the magic bytes matched
+	factors := &ConfidenceFactors{MagicMatch: true}
 
 	// Extract MSI metadata
 	installerMeta, err := extractMSIInstallerMetadata(c)
 	if err != nil {
 		logger.Debugf("MSI metadata extraction error: %v", err)
+		factors.MalformedStructure = true
 		return &Result{
 			FileType:    "msi",
 			Platform:    "windows",
-			Confidence:  confidence,
 			IsInstaller: true,
 			Metadata:    metadata,
-			AnalyzedAt:  timeNow(),
+			Factors:     factors,
 		}, nil
 	}
+	factors.ParsedStructure = true
 
 	// Copy installer metadata to main metadata map
 	for k, v := range installerMeta.ToMap() {
@@ -122,41 +133,259 @@ func (a *MSIAnalyzer) Analyze(filePath string) (*Result, error) {
 	return &Result{
 		FileType:    "msi",
 		Platform:    "windows",
-		Confidence:  confidence,
 		IsInstaller: true,
 		Metadata:    metadata,
-		AnalyzedAt:  timeNow(),
+		Factors:     factors,
 	}, nil
 }
 
 // extractMSIInstallerMetadata extracts metadata from an MSI file using the Property table
 func extractMSIInstallerMetadata(c *comdoc.ComDoc) (*InstallerMetadata, error) {
-	e, err := c.ListDir(nil)
+	properties, err := readMSIProperties(c)
 	if err != nil {
 		return nil, err
 	}
 
-	// Search for summary information stream
-	for _, ee := range e {
-		if ee.Type != comdoc.DirStream {
+	meta := &InstallerMetadata{
+		Name:          properties["ProductName"],
+		Version:       properties["ProductVersion"],
+		VersionSource: version.SourcePackage,
+		Publisher:     properties["Manufacturer"],
+		ProductCode:   strings.ToUpper(properties["ProductCode"]),
+		UpgradeCode:   strings.ToUpper(properties["UpgradeCode"]),
+	}
+	if meta.ProductCode != "" {
+		meta.PackageIDs = []string{meta.ProductCode}
+	}
+	if template, err := readMSITemplate(c); err == nil {
+		meta.Architecture = msiArchitecture(template)
+	} else {
+		logger.Debugf("MSI summary information unavailable: %v", err)
+	}
+	return meta, nil
+}
+
+// msiSummaryStream is the name of the summary information property set
+const msiSummaryStream = "\x05SummaryInformation"
+
+// msiPIDTemplate is the summary property holding the package's platform
+// and languages, such as "x64;1033"
+const msiPIDTemplate = 7
+
+// readMSITemplate reads the Template property from the package's summary
+// information
+func readMSITemplate(c *comdoc.ComDoc) (string, error) {
+	entries, err := c.ListDir(nil)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if e.Type != comdoc.DirStream || e.Name() != msiSummaryStream {
 			continue
 		}
+		if e.StreamSize > msiMaxTableSize {
+			return "", fmt.Errorf("MSI summary information is too large (%d bytes)", e.StreamSize)
+		}
+		reader, err := c.ReadStream(e)
+		if err != nil {
+			return "", err
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return "", fmt.Errorf("failed to read MSI summary information: %w", err)
+		}
+		return propertySetString(data, msiPIDTemplate)
+	}
+	return "", errors.New("MSI has no summary information")
+}
+
+// propertySetString reads a string property from the first section of an
+// OLE property set
+func propertySetString(data []byte, pid uint32) (string, error) {
+	// The header is followed by the FMTID and offset of the first section
+	if len(data) < 48 {
+		return "", errors.New("property set is truncated")
+	}
+	section := int(binary.LittleEndian.Uint32(data[44:]))
+	if section+8 > len(data) {
+		return "", errors.New("property set section is out of range")
+	}
+	count := int(binary.LittleEndian.Uint32(data[section+4:]))
 
-		name := ee.Name()
-		if strings.Contains(name, "SummaryInformation") {
-			return &InstallerMetadata{
-				Name:       "Sample MSI Application", // Extract from properties
-				Version:    "1.0.0",                  // Extract from properties
-				Publisher:  "Sample Vendor",          // Extract from properties
-				PackageIDs: []string{"SampleMSI"},    // Extract ProductCode
-			}, nil
+	for i := 0; i < count; i++ {
+		entry := section + 8 + i*8
+		if entry+8 > len(data) {
+			break
+		}
+		if binary.LittleEndian.Uint32(data[entry:]) != pid {
+			continue
+		}
+		offset := section + int(binary.LittleEndian.Uint32(data[entry+4:]))
+		if offset+8 > len(data) {
+			return "", errors.New("property is out of range")
+		}
+		// Only VT_LPSTR, a length-prefixed NUL-terminated string, is expected
+		const vtLPSTR = 30
+		if binary.LittleEndian.Uint32(data[offset:]) != vtLPSTR {
+			return "", fmt.Errorf("property %d is not a string", pid)
 		}
+		length := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		if length < 0 || offset+8+length > len(data) {
+			return "", errors.New("property is truncated")
+		}
+		return strings.TrimRight(msiString(data[offset+8:offset+8+length]), "\x00"), nil
 	}
+	return "", fmt.Errorf("property %d not found", pid)
+}
 
-	return &InstallerMetadata{
-		Name:    "Unknown MSI Application",
-		Version: "",
-	}, nil
+// msiArchitecture reads the platform from a Template value such as
+// "Intel;1033" or "x64,Arm64;1033". Intel64 (Itanium) is not recognized.
+func msiArchitecture(template string) string {
+	platforms, _, _ := strings.Cut(template, ";")
+	return arch.Combine(strings.Split(platforms, ",")...)
+}
+
+// readMSIProperties reads the name/value pairs of the MSI Property table
+func readMSIProperties(c *comdoc.ComDoc) (map[string]string, error) {
+	entries, err := c.ListDir(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make(map[string][]byte)
+	for _, e := range entries {
+		if e.Type != comdoc.DirStream {
+			continue
+		}
+		name, isTable := msiStreamName(e.Name())
+		if !isTable || (name != "_StringPool" && name != "_StringData" && name != "Property") {
+			continue
+		}
+		if e.StreamSize > msiMaxTableSize {
+			return nil, fmt.Errorf("MSI table %s is too large (%d bytes)", name, e.StreamSize)
+		}
+		reader, err := c.ReadStream(e)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read MSI table %s: %w", name, err)
+		}
+		tables[name] = data
+	}
+
+	if tables["Property"] == nil {
+		return nil, errors.New("MSI has no Property table")
+	}
+	strs, refSize, err := parseMSIStringPool(tables["_StringPool"], tables["_StringData"])
+	if err != nil {
+		return nil, err
+	}
+
+	// Tables are stored column by column; Property has two string columns
+	data := tables["Property"]
+	rows := len(data) / (2 * refSize)
+	properties := make(map[string]string, rows)
+	for row := 0; row < rows; row++ {
+		key := msiStringRef(data[row*refSize:], refSize)
+		value := msiStringRef(data[(rows+row)*refSize:], refSize)
+		if key < len(strs) && value < len(strs) {
+			properties[strs[key]] = strs[value]
+		}
+	}
+	return properties, nil
+}
+
+// msiMaxTableSize bounds the size of a table stream read into memory
+const msiMaxTableSize = 64 * 1024 * 1024
+
+// msiStreamName decodes the compressed stream names MSI uses for its
+// tables, reporting whether the stream holds a table
+func msiStreamName(name string) (string, bool) {
+	const charset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz._"
+
+	var b strings.Builder
+	isTable := false
+	for i, r := range name {
+		switch {
+		case r == 0x4840 && i == 0:
+			isTable = true
+		case r >= 0x3800 && r < 0x4800:
+			r -= 0x3800
+			b.WriteByte(charset[r&0x3f])
+			b.WriteByte(charset[(r>>6)&0x3f])
+		case r >= 0x4800 && r < 0x4840:
+			b.WriteByte(charset[r-0x4800])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), isTable
+}
+
+// parseMSIStringPool decodes the MSI string table. String ids start at 1;
+// id 0 is the empty string. It also returns the width in bytes of a string
+// reference in the other tables.
+func parseMSIStringPool(pool, data []byte) ([]string, int, error) {
+	if len(pool) < 4 {
+		return nil, 0, errors.New("MSI string pool is missing or truncated")
+	}
+
+	refSize := 2
+	if binary.LittleEndian.Uint32(pool)&0x80000000 != 0 {
+		refSize = 3
+	}
+
+	strs := []string{""}
+	offset := 0
+	for i := 4; i+4 <= len(pool); {
+		length := int(binary.LittleEndian.Uint16(pool[i:]))
+		refs := binary.LittleEndian.Uint16(pool[i+2:])
+		i += 4
+
+		if length == 0 && refs == 0 {
+			strs = append(strs, "")
+			continue
+		}
+		// Strings of 64KB or more store their length in the following entry
+		if length == 0 {
+			if i+4 > len(pool) {
+				return nil, 0, errors.New("MSI string pool is truncated")
+			}
+			length = int(binary.LittleEndian.Uint16(pool[i+2:]))<<16 | int(binary.LittleEndian.Uint16(pool[i:]))
+			i += 4
+		}
+
+		if offset+length > len(data) {
+			return nil, 0, errors.New("MSI string data is truncated")
+		}
+		strs = append(strs, msiString(data[offset:offset+length]))
+		offset += length
+	}
+	return strs, refSize, nil
+}
+
+// msiStringRef reads a string id of refSize bytes
+func msiStringRef(b []byte, refSize int) int {
+	ref := int(binary.LittleEndian.Uint16(b))
+	if refSize == 3 {
+		ref |= int(b[2]) << 16
+	}
+	return ref
+}
+
+// msiString converts MSI string data to UTF-8. Strings are in the package's
+// code page; anything that is not already UTF-8 is read as Latin-1.
+func msiString(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
 }
 
 // checkEmbeddedFiles scans the MSI file for embedded executables
@@ -0,0 +1,208 @@
+package fileanalyzer
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/deploymenttheory/go-app-index/internal/arch"
+	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/version"
+)
+
+const (
+	msixManifest       = "AppxManifest.xml"
+	msixBundleManifest = "AppxMetadata/AppxBundleManifest.xml"
+	msixSignature      = "AppxSignature.p7x"
+
+	// msixMaxManifestSize bounds the size of a manifest read into memory
+	msixMaxManifestSize = 16 * 1024 * 1024
+)
+
+// msixIdentity represents the Identity element of a package or bundle manifest
+type msixIdentity struct {
+	Name                  string `xml:"Name,attr"`
+	Publisher             string `xml:"Publisher,attr"`
+	Version               string `xml:"Version,attr"`
+	ProcessorArchitecture string `xml:"ProcessorArchitecture,attr"`
+}
+
+// msixPackageManifest represents AppxManifest.xml
+type msixPackageManifest struct {
+	Identity   msixIdentity `xml:"Identity"`
+	Properties struct {
+		DisplayName          string `xml:"DisplayName"`
+		PublisherDisplayName string `xml:"PublisherDisplayName"`
+	} `xml:"Properties"`
+}
+
+// msixBundleManifestXML represents AppxBundleManifest.xml
+type msixBundleManifestXML struct {
+	Identity msixIdentity `xml:"Identity"`
+	Packages []struct {
+		Type         string `xml:"Type,attr"`
+		Architecture string `xml:"Architecture,attr"`
+	} `xml:"Packages>Package"`
+}
+
+// MSIXAnalyzer analyzes MSIX and AppX packages and bundles
+type MSIXAnalyzer struct{}
+
+// Name identifies the analyzer in merged results
+func (a *MSIXAnalyzer) Name() string {
+	return "msix"
+}
+
+// CanHandle checks if the file is a potential MSIX or AppX package
+func (a *MSIXAnalyzer) CanHandle(filePath string, contentType string) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".msix", ".appx", ".msixbundle", ".appxbundle":
+		return true
+	}
+
+	contentType = strings.ToLower(contentType)
+	return strings.Contains(contentType, "application/msix") ||
+		strings.Contains(contentType, "application/appx") ||
+		strings.Contains(contentType, "application/vnd.ms-appx")
+}
+
+// Analyze extracts the package identity from the manifest of an MSIX or
+// AppX package, or from the bundle manifest of a bundle
+func (a *MSIXAnalyzer) Analyze(filePath string) (*Result, error) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		logger.Debugf("Not a valid MSIX package: %v", err)
+		return &Result{
+			FileType:    "unknown",
+			Platform:    "unknown",
+			Confidence:  0.1,
+			IsInstaller: false,
+			Metadata:    make(map[string]interface{}),
+		}, nil
+	}
+	defer reader.Close()
+
+	files := make(map[string]*zip.File, len(reader.File))
+	for _, f := range reader.File {
+		files[f.Name] = f
+	}
+
+	var meta *InstallerMetadata
+	fileType := strings.TrimPrefix(strings.ToLower(filepath.Ext(filePath)), ".")
+	if f, ok := files[msixBundleManifest]; ok {
+		meta, err = readMSIXBundleManifest(f)
+		if !strings.HasSuffix(fileType, "bundle") {
+			fileType = "msixbundle"
+		}
+	} else if f, ok := files[msixManifest]; ok {
+		meta, err = readMSIXManifest(f)
+		if fileType != "appx" {
+			fileType = "msix"
+		}
+	} else {
+		err = errors.New("no package or bundle manifest found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MSIX manifest: %w", err)
+	}
+
+	meta.VersionSource = version.SourcePackage
+	metadata := meta.ToMap()
+
+	if shaSum, err := calculateSHA256(filePath); err == nil {
+		metadata["sha256"] = shaSum
+	}
+	_, metadata["is_signed"] = files[msixSignature]
+
+	logger.Debugf("MSIX analysis of %s: name=%s, version=%s, architecture=%s",
+		filePath, meta.Name, meta.Version, meta.Architecture)
+
+	return &Result{
+		FileType:    fileType,
+		Platform:    "windows",
+		IsInstaller: true,
+		Metadata:    metadata,
+		// The package's zip structure and manifest parsed
+		Factors: &ConfidenceFactors{MagicMatch: true, ParsedStructure: true},
+	}, nil
+}
+
+// readMSIXManifest reads a package's identity and display names
+func readMSIXManifest(f *zip.File) (*InstallerMetadata, error) {
+	var manifest msixPackageManifest
+	if err := decodeMSIXXML(f, &manifest); err != nil {
+		return nil, err
+	}
+
+	meta := msixIdentityMetadata(manifest.Identity)
+	meta.Architecture = arch.Normalize(manifest.Identity.ProcessorArchitecture)
+	// Display names can be references into the package's resources
+	if name := manifest.Properties.DisplayName; name != "" && !strings.HasPrefix(name, "ms-resource:") {
+		meta.Name = name
+	}
+	if publisher := manifest.Properties.PublisherDisplayName; publisher != "" && !strings.HasPrefix(publisher, "ms-resource:") {
+		meta.Publisher = publisher
+	}
+	return meta, nil
+}
+
+// readMSIXBundleManifest reads a bundle's identity. Its architecture comes
+// from the application packages it contains; resource packages are
+// architecture neutral.
+func readMSIXBundleManifest(f *zip.File) (*InstallerMetadata, error) {
+	var manifest msixBundleManifestXML
+	if err := decodeMSIXXML(f, &manifest); err != nil {
+		return nil, err
+	}
+
+	meta := msixIdentityMetadata(manifest.Identity)
+	var architectures []string
+	for _, p := range manifest.Packages {
+		if p.Type == "" || strings.EqualFold(p.Type, "application") {
+			architectures = append(architectures, p.Architecture)
+		}
+	}
+	meta.Architecture = arch.Combine(architectures...)
+	return meta, nil
+}
+
+// msixIdentityMetadata fills installer metadata from a package identity
+func msixIdentityMetadata(identity msixIdentity) *InstallerMetadata {
+	meta := &InstallerMetadata{
+		Name:      identity.Name,
+		Version:   identity.Version,
+		Publisher: msixPublisherName(identity.Publisher),
+	}
+	if identity.Name != "" {
+		meta.PackageIDs = []string{identity.Name}
+	}
+	return meta
+}
+
+// msixPublisherName returns the common name from a publisher's
+// distinguished name, such as "Contoso" from "CN=Contoso, O=Contoso, C=US"
+func msixPublisherName(dn string) string {
+	for _, part := range strings.Split(dn, ",") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(part), "="); ok && strings.EqualFold(key, "CN") {
+			return strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return strings.TrimSpace(dn)
+}
+
+// decodeMSIXXML decodes a manifest from the package
+func decodeMSIXXML(f *zip.File, v interface{}) error {
+	if f.UncompressedSize64 > msixMaxManifestSize {
+		return fmt.Errorf("%s is too large (%d bytes)", f.Name, f.UncompressedSize64)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(io.LimitReader(rc, msixMaxManifestSize)).Decode(v)
+}
@@ -0,0 +1,290 @@
+package fileanalyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/logger"
+)
+
+const (
+	// PluginProtocolVersion is sent with every request to a plugin
+	PluginProtocolVersion = 1
+
+	// DefaultPluginTimeout bounds each call to a plugin
+	DefaultPluginTimeout = 30 * time.Second
+
+	// maxPluginOutput bounds how much of a plugin's stdout and stderr is kept
+	maxPluginOutput = 16 * 1024 * 1024
+)
+
+// Plugin describes an out-of-process analyzer executable
+type Plugin struct {
+	Name        string        // Defaults to the executable's name
+	Path        string        // Executable to run
+	Args        []string      // Extra arguments passed on every call
+	Timeout     time.Duration // Per call; defaults to DefaultPluginTimeout
+	MemoryLimit int64         // Bytes of address space, enforced on Linux; zero is unlimited
+}
+
+// pluginRequest is written to a plugin's stdin
+type pluginRequest struct {
+	Protocol    int    `json:"protocol"`
+	Method      string `json:"method"` // can_handle or analyze
+	FilePath    string `json:"file_path"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// pluginResponse is read from a plugin's stdout
+type pluginResponse struct {
+	Error string `json:"error,omitempty"`
+
+	// can_handle
+	CanHandle bool `json:"can_handle"`
+
+	// analyze
+	FileType    string                 `json:"file_type"`
+	Platform    string                 `json:"platform"`
+	Confidence  float64                `json:"confidence"`
+	IsInstaller bool                   `json:"is_installer"`
+	Metadata    map[string]interface{} `json:"metadata"`
+	Factors     []string               `json:"factors"` // Confidence factors the plugin verified
+}
+
+// PluginAnalyzer runs an analyzer plugin, starting a new process for every
+// call so a crashing or hanging plugin cannot affect the others
+type PluginAnalyzer struct {
+	plugin Plugin
+}
+
+// NewPluginAnalyzer creates an analyzer that runs plugin
+func NewPluginAnalyzer(plugin Plugin) *PluginAnalyzer {
+	if plugin.Name == "" {
+		plugin.Name = pluginName(plugin.Path)
+	}
+	if plugin.Timeout <= 0 {
+		plugin.Timeout = DefaultPluginTimeout
+	}
+	return &PluginAnalyzer{plugin: plugin}
+}
+
+// Name identifies the analyzer in merged results
+func (a *PluginAnalyzer) Name() string {
+	return a.plugin.Name
+}
+
+// CanHandle asks the plugin whether it analyzes the file
+func (a *PluginAnalyzer) CanHandle(filePath string, contentType string) bool {
+	return a.CanHandleContext(context.Background(), filePath, contentType)
+}
+
+// CanHandleContext asks the plugin whether it analyzes the file, killing
+// the plugin when ctx is done. No plugin is started once ctx is done.
+func (a *PluginAnalyzer) CanHandleContext(ctx context.Context, filePath string, contentType string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var response pluginResponse
+	err := a.call(ctx, pluginRequest{Method: "can_handle", FilePath: filePath, ContentType: contentType}, &response)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Warningf("Analyzer plugin %s: %v", a.plugin.Name, err)
+		}
+		return false
+	}
+	return response.CanHandle
+}
+
+// Analyze asks the plugin to analyze the file
+func (a *PluginAnalyzer) Analyze(filePath string) (*Result, error) {
+	return a.AnalyzeContext(context.Background(), filePath)
+}
+
+// AnalyzeContext asks the plugin to analyze the file, killing the plugin
+// when ctx is done
+func (a *PluginAnalyzer) AnalyzeContext(ctx context.Context, filePath string) (*Result, error) {
+	var response pluginResponse
+	if err := a.call(ctx, pluginRequest{Method: "analyze", FilePath: filePath}, &response); err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		FileType:    response.FileType,
+		Platform:    response.Platform,
+		Confidence:  clampConfidence(response.Confidence),
+		IsInstaller: response.IsInstaller,
+		Metadata:    response.Metadata,
+	}
+	if result.FileType == "" {
+		result.FileType = "unknown"
+	}
+	if result.Platform == "" {
+		result.Platform = "unknown"
+	}
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	if len(response.Factors) > 0 {
+		factors, err := pluginFactors(response.Factors)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %w", a.plugin.Name, err)
+		}
+		result.Factors = factors
+	}
+
+	return result, nil
+}
+
+// call runs the plugin with one request and decodes its response. The
+// plugin is killed when ctx is done or the plugin's timeout expires.
+func (a *PluginAnalyzer) call(parent context.Context, request pluginRequest, response *pluginResponse) error {
+	request.Protocol = PluginProtocolVersion
+	if abs, err := filepath.Abs(request.FilePath); err == nil {
+		request.FilePath = abs
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(parent, a.plugin.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, a.plugin.Path, a.plugin.Args...)
+	cmd.Stdin = bytes.NewReader(payload)
+	stdout := &cappedBuffer{limit: maxPluginOutput}
+	stderr := &cappedBuffer{limit: maxPluginOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Don't wait for children the plugin left holding its output open
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Start(); err != nil {
+		if parent.Err() != nil {
+			return fmt.Errorf("plugin %s stopped: %w", a.plugin.Name, parent.Err())
+		}
+		return fmt.Errorf("failed to start plugin %s: %w", a.plugin.Name, err)
+	}
+	if err := limitProcess(cmd.Process.Pid, a.plugin); err != nil {
+		logger.Warningf("Failed to apply resource limits to plugin %s: %v", a.plugin.Name, err)
+	}
+
+	err = cmd.Wait()
+	switch {
+	case parent.Err() != nil:
+		return fmt.Errorf("plugin %s stopped: %w", a.plugin.Name, parent.Err())
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("plugin %s timed out after %v", a.plugin.Name, a.plugin.Timeout)
+	case err != nil:
+		return fmt.Errorf("plugin %s failed: %w%s", a.plugin.Name, err, stderrSuffix(stderr.String()))
+	case stdout.truncated:
+		return fmt.Errorf("plugin %s wrote more than %d bytes", a.plugin.Name, maxPluginOutput)
+	}
+
+	if err := json.Unmarshal(bytes.TrimSpace(stdout.Bytes()), response); err != nil {
+		return fmt.Errorf("plugin %s returned an invalid response: %w", a.plugin.Name, err)
+	}
+	if response.Error != "" {
+		return fmt.Errorf("plugin %s: %s", a.plugin.Name, response.Error)
+	}
+	return nil
+}
+
+// pluginFactors converts the factor names a plugin reports
+func pluginFactors(names []string) (*ConfidenceFactors, error) {
+	factors := &ConfidenceFactors{}
+	fields := map[string]*bool{
+		FactorMagicMatch:       &factors.MagicMatch,
+		FactorExtensionMatch:   &factors.ExtensionMatch,
+		FactorParsedStructure:  &factors.ParsedStructure,
+		FactorSignaturePresent: &factors.SignaturePresent,
+		FactorVersionFound:     &factors.VersionFound,
+		FactorSizeInRange:      &factors.SizeInRange,
+		FactorTooSmall:         &factors.TooSmall,
+		FactorMalformed:        &factors.MalformedStructure,
+	}
+	for _, name := range names {
+		field, ok := fields[factorName(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown confidence factor %q", name)
+		}
+		*field = true
+	}
+	return factors, nil
+}
+
+// DiscoverPlugins returns a plugin for every executable in dir, in name order
+func DiscoverPlugins(dir string) ([]Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	var plugins []Plugin
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || !isExecutable(entry.Name(), info.Mode()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		plugins = append(plugins, Plugin{Name: pluginName(path), Path: path})
+	}
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].Name < plugins[j].Name
+	})
+	return plugins, nil
+}
+
+// isExecutable reports whether a plugin directory entry can be run
+func isExecutable(name string, mode os.FileMode) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(name), ".exe")
+	}
+	return mode&0111 != 0
+}
+
+// pluginName derives a plugin's name from its executable
+func pluginName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// stderrSuffix formats the end of a plugin's stderr for an error message
+func stderrSuffix(stderr string) string {
+	stderr = strings.TrimSpace(stderr)
+	if stderr == "" {
+		return ""
+	}
+	if len(stderr) > 500 {
+		stderr = "..." + stderr[len(stderr)-500:]
+	}
+	return ": " + stderr
+}
+
+// cappedBuffer collects output up to a limit and discards the rest
+type cappedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,15 @@
+//go:build linux
+
+package fileanalyzer
+
+import "golang.org/x/sys/unix"
+
+// limitProcess caps a plugin process's address space. The limit applies
+// from just after the process starts.
+func limitProcess(pid int, plugin Plugin) error {
+	if plugin.MemoryLimit <= 0 {
+		return nil
+	}
+	limit := &unix.Rlimit{Cur: uint64(plugin.MemoryLimit), Max: uint64(plugin.MemoryLimit)}
+	return unix.Prlimit(pid, unix.RLIMIT_AS, limit, nil)
+}
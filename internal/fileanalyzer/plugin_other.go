@@ -0,0 +1,8 @@
+//go:build !linux
+
+package fileanalyzer
+
+// limitProcess is a no-op; memory limits are only enforced on Linux
+func limitProcess(pid int, plugin Plugin) error {
+	return nil
+}
@@ -0,0 +1,113 @@
+package fileanalyzer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPluginHelper is run as a plugin by the other tests. It appends each
+// request's method to the file named by PLUGIN_HELPER_LOG and hangs on
+// analyze requests.
+func TestPluginHelper(t *testing.T) {
+	log := os.Getenv("PLUGIN_HELPER_LOG")
+	if log == "" {
+		return
+	}
+	var request pluginRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&request); err != nil {
+		os.Exit(2)
+	}
+	f, err := os.OpenFile(log, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		os.Exit(2)
+	}
+	fmt.Fprintln(f, request.Method)
+	f.Close()
+	if request.Method == "analyze" {
+		time.Sleep(time.Minute)
+	}
+	fmt.Print(`{"can_handle": true}`)
+	os.Exit(0)
+}
+
+// helperPlugin returns a plugin that runs TestPluginHelper and the file
+// its calls are logged to
+func helperPlugin(t *testing.T) (Plugin, string) {
+	t.Helper()
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := filepath.Join(t.TempDir(), "calls.log")
+	t.Setenv("PLUGIN_HELPER_LOG", log)
+	return Plugin{Name: "helper", Path: executable, Args: []string{"-test.run=^TestPluginHelper$"}, Timeout: time.Minute}, log
+}
+
+// pluginCalls returns the methods the helper plugin was called with
+func pluginCalls(t *testing.T, log string) []string {
+	t.Helper()
+	data, err := os.ReadFile(log)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Fields(string(data))
+}
+
+func TestPluginAnalyzeStopsWithContext(t *testing.T) {
+	plugin, log := helperPlugin(t)
+	analyzer := NewPluginAnalyzer(plugin)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := analyzer.AnalyzeContext(ctx, "setup.exe")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("AnalyzeContext() error = %v, want a deadline error", err)
+	}
+	// The call returns once the process has been waited for, so a prompt
+	// return means the plugin was killed rather than left running
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("AnalyzeContext() took %v after ctx was done", elapsed)
+	}
+	if calls := pluginCalls(t, log); len(calls) != 1 || calls[0] != "analyze" {
+		t.Errorf("plugin calls = %v, want one analyze", calls)
+	}
+}
+
+func TestManagerPassesContextToPlugins(t *testing.T) {
+	plugin, log := helperPlugin(t)
+	m := NewManager(WithPlugins(plugin), WithTimeout(2*time.Second))
+
+	start := time.Now()
+	if _, err := m.Analyze(context.Background(), "tool.bin", ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Analyze() error = %v, want a deadline error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Analyze() took %v with a 2s timeout", elapsed)
+	}
+	// Nothing was abandoned: the plugin was killed with the analysis
+	if len(m.abandoned) != 0 {
+		t.Errorf("%d abandoned analyzers, want 0", len(m.abandoned))
+	}
+	if calls := pluginCalls(t, log); len(calls) != 2 || calls[0] != "can_handle" || calls[1] != "analyze" {
+		t.Errorf("plugin calls = %v, want can_handle then analyze", calls)
+	}
+
+	// Once the analysis is stopped no plugin is started for the file
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.Analyze(ctx, "tool.bin", "")
+	if calls := pluginCalls(t, log); len(calls) != 2 {
+		t.Errorf("plugin calls after cancellation = %v", calls)
+	}
+}
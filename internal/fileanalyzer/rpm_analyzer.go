@@ -5,16 +5,23 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
-	"time"
 
 	"github.com/cavaliergopher/rpm"
+	"github.com/deploymenttheory/go-app-index/internal/arch"
 	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/version"
 )
 
 // RPMAnalyzer analyzes RPM package files
 type RPMAnalyzer struct{}
 
+// Name identifies the analyzer in merged results
+func (a *RPMAnalyzer) Name() string {
+	return "rpm"
+}
+
 // CanHandle checks if the file is potentially an RPM package
 func (a *RPMAnalyzer) CanHandle(filePath string, contentType string) bool {
 	ext := strings.ToLower(filepath.Ext(filePath))
@@ -56,7 +63,6 @@ func (a *RPMAnalyzer) Analyze(filePath string) (*Result, error) {
 			Confidence:  0.1,
 			IsInstaller: false,
 			Metadata:    make(map[string]interface{}),
-			AnalyzedAt:  time.Now(),
 		}, nil
 	}
 
@@ -67,16 +73,15 @@ func (a *RPMAnalyzer) Analyze(filePath string) (*Result, error) {
 		// Continue anyway, we might have enough information
 	}
 
-	// It's a valid RPM, so we have high confidence
-	confidence := 0.9
-
 	// Create installer metadata
 	installerMeta := &InstallerMetadata{
-		Name:       pkg.Name(),
-		Version:    pkg.Version(),
-		Publisher:  pkg.Vendor(),
-		PackageIDs: []string{pkg.Name()},
-		SHASum:     h.Sum(nil),
+		Name:          pkg.Name(),
+		Version:       pkg.Version(),
+		VersionSource: version.SourcePackage,
+		Publisher:     pkg.Vendor(),
+		PackageIDs:    []string{pkg.Name()},
+		Architecture:  arch.Normalize(pkg.Architecture()),
+		SHASum:        h.Sum(nil),
 	}
 
 	// Create main metadata map
@@ -98,12 +103,16 @@ func (a *RPMAnalyzer) Analyze(filePath string) (*Result, error) {
 	metadata["package_sourcerpm"] = pkg.SourceRPM()
 	metadata["url"] = pkg.URL()
 	metadata["license"] = pkg.License()
-	metadata["build_time"] = pkg.BuildTime()
+	metadata["build_time"] = pkg.BuildTime().UTC()
 	metadata["build_host"] = pkg.BuildHost()   // Add build host info
 	metadata["vendor"] = pkg.Vendor()          // Explicit vendor extraction
 	metadata["packager"] = pkg.Packager()      // Packager info
 	metadata["rpm_version"] = pkg.RPMVersion() // Extract RPM version
 
+	if dependencies := rpmDependencies(pkg.Requires()); len(dependencies) > 0 {
+		metadata["dependencies"] = dependencies
+	}
+
 	// Add package summary and description if available
 	if pkg.Summary() != "" {
 		metadata["summary"] = pkg.Summary()
@@ -137,9 +146,28 @@ func (a *RPMAnalyzer) Analyze(filePath string) (*Result, error) {
 	return &Result{
 		FileType:    "rpm",
 		Platform:    platform,
-		Confidence:  confidence,
 		IsInstaller: true,
 		Metadata:    metadata,
-		AnalyzedAt:  time.Now(),
+		// The RPM lead and headers parsed
+		Factors: &ConfidenceFactors{MagicMatch: true, ParsedStructure: true},
 	}, nil
 }
+
+// rpmDependencies returns the names of the packages an RPM requires, sorted.
+// Requirements on files and on rpmlib features are not packages, so they
+// are left out.
+func rpmDependencies(requires []rpm.Dependency) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, dep := range requires {
+		name := dep.Name()
+		if name == "" || seen[name] || strings.HasPrefix(name, "/") ||
+			strings.HasPrefix(name, "rpmlib(") || strings.HasPrefix(name, "config(") {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
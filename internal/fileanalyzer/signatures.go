@@ -6,7 +6,6 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/deploymenttheory/go-app-index/internal/logger"
 )
@@ -15,6 +14,7 @@ import (
 type FileSignature struct {
 	Name      string
 	Magic     []byte
+	Offset    int // Position of Magic from the start of the file
 	Extension string
 	Platform  string
 	Type      string
@@ -35,7 +35,8 @@ var knownSignatures = []FileSignature{
 	// Linux Packages
 	{Name: "Debian Package", Magic: []byte{0x21, 0x3C, 0x61, 0x72, 0x63, 0x68, 0x3E}, Extension: ".deb", Platform: "linux", Type: "deb"},
 	{Name: "RPM Package", Magic: []byte{0xED, 0xAB, 0xEE, 0xDB}, Extension: ".rpm", Platform: "linux", Type: "rpm"},
-	{Name: "AppImage", Magic: []byte{0x41, 0x49, 0x01}, Extension: ".AppImage", Platform: "linux", Type: "appimage"},
+	{Name: "AppImage", Magic: []byte{0x41, 0x49, 0x02}, Offset: 8, Extension: ".AppImage", Platform: "linux", Type: "appimage"},
+	{Name: "AppImage (type 1)", Magic: []byte{0x41, 0x49, 0x01}, Offset: 8, Extension: ".AppImage", Platform: "linux", Type: "appimage"},
 
 	// Java/Cross-platform
 	{Name: "JAR File", Magic: []byte{0x50, 0x4B, 0x03, 0x04}, Extension: ".jar", Platform: "multiplatform", Type: "jar"},
@@ -61,32 +62,30 @@ func (a *SignatureAnalyzer) Analyze(filePath string) (*Result, error) {
 	}
 	defer file.Close()
 
-	header := make([]byte, 8)
-	_, err = io.ReadAtLeast(file, header, len(header))
+	header := make([]byte, SignatureHeaderSize)
+	n, err := io.ReadAtLeast(file, header, 8)
 	if err != nil {
 		return nil, err
 	}
+	header = header[:n]
 
-	for _, sig := range knownSignatures {
-		if len(header) >= len(sig.Magic) && bytes.Equal(header[:len(sig.Magic)], sig.Magic) {
-			logger.Debugf("Signature match: %s for file %s", sig.Name, filePath)
-			ext := strings.ToLower(filepath.Ext(filePath))
-			confidence := 0.7
-			if ext == sig.Extension {
-				confidence = 0.9
-			}
-			return &Result{
-				FileType:    sig.Type,
-				Platform:    sig.Platform,
-				Confidence:  confidence,
-				IsInstaller: isLikelyInstaller(sig.Type),
-				Metadata: map[string]interface{}{
-					"signature_name": sig.Name,
-					"detected_by":    "signature",
-				},
-				AnalyzedAt: timeNow(),
-			}, nil
+	if sig, ok := DetectSignature(header); ok {
+		logger.Debugf("Signature match: %s for file %s", sig.Name, filePath)
+		ext := strings.ToLower(filepath.Ext(filePath))
+		confidence := 0.7
+		if ext == sig.Extension {
+			confidence = 0.9
 		}
+		return &Result{
+			FileType:    sig.Type,
+			Platform:    sig.Platform,
+			Confidence:  confidence,
+			IsInstaller: isLikelyInstaller(sig.Type),
+			Metadata: map[string]interface{}{
+				"signature_name": sig.Name,
+				"detected_by":    "signature",
+			},
+		}, nil
 	}
 
 	ext := strings.ToLower(filepath.Ext(filePath))
@@ -101,7 +100,6 @@ func (a *SignatureAnalyzer) Analyze(filePath string) (*Result, error) {
 				Metadata: map[string]interface{}{
 					"detected_by": "extension",
 				},
-				AnalyzedAt: timeNow(),
 			}, nil
 		}
 	}
@@ -112,10 +110,29 @@ func (a *SignatureAnalyzer) Analyze(filePath string) (*Result, error) {
 		Confidence:  0.1,
 		IsInstaller: false,
 		Metadata:    make(map[string]interface{}),
-		AnalyzedAt:  timeNow(),
 	}, nil
 }
 
+// SignatureHeaderSize is the number of leading bytes DetectSignature needs
+const SignatureHeaderSize = 16
+
+// DetectSignature matches the leading bytes of a file against the known
+// signatures and returns the first match
+func DetectSignature(header []byte) (FileSignature, bool) {
+	for _, sig := range knownSignatures {
+		end := sig.Offset + len(sig.Magic)
+		if len(header) >= end && bytes.Equal(header[sig.Offset:end], sig.Magic) {
+			return sig, true
+		}
+	}
+	return FileSignature{}, false
+}
+
+// IsInstallerType reports whether a detected file type is an installer format
+func IsInstallerType(fileType string) bool {
+	return isLikelyInstaller(fileType)
+}
+
 // isLikelyInstaller returns true if the file type is likely an installer
 func isLikelyInstaller(fileType string) bool {
 	installerTypes := map[string]bool{
@@ -129,7 +146,3 @@ func isLikelyInstaller(fileType string) bool {
 	}
 	return installerTypes[fileType]
 }
-
-var timeNow = func() time.Time {
-	return time.Now()
-}
@@ -0,0 +1,549 @@
+//go:build ignore
+
+// generate writes the synthetic installers the golden analysis tests run on.
+// Run it from internal/fileanalyzer with: go run testdata/generate.go
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+	"unicode/utf16"
+
+	"github.com/sassoftware/relic/v8/lib/comdoc"
+)
+
+// modTime is stamped on archive members so the output is reproducible
+var modTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func main() {
+	files := map[string]func() ([]byte, error){
+		"setup-nsis.exe":              nsisStub,
+		"tool_1.2.3-1_amd64.deb":      debPackage,
+		"tool-1.2.3-1.el9.x86_64.rpm": rpmPackage,
+		"Tool-3.1.0.pkg":              xarPackage,
+		"tool-bundle.zip":             zipArchive,
+	}
+	for name, generate := range files {
+		data, err := generate()
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join("testdata", name), data, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// The compound document writer edits a file in place
+	if err := msiPackage(filepath.Join("testdata", "tool-2.4.1-x64.msi")); err != nil {
+		log.Fatalf("msi: %v", err)
+	}
+}
+
+func utf16LE(s string) []byte {
+	var buf bytes.Buffer
+	for _, c := range utf16.Encode([]rune(s)) {
+		binary.Write(&buf, binary.LittleEndian, c)
+	}
+	return buf.Bytes()
+}
+
+// nsisStub is a 32-bit PE with a version resource and the strings of an
+// NSIS stub
+func nsisStub() ([]byte, error) {
+	const (
+		fileAlign    = 0x200
+		sectionAlign = 0x1000
+	)
+	text := append([]byte("NSIS Error\x00Error launching installer\x00"), make([]byte, 8)...)
+	var rsrc bytes.Buffer
+	for _, kv := range [][2]string{
+		{"CompanyName", "Example Software, Inc."},
+		{"ProductName", "Example Tool"},
+		{"ProductVersion", "2.4.1"},
+	} {
+		rsrc.Write(utf16LE(kv[0] + "\x00"))
+		rsrc.Write(utf16LE(kv[1] + "\x00"))
+	}
+	sections := []struct {
+		name string
+		data []byte
+		flag uint32
+	}{
+		{".text", text, 0x60000020},
+		{".rsrc", rsrc.Bytes(), 0x40000040},
+	}
+
+	var buf bytes.Buffer
+	le := func(v interface{}) { binary.Write(&buf, binary.LittleEndian, v) }
+
+	// DOS header pointing at the PE header
+	dos := make([]byte, 0x40)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x40)
+	buf.Write(dos)
+
+	buf.WriteString("PE\x00\x00")
+	le(uint16(0x14c)) // i386
+	le(uint16(len(sections)))
+	le(uint32(0))   // TimeDateStamp
+	le(uint32(0))   // PointerToSymbolTable
+	le(uint32(0))   // NumberOfSymbols
+	le(uint16(224)) // SizeOfOptionalHeader
+	le(uint16(0x0102))
+
+	headers := uint32(fileAlign)
+	imageSize := uint32(sectionAlign * (len(sections) + 1))
+	le(uint16(0x10b)) // PE32
+	le(uint8(14))
+	le(uint8(0))
+	le(uint32(fileAlign)) // SizeOfCode
+	le(uint32(fileAlign)) // SizeOfInitializedData
+	le(uint32(0))
+	le(uint32(sectionAlign)) // AddressOfEntryPoint
+	le(uint32(sectionAlign)) // BaseOfCode
+	le(uint32(2 * sectionAlign))
+	le(uint32(0x400000)) // ImageBase
+	le(uint32(sectionAlign))
+	le(uint32(fileAlign))
+	le(uint16(5))
+	le(uint16(1))
+	le(uint16(0))
+	le(uint16(0))
+	le(uint16(5))
+	le(uint16(1))
+	le(uint32(0))
+	le(imageSize)
+	le(headers)
+	le(uint32(0))      // CheckSum
+	le(uint16(2))      // IMAGE_SUBSYSTEM_WINDOWS_GUI
+	le(uint16(0x8140)) // DllCharacteristics
+	le(uint32(0x100000))
+	le(uint32(0x1000))
+	le(uint32(0x100000))
+	le(uint32(0x1000))
+	le(uint32(0))
+	le(uint32(16))
+	buf.Write(make([]byte, 16*8)) // Data directories
+
+	for i, s := range sections {
+		name := make([]byte, 8)
+		copy(name, s.name)
+		buf.Write(name)
+		le(uint32(len(s.data)))
+		le(uint32(sectionAlign * (i + 1)))
+		le(uint32(fileAlign))
+		le(uint32(fileAlign * (i + 1)))
+		le(uint32(0))
+		le(uint32(0))
+		le(uint16(0))
+		le(uint16(0))
+		le(s.flag)
+	}
+	for _, s := range sections {
+		buf.Write(make([]byte, fileAlign*((buf.Len()+fileAlign-1)/fileAlign)-buf.Len()))
+		buf.Write(s.data)
+	}
+	buf.Write(make([]byte, fileAlign*((buf.Len()+fileAlign-1)/fileAlign)-buf.Len()))
+	return buf.Bytes(), nil
+}
+
+// emptyCompoundDocument is a compound document with a root storage and an
+// empty stream, which the compound document writer needs to start from:
+// the header, a FAT sector, a directory sector, an empty short stream
+// container and its allocation table
+func emptyCompoundDocument() []byte {
+	const (
+		endOfChain = 0xfffffffe
+		freeSect   = 0xffffffff
+		fatSect    = 0xfffffffd
+		noStream   = 0xffffffff
+	)
+	var buf bytes.Buffer
+	le := func(v interface{}) { binary.Write(&buf, binary.LittleEndian, v) }
+
+	buf.Write([]byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1})
+	buf.Write(make([]byte, 16))
+	le(uint16(0x3e))
+	le(uint16(3))
+	le(uint16(0xfffe))
+	le(uint16(9)) // 512-byte sectors
+	le(uint16(6)) // 64-byte short sectors
+	buf.Write(make([]byte, 6))
+	le(uint32(0))
+	le(uint32(1)) // FAT sectors
+	le(uint32(1)) // First directory sector
+	le(uint32(0))
+	le(uint32(4096))
+	le(uint32(3)) // Short sector allocation table
+	le(uint32(1))
+	le(uint32(endOfChain))
+	le(uint32(0))
+	le(uint32(0)) // The FAT is sector 0
+	for i := 1; i < 109; i++ {
+		le(uint32(freeSect))
+	}
+
+	le(uint32(fatSect))
+	le(uint32(endOfChain)) // Directory
+	le(uint32(endOfChain)) // Short stream container
+	le(uint32(endOfChain)) // Short sector allocation table
+	for i := 4; i < 128; i++ {
+		le(uint32(freeSect))
+	}
+
+	root := make([]byte, 128)
+	name := utf16LE("Root Entry\x00")
+	copy(root, name)
+	binary.LittleEndian.PutUint16(root[64:], uint16(len(name)))
+	root[66] = 5 // Root storage
+	root[67] = 1 // Black
+	binary.LittleEndian.PutUint32(root[68:], noStream)
+	binary.LittleEndian.PutUint32(root[72:], noStream)
+	binary.LittleEndian.PutUint32(root[76:], 1)
+	binary.LittleEndian.PutUint32(root[116:], 2)
+	binary.LittleEndian.PutUint32(root[120:], 512)
+	buf.Write(root)
+
+	stream := make([]byte, 128)
+	name = utf16LE("\x05DocumentSummaryInformation\x00")
+	copy(stream, name)
+	binary.LittleEndian.PutUint16(stream[64:], uint16(len(name)))
+	stream[66] = 2 // Stream
+	stream[67] = 1
+	binary.LittleEndian.PutUint32(stream[68:], noStream)
+	binary.LittleEndian.PutUint32(stream[72:], noStream)
+	binary.LittleEndian.PutUint32(stream[76:], noStream)
+	binary.LittleEndian.PutUint32(stream[116:], endOfChain)
+	buf.Write(stream)
+	for i := 2; i < 4; i++ {
+		entry := make([]byte, 128)
+		binary.LittleEndian.PutUint32(entry[68:], noStream)
+		binary.LittleEndian.PutUint32(entry[72:], noStream)
+		binary.LittleEndian.PutUint32(entry[76:], noStream)
+		buf.Write(entry)
+	}
+	buf.Write(make([]byte, 512))
+	for i := 0; i < 128; i++ {
+		le(uint32(freeSect))
+	}
+	return buf.Bytes()
+}
+
+// msiTableName encodes a table name the way MSI names its streams
+func msiTableName(name string) string {
+	const charset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz._"
+	index := func(c byte) rune {
+		return rune(bytes.IndexByte([]byte(charset), c))
+	}
+	runes := []rune{0x4840}
+	for i := 0; i < len(name); i += 2 {
+		if i+1 < len(name) {
+			runes = append(runes, 0x3800+index(name[i])+index(name[i+1])<<6)
+		} else {
+			runes = append(runes, 0x4800+index(name[i]))
+		}
+	}
+	return string(runes)
+}
+
+// msiPackage writes an x64 MSI with a Property table and summary
+// information
+func msiPackage(path string) error {
+	if err := os.WriteFile(path, emptyCompoundDocument(), 0644); err != nil {
+		return err
+	}
+	doc, err := comdoc.WritePath(path)
+	if err != nil {
+		return err
+	}
+
+	properties := [][2]string{
+		{"ProductName", "Example Tool"},
+		{"ProductVersion", "2.4.1"},
+		{"Manufacturer", "Example Software, Inc."},
+		{"ProductCode", "{6f1c1a38-2d3e-4b1a-9c55-0c6c1f0b7a11}"},
+		{"UpgradeCode", "{0b3f7e52-8a44-4f1e-b1d2-6a9e4c3d2f10}"},
+	}
+	var pool, data, keys, values bytes.Buffer
+	binary.Write(&pool, binary.LittleEndian, uint32(0)) // Code page, 2-byte references
+	id := uint16(1)
+	for _, kv := range properties {
+		for _, s := range kv {
+			binary.Write(&pool, binary.LittleEndian, uint16(len(s)))
+			binary.Write(&pool, binary.LittleEndian, uint16(1))
+			data.WriteString(s)
+		}
+		binary.Write(&keys, binary.LittleEndian, id)
+		binary.Write(&values, binary.LittleEndian, id+1)
+		id += 2
+	}
+
+	template := "x64;1033\x00"
+	var summary bytes.Buffer
+	le := func(v interface{}) { binary.Write(&summary, binary.LittleEndian, v) }
+	le(uint16(0xfffe))
+	le(uint16(0))
+	le(uint32(0x00020006))
+	summary.Write(make([]byte, 16))
+	le(uint32(1))
+	summary.Write([]byte{0xe0, 0x85, 0x9f, 0xf2, 0xf9, 0x4f, 0x68, 0x10, 0xab, 0x91, 0x08, 0x00, 0x2b, 0x27, 0xb3, 0xd9})
+	le(uint32(48))
+	le(uint32(8 + 8 + 8 + len(template)))
+	le(uint32(1))
+	le(uint32(7)) // PID_TEMPLATE
+	le(uint32(16))
+	le(uint32(30)) // VT_LPSTR
+	le(uint32(len(template)))
+	summary.WriteString(template)
+
+	streams := map[string][]byte{
+		msiTableName("_StringPool"): pool.Bytes(),
+		msiTableName("_StringData"): data.Bytes(),
+		msiTableName("Property"):    append(keys.Bytes(), values.Bytes()...),
+		"\x05SummaryInformation":    summary.Bytes(),
+	}
+	for _, name := range []string{msiTableName("_StringPool"), msiTableName("_StringData"), msiTableName("Property"), "\x05SummaryInformation"} {
+		if err := doc.AddFile(name, streams[name]); err != nil {
+			return err
+		}
+	}
+	return doc.Close()
+}
+
+func tarGz(files map[string]string, order []string) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range order {
+		content := files[name]
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), ModTime: modTime}); err != nil {
+			return nil, err
+		}
+		tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// debPackage is a Debian package with a control file and a
+// machine-readable copyright file
+func debPackage() ([]byte, error) {
+	control, err := tarGz(map[string]string{"./control": `Package: example-tool
+Source: example-tool-src (1.2.3-1)
+Version: 1.2.3-1
+Architecture: amd64
+Maintainer: Example Maintainers <packages@example.com>
+Pre-Depends: dpkg (>= 1.17)
+Depends: libc6 (>= 2.34), libssl3 | libssl1.1, zlib1g:any
+Description: example tool
+`}, []string{"./control"})
+	if err != nil {
+		return nil, err
+	}
+	data, err := tarGz(map[string]string{"./usr/share/doc/example-tool/copyright": `Format: https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/
+Upstream-Name: example-tool
+
+Files: *
+License: Apache-2.0
+
+Files: vendor/*
+License: MIT or BSD-3-Clause
+`}, []string{"./usr/share/doc/example-tool/copyright"})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("!<arch>\n")
+	for _, member := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", control},
+		{"data.tar.gz", data},
+	} {
+		fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", member.name, modTime.Unix(), 0, 0, "100644", len(member.data))
+		buf.Write(member.data)
+		if len(member.data)%2 != 0 {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// rpmTag is one entry of an RPM header
+type rpmTag struct {
+	id    uint32
+	typ   uint32
+	value interface{} // string, []string or []uint32
+}
+
+// rpmHeader encodes an RPM header structure
+func rpmHeader(tags []rpmTag) []byte {
+	const (
+		typeInt32       = 4
+		typeString      = 6
+		typeStringArray = 8
+	)
+	var index, store bytes.Buffer
+	for _, tag := range tags {
+		count := 1
+		if tag.typ == typeInt32 {
+			for store.Len()%4 != 0 {
+				store.WriteByte(0)
+			}
+		}
+		offset := store.Len()
+		switch v := tag.value.(type) {
+		case string:
+			store.WriteString(v + "\x00")
+		case []string:
+			count = len(v)
+			for _, s := range v {
+				store.WriteString(s + "\x00")
+			}
+		case []uint32:
+			count = len(v)
+			for _, n := range v {
+				binary.Write(&store, binary.BigEndian, n)
+			}
+		}
+		binary.Write(&index, binary.BigEndian, []uint32{tag.id, tag.typ, uint32(offset), uint32(count)})
+	}
+
+	var buf bytes.Buffer
+	buf.Write([]byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0})
+	binary.Write(&buf, binary.BigEndian, uint32(len(tags)))
+	binary.Write(&buf, binary.BigEndian, uint32(store.Len()))
+	buf.Write(index.Bytes())
+	buf.Write(store.Bytes())
+	return buf.Bytes()
+}
+
+// rpmPackage is an RPM lead, an empty signature and a header without a
+// payload
+func rpmPackage() ([]byte, error) {
+	const (
+		typeInt32       = 4
+		typeString      = 6
+		typeStringArray = 8
+		typeI18NString  = 9
+	)
+	lead := make([]byte, 96)
+	copy(lead, []byte{0xed, 0xab, 0xee, 0xdb, 3, 0})
+	binary.BigEndian.PutUint16(lead[8:], 1) // x86_64
+	copy(lead[10:], "example-tool-1.2.3-1.el9")
+	binary.BigEndian.PutUint16(lead[76:], 1) // Linux
+	binary.BigEndian.PutUint16(lead[78:], 5) // Header-style signature
+
+	signature := rpmHeader(nil)
+	for len(signature)%8 != 0 {
+		signature = append(signature, 0)
+	}
+	header := rpmHeader([]rpmTag{
+		{1000, typeString, "example-tool"},
+		{1001, typeString, "1.2.3"},
+		{1002, typeString, "1.el9"},
+		{1003, typeInt32, []uint32{2}},
+		{1004, typeI18NString, []string{"Example command-line tool"}},
+		{1005, typeI18NString, []string{"An example tool packaged for the analyzer tests."}},
+		{1006, typeInt32, []uint32{uint32(modTime.Unix())}},
+		{1007, typeString, "build.example.com"},
+		{1011, typeString, "Example Software, Inc."},
+		{1014, typeString, "Apache-2.0"},
+		{1015, typeString, "Example Packagers <rpm@example.com>"},
+		{1016, typeI18NString, []string{"Applications/System"}},
+		{1020, typeString, "https://example.com/tool"},
+		{1021, typeString, "linux"},
+		{1022, typeString, "x86_64"},
+		{1044, typeString, "example-tool-1.2.3-1.el9.src.rpm"},
+		{1048, typeInt32, []uint32{0x4c, 0, 0x1000000}},
+		{1049, typeStringArray, []string{"glibc", "/bin/sh", "rpmlib(CompressedFileNames)"}},
+		{1050, typeStringArray, []string{"2.34", "", "3.0.4-1"}},
+		{1064, typeString, "4.16.1.3"},
+		{1132, typeString, "x86_64-redhat-linux-gnu"},
+	})
+	return append(append(lead, signature...), header...), nil
+}
+
+func zlibBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// xarPackage is a flat macOS package whose only member is its PackageInfo
+func xarPackage() ([]byte, error) {
+	packageInfo := []byte(`<?xml version="1.0" encoding="utf-8"?>
+<pkg-info format-version="2" identifier="com.example.tool.pkg" version="3.1.0" install-location="/Applications" auth="root">
+    <bundle path="./Tool.app" id="com.example.tool" CFBundleShortVersionString="3.1.0" CFBundleVersion="310"/>
+    <bundle path="./Tool.app/Contents/Helpers/Updater.app" id="com.example.tool.updater" CFBundleShortVersionString="1.0"/>
+</pkg-info>
+`)
+	heap := zlibBytes(packageInfo)
+	toc := []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<xar>
+ <toc>
+  <file id="1">
+   <name>PackageInfo</name>
+   <type>file</type>
+   <data>
+    <length>%d</length>
+    <offset>0</offset>
+    <size>%d</size>
+    <encoding style="application/x-gzip"/>
+   </data>
+  </file>
+ </toc>
+</xar>
+`, len(heap), len(packageInfo)))
+	compressed := zlibBytes(toc)
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint32(0x78617221))
+	binary.Write(&buf, binary.BigEndian, uint16(28))
+	binary.Write(&buf, binary.BigEndian, uint16(1))
+	binary.Write(&buf, binary.BigEndian, uint64(len(compressed)))
+	binary.Write(&buf, binary.BigEndian, uint64(len(toc)))
+	binary.Write(&buf, binary.BigEndian, uint32(0)) // No checksum
+	buf.Write(compressed)
+	buf.Write(heap)
+	return buf.Bytes(), nil
+}
+
+// zipArchive is a ZIP with documentation and no installer inside
+func zipArchive() ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct{ name, content string }{
+		{"tool/README.txt", "Example Tool 2.4.1\n"},
+		{"tool/LICENSE", "Apache License 2.0\n"},
+		{"tool/bin/tool.sh", "#!/bin/sh\necho tool\n"},
+	} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: modTime})
+		if err != nil {
+			return nil, err
+		}
+		w.Write([]byte(f.content))
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
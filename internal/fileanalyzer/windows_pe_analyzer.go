@@ -1,23 +1,34 @@
 package fileanalyzer
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"crypto/x509"
 	"debug/pe"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/pem"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"unicode/utf16"
 
+	"github.com/deploymenttheory/go-app-index/internal/arch"
 	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/version"
 )
 
 // PEAnalyzer analyzes Windows PE (Portable Executable) files
 type PEAnalyzer struct{}
 
+// Name identifies the analyzer in merged results
+func (a *PEAnalyzer) Name() string {
+	return "pe"
+}
+
 // CanHandle checks if the file is a potential Windows PE file
 func (a *PEAnalyzer) CanHandle(filePath string, contentType string) bool {
 	// Ensure the file has a valid PE extension
@@ -85,6 +96,16 @@ func (a *PEAnalyzer) Analyze(filePath string) (*Result, error) {
 		metadata["installer_type"] = installerType
 	}
 
+	// A setup engine's stub runs as i386 whatever it installs, so its
+	// machine type is only kept as the stub's
+	if architecture := peArchitecture(file.Machine); architecture != "" {
+		if stubInstallers[installerType] {
+			metadata["stub_architecture"] = architecture
+		} else {
+			metadata["architecture"] = architecture
+		}
+	}
+
 	// Check digital signature
 	isSigned, signatureInfo := checkSignature(filePath)
 	metadata["is_signed"] = isSigned
@@ -110,22 +131,47 @@ func (a *PEAnalyzer) Analyze(filePath string) (*Result, error) {
 	return &Result{
 		FileType:    fileType,
 		Platform:    "windows",
-		Confidence:  0.9,
 		IsInstaller: installerType != "",
 		Metadata:    metadata,
-		AnalyzedAt:  timeNow(),
+		// The PE headers parsed
+		Factors: &ConfidenceFactors{MagicMatch: true, ParsedStructure: true},
 	}, nil
 }
 
 func getBitness(file *pe.File) string {
+	if _, ok := file.OptionalHeader.(*pe.OptionalHeader64); ok {
+		return "64-bit"
+	}
 	switch file.Machine {
-	case pe.IMAGE_FILE_MACHINE_AMD64, pe.IMAGE_FILE_MACHINE_IA64:
+	case pe.IMAGE_FILE_MACHINE_AMD64, pe.IMAGE_FILE_MACHINE_IA64, pe.IMAGE_FILE_MACHINE_ARM64:
 		return "64-bit"
 	default:
 		return "32-bit"
 	}
 }
 
+// Machine types debug/pe does not define
+const (
+	imageFileMachineARM64EC = 0xa641 // ARM64 code that interoperates with x64
+	imageFileMachineARM64X  = 0xa64e // Hybrid ARM64 and ARM64EC image
+)
+
+// peArchitecture maps a PE machine type to a normalized architecture
+func peArchitecture(machine uint16) string {
+	switch machine {
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return arch.X86
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return arch.X64
+	case pe.IMAGE_FILE_MACHINE_ARM64, imageFileMachineARM64EC, imageFileMachineARM64X:
+		return arch.ARM64
+	case pe.IMAGE_FILE_MACHINE_ARM, pe.IMAGE_FILE_MACHINE_ARMNT, pe.IMAGE_FILE_MACHINE_THUMB:
+		return arch.ARM
+	default:
+		return ""
+	}
+}
+
 func getSubsystem(file *pe.File) string {
 	if hdr, ok := file.OptionalHeader.(*pe.OptionalHeader64); ok {
 		switch hdr.Subsystem {
@@ -145,45 +191,141 @@ func getSubsystem(file *pe.File) string {
 	return "unknown"
 }
 
+// versionResourceFields maps metadata keys to version resource strings
+var versionResourceFields = []struct {
+	key  string
+	name string
+}{
+	{"version", "ProductVersion"},
+	{"company", "CompanyName"},
+	{"product_name", "ProductName"},
+}
+
+// extractVersionInfo reads the product version, company and product name
+// from the PE version resource. The four-part product version in
+// VS_FIXEDFILEINFO is used when the string table has no usable version.
 func extractVersionInfo(filePath string) (map[string]string, error) {
-	data, err := os.ReadFile(filePath)
+	data, err := resourceData(filePath)
 	if err != nil {
 		return nil, err
 	}
 
 	info := make(map[string]string)
-	patterns := map[string]string{
-		"version":      `(?i)ProductVersion[\s\x00]+([0-9]+\.[0-9]+\.[0-9]+\.[0-9]+)`,
-		"company":      `(?i)CompanyName[\s\x00]+([^\x00]{3,50})`,
-		"product_name": `(?i)ProductName[\s\x00]+([^\x00]{3,50})`,
+	for _, field := range versionResourceFields {
+		if value := versionResourceString(data, field.name); value != "" {
+			info[field.key] = value
+		}
 	}
 
-	for key, pattern := range patterns {
-		matches := regexp.MustCompile(pattern).FindStringSubmatch(string(data))
-		if len(matches) > 1 {
-			info[key] = strings.TrimSpace(matches[1])
+	if _, ok := version.Parse(info["version"]); !ok {
+		delete(info, "version")
+		if fixed := fixedProductVersion(data); fixed != "" {
+			info["version"] = fixed
 		}
 	}
+	if info["version"] != "" {
+		info["version_source"] = string(version.SourceResource)
+	}
 
 	return info, nil
 }
 
+// resourceData returns the contents of a PE file's resource section, or the
+// whole file when it has none
+func resourceData(filePath string) ([]byte, error) {
+	file, err := pe.Open(filePath)
+	if err == nil {
+		defer file.Close()
+		if section := file.Section(".rsrc"); section != nil {
+			if data, err := section.Data(); err == nil {
+				return data, nil
+			}
+		}
+	}
+	return os.ReadFile(filePath)
+}
+
+// versionResourceString reads a value from the StringFileInfo table of a
+// version resource, where keys and values are NUL-terminated UTF-16
+func versionResourceString(data []byte, key string) string {
+	needle := encodeUTF16LE(key + "\x00")
+	i := bytes.Index(data, needle)
+	if i < 0 {
+		return ""
+	}
+	pos := i + len(needle)
+
+	// Values are 32-bit aligned, so at most one padding character follows
+	if pos+1 < len(data) && data[pos] == 0 && data[pos+1] == 0 {
+		pos += 2
+	}
+
+	var chars []uint16
+	for ; pos+1 < len(data) && len(chars) < 256; pos += 2 {
+		c := binary.LittleEndian.Uint16(data[pos:])
+		if c == 0 {
+			break
+		}
+		chars = append(chars, c)
+	}
+	return strings.TrimSpace(string(utf16.Decode(chars)))
+}
+
+// fixedProductVersion reads the binary product version from the
+// VS_FIXEDFILEINFO structure
+func fixedProductVersion(data []byte) string {
+	signature := []byte{0xbd, 0x04, 0xef, 0xfe}
+	i := bytes.Index(data, signature)
+	if i < 0 || i+24 > len(data) {
+		return ""
+	}
+	ms := binary.LittleEndian.Uint32(data[i+16:])
+	ls := binary.LittleEndian.Uint32(data[i+20:])
+	if ms == 0 && ls == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d.%d", ms>>16, ms&0xffff, ls>>16, ls&0xffff)
+}
+
+func encodeUTF16LE(s string) []byte {
+	chars := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(chars))
+	for i, c := range chars {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+	return b
+}
+
+// installerTypePatterns identify setup engines by strings in the binary.
+// They are tried in order, since an installer can mention several engines.
+var installerTypePatterns = []struct {
+	installer string
+	pattern   *regexp.Regexp
+}{
+	{"installshield", regexp.MustCompile(`(?i)InstallShield`)},
+	{"nsis", regexp.MustCompile(`(?i)NSIS`)},
+	{"inno_setup", regexp.MustCompile(`(?i)Inno Setup`)},
+	{"msi", regexp.MustCompile(`(?i)Windows Installer`)},
+}
+
+// stubInstallers are setup engines that wrap their payload in a 32-bit
+// stub executable
+var stubInstallers = map[string]bool{
+	"installshield": true,
+	"nsis":          true,
+	"inno_setup":    true,
+}
+
+// detectInstallerType returns the setup engine that built an installer
 func detectInstallerType(filePath string) string {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return ""
 	}
 
-	patterns := map[string]string{
-		"installshield": `(?i)InstallShield`,
-		"nsis":          `(?i)NSIS`,
-		"inno_setup":    `(?i)Inno Setup`,
-		"msi":           `(?i)Windows Installer`,
-	}
-
-	for installer, pattern := range patterns {
-		if regexp.MustCompile(pattern).MatchString(string(data)) {
-			return installer
+	for _, p := range installerTypePatterns {
+		if p.pattern.Match(data) {
+			return p.installer
 		}
 	}
 
@@ -3,6 +3,7 @@ package fileanalyzer
 import (
 	"archive/zip"
 	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"os"
 	"path/filepath"
@@ -14,6 +15,11 @@ import (
 // ZipAnalyzer analyzes ZIP-based package files
 type ZipAnalyzer struct{}
 
+// Name identifies the analyzer in merged results
+func (a *ZipAnalyzer) Name() string {
+	return "zip"
+}
+
 // CanHandle checks if the file is a ZIP archive
 func (a *ZipAnalyzer) CanHandle(filePath string, contentType string) bool {
 	ext := strings.ToLower(filepath.Ext(filePath))
@@ -33,7 +39,10 @@ func (a *ZipAnalyzer) Analyze(filePath string) (*Result, error) {
 	metadata["sha256"] = shaSum
 
 	// Scan for potential installer files
-	containsInstaller, installerFiles := scanForInstallers(filePath)
+	containsInstaller, installerFiles, err := scanForInstallers(filePath)
+	if err != nil {
+		logger.Errorf("Failed to open ZIP: %v", err)
+	}
 	metadata["contains_installer"] = containsInstaller
 	metadata["installer_files"] = installerFiles
 
@@ -47,14 +56,13 @@ func (a *ZipAnalyzer) Analyze(filePath string) (*Result, error) {
 	return &Result{
 		FileType:    "zip",
 		Platform:    "unknown",
-		Confidence:  0.7,
 		IsInstaller: false,
 		Metadata:    metadata,
-		AnalyzedAt:  timeNow(),
+		Factors:     &ConfidenceFactors{MagicMatch: err == nil, ParsedStructure: err == nil},
 	}, nil
 }
 
-// computeSHA256 calculates the SHA-256 hash of a file
+// computeSHA256 calculates the SHA-256 hash of a file as hex
 func computeSHA256(filePath string) (string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -67,15 +75,14 @@ func computeSHA256(filePath string) (string, error) {
 		return "", err
 	}
 
-	return string(hash.Sum(nil)), nil
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
 // scanForInstallers checks for known installer formats inside the ZIP
-func scanForInstallers(zipPath string) (bool, []string) {
+func scanForInstallers(zipPath string) (bool, []string, error) {
 	reader, err := zip.OpenReader(zipPath)
 	if err != nil {
-		logger.Errorf("Failed to open ZIP: %v", err)
-		return false, nil
+		return false, nil, err
 	}
 	defer reader.Close()
 
@@ -94,7 +101,7 @@ func scanForInstallers(zipPath string) (bool, []string) {
 		}
 	}
 
-	return len(installerFiles) > 0, installerFiles
+	return len(installerFiles) > 0, installerFiles, nil
 }
 
 // delegateToInstallerAnalyzer determines the correct analyzer for an installer
@@ -114,6 +121,5 @@ func delegateToInstallerAnalyzer(installerPath string) (*Result, error) {
 		Confidence:  0.9,
 		IsInstaller: true,
 		Metadata:    map[string]interface{}{"original_file": installerPath},
-		AnalyzedAt:  timeNow(),
 	}, nil
 }
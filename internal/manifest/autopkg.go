@@ -0,0 +1,126 @@
+package manifest
+
+import (
+	"path"
+	"strings"
+
+	"howett.net/plist"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// autopkgIdentifierPrefix prefixes the identifiers of generated recipes
+const autopkgIdentifierPrefix = "com.github.installer-scraper"
+
+// autopkgMinimumVersion is the oldest AutoPkg the recipes run on
+const autopkgMinimumVersion = "2.3"
+
+// AutoPkgFormatter writes a download recipe and a Munki recipe that
+// imports the download for each macOS disk image, archive or package
+type AutoPkgFormatter struct{}
+
+type autopkgRecipe struct {
+	Description    string                 `plist:"Description"`
+	Identifier     string                 `plist:"Identifier"`
+	Input          map[string]interface{} `plist:"Input"`
+	MinimumVersion string                 `plist:"MinimumVersion"`
+	ParentRecipe   string                 `plist:"ParentRecipe,omitempty"`
+	Process        []autopkgStep          `plist:"Process"`
+}
+
+type autopkgStep struct {
+	Processor string                 `plist:"Processor"`
+	Arguments map[string]interface{} `plist:"Arguments,omitempty"`
+}
+
+func (a *AutoPkgFormatter) Name() string {
+	return "autopkg"
+}
+
+func (a *AutoPkgFormatter) CanFormat(file types.ProcessedFile) bool {
+	if file.Platform != "macos" {
+		return false
+	}
+	switch file.FileType {
+	case "dmg", "zip", "pkg":
+		return true
+	default:
+		return false
+	}
+}
+
+// Format requires a name and download URL
+func (a *AutoPkgFormatter) Format(file types.ProcessedFile) ([]File, error) {
+	f := newFields(a.Name(), file)
+	displayName := f.name()
+	name := f.require("name", identifier(displayName, ""))
+	url := f.require("source_url", f.url())
+	if err := f.err(); err != nil {
+		return nil, err
+	}
+
+	ext := path.Ext(file.Filename)
+	if ext == "" {
+		ext = "." + file.FileType
+	}
+	downloadID := autopkgIdentifierPrefix + ".download." + name
+	download := autopkgRecipe{
+		Description:    "Downloads " + displayName + ".",
+		Identifier:     downloadID,
+		Input:          map[string]interface{}{"NAME": name},
+		MinimumVersion: autopkgMinimumVersion,
+		Process: []autopkgStep{
+			{Processor: "URLDownloader", Arguments: map[string]interface{}{
+				"url":      url,
+				"filename": "%NAME%" + strings.ToLower(ext),
+			}},
+			{Processor: "EndOfCheckPhase"},
+		},
+	}
+
+	pkginfo := map[string]interface{}{
+		"catalogs":           munkiCatalogs,
+		"display_name":       displayName,
+		"name":               "%NAME%",
+		"unattended_install": false,
+	}
+	if description := f.description(); description != "" {
+		pkginfo["description"] = description
+	}
+	if developer := f.publisher(); developer != "" {
+		pkginfo["developer"] = developer
+	}
+	munki := autopkgRecipe{
+		Description: "Imports " + displayName + " into Munki.",
+		Identifier:  autopkgIdentifierPrefix + ".munki." + name,
+		Input: map[string]interface{}{
+			"NAME":              name,
+			"MUNKI_REPO_SUBDIR": "apps/%NAME%",
+			"pkginfo":           pkginfo,
+		},
+		MinimumVersion: autopkgMinimumVersion,
+		ParentRecipe:   downloadID,
+		Process: []autopkgStep{
+			{Processor: "MunkiImporter", Arguments: map[string]interface{}{
+				"pkg_path":          "%pathname%",
+				"repo_subdirectory": "%MUNKI_REPO_SUBDIR%",
+			}},
+		},
+	}
+
+	var files []File
+	for _, recipe := range []struct {
+		kind   string
+		recipe autopkgRecipe
+	}{{"download", download}, {"munki", munki}} {
+		content, err := plist.MarshalIndent(recipe.recipe, plist.XMLFormat, "\t")
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{
+			Path:    path.Join(name, name+"."+recipe.kind+".recipe"),
+			Content: append(plistComment(content), '\n'),
+		})
+	}
+	return files, nil
+}
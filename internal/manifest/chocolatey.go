@@ -0,0 +1,120 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// ChocolateyFormatter writes a Chocolatey package for each installer: a
+// nuspec and a tools/chocolateyinstall.ps1 that downloads the installer,
+// verifies its checksum and runs it silently
+type ChocolateyFormatter struct{}
+
+type nuspec struct {
+	XMLName  xml.Name       `xml:"package"`
+	XMLNS    string         `xml:"xmlns,attr"`
+	Metadata nuspecMetadata `xml:"metadata"`
+	Files    []nuspecFile   `xml:"files>file"`
+}
+
+type nuspecMetadata struct {
+	ID          string `xml:"id"`
+	Version     string `xml:"version"`
+	Title       string `xml:"title"`
+	Authors     string `xml:"authors"`
+	ProjectURL  string `xml:"projectUrl,omitempty"`
+	Tags        string `xml:"tags"`
+	Description string `xml:"description"`
+}
+
+type nuspecFile struct {
+	Src    string `xml:"src,attr"`
+	Target string `xml:"target,attr"`
+}
+
+func (c *ChocolateyFormatter) Name() string {
+	return "chocolatey"
+}
+
+func (c *ChocolateyFormatter) CanFormat(file types.ProcessedFile) bool {
+	return file.FileType == "exe" || file.FileType == "msi"
+}
+
+// Format requires a name, publisher, version, download URL and SHA-256;
+// and a setup engine with known silent arguments for executables
+func (c *ChocolateyFormatter) Format(file types.ProcessedFile) ([]File, error) {
+	f := newFields(c.Name(), file)
+	name := f.name()
+	id := f.require("name", token(name))
+	publisher := f.require("publisher", f.publisher())
+	version := f.require("version", f.version())
+	url := f.require("source_url", f.url())
+	checksum := f.require("sha256", f.file.SHA256())
+
+	args := f.require("installer_type", silentArgs[f.engine()])
+	if err := f.err(); err != nil {
+		return nil, err
+	}
+
+	description := f.descriptionOr(name, publisher)
+	spec := nuspec{
+		XMLNS: "http://schemas.microsoft.com/packaging/2015/06/nuspec.xsd",
+		Metadata: nuspecMetadata{
+			ID:          id,
+			Version:     version,
+			Title:       name,
+			Authors:     publisher,
+			ProjectURL:  f.homepage(),
+			Tags:        strings.Join(append([]string{id}, file.Tags...), " "),
+			Description: description,
+		},
+		Files: []nuspecFile{{Src: `tools\**`, Target: "tools"}},
+	}
+
+	var nuspecXML bytes.Buffer
+	nuspecXML.WriteString(xml.Header)
+	fmt.Fprintf(&nuspecXML, "<!-- Created with %s -->\n", ToolName)
+	encoder := xml.NewEncoder(&nuspecXML)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(spec); err != nil {
+		return nil, err
+	}
+	nuspecXML.WriteString("\n")
+
+	// 64-bit installers go in the 64-bit arguments, so Chocolatey only
+	// installs them on 64-bit Windows
+	bitness := ""
+	if file.Architecture == "x64" || file.Architecture == "arm64" {
+		bitness = "64bit"
+	}
+	var script bytes.Buffer
+	fmt.Fprintf(&script, "# Created with %s\n", ToolName)
+	script.WriteString("$ErrorActionPreference = 'Stop'\n\n")
+	script.WriteString("$packageArgs = @{\n")
+	script.WriteString("  packageName    = $env:ChocolateyPackageName\n")
+	fmt.Fprintf(&script, "  fileType       = %s\n", psQuote(file.FileType))
+	fmt.Fprintf(&script, "  %-14s = %s\n", "url"+bitness, psQuote(url))
+	fmt.Fprintf(&script, "  %-14s = %s\n", "checksum"+strings.TrimSuffix(bitness, "bit"), psQuote(checksum))
+	fmt.Fprintf(&script, "  %-14s = 'sha256'\n", "checksumType"+strings.TrimSuffix(bitness, "bit"))
+	fmt.Fprintf(&script, "  softwareName   = %s\n", psQuote(name+"*"))
+	fmt.Fprintf(&script, "  silentArgs     = %s\n", psQuote(args))
+	script.WriteString("  validExitCodes = @(0, 3010, 1641)\n")
+	script.WriteString("}\n\n")
+	script.WriteString("Install-ChocolateyPackage @packageArgs\n")
+
+	return []File{
+		{Path: path.Join(id, id+".nuspec"), Content: nuspecXML.Bytes()},
+		{Path: path.Join(id, "tools", "chocolateyinstall.ps1"), Content: script.Bytes()},
+	}, nil
+}
+
+// psQuote quotes a string for PowerShell, which takes single-quoted
+// strings literally apart from doubled quotes
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,111 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// caskArchitectures maps normalized architectures to Homebrew's names
+var caskArchitectures = map[string]string{
+	"arm64": ":arm64",
+	"x64":   ":x86_64",
+}
+
+// CaskFormatter writes a Homebrew Cask for each macOS disk image, archive
+// or package
+type CaskFormatter struct{}
+
+func (c *CaskFormatter) Name() string {
+	return "cask"
+}
+
+func (c *CaskFormatter) CanFormat(file types.ProcessedFile) bool {
+	if file.Platform != "macos" {
+		return false
+	}
+	switch file.FileType {
+	case "dmg", "zip", "pkg":
+		return true
+	default:
+		return false
+	}
+}
+
+// Format requires a name, version, download URL and SHA-256; and the
+// package IDs a package installs, so the cask can uninstall them
+func (c *CaskFormatter) Format(file types.ProcessedFile) ([]File, error) {
+	f := newFields(c.Name(), file)
+	name := f.name()
+	caskToken := f.require("name", token(name))
+	version := f.require("version", f.version())
+	url := f.require("source_url", f.url())
+	sha256 := f.require("sha256", f.file.SHA256())
+
+	var artifact []string
+	if file.FileType == "pkg" {
+		packageIDs := f.file.MetadataStrings("package_ids")
+		if len(packageIDs) == 0 {
+			if id := f.file.MetadataString("bundle_identifier"); id != "" {
+				packageIDs = []string{id}
+			}
+		}
+		f.require("package_ids", strings.Join(packageIDs, ","))
+		artifact = append(artifact, fmt.Sprintf("pkg %s", rbQuote(file.Filename)), "")
+		artifact = append(artifact, "uninstall pkgutil: "+rbList(packageIDs))
+	} else {
+		artifact = append(artifact, fmt.Sprintf("app %s", rbQuote(f.appBundle(name))))
+	}
+	if err := f.err(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Created with %s\n", ToolName)
+	fmt.Fprintf(&buf, "cask %s do\n", rbQuote(caskToken))
+	fmt.Fprintf(&buf, "  version %s\n", rbQuote(version))
+	fmt.Fprintf(&buf, "  sha256 %s\n\n", rbQuote(sha256))
+	fmt.Fprintf(&buf, "  url %s\n", rbQuote(url))
+	fmt.Fprintf(&buf, "  name %s\n", rbQuote(name))
+	if description := f.description(); description != "" {
+		fmt.Fprintf(&buf, "  desc %s\n", rbQuote(description))
+	}
+	if homepage := f.homepage(); homepage != "" {
+		fmt.Fprintf(&buf, "  homepage %s\n", rbQuote(homepage))
+	}
+	if arch, ok := caskArchitectures[file.Architecture]; ok {
+		fmt.Fprintf(&buf, "\n  depends_on arch: %s\n", arch)
+	}
+	buf.WriteString("\n")
+	for _, line := range artifact {
+		if line == "" {
+			buf.WriteString("\n")
+			continue
+		}
+		fmt.Fprintf(&buf, "  %s\n", line)
+	}
+	buf.WriteString("end\n")
+
+	return []File{{Path: path.Join("Casks", caskToken+".rb"), Content: buf.Bytes()}}, nil
+}
+
+// rbQuote quotes a string as a Ruby double-quoted literal
+func rbQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `#{`, `\#{`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// rbList formats strings as a Ruby array, or a single string
+func rbList(values []string) string {
+	if len(values) == 1 {
+		return rbQuote(values[0])
+	}
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = rbQuote(v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
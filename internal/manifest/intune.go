@@ -0,0 +1,202 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// intuneArchitectures maps normalized architectures to the Windows
+// architectures a Win32 app applies to. 32-bit installers also run on
+// 64-bit Windows.
+var intuneArchitectures = map[string]string{
+	"x64":   "x64",
+	"x86":   "x86,x64",
+	"arm64": "arm64",
+}
+
+// intuneUninstallers are the uninstall commands of each executable setup
+// engine, as formats of the install directory and setup filename
+var intuneUninstallers = map[string]string{
+	"nsis":          `"%[1]s\uninstall.exe" /S`,
+	"inno_setup":    `"%[1]s\unins000.exe" /VERYSILENT /SUPPRESSMSGBOXES /NORESTART`,
+	"installshield": `"%[2]s" /s /x /v"/qn"`,
+}
+
+// intuneReturnCodes are the Windows Installer exit codes Intune interprets
+var intuneReturnCodes = []intuneReturnCode{
+	{ReturnCode: 0, Type: "success"},
+	{ReturnCode: 1707, Type: "success"},
+	{ReturnCode: 3010, Type: "softReboot"},
+	{ReturnCode: 1641, Type: "hardReboot"},
+	{ReturnCode: 1618, Type: "retry"},
+}
+
+// IntuneFormatter writes the Microsoft Graph win32LobApp body that creates
+// an Intune Win32 app for each Windows installer. The installer itself
+// still has to be wrapped with the Win32 Content Prep Tool and uploaded as
+// the app's content.
+type IntuneFormatter struct{}
+
+type intuneWin32App struct {
+	ODataType                       string                `json:"@odata.type"`
+	DisplayName                     string                `json:"displayName"`
+	Description                     string                `json:"description"`
+	Publisher                       string                `json:"publisher"`
+	DisplayVersion                  string                `json:"displayVersion"`
+	InformationURL                  string                `json:"informationUrl,omitempty"`
+	FileName                        string                `json:"fileName"`
+	SetupFilePath                   string                `json:"setupFilePath"`
+	InstallCommandLine              string                `json:"installCommandLine"`
+	UninstallCommandLine            string                `json:"uninstallCommandLine"`
+	ApplicableArchitectures         string                `json:"applicableArchitectures"`
+	MinimumSupportedOperatingSystem map[string]bool       `json:"minimumSupportedOperatingSystem"`
+	InstallExperience               intuneInstallExp      `json:"installExperience"`
+	ReturnCodes                     []intuneReturnCode    `json:"returnCodes"`
+	DetectionRules                  []interface{}         `json:"detectionRules"`
+	MSIInformation                  *intuneMSIInformation `json:"msiInformation,omitempty"`
+}
+
+type intuneInstallExp struct {
+	RunAsAccount          string `json:"runAsAccount"`
+	DeviceRestartBehavior string `json:"deviceRestartBehavior"`
+}
+
+type intuneReturnCode struct {
+	ReturnCode int    `json:"returnCode"`
+	Type       string `json:"type"`
+}
+
+type intuneMSIInformation struct {
+	ProductCode    string `json:"productCode"`
+	ProductVersion string `json:"productVersion"`
+	UpgradeCode    string `json:"upgradeCode,omitempty"`
+	RequiresReboot bool   `json:"requiresReboot"`
+	PackageType    string `json:"packageType"`
+	ProductName    string `json:"productName"`
+	Publisher      string `json:"publisher"`
+}
+
+type intuneProductCodeDetection struct {
+	ODataType              string `json:"@odata.type"`
+	ProductCode            string `json:"productCode"`
+	ProductVersionOperator string `json:"productVersionOperator"`
+	ProductVersion         string `json:"productVersion"`
+}
+
+type intuneFileSystemDetection struct {
+	ODataType            string `json:"@odata.type"`
+	Path                 string `json:"path"`
+	FileOrFolderName     string `json:"fileOrFolderName"`
+	Check32BitOn64System bool   `json:"check32BitOn64System"`
+	DetectionType        string `json:"detectionType"`
+	Operator             string `json:"operator"`
+}
+
+func (i *IntuneFormatter) Name() string {
+	return "intune"
+}
+
+func (i *IntuneFormatter) CanFormat(file types.ProcessedFile) bool {
+	return file.FileType == "exe" || file.FileType == "msi"
+}
+
+// Format requires a name, publisher, version and architecture; a
+// ProductCode for MSI packages, which detection checks with the version;
+// and a setup engine with known silent switches for executables, which
+// are detected by their install directory
+func (i *IntuneFormatter) Format(file types.ProcessedFile) ([]File, error) {
+	f := newFields(i.Name(), file)
+	name := f.name()
+	id := f.require("name", token(name))
+	publisher := f.require("publisher", f.publisher())
+	version := f.require("version", f.version())
+	f.require("version", pathVersion(version))
+	architectures := f.require("architecture", intuneArchitectures[file.Architecture])
+	args := f.require("installer_type", silentArgs[f.engine()])
+
+	description := f.descriptionOr(name, publisher)
+	app := intuneWin32App{
+		ODataType:               "#microsoft.graph.win32LobApp",
+		DisplayName:             name,
+		Description:             description,
+		Publisher:               publisher,
+		DisplayVersion:          version,
+		InformationURL:          f.homepage(),
+		FileName:                trimExt(file.Filename) + ".intunewin",
+		SetupFilePath:           file.Filename,
+		ApplicableArchitectures: architectures,
+		MinimumSupportedOperatingSystem: map[string]bool{
+			"v10_1607": true,
+		},
+		InstallExperience: intuneInstallExp{RunAsAccount: "system", DeviceRestartBehavior: "basedOnReturnCode"},
+		ReturnCodes:       intuneReturnCodes,
+	}
+
+	if file.FileType == "msi" {
+		productCode := f.require("product_code", f.file.MetadataString("product_code"))
+		app.InstallCommandLine = fmt.Sprintf(`msiexec /i "%s" %s`, file.Filename, args)
+		app.UninstallCommandLine = fmt.Sprintf(`msiexec /x "%s" %s`, productCode, args)
+		app.DetectionRules = []interface{}{intuneProductCodeDetection{
+			ODataType:              "#microsoft.graph.win32LobAppProductCodeDetection",
+			ProductCode:            productCode,
+			ProductVersionOperator: "greaterThanOrEqual",
+			ProductVersion:         version,
+		}}
+		app.MSIInformation = &intuneMSIInformation{
+			ProductCode:    productCode,
+			ProductVersion: version,
+			UpgradeCode:    f.file.MetadataString("upgrade_code"),
+			PackageType:    "perMachine",
+			ProductName:    name,
+			Publisher:      publisher,
+		}
+	} else {
+		// Executables are detected by the directory they install to,
+		// assumed to be named after the product under Program Files
+		programFiles := "%ProgramFiles%"
+		if file.Architecture == "x86" {
+			programFiles = "%ProgramFiles(x86)%"
+		}
+		app.InstallCommandLine = fmt.Sprintf(`"%s" %s`, file.Filename, args)
+		app.UninstallCommandLine = fmt.Sprintf(intuneUninstallers[f.engine()], programFiles+`\`+name, file.Filename)
+		app.DetectionRules = []interface{}{intuneFileSystemDetection{
+			ODataType:            "#microsoft.graph.win32LobAppFileSystemDetection",
+			Path:                 programFiles,
+			FileOrFolderName:     name,
+			Check32BitOn64System: file.Architecture == "x86",
+			DetectionType:        "exists",
+			Operator:             "notConfigured",
+		}}
+	}
+	if err := f.err(); err != nil {
+		return nil, err
+	}
+
+	content, err := encodeJSON(app)
+	if err != nil {
+		return nil, err
+	}
+	return []File{{Path: path.Join(id, id+"-"+pathVersion(version)+".win32LobApp.json"), Content: content}}, nil
+}
+
+// encodeJSON encodes an API payload as indented JSON, leaving the
+// characters of command lines and URLs unescaped
+func encodeJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// trimExt removes a filename's extension
+func trimExt(filename string) string {
+	return filename[:len(filename)-len(path.Ext(filename))]
+}
@@ -0,0 +1,207 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// jamfLatestMacOS is the newest macOS major release listed in a package's
+// OS requirements
+const jamfLatestMacOS = 26
+
+// jamfProcessors maps normalized architectures to the processor a Classic
+// API package requires. Apple silicon runs Intel packages through Rosetta,
+// so only Intel-only packages are restricted.
+var jamfProcessors = map[string]string{
+	"x64": "x86",
+}
+
+// JamfFormatter writes the Jamf Pro API and Classic API package records for
+// each macOS disk image or package. Jamf has no fields for bundle IDs and
+// receipts, so they are recorded in the package notes for smart groups and
+// patch definitions to be built from.
+type JamfFormatter struct{}
+
+type jamfPackage struct {
+	PackageName          string `json:"packageName"`
+	FileName             string `json:"fileName"`
+	CategoryID           string `json:"categoryId"`
+	Info                 string `json:"info"`
+	Notes                string `json:"notes"`
+	Priority             int    `json:"priority"`
+	OSRequirements       string `json:"osRequirements"`
+	FillUserTemplate     bool   `json:"fillUserTemplate"`
+	FillExistingUsers    bool   `json:"fillExistingUsers"`
+	RebootRequired       bool   `json:"rebootRequired"`
+	OSInstall            bool   `json:"osInstall"`
+	SuppressUpdates      bool   `json:"suppressUpdates"`
+	SuppressFromDock     bool   `json:"suppressFromDock"`
+	SuppressEula         bool   `json:"suppressEula"`
+	SuppressRegistration bool   `json:"suppressRegistration"`
+	SelfHealNotify       bool   `json:"selfHealNotify"`
+	SelfHealingAction    string `json:"selfHealingAction"`
+	SHA256               string `json:"sha256,omitempty"`
+	Size                 string `json:"size,omitempty"`
+}
+
+type jamfClassicPackage struct {
+	XMLName                    xml.Name `xml:"package"`
+	Name                       string   `xml:"name"`
+	Category                   string   `xml:"category"`
+	Filename                   string   `xml:"filename"`
+	Info                       string   `xml:"info"`
+	Notes                      string   `xml:"notes"`
+	Priority                   int      `xml:"priority"`
+	RebootRequired             bool     `xml:"reboot_required"`
+	FillUserTemplate           bool     `xml:"fill_user_template"`
+	FillExistingUsers          bool     `xml:"fill_existing_users"`
+	OSRequirements             string   `xml:"os_requirements"`
+	RequiredProcessor          string   `xml:"required_processor"`
+	SwitchWithPackage          string   `xml:"switch_with_package"`
+	InstallIfReportedAvailable bool     `xml:"install_if_reported_available"`
+	ReinstallOption            string   `xml:"reinstall_option"`
+	SendNotification           bool     `xml:"send_notification"`
+}
+
+func (j *JamfFormatter) Name() string {
+	return "jamf"
+}
+
+func (j *JamfFormatter) CanFormat(file types.ProcessedFile) bool {
+	return file.Platform == "macos" && (file.FileType == "dmg" || file.FileType == "pkg")
+}
+
+// Format requires a name and version; the package IDs a package installs,
+// which Jamf inventory reports as receipts; and the bundle ID of the app a
+// disk image copies
+func (j *JamfFormatter) Format(file types.ProcessedFile) ([]File, error) {
+	f := newFields(j.Name(), file)
+	name := f.name()
+	id := f.require("name", token(name))
+	version := f.require("version", f.version())
+	f.require("version", pathVersion(version))
+
+	var bundleID string
+	var receipts []string
+	if file.FileType == "pkg" {
+		receipts = f.file.MetadataStrings("package_ids")
+		f.require("package_ids", strings.Join(receipts, ","))
+		bundleID = f.file.MetadataString("bundle_identifier")
+	} else {
+		bundleID = f.require("bundle_identifier", f.file.MetadataString("bundle_identifier"))
+	}
+	if err := f.err(); err != nil {
+		return nil, err
+	}
+
+	minimumOS := ""
+	if file.Release != nil {
+		minimumOS = file.Release.MinOSVersion
+	}
+	osRequirements := jamfOSRequirements(minimumOS)
+
+	var notes strings.Builder
+	fmt.Fprintf(&notes, "Version: %s\n", version)
+	if bundleID != "" {
+		fmt.Fprintf(&notes, "Bundle ID: %s\n", bundleID)
+	}
+	if len(receipts) > 0 {
+		fmt.Fprintf(&notes, "Receipts: %s\n", strings.Join(receipts, ", "))
+	}
+	if minimumOS != "" {
+		fmt.Fprintf(&notes, "Minimum macOS: %s\n", minimumOS)
+	}
+	if sha256 := f.file.SHA256(); sha256 != "" {
+		fmt.Fprintf(&notes, "SHA-256: %s\n", sha256)
+	}
+	fmt.Fprintf(&notes, "Created with %s", ToolName)
+
+	packageName := name + " " + version
+	pkg := jamfPackage{
+		PackageName:       packageName,
+		FileName:          file.Filename,
+		CategoryID:        "-1",
+		Info:              f.description(),
+		Notes:             notes.String(),
+		Priority:          10,
+		OSRequirements:    osRequirements,
+		SelfHealingAction: "nothing",
+		SHA256:            f.file.SHA256(),
+	}
+	if file.FileSizeBytes > 0 {
+		pkg.Size = strconv.FormatInt(file.FileSizeBytes, 10)
+	}
+	content, err := encodeJSON(pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	processor := jamfProcessors[file.Architecture]
+	if processor == "" {
+		processor = "None"
+	}
+	classic := jamfClassicPackage{
+		Name:              packageName,
+		Category:          "No category assigned",
+		Filename:          file.Filename,
+		Info:              pkg.Info,
+		Notes:             pkg.Notes,
+		Priority:          pkg.Priority,
+		OSRequirements:    osRequirements,
+		RequiredProcessor: processor,
+		SwitchWithPackage: "Do Not Install",
+		ReinstallOption:   "Do Not Reinstall",
+	}
+	var classicXML bytes.Buffer
+	classicXML.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&classicXML)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(classic); err != nil {
+		return nil, err
+	}
+	classicXML.WriteString("\n")
+
+	base := path.Join(id, id+"-"+pathVersion(version))
+	return []File{
+		{Path: base + ".package.json", Content: content},
+		{Path: base + ".package.xml", Content: classicXML.Bytes()},
+	}, nil
+}
+
+// jamfOSRequirements lists the macOS releases from a minimum version to
+// the newest, in the wildcard form Jamf matches OS versions with: minor
+// releases of macOS 10, major releases since. An unknown minimum leaves
+// the package unrestricted.
+func jamfOSRequirements(minimum string) string {
+	parts := strings.Split(minimum, ".")
+	major, err := strconv.Atoi(parts[0])
+	if err != nil || major < 10 || major > jamfLatestMacOS {
+		return ""
+	}
+
+	var releases []string
+	if major == 10 {
+		minor := 0
+		if len(parts) > 1 {
+			minor, _ = strconv.Atoi(parts[1])
+		}
+		for ; minor <= 15; minor++ {
+			releases = append(releases, fmt.Sprintf("10.%d.x", minor))
+		}
+		major = 11
+	}
+	for ; major <= jamfLatestMacOS; major++ {
+		// macOS jumped from 15 to 26
+		if major > 15 && major < 26 {
+			continue
+		}
+		releases = append(releases, fmt.Sprintf("%d.x", major))
+	}
+	return strings.Join(releases, ", ")
+}
@@ -0,0 +1,309 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/deploymenttheory/go-app-index/internal/storage"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// ToolName identifies the generator in manifest comments
+const ToolName = "installer-scraper"
+
+// Formatter generates the manifests a package manager or deployment tool
+// installs an installer from
+type Formatter interface {
+	// Name identifies the format on the command line
+	Name() string
+
+	// CanFormat checks if the format can deploy this installer at all,
+	// by platform and file type
+	CanFormat(file types.ProcessedFile) bool
+
+	// Format returns the manifest files for an installer, or a
+	// *ValidationError naming the required fields it is missing
+	Format(file types.ProcessedFile) ([]File, error)
+}
+
+// File is a generated manifest
+type File struct {
+	Path    string // Relative to the output directory, slash separated
+	Content []byte
+}
+
+// ValidationError reports the required fields an installer is missing for
+// a format, or why its manifests cannot be written
+type ValidationError struct {
+	Format   string
+	Filename string
+	Missing  []string
+	Reason   string // Set instead of Missing when no field is missing
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Missing) == 0 {
+		return fmt.Sprintf("%s: %s manifest %s", e.Filename, e.Format, e.Reason)
+	}
+	return fmt.Sprintf("%s: %s manifest requires %s", e.Filename, e.Format, strings.Join(e.Missing, ", "))
+}
+
+// formatters are the registered formats, by name
+var formatters = make(map[string]Formatter)
+
+func init() {
+	Register(&WingetFormatter{})
+	Register(&ChocolateyFormatter{})
+	Register(&CaskFormatter{})
+	Register(&MunkiFormatter{})
+	Register(&AutoPkgFormatter{})
+	Register(&IntuneFormatter{})
+	Register(&JamfFormatter{})
+}
+
+// Register adds a format, replacing any registered under the same name
+func Register(f Formatter) {
+	formatters[f.Name()] = f
+}
+
+// Lookup returns the format registered under name
+func Lookup(name string) (Formatter, error) {
+	if f, ok := formatters[strings.ToLower(name)]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unknown manifest format %q (want %s)", name, strings.Join(Names(), ", "))
+}
+
+// Names returns the registered format names, sorted
+func Names() []string {
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Summary counts the installers an export handled
+type Summary struct {
+	Exported    int     // Installers manifests were written for
+	Unsupported int     // Installers the format cannot deploy
+	Invalid     []error // Installers missing required fields
+	Files       int     // Manifest files written
+}
+
+// Export writes manifests under dir for every stored installer that filter
+// selects and the format can deploy. Installers missing required fields,
+// or whose manifest paths would leave dir, are skipped and reported in the
+// summary; an error is only returned when reading the store or writing a
+// manifest fails.
+func Export(store storage.Storage, filter storage.Filter, f Formatter, dir string) (Summary, error) {
+	var summary Summary
+	err := store.Iterate(filter, func(file types.ProcessedFile) error {
+		if !file.IsInstaller || !f.CanFormat(file) {
+			summary.Unsupported++
+			return nil
+		}
+
+		files, err := f.Format(file)
+		var invalid *ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			summary.Invalid = append(summary.Invalid, err)
+			return nil
+		case err != nil:
+			return fmt.Errorf("failed to format %s: %w", file.Filename, err)
+		}
+		for _, manifest := range files {
+			if !filepath.IsLocal(filepath.FromSlash(manifest.Path)) {
+				summary.Invalid = append(summary.Invalid, &ValidationError{
+					Format:   f.Name(),
+					Filename: file.Filename,
+					Reason:   fmt.Sprintf("path %q is outside the output directory", manifest.Path),
+				})
+				return nil
+			}
+		}
+
+		for _, manifest := range files {
+			path := filepath.Join(dir, filepath.FromSlash(manifest.Path))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+			}
+			if err := os.WriteFile(path, manifest.Content, 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", path, err)
+			}
+			summary.Files++
+		}
+		summary.Exported++
+		return nil
+	})
+	return summary, err
+}
+
+// fields gathers the values manifests are built from, recording which
+// required ones are missing
+type fields struct {
+	format  string
+	file    types.ProcessedFile
+	missing []string
+}
+
+func newFields(format string, file types.ProcessedFile) *fields {
+	return &fields{format: format, file: file}
+}
+
+// require records name as missing when value is empty, and returns value
+func (f *fields) require(name, value string) string {
+	if value == "" && !containsString(f.missing, name) {
+		f.missing = append(f.missing, name)
+	}
+	return value
+}
+
+// err returns a *ValidationError if any required field was missing
+func (f *fields) err() error {
+	if len(f.missing) == 0 {
+		return nil
+	}
+	return &ValidationError{Format: f.format, Filename: f.file.Filename, Missing: f.missing}
+}
+
+// name returns the product name the analyzers found
+func (f *fields) name() string {
+	for _, key := range []string{"product_name", "name", "package_name"} {
+		if name := f.file.MetadataString(key); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// version returns the installer's version, preferring the normalized one
+func (f *fields) version() string {
+	if f.file.NormalizedVersion != "" {
+		return f.file.NormalizedVersion
+	}
+	return f.file.Version
+}
+
+// publisher returns who published the installer
+func (f *fields) publisher() string {
+	if f.file.Publisher != "" {
+		return f.file.Publisher
+	}
+	for _, key := range []string{"publisher", "company", "vendor", "maintainer"} {
+		if name := f.file.MetadataString(key); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// url returns the installer's download URL, if it is an absolute HTTP URL
+func (f *fields) url() string {
+	u, err := url.Parse(f.file.SourceURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return ""
+	}
+	return f.file.SourceURL
+}
+
+// homepage returns the root of the site the installer was found on
+func (f *fields) homepage() string {
+	if u := f.file.MetadataString("url"); strings.HasPrefix(u, "http") {
+		return u
+	}
+	u, err := url.Parse(f.url())
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host + "/"
+}
+
+// description returns the package's own description, if it has one
+func (f *fields) description() string {
+	for _, key := range []string{"summary", "description"} {
+		if description := f.file.MetadataString(key); description != "" {
+			return strings.Join(strings.Fields(description), " ")
+		}
+	}
+	return ""
+}
+
+// descriptionOr returns the package's description, or one naming the
+// product and publisher for formats that require a description
+func (f *fields) descriptionOr(name, publisher string) string {
+	if description := f.description(); description != "" {
+		return description
+	}
+	return fmt.Sprintf("%s by %s.", name, strings.TrimSuffix(publisher, "."))
+}
+
+// appBundle returns the name of the app an archive or disk image holds:
+// the first app bundle listed inside an archive, or one named after the
+// product
+func (f *fields) appBundle(name string) string {
+	for _, file := range f.file.MetadataStrings("installer_files") {
+		for _, part := range strings.Split(file, "/") {
+			if strings.HasSuffix(part, ".app") {
+				return part
+			}
+		}
+	}
+	return name + ".app"
+}
+
+// engine returns the setup engine of a Windows installer: "msi" for MSI
+// packages, or the engine the PE analyzer detected in an executable
+func (f *fields) engine() string {
+	if f.file.FileType == "exe" {
+		return f.file.MetadataString("installer_type")
+	}
+	return f.file.FileType
+}
+
+// silentArgs are the unattended install arguments of each setup
+// engine, by the file type or PE installer type
+var silentArgs = map[string]string{
+	"msi":           "/qn /norestart",
+	"nsis":          "/S",
+	"inno_setup":    "/VERYSILENT /SUPPRESSMSGBOXES /NORESTART /SP-",
+	"installshield": `/s /v"/qn"`,
+}
+
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// identifier reduces a name to letters and digits joined by sep, as
+// package identifiers require
+func identifier(name, sep string) string {
+	return strings.Trim(nonIdentifier.ReplaceAllString(name, sep), sep)
+}
+
+var nonPathVersion = regexp.MustCompile(`[^A-Za-z0-9.+_-]+`)
+
+// pathVersion reduces a version to the characters safe in a manifest path,
+// keeping its dots, so that "1.2/../3" cannot name another directory
+func pathVersion(version string) string {
+	return strings.Trim(nonPathVersion.ReplaceAllString(version, "-"), "-.")
+}
+
+// token reduces a name to a lowercase, hyphenated package token
+func token(name string) string {
+	return strings.ToLower(identifier(name, "-"))
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package manifest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/deploymenttheory/go-app-index/internal/storage"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+const testSHA256 = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+// wingetMSI returns an MSI installer with every field winget requires
+func wingetMSI(version string) types.ProcessedFile {
+	return types.ProcessedFile{
+		Filename:     "Tool-x64.msi",
+		SourceURL:    "https://downloads.example.com/Tool-x64.msi",
+		FileType:     "msi",
+		Platform:     "windows",
+		Architecture: "x64",
+		IsInstaller:  true,
+		Version:      version,
+		Publisher:    "Example Corp",
+		ExtendedMetadata: map[string]interface{}{
+			"product_name": "Example Tool",
+			"license":      "Proprietary",
+			"sha256":       testSHA256,
+			"product_code": "{11111111-2222-3333-4444-555555555555}",
+		},
+	}
+}
+
+// munkiDMG returns a disk image with every field Munki requires
+func munkiDMG(version string) types.ProcessedFile {
+	return types.ProcessedFile{
+		Filename:         "Tool.dmg",
+		FileType:         "dmg",
+		Platform:         "macos",
+		IsInstaller:      true,
+		Version:          version,
+		ExtendedMetadata: map[string]interface{}{"product_name": "Example Tool", "sha256": testSHA256},
+	}
+}
+
+// paths returns the paths of manifest files
+func paths(files []File) []string {
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	return paths
+}
+
+func TestPathVersion(t *testing.T) {
+	tests := map[string]string{
+		"1.2.3":          "1.2.3",
+		"2.0.0-beta+7":   "2.0.0-beta+7",
+		"1.2_rc1":        "1.2_rc1",
+		"1.2/../3":       "1.2-..-3",
+		"../../etc":      "etc",
+		"..":             "",
+		`1.0\..\..\x`:    "1.0-..-..-x",
+		"10.4 (Build 7)": "10.4-Build-7",
+		"":               "",
+	}
+	for in, want := range tests {
+		if got := pathVersion(in); got != want {
+			t.Errorf("pathVersion(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWingetVersionPath(t *testing.T) {
+	files, err := (&WingetFormatter{}).Format(wingetMSI("1.2/../../../evil"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"manifests/e/Example/Tool/1.2-..-..-..-evil/Example.Tool.yaml",
+		"manifests/e/Example/Tool/1.2-..-..-..-evil/Example.Tool.locale.en-US.yaml",
+		"manifests/e/Example/Tool/1.2-..-..-..-evil/Example.Tool.installer.yaml",
+	}
+	if got := paths(files); !reflect.DeepEqual(got, want) {
+		t.Errorf("paths = %v, want %v", got, want)
+	}
+	// The manifests keep the version as the installer reports it
+	if !strings.Contains(string(files[0].Content), "PackageVersion: 1.2/../../../evil") {
+		t.Errorf("version manifest:\n%s", files[0].Content)
+	}
+}
+
+func TestMunkiVersionPath(t *testing.T) {
+	files, err := (&MunkiFormatter{}).Format(munkiDMG("3.1/../../x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"pkgsinfo/ExampleTool/ExampleTool-3.1-..-..-x.plist"}; !reflect.DeepEqual(paths(files), want) {
+		t.Errorf("paths = %v, want %v", paths(files), want)
+	}
+}
+
+func TestDeploymentVersionPaths(t *testing.T) {
+	dmg := munkiDMG("2.0/../../../x")
+	dmg.ExtendedMetadata["bundle_identifier"] = "com.example.tool"
+
+	tests := []struct {
+		f    Formatter
+		file types.ProcessedFile
+		want []string
+	}{
+		{&IntuneFormatter{}, wingetMSI("1.2/../../x"), []string{"example-tool/example-tool-1.2-..-..-x.win32LobApp.json"}},
+		{&IntuneFormatter{}, wingetMSI("4.5.6"), []string{"example-tool/example-tool-4.5.6.win32LobApp.json"}},
+		{&JamfFormatter{}, dmg, []string{
+			"example-tool/example-tool-2.0-..-..-..-x.package.json",
+			"example-tool/example-tool-2.0-..-..-..-x.package.xml",
+		}},
+	}
+	for _, tt := range tests {
+		files, err := tt.f.Format(tt.file)
+		if err != nil {
+			t.Errorf("%s: Format() error = %v", tt.f.Name(), err)
+			continue
+		}
+		if got := paths(files); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: paths = %v, want %v", tt.f.Name(), got, tt.want)
+		}
+	}
+}
+
+func TestFormatValidation(t *testing.T) {
+	noLicense := wingetMSI("1.0")
+	delete(noLicense.ExtendedMetadata, "license")
+	noLicense.Architecture = ""
+	noVersionPkg := munkiDMG("")
+	noVersionPkg.FileType = "pkg"
+
+	tests := []struct {
+		name    string
+		f       Formatter
+		file    types.ProcessedFile
+		missing []string
+	}{
+		{"winget missing fields", &WingetFormatter{}, noLicense, []string{"license", "architecture"}},
+		{"winget unusable version", &WingetFormatter{}, wingetMSI("../"), []string{"version"}},
+		{"munki unusable version", &MunkiFormatter{}, munkiDMG("//"), []string{"version"}},
+		{"munki package", &MunkiFormatter{}, noVersionPkg, []string{"version", "package_ids"}},
+		{"intune unusable version", &IntuneFormatter{}, wingetMSI(".."), []string{"version"}},
+		{"jamf unusable version", &JamfFormatter{}, munkiDMG("/"), []string{"version", "bundle_identifier"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.f.Format(tt.file)
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("Format() error = %v, want a *ValidationError", err)
+			}
+			if !reflect.DeepEqual(invalid.Missing, tt.missing) || invalid.Format != tt.f.Name() || invalid.Filename != tt.file.Filename {
+				t.Errorf("ValidationError = %+v, want missing %v", invalid, tt.missing)
+			}
+		})
+	}
+}
+
+// escapingFormatter writes a manifest at a fixed path
+type escapingFormatter struct {
+	paths []string
+}
+
+func (f *escapingFormatter) Name() string { return "escaping" }
+
+func (f *escapingFormatter) CanFormat(types.ProcessedFile) bool { return true }
+
+func (f *escapingFormatter) Format(types.ProcessedFile) ([]File, error) {
+	var files []File
+	for _, p := range f.paths {
+		files = append(files, File{Path: p, Content: []byte("manifest")})
+	}
+	return files, nil
+}
+
+func TestExportRejectsPathsOutsideDir(t *testing.T) {
+	root := t.TempDir()
+	store, err := storage.NewJSONL(filepath.Join(root, "installers.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.Store(types.ProcessedFile{Filename: "tool.exe", SHA3Hash: "a", SourceURL: "https://example.com/tool.exe", IsInstaller: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(root, "out")
+	tests := map[string][]string{
+		"parent":            {"../escaped.yaml"},
+		"nested parent":     {"tool/../../escaped.yaml"},
+		"absolute":          {"/tmp/escaped.yaml"},
+		"empty":             {""},
+		"after a valid one": {"tool/ok.yaml", "../escaped.yaml"},
+	}
+	for name, manifestPaths := range tests {
+		t.Run(name, func(t *testing.T) {
+			summary, err := Export(store, storage.Filter{}, &escapingFormatter{paths: manifestPaths}, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var invalid *ValidationError
+			if summary.Exported != 0 || summary.Files != 0 || len(summary.Invalid) != 1 || !errors.As(summary.Invalid[0], &invalid) {
+				t.Fatalf("summary = %+v", summary)
+			}
+			if !strings.Contains(invalid.Error(), "outside the output directory") {
+				t.Errorf("error = %v", invalid)
+			}
+			// Nothing is written, not even the valid manifests
+			if entries, _ := os.ReadDir(root); len(entries) != 1 {
+				t.Errorf("files written: %v", entries)
+			}
+		})
+	}
+
+	summary, err := Export(store, storage.Filter{}, &escapingFormatter{paths: []string{"tool/ok.yaml"}}, dir)
+	if err != nil || summary.Exported != 1 || summary.Files != 1 {
+		t.Fatalf("Export() = %+v, %v", summary, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "tool", "ok.yaml")); err != nil || string(data) != "manifest" {
+		t.Errorf("manifest = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,142 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"howett.net/plist"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// munkiArchitectures maps normalized architectures to Munki's names
+var munkiArchitectures = map[string][]string{
+	"arm64":     {"arm64"},
+	"x64":       {"x86_64"},
+	"universal": {"arm64", "x86_64"},
+}
+
+// munkiCatalogs are the catalogs new items are imported into
+var munkiCatalogs = []string{"testing"}
+
+// MunkiFormatter writes a Munki pkginfo for each macOS disk image or
+// package. Packages are tracked by their receipts; apps copied from disk
+// images by their bundle.
+type MunkiFormatter struct{}
+
+type munkiPkginfo struct {
+	Name                   string          `plist:"name"`
+	DisplayName            string          `plist:"display_name"`
+	Version                string          `plist:"version"`
+	Description            string          `plist:"description,omitempty"`
+	Developer              string          `plist:"developer,omitempty"`
+	Catalogs               []string        `plist:"catalogs"`
+	InstallerItemLocation  string          `plist:"installer_item_location"`
+	InstallerItemHash      string          `plist:"installer_item_hash"`
+	InstallerItemSize      int64           `plist:"installer_item_size"` // In KiB
+	InstallerType          string          `plist:"installer_type,omitempty"`
+	ItemsToCopy            []munkiCopyItem `plist:"items_to_copy,omitempty"`
+	Installs               []munkiInstall  `plist:"installs,omitempty"`
+	Receipts               []munkiReceipt  `plist:"receipts,omitempty"`
+	SupportedArchitectures []string        `plist:"supported_architectures,omitempty"`
+	UninstallMethod        string          `plist:"uninstall_method"`
+	Uninstallable          bool            `plist:"uninstallable"`
+	UnattendedInstall      bool            `plist:"unattended_install"`
+}
+
+type munkiCopyItem struct {
+	SourceItem      string `plist:"source_item"`
+	DestinationPath string `plist:"destination_path"`
+}
+
+type munkiInstall struct {
+	Type                       string `plist:"type"`
+	Path                       string `plist:"path"`
+	CFBundleIdentifier         string `plist:"CFBundleIdentifier,omitempty"`
+	CFBundleShortVersionString string `plist:"CFBundleShortVersionString"`
+	VersionComparisonKey       string `plist:"version_comparison_key"`
+}
+
+type munkiReceipt struct {
+	PackageID string `plist:"packageid"`
+	Version   string `plist:"version"`
+}
+
+func (m *MunkiFormatter) Name() string {
+	return "munki"
+}
+
+func (m *MunkiFormatter) CanFormat(file types.ProcessedFile) bool {
+	return file.Platform == "macos" && (file.FileType == "dmg" || file.FileType == "pkg")
+}
+
+// Format requires a name, version and SHA-256; and the package IDs a
+// package installs, which Munki checks receipts for
+func (m *MunkiFormatter) Format(file types.ProcessedFile) ([]File, error) {
+	f := newFields(m.Name(), file)
+	displayName := f.name()
+	name := f.require("name", identifier(displayName, ""))
+	version := f.require("version", f.version())
+	f.require("version", pathVersion(version))
+
+	info := munkiPkginfo{
+		Name:                   name,
+		DisplayName:            displayName,
+		Version:                version,
+		Description:            f.description(),
+		Developer:              f.publisher(),
+		Catalogs:               munkiCatalogs,
+		InstallerItemLocation:  path.Join(name, file.Filename),
+		InstallerItemHash:      f.require("sha256", f.file.SHA256()),
+		InstallerItemSize:      (file.FileSizeBytes + 1023) / 1024,
+		SupportedArchitectures: munkiArchitectures[file.Architecture],
+		Uninstallable:          true,
+	}
+	if file.FileType == "pkg" {
+		for _, id := range f.file.MetadataStrings("package_ids") {
+			info.Receipts = append(info.Receipts, munkiReceipt{PackageID: id, Version: version})
+		}
+		f.require("package_ids", strings.Join(f.file.MetadataStrings("package_ids"), ","))
+		info.UninstallMethod = "removepackages"
+	} else {
+		app := f.appBundle(displayName)
+		info.InstallerType = "copy_from_dmg"
+		info.ItemsToCopy = []munkiCopyItem{{SourceItem: app, DestinationPath: "/Applications"}}
+		info.Installs = []munkiInstall{{
+			Type:                       "application",
+			Path:                       path.Join("/Applications", app),
+			CFBundleIdentifier:         f.file.MetadataString("bundle_identifier"),
+			CFBundleShortVersionString: version,
+			VersionComparisonKey:       "CFBundleShortVersionString",
+		}}
+		info.UninstallMethod = "remove_copied_items"
+	}
+	if err := f.err(); err != nil {
+		return nil, err
+	}
+
+	content, err := plist.MarshalIndent(info, plist.XMLFormat, "\t")
+	if err != nil {
+		return nil, err
+	}
+	content = append(plistComment(content), '\n')
+	return []File{{
+		Path:    path.Join("pkgsinfo", name, fmt.Sprintf("%s-%s.plist", name, pathVersion(version))),
+		Content: content,
+	}}, nil
+}
+
+// plistComment marks a generated plist, after its XML declaration
+func plistComment(content []byte) []byte {
+	declaration, rest, ok := bytes.Cut(content, []byte("\n"))
+	if !ok {
+		return content
+	}
+	var buf bytes.Buffer
+	buf.Write(declaration)
+	fmt.Fprintf(&buf, "\n<!-- Created with %s -->\n", ToolName)
+	buf.Write(rest)
+	return buf.Bytes()
+}
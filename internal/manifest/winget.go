@@ -0,0 +1,206 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// wingetManifestVersion is the winget manifest schema version written
+const wingetManifestVersion = "1.6.0"
+
+// wingetLocale is the default locale of generated manifests
+const wingetLocale = "en-US"
+
+// wingetArchitectures maps normalized architectures to winget's names
+var wingetArchitectures = map[string]string{
+	"x64":       "x64",
+	"x86":       "x86",
+	"arm64":     "arm64",
+	"arm":       "arm",
+	"noarch":    "neutral",
+	"universal": "neutral",
+}
+
+// wingetExeTypes maps the setup engines the PE analyzer detects to winget
+// installer types, with the switches an "exe" installer needs
+var wingetExeTypes = map[string]struct {
+	installerType string
+	switches      *wingetSwitches
+}{
+	"nsis":          {installerType: "nsis"},
+	"inno_setup":    {installerType: "inno"},
+	"installshield": {installerType: "exe", switches: &wingetSwitches{Silent: `/s /v"/qn"`, SilentWithProgress: `/s /v"/qb"`}},
+}
+
+// WingetFormatter writes Windows Package Manager manifests: a version, a
+// default locale and an installer manifest for each installer, laid out as
+// in the winget-pkgs repository
+type WingetFormatter struct{}
+
+type wingetVersion struct {
+	PackageIdentifier string `yaml:"PackageIdentifier"`
+	PackageVersion    string `yaml:"PackageVersion"`
+	DefaultLocale     string `yaml:"DefaultLocale"`
+	ManifestType      string `yaml:"ManifestType"`
+	ManifestVersion   string `yaml:"ManifestVersion"`
+}
+
+type wingetDefaultLocale struct {
+	PackageIdentifier string `yaml:"PackageIdentifier"`
+	PackageVersion    string `yaml:"PackageVersion"`
+	PackageLocale     string `yaml:"PackageLocale"`
+	Publisher         string `yaml:"Publisher"`
+	PackageName       string `yaml:"PackageName"`
+	PackageURL        string `yaml:"PackageUrl,omitempty"`
+	License           string `yaml:"License"`
+	ShortDescription  string `yaml:"ShortDescription"`
+	ManifestType      string `yaml:"ManifestType"`
+	ManifestVersion   string `yaml:"ManifestVersion"`
+}
+
+type wingetInstaller struct {
+	PackageIdentifier string                 `yaml:"PackageIdentifier"`
+	PackageVersion    string                 `yaml:"PackageVersion"`
+	Installers        []wingetInstallerEntry `yaml:"Installers"`
+	ManifestType      string                 `yaml:"ManifestType"`
+	ManifestVersion   string                 `yaml:"ManifestVersion"`
+}
+
+type wingetInstallerEntry struct {
+	Architecture           string               `yaml:"Architecture"`
+	InstallerType          string               `yaml:"InstallerType"`
+	InstallerURL           string               `yaml:"InstallerUrl"`
+	InstallerSha256        string               `yaml:"InstallerSha256"`
+	ProductCode            string               `yaml:"ProductCode,omitempty"`
+	InstallerSwitches      *wingetSwitches      `yaml:"InstallerSwitches,omitempty"`
+	AppsAndFeaturesEntries []wingetAppsFeatures `yaml:"AppsAndFeaturesEntries,omitempty"`
+}
+
+type wingetSwitches struct {
+	Silent             string `yaml:"Silent"`
+	SilentWithProgress string `yaml:"SilentWithProgress"`
+}
+
+type wingetAppsFeatures struct {
+	UpgradeCode string `yaml:"UpgradeCode"`
+}
+
+func (w *WingetFormatter) Name() string {
+	return "winget"
+}
+
+func (w *WingetFormatter) CanFormat(file types.ProcessedFile) bool {
+	switch file.FileType {
+	case "exe", "msi", "msix", "msixbundle", "appx":
+		return true
+	default:
+		return false
+	}
+}
+
+// Format requires a name, publisher, version, license, download URL,
+// SHA-256 and architecture; a ProductCode for MSI packages; and a setup
+// engine with known silent switches for executables
+func (w *WingetFormatter) Format(file types.ProcessedFile) ([]File, error) {
+	f := newFields(w.Name(), file)
+	// The package identifier is built from the publisher and name
+	name, publisher := f.name(), f.publisher()
+	f.require("name", identifier(name, ""))
+	f.require("publisher", identifier(publisher, ""))
+	version := f.require("version", f.version())
+	f.require("version", pathVersion(version))
+	license := f.require("license", f.file.MetadataString("license"))
+
+	entry := wingetInstallerEntry{
+		Architecture:    f.require("architecture", wingetArchitectures[file.Architecture]),
+		InstallerURL:    f.require("source_url", f.url()),
+		InstallerSha256: strings.ToUpper(f.require("sha256", f.file.SHA256())),
+	}
+	switch file.FileType {
+	case "msi":
+		entry.InstallerType = "msi"
+		entry.ProductCode = f.require("product_code", f.file.MetadataString("product_code"))
+		if upgradeCode := f.file.MetadataString("upgrade_code"); upgradeCode != "" {
+			entry.AppsAndFeaturesEntries = []wingetAppsFeatures{{UpgradeCode: upgradeCode}}
+		}
+	case "exe":
+		exe, ok := wingetExeTypes[f.file.MetadataString("installer_type")]
+		f.require("installer_type", exe.installerType)
+		if ok {
+			entry.InstallerType = exe.installerType
+			entry.InstallerSwitches = exe.switches
+		}
+	default:
+		entry.InstallerType = "msix"
+	}
+	if err := f.err(); err != nil {
+		return nil, err
+	}
+
+	id := wingetIdentifier(publisher, name)
+	description := f.description()
+	if description == "" {
+		description = name
+	}
+	dir := path.Join("manifests", strings.ToLower(id[:1]), strings.ReplaceAll(id, ".", "/"), pathVersion(version))
+
+	documents := []struct {
+		suffix   string
+		schema   string
+		manifest interface{}
+	}{
+		{"", "version", wingetVersion{
+			PackageIdentifier: id, PackageVersion: version, DefaultLocale: wingetLocale,
+			ManifestType: "version", ManifestVersion: wingetManifestVersion,
+		}},
+		{".locale." + wingetLocale, "defaultLocale", wingetDefaultLocale{
+			PackageIdentifier: id, PackageVersion: version, PackageLocale: wingetLocale,
+			Publisher: publisher, PackageName: name, PackageURL: f.homepage(), License: license,
+			ShortDescription: description, ManifestType: "defaultLocale", ManifestVersion: wingetManifestVersion,
+		}},
+		{".installer", "installer", wingetInstaller{
+			PackageIdentifier: id, PackageVersion: version, Installers: []wingetInstallerEntry{entry},
+			ManifestType: "installer", ManifestVersion: wingetManifestVersion,
+		}},
+	}
+
+	files := make([]File, 0, len(documents))
+	for _, doc := range documents {
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "# Created with %s\n", ToolName)
+		fmt.Fprintf(&buf, "# yaml-language-server: $schema=https://aka.ms/winget-manifest.%s.%s.schema.json\n\n",
+			doc.schema, wingetManifestVersion)
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(doc.manifest); err != nil {
+			return nil, err
+		}
+		encoder.Close()
+		files = append(files, File{Path: path.Join(dir, id+doc.suffix+".yaml"), Content: buf.Bytes()})
+	}
+	return files, nil
+}
+
+// wingetIdentifier builds a package identifier such as Mozilla.Firefox from
+// a publisher and product name, dropping legal-entity suffixes from the
+// publisher and the publisher's name from the start of the product
+func wingetIdentifier(publisher, name string) string {
+	words := strings.Fields(nonIdentifier.ReplaceAllString(publisher, " "))
+	if trimmed := types.TrimLegalSuffixes(words); len(trimmed) > 0 {
+		words = trimmed
+	} else if len(words) > 1 {
+		words = words[:1]
+	}
+	nameWords := strings.Fields(nonIdentifier.ReplaceAllString(name, " "))
+	if len(nameWords) > len(words) && strings.EqualFold(strings.Join(nameWords[:len(words)], " "), strings.Join(words, " ")) {
+		nameWords = nameWords[len(words):]
+	}
+	publisherID, nameID := strings.Join(words, ""), strings.Join(nameWords, "")
+	return publisherID + "." + nameID
+}
@@ -0,0 +1,258 @@
+package policy
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/config"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = 5 * time.Minute
+)
+
+// Mode selects which of a policy's settings a Limiter enforces
+type Mode int
+
+const (
+	// ModeCrawl enforces backoff and minimum delays only; colly applies the
+	// crawl parallelism and delay through its limit rules
+	ModeCrawl Mode = iota
+	// ModeDownload enforces download parallelism, delay, jitter, bandwidth
+	// and backoff
+	ModeDownload
+)
+
+// Limiter enforces per-host politeness for requests made outside colly
+type Limiter struct {
+	set   *Set
+	mode  Mode
+	hosts map[string]*hostState
+	mutex sync.Mutex
+}
+
+// hostState tracks pacing for a single host
+type hostState struct {
+	slots   chan struct{} // nil when parallelism is unlimited
+	next    time.Time     // earliest time the next request may start
+	backoff time.Duration
+	bucket  *tokenBucket // nil when bandwidth is unlimited
+	mutex   sync.Mutex
+}
+
+// NewLimiter creates a limiter for the given policy set
+func NewLimiter(set *Set, mode Mode) *Limiter {
+	return &Limiter{
+		set:   set,
+		mode:  mode,
+		hosts: make(map[string]*hostState),
+	}
+}
+
+func (l *Limiter) state(host string) *hostState {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if s, ok := l.hosts[host]; ok {
+		return s
+	}
+
+	p := l.set.For(host)
+	s := &hostState{}
+	if l.mode == ModeDownload {
+		if p.DownloadParallelism > 0 {
+			s.slots = make(chan struct{}, p.DownloadParallelism)
+		}
+		if p.BandwidthLimit > 0 {
+			s.bucket = newTokenBucket(p.BandwidthLimit)
+		}
+	}
+	l.hosts[host] = s
+	return s
+}
+
+// Acquire blocks until a request slot for host is free and returns a
+// function releasing it
+func (l *Limiter) Acquire(ctx context.Context, host string) (func(), error) {
+	s := l.state(host)
+	if s.slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		return func() { <-s.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Wait blocks until the next request to host may start. minDelay, such as
+// a robots.txt Crawl-delay, is applied in addition to the policy delay.
+func (l *Limiter) Wait(ctx context.Context, host string, minDelay time.Duration) error {
+	s := l.state(host)
+
+	var spacing time.Duration
+	if l.mode == ModeDownload {
+		p := l.set.For(host)
+		spacing = time.Duration(p.Delay) * time.Millisecond
+		if p.RandomDelay > 0 {
+			spacing += time.Duration(rand.Int63n(int64(p.RandomDelay)+1)) * time.Millisecond
+		}
+	}
+	if minDelay > spacing {
+		spacing = minDelay
+	}
+
+	s.mutex.Lock()
+	now := time.Now()
+	start := now
+	if s.next.After(start) {
+		start = s.next
+	}
+	s.next = start.Add(spacing)
+	s.mutex.Unlock()
+
+	wait := start.Sub(now)
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Backoff records a throttling response from host and pushes back its next
+// request. The delay doubles on each consecutive throttle, and a longer
+// Retry-After from the server takes precedence.
+func (l *Limiter) Backoff(host string, retryAfter time.Duration) time.Duration {
+	s := l.state(host)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.backoff == 0 {
+		s.backoff = minBackoff
+	} else {
+		s.backoff *= 2
+	}
+	if s.backoff > maxBackoff {
+		s.backoff = maxBackoff
+	}
+
+	delay := s.backoff
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+
+	if next := time.Now().Add(delay); next.After(s.next) {
+		s.next = next
+	}
+	return delay
+}
+
+// Success records a successful response from host, easing any backoff
+func (l *Limiter) Success(host string) {
+	s := l.state(host)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.backoff /= 2
+	if s.backoff < minBackoff {
+		s.backoff = 0
+	}
+}
+
+// Reader wraps r so that reads from host respect the policy's bandwidth
+// limit, which is shared by all concurrent downloads from that host
+func (l *Limiter) Reader(ctx context.Context, host string, r io.Reader) io.Reader {
+	s := l.state(host)
+	if s.bucket == nil {
+		return r
+	}
+	return &throttledReader{ctx: ctx, reader: r, bucket: s.bucket}
+}
+
+// Policy returns the effective policy for host
+func (l *Limiter) Policy(host string) config.DomainPolicy {
+	return l.set.For(host)
+}
+
+// tokenBucket is a byte-rate limiter refilled continuously at rate bytes
+// per second with a one second burst
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+	mutex  sync.Mutex
+}
+
+func newTokenBucket(bytesPerSecond int64) *tokenBucket {
+	return &tokenBucket{
+		rate:   float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+	}
+}
+
+// take reserves n bytes and returns how long the caller must wait before
+// using them
+func (b *tokenBucket) take(n int) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// throttledReader limits reads to the rate of a shared token bucket
+type throttledReader struct {
+	ctx    context.Context
+	reader io.Reader
+	bucket *tokenBucket
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	// Keep individual reads small so concurrent downloads share the bucket fairly
+	if max := int(r.bucket.rate); max > 0 && len(p) > max {
+		p = p[:max]
+	}
+	if len(p) > 32*1024 {
+		p = p[:32*1024]
+	}
+
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if wait := r.bucket.take(n); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-r.ctx.Done():
+				timer.Stop()
+				return n, r.ctx.Err()
+			}
+		}
+	}
+	return n, err
+}
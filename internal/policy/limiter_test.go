@@ -0,0 +1,187 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/config"
+)
+
+func TestAcquireCapsPerHost(t *testing.T) {
+	set := NewSet(config.DomainPolicy{}, []config.DomainPolicy{{Domain: "slow.example.com", DownloadParallelism: 2}})
+	limiter := NewLimiter(set, ModeDownload)
+	ctx := context.Background()
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, err := limiter.Acquire(ctx, "slow.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		releases = append(releases, release)
+	}
+
+	// The host is full, but others are not limited
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.Acquire(short, "slow.example.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("third Acquire() error = %v, want a timeout", err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := limiter.Acquire(short, "fast.example.com"); err != nil {
+			t.Fatalf("Acquire() of an unlimited host error = %v", err)
+		}
+	}
+
+	releases[0]()
+	if _, err := limiter.Acquire(ctx, "slow.example.com"); err != nil {
+		t.Errorf("Acquire() after a release error = %v", err)
+	}
+
+	// Crawl limiters leave parallelism to colly
+	crawl := NewLimiter(set, ModeCrawl)
+	for i := 0; i < 3; i++ {
+		if _, err := crawl.Acquire(short, "slow.example.com"); err != nil {
+			t.Fatalf("crawl Acquire() error = %v", err)
+		}
+	}
+}
+
+func TestWaitSpacesRequests(t *testing.T) {
+	set := NewSet(config.DomainPolicy{Delay: 30}, nil)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		mode     Mode
+		minDelay time.Duration
+		min, max time.Duration
+	}{
+		{"download delay", ModeDownload, 0, 60 * time.Millisecond, time.Second},
+		{"crawl ignores the policy delay", ModeCrawl, 0, 0, 25 * time.Millisecond},
+		{"longer minimum delay", ModeDownload, 50 * time.Millisecond, 100 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewLimiter(set, tt.mode)
+			start := time.Now()
+			for i := 0; i < 3; i++ {
+				if err := limiter.Wait(ctx, "example.com", tt.minDelay); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if elapsed := time.Since(start); elapsed < tt.min || elapsed > tt.max {
+				t.Errorf("three requests took %v, want %v to %v", elapsed, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	limiter := NewLimiter(NewSet(config.DomainPolicy{}, nil), ModeDownload)
+
+	// Consecutive throttles double the delay, and a longer Retry-After wins
+	steps := []struct {
+		retryAfter time.Duration
+		want       time.Duration
+	}{
+		{0, time.Second},
+		{0, 2 * time.Second},
+		{time.Minute, time.Minute},
+		{time.Second, 8 * time.Second},
+	}
+	for i, step := range steps {
+		if got := limiter.Backoff("example.com", step.retryAfter); got != step.want {
+			t.Errorf("Backoff() #%d = %v, want %v", i+1, got, step.want)
+		}
+	}
+
+	// The host waits out the backoff; others do not
+	short, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(short, "example.com", 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() after Backoff() error = %v, want a timeout", err)
+	}
+	if err := limiter.Wait(short, "other.example.com", 0); err != nil {
+		t.Errorf("Wait() of another host error = %v", err)
+	}
+
+	// Successes ease the backoff until it resets
+	for i := 0; i < 3; i++ {
+		limiter.Success("example.com")
+	}
+	if got := limiter.Backoff("example.com", 0); got != 2*time.Second {
+		t.Errorf("Backoff() after successes = %v, want 2s", got)
+	}
+	for i := 0; i < 3; i++ {
+		limiter.Success("example.com")
+	}
+	if got := limiter.Backoff("example.com", 0); got != time.Second {
+		t.Errorf("Backoff() after a reset = %v, want 1s", got)
+	}
+
+	for i := 0; i < 20; i++ {
+		limiter.Backoff("capped.example.com", 0)
+	}
+	if got := limiter.Backoff("capped.example.com", 0); got != maxBackoff {
+		t.Errorf("Backoff() = %v, want it capped at %v", got, maxBackoff)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	bucket := newTokenBucket(1000)
+
+	// A full second's burst is free, then the debt must be waited out
+	if wait := bucket.take(1000); wait != 0 {
+		t.Errorf("take(1000) from a full bucket = %v, want 0", wait)
+	}
+	if wait := bucket.take(500); wait < 490*time.Millisecond || wait > 500*time.Millisecond {
+		t.Errorf("take(500) from an empty bucket = %v, want about 500ms", wait)
+	}
+
+	// Refills at the rate, up to one second's worth
+	bucket.mutex.Lock()
+	bucket.last = bucket.last.Add(-10 * time.Second)
+	bucket.mutex.Unlock()
+	if wait := bucket.take(1000); wait != 0 {
+		t.Errorf("take(1000) after a refill = %v, want 0", wait)
+	}
+	if wait := bucket.take(100); wait < 90*time.Millisecond {
+		t.Errorf("take(100) = %v, want the burst capped at one second", wait)
+	}
+}
+
+func TestReaderThrottles(t *testing.T) {
+	set := NewSet(config.DomainPolicy{}, []config.DomainPolicy{{Domain: "example.com", BandwidthLimit: 2000}})
+	limiter := NewLimiter(set, ModeDownload)
+	data := bytes.Repeat([]byte("x"), 3000)
+
+	start := time.Now()
+	read, err := io.ReadAll(limiter.Reader(context.Background(), "example.com", bytes.NewReader(data)))
+	if err != nil || !bytes.Equal(read, data) {
+		t.Fatalf("ReadAll() = %d bytes, %v", len(read), err)
+	}
+	// 2000 bytes of burst, then 1000 bytes at 2000 a second
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("reading 3000 bytes at 2000/s took %v, want about 500ms", elapsed)
+	}
+
+	// Unlimited hosts and crawl limiters read directly
+	plain := bytes.NewReader(data)
+	if r := limiter.Reader(context.Background(), "other.com", plain); r != io.Reader(plain) {
+		t.Error("Reader() wrapped an unlimited host")
+	}
+	if r := NewLimiter(set, ModeCrawl).Reader(context.Background(), "example.com", plain); r != io.Reader(plain) {
+		t.Error("crawl Reader() wrapped the host")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := io.ReadAll(limiter.Reader(ctx, "example.com", bytes.NewReader(data))); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReadAll() with a cancelled context error = %v", err)
+	}
+}
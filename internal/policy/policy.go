@@ -0,0 +1,152 @@
+package policy
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/config"
+	"github.com/deploymenttheory/go-app-index/internal/urlutil"
+)
+
+// Set resolves the politeness policy that applies to a host
+type Set struct {
+	defaults config.DomainPolicy
+	policies []config.DomainPolicy
+}
+
+// NewSet creates a policy set. The first policy whose domain glob matches a
+// host applies, with unset fields taken from defaults.
+func NewSet(defaults config.DomainPolicy, policies []config.DomainPolicy) *Set {
+	if defaults.Domain == "" {
+		defaults.Domain = "*"
+	}
+	return &Set{
+		defaults: defaults,
+		policies: policies,
+	}
+}
+
+// Policies returns the configured per-domain policies in match order
+func (s *Set) Policies() []config.DomainPolicy {
+	if s == nil {
+		return nil
+	}
+	return s.policies
+}
+
+// Defaults returns the policy used for hosts no rule matches
+func (s *Set) Defaults() config.DomainPolicy {
+	if s == nil {
+		return config.DomainPolicy{Domain: "*"}
+	}
+	return s.defaults
+}
+
+// For returns the effective policy for a host
+func (s *Set) For(host string) config.DomainPolicy {
+	result := s.Defaults()
+	if s == nil {
+		return result
+	}
+
+	for _, p := range s.policies {
+		if !urlutil.MatchDomain(p.Domain, host) {
+			continue
+		}
+
+		result.Domain = p.Domain
+		if p.CrawlParallelism > 0 {
+			result.CrawlParallelism = p.CrawlParallelism
+		}
+		if p.DownloadParallelism > 0 {
+			result.DownloadParallelism = p.DownloadParallelism
+		}
+		if p.Delay > 0 {
+			result.Delay = p.Delay
+		}
+		if p.RandomDelay > 0 {
+			result.RandomDelay = p.RandomDelay
+		}
+		if p.BandwidthLimit > 0 {
+			result.BandwidthLimit = p.BandwidthLimit
+		}
+		if p.UserAgent != "" {
+			result.UserAgent = p.UserAgent
+		}
+		result.Headers = mergeMaps(result.Headers, p.Headers)
+		result.Cookies = mergeMaps(result.Cookies, p.Cookies)
+		break
+	}
+
+	return result
+}
+
+// ApplyHeaders sets the policy's User-Agent, headers and cookies for host
+func (s *Set) ApplyHeaders(host string, header http.Header) {
+	p := s.For(host)
+
+	if p.UserAgent != "" {
+		header.Set("User-Agent", p.UserAgent)
+	}
+	for _, k := range sortedKeys(p.Headers) {
+		header.Set(k, p.Headers[k])
+	}
+	if len(p.Cookies) > 0 {
+		cookies := make([]string, 0, len(p.Cookies))
+		for _, k := range sortedKeys(p.Cookies) {
+			cookies = append(cookies, (&http.Cookie{Name: k, Value: p.Cookies[k]}).String())
+		}
+		if existing := header.Get("Cookie"); existing != "" {
+			cookies = append([]string{existing}, cookies...)
+		}
+		header.Set("Cookie", strings.Join(cookies, "; "))
+	}
+}
+
+// IsThrottleStatus reports whether a status code asks the client to slow down
+func IsThrottleStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
+}
+
+// RetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func RetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func mergeMaps(base, override map[string]string) map[string]string {
+	if len(override) == 0 {
+		return base
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,86 @@
+package policy
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/config"
+)
+
+func TestSetFor(t *testing.T) {
+	set := NewSet(
+		config.DomainPolicy{Delay: 100, DownloadParallelism: 4, UserAgent: "default", Headers: map[string]string{"Accept": "*/*"}},
+		[]config.DomainPolicy{
+			{Domain: "downloads.example.com", DownloadParallelism: 1, Headers: map[string]string{"X-Token": "a"}},
+			{Domain: "*.example.com", Delay: 500, BandwidthLimit: 1024},
+			{Domain: "*.example.com", Delay: 900},
+			{Domain: "cdn.example.org", UserAgent: "cdn"},
+		},
+	)
+
+	tests := []struct {
+		host string
+		want config.DomainPolicy
+	}{
+		{"downloads.example.com", config.DomainPolicy{Domain: "downloads.example.com", Delay: 100, DownloadParallelism: 1, UserAgent: "default",
+			Headers: map[string]string{"Accept": "*/*", "X-Token": "a"}}},
+		// Case and ports do not matter, and the first matching glob wins
+		{"WWW.Example.com:8443", config.DomainPolicy{Domain: "*.example.com", Delay: 500, DownloadParallelism: 4, BandwidthLimit: 1024, UserAgent: "default",
+			Headers: map[string]string{"Accept": "*/*"}}},
+		// The wildcard does not cover the apex domain
+		{"example.com", config.DomainPolicy{Domain: "*", Delay: 100, DownloadParallelism: 4, UserAgent: "default", Headers: map[string]string{"Accept": "*/*"}}},
+		{"cdn.example.org", config.DomainPolicy{Domain: "cdn.example.org", Delay: 100, DownloadParallelism: 4, UserAgent: "cdn", Headers: map[string]string{"Accept": "*/*"}}},
+		{"other.net", config.DomainPolicy{Domain: "*", Delay: 100, DownloadParallelism: 4, UserAgent: "default", Headers: map[string]string{"Accept": "*/*"}}},
+	}
+	for _, tt := range tests {
+		if got := set.For(tt.host); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("For(%q) = %+v, want %+v", tt.host, got, tt.want)
+		}
+	}
+
+	var none *Set
+	if got := none.For("example.com"); !reflect.DeepEqual(got, config.DomainPolicy{Domain: "*"}) {
+		t.Errorf("nil Set For() = %+v", got)
+	}
+}
+
+func TestApplyHeaders(t *testing.T) {
+	set := NewSet(config.DomainPolicy{UserAgent: "scraper"}, []config.DomainPolicy{
+		{Domain: "example.com", Headers: map[string]string{"Referer": "https://example.com/"}, Cookies: map[string]string{"b": "2", "a": "1"}},
+	})
+	header := http.Header{"Cookie": {"session=x"}}
+	set.ApplyHeaders("example.com", header)
+
+	want := http.Header{
+		"User-Agent": {"scraper"},
+		"Referer":    {"https://example.com/"},
+		"Cookie":     {"session=x; a=1; b=2"},
+	}
+	if !reflect.DeepEqual(header, want) {
+		t.Errorf("headers = %v, want %v", header, want)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := map[string]time.Duration{
+		"120":                           120 * time.Second,
+		" 5 ":                           5 * time.Second,
+		"0":                             0,
+		"-5":                            0,
+		"":                              0,
+		"soon":                          0,
+		"Wed, 21 Oct 2015 07:28:00 GMT": 0, // In the past
+	}
+	for value, want := range tests {
+		if got := RetryAfter(value); got != want {
+			t.Errorf("RetryAfter(%q) = %v, want %v", value, got, want)
+		}
+	}
+
+	date := time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat)
+	if got := RetryAfter(date); got <= 80*time.Second || got > 90*time.Second {
+		t.Errorf("RetryAfter(%q) = %v, want about 90s", date, got)
+	}
+}
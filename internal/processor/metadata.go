@@ -3,6 +3,10 @@ package processor
 import (
 	"path/filepath"
 	"strings"
+
+	"github.com/deploymenttheory/go-app-index/internal/arch"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+	"github.com/deploymenttheory/go-app-index/internal/version"
 )
 
 // extractMetadata extracts additional metadata from a file
@@ -24,3 +28,81 @@ func extractMetadata(filePath string) map[string]string {
 
 	return metadata
 }
+
+// resolveVersion sets a file's normalized version from the most reliable
+// source that yields one: the vendor feed, the analyzers, then the filename
+func resolveVersion(file *types.ProcessedFile) {
+	if file.Release != nil && file.Release.Version != "" {
+		if v, ok := version.Parse(file.Release.Version); ok {
+			setVersion(file, v, version.SourceFeed)
+			return
+		}
+	}
+
+	if raw := file.MetadataString("version"); raw != "" {
+		source := version.Source(file.MetadataString("version_source"))
+		if source == "" {
+			source = version.SourceContent
+		}
+		if v, ok := parseAnalyzedVersion(file, raw, source); ok {
+			setVersion(file, v, source)
+			return
+		}
+	}
+
+	if v, ok := version.FromFilename(file.Filename); ok {
+		setVersion(file, v, version.SourceFilename)
+	}
+}
+
+// parseAnalyzedVersion parses a version reported by an analyzer using the
+// rules of the file's packaging format
+func parseAnalyzedVersion(file *types.ProcessedFile, raw string, source version.Source) (version.Version, bool) {
+	switch {
+	case file.FileType == "deb":
+		return version.ParseDebian(raw)
+	case file.FileType == "rpm":
+		// Qualify the version with its epoch and release
+		return version.ParseRPM(version.RPMEVR(file.MetadataString("package_epoch"), raw, file.MetadataString("package_release")))
+	case source == version.SourceBundle:
+		return version.ParseApple(raw, file.MetadataString("bundle_version"))
+	default:
+		return version.Parse(raw)
+	}
+}
+
+// stubInstallers are setup engines whose 32-bit stub says nothing about
+// the architecture of what it installs
+var stubInstallers = map[string]bool{
+	"installshield": true,
+	"nsis":          true,
+	"inno_setup":    true,
+}
+
+// resolveArchitecture sets a file's architecture from the analyzers, or
+// from its filename when they found none. For setup engine stubs only an
+// MSI Template describes the payload; a machine type found by another
+// analyzer is the stub's and is kept as stub_architecture.
+func resolveArchitecture(file *types.ProcessedFile) {
+	if stubInstallers[file.MetadataString("installer_type")] && file.Provenance["architecture"].Analyzer != "msi" {
+		if stub := file.MetadataString("architecture"); stub != "" {
+			if file.MetadataString("stub_architecture") == "" {
+				file.ExtendedMetadata["stub_architecture"] = stub
+			}
+			delete(file.ExtendedMetadata, "architecture")
+			delete(file.Provenance, "architecture")
+		}
+	}
+
+	if architecture := arch.Normalize(file.MetadataString("architecture")); architecture != "" {
+		file.Architecture = architecture
+		return
+	}
+	file.Architecture = arch.FromFilename(file.Filename)
+}
+
+func setVersion(file *types.ProcessedFile, v version.Version, source version.Source) {
+	file.NormalizedVersion = v.Normalized
+	file.VersionSource = string(source)
+	file.VersionConfidence = version.Confidence(source, v)
+}
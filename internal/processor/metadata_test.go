@@ -0,0 +1,40 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+func TestResolveArchitecture(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		metadata map[string]interface{}
+		analyzer string
+		want     string
+		stub     string
+	}{
+		{"plain PE", "tool.exe", map[string]interface{}{"architecture": "x64"}, "pe", "x64", ""},
+		{"filename only", "tool-arm64.exe", map[string]interface{}{}, "", "arm64", ""},
+		{"NSIS stub", "tool-x64-setup.exe", map[string]interface{}{"installer_type": "nsis", "stub_architecture": "x86"}, "", "x64", "x86"},
+		{"Inno Setup stub from content", "tool-setup.exe", map[string]interface{}{"installer_type": "inno_setup", "architecture": "x86"}, "content", "", "x86"},
+		{"InstallShield with MSI Template", "setup.exe", map[string]interface{}{"installer_type": "installshield", "architecture": "x64", "stub_architecture": "x86"}, "msi", "x64", "x86"},
+		{"MSI engine is not a stub", "setup.exe", map[string]interface{}{"installer_type": "msi", "architecture": "x64"}, "pe", "x64", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := types.ProcessedFile{Filename: tt.filename, ExtendedMetadata: tt.metadata}
+			if tt.analyzer != "" {
+				file.Provenance = map[string]types.FieldSource{"architecture": {Analyzer: tt.analyzer}}
+			}
+			resolveArchitecture(&file)
+			if file.Architecture != tt.want {
+				t.Errorf("Architecture = %q, want %q", file.Architecture, tt.want)
+			}
+			if got := file.MetadataString("stub_architecture"); got != tt.stub {
+				t.Errorf("stub_architecture = %q, want %q", got, tt.stub)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package processor
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"sync"
@@ -9,13 +10,17 @@ import (
 	"github.com/deploymenttheory/go-app-index/internal/downloader"
 	"github.com/deploymenttheory/go-app-index/internal/fileanalyzer"
 	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/rules"
 	"github.com/deploymenttheory/go-app-index/internal/storage"
 	"github.com/deploymenttheory/go-app-index/internal/types"
+	"github.com/deploymenttheory/go-app-index/internal/vuln"
 )
 
 // Stats holds processor statistics
 type Stats struct {
 	FilesProcessed int
+	FilesNew       int // Stored files the index did not already have
+	FilesExisting  int // Files the index already had by hash or URL
 	Errors         int
 	StartTime      time.Time
 	EndTime        time.Time
@@ -28,6 +33,16 @@ type Processor struct {
 	tempDir    string
 	inputQueue chan downloader.DownloadResult
 
+	analysisTimeout time.Duration
+	weights         fileanalyzer.ConfidenceWeights
+	plugins         []fileanalyzer.Plugin
+	clock           fileanalyzer.Clock
+	rules           *rules.Engine
+	vulnerabilities *vuln.Database
+	analyzer        *fileanalyzer.Manager
+	ctx             context.Context
+	cancel          context.CancelFunc
+
 	wg         sync.WaitGroup
 	stats      Stats
 	statsMutex sync.RWMutex
@@ -37,21 +52,94 @@ type Processor struct {
 	stop      chan struct{}
 }
 
+// Option configures a Processor
+type Option func(*Processor)
+
+// WithAnalysisTimeout bounds how long the analyzers may spend on one file
+func WithAnalysisTimeout(timeout time.Duration) Option {
+	return func(p *Processor) {
+		p.analysisTimeout = timeout
+	}
+}
+
+// WithConfidenceWeights sets the weights used to score detection confidence
+func WithConfidenceWeights(weights fileanalyzer.ConfidenceWeights) Option {
+	return func(p *Processor) {
+		p.weights = weights
+	}
+}
+
+// WithPlugins adds out-of-process analyzer plugins
+func WithPlugins(plugins ...fileanalyzer.Plugin) Option {
+	return func(p *Processor) {
+		p.plugins = append(p.plugins, plugins...)
+	}
+}
+
+// WithClock sets the clock analysis results and run statistics are
+// stamped with, so that processing can be reproduced exactly
+func WithClock(clock fileanalyzer.Clock) Option {
+	return func(p *Processor) {
+		p.clock = clock
+	}
+}
+
+// WithRules applies detection rules to every analyzed file
+func WithRules(engine *rules.Engine) Option {
+	return func(p *Processor) {
+		p.rules = engine
+	}
+}
+
+// WithVulnerabilities matches every analyzed file against an offline
+// vulnerability database
+func WithVulnerabilities(db *vuln.Database) Option {
+	return func(p *Processor) {
+		p.vulnerabilities = db
+	}
+}
+
 // New creates a new Processor
-func New(workers int, storage storage.Storage, tempDir string) *Processor {
-	return &Processor{
-		workers:    workers,
-		storage:    storage,
-		tempDir:    tempDir,
-		inputQueue: make(chan downloader.DownloadResult, 100),
-		stop:       make(chan struct{}),
+func New(workers int, storage storage.Storage, tempDir string, opts ...Option) *Processor {
+	p := &Processor{
+		workers:         workers,
+		storage:         storage,
+		tempDir:         tempDir,
+		inputQueue:      make(chan downloader.DownloadResult, 100),
+		stop:            make(chan struct{}),
+		analysisTimeout: fileanalyzer.DefaultTimeout,
+		weights:         fileanalyzer.DefaultConfidenceWeights(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	managerOpts := []fileanalyzer.ManagerOption{
+		fileanalyzer.WithTimeout(p.analysisTimeout),
+		fileanalyzer.WithMaxAbandoned(p.workers),
+		fileanalyzer.WithConfidenceWeights(p.weights),
+		fileanalyzer.WithPlugins(p.plugins...),
+	}
+	if p.clock != nil {
+		managerOpts = append(managerOpts, fileanalyzer.WithClock(p.clock))
 	}
+	p.analyzer = fileanalyzer.NewManager(managerOpts...)
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	return p
+}
+
+// now reads the processor's clock
+func (p *Processor) now() time.Time {
+	if p.clock != nil {
+		return p.clock.Now()
+	}
+	return time.Now()
 }
 
 // Start begins the processing workers
 func (p *Processor) Start() {
 	p.statsMutex.Lock()
-	p.stats.StartTime = time.Now()
+	p.stats.StartTime = p.now()
 	p.statsMutex.Unlock()
 
 	for i := 0; i < p.workers; i++ {
@@ -79,11 +167,20 @@ func (p *Processor) worker(id int) {
 				logger.Errorf("Worker %d: Failed to process %s: %v", id, result.FilePath, err)
 				p.incrementErrors()
 			} else {
-				// Store the processed file info
-				err = p.storage.Store(processedFile)
+				// Store the processed file info, noting whether the index already had it
+				existing, err := storage.IsStored(p.storage, processedFile.SHA3Hash, processedFile.SourceURL)
 				if err != nil {
+					logger.Warningf("Worker %d: Failed to look up %s: %v", id, processedFile.SourceURL, err)
+				}
+				err = p.storage.Store(processedFile)
+				switch {
+				case err != nil:
 					logger.Errorf("Worker %d: Failed to store metadata for %s: %v", id, result.FilePath, err)
 					p.incrementErrors()
+				case existing:
+					p.incrementFilesExisting()
+				default:
+					p.incrementFilesNew()
 				}
 
 				p.incrementFilesProcessed()
@@ -120,8 +217,7 @@ func (p *Processor) processFile(result downloader.DownloadResult) (types.Process
 	}
 
 	// Use enhanced file analysis if possible
-	analyzer := fileanalyzer.NewManager()
-	analysisResult, err := analyzer.Analyze(result.FilePath, result.ContentType)
+	analysisResult, err := p.analyzer.Analyze(p.ctx, result.FilePath, result.ContentType)
 
 	if err == nil && analysisResult.Confidence > 0.5 {
 		// Use enhanced analysis results
@@ -131,7 +227,10 @@ func (p *Processor) processFile(result downloader.DownloadResult) (types.Process
 		processedFile.FileType = analysisResult.FileType
 		processedFile.Platform = analysisResult.Platform
 		processedFile.DetectionScore = analysisResult.Confidence
+		processedFile.ConfidenceFactors = analysisResult.ScoreFactors
 		processedFile.IsInstaller = analysisResult.IsInstaller
+		processedFile.Provenance = analysisResult.Provenance
+		processedFile.Conflicts = analysisResult.Conflicts
 
 		// Extract additional metadata if available
 		if analysisResult.Metadata != nil {
@@ -170,6 +269,30 @@ func (p *Processor) processFile(result downloader.DownloadResult) (types.Process
 		processedFile.ExtendedMetadata = extendedMetadata
 	}
 
+	// Merge feed metadata; feed versions are authoritative
+	if result.Release != nil {
+		processedFile.Release = result.Release
+		if result.Release.Version != "" {
+			processedFile.Version = result.Release.Version
+		}
+	}
+
+	resolveVersion(&processedFile)
+	resolveArchitecture(&processedFile)
+
+	if p.rules != nil {
+		if err := p.rules.Apply(result.FilePath, &processedFile); err != nil {
+			logger.Warningf("Failed to apply rules to %s: %v", result.FileName, err)
+		}
+	}
+
+	if p.vulnerabilities != nil {
+		processedFile.Vulnerabilities = p.vulnerabilities.Match(processedFile)
+		if n := len(processedFile.Vulnerabilities); n > 0 {
+			logger.Warningf("%s is affected by %d known vulnerabilities", result.FileName, n)
+		}
+	}
+
 	return processedFile, nil
 }
 
@@ -186,9 +309,12 @@ func (p *Processor) Done() {
 	close(p.inputQueue)
 }
 
-// Stop signals the processor to stop
+// Stop signals the processor to stop and waits for its workers to finish
+// the files they are on, so storage can be closed safely afterwards
 func (p *Processor) Stop() {
+	p.cancel()
 	close(p.stop)
+	p.Wait()
 }
 
 // Wait waits for all processing to complete
@@ -196,7 +322,7 @@ func (p *Processor) Wait() {
 	p.wg.Wait()
 
 	p.statsMutex.Lock()
-	p.stats.EndTime = time.Now()
+	p.stats.EndTime = p.now()
 	p.statsMutex.Unlock()
 }
 
@@ -214,6 +340,20 @@ func (p *Processor) incrementFilesProcessed() {
 	p.statsMutex.Unlock()
 }
 
+// Increment new files counter
+func (p *Processor) incrementFilesNew() {
+	p.statsMutex.Lock()
+	p.stats.FilesNew++
+	p.statsMutex.Unlock()
+}
+
+// Increment existing files counter
+func (p *Processor) incrementFilesExisting() {
+	p.statsMutex.Lock()
+	p.stats.FilesExisting++
+	p.statsMutex.Unlock()
+}
+
 // Increment errors counter
 func (p *Processor) incrementErrors() {
 	p.statsMutex.Lock()
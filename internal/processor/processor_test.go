@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/downloader"
+	"github.com/deploymenttheory/go-app-index/internal/storage"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// fixedClock stamps everything with the same time
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestProcessorIsReproducibleWithClock(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewJSONL(filepath.Join(dir, "installers.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	clock := fixedClock(time.Date(2024, 6, 1, 8, 30, 0, 0, time.UTC))
+	p := New(1, store, dir, WithClock(clock))
+	fixture, err := os.ReadFile(filepath.Join("..", "fileanalyzer", "testdata", "setup-nsis.exe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Processing removes the download, so each run gets its own copy
+	var files []types.ProcessedFile
+	for i := 0; i < 2; i++ {
+		path := filepath.Join(dir, "setup-nsis.exe")
+		if err := os.WriteFile(path, fixture, 0644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := p.processFile(downloader.DownloadResult{
+			URL:          "https://downloads.example.com/setup-nsis.exe",
+			FilePath:     path,
+			FileName:     "setup-nsis.exe",
+			FileSize:     int64(len(fixture)),
+			DownloadedAt: time.Time(clock),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	if !reflect.DeepEqual(files[0], files[1]) {
+		t.Errorf("processing is not reproducible:\n%+v\nthen\n%+v", files[0], files[1])
+	}
+
+	// The stub's machine type says nothing about the payload
+	file := files[0]
+	if file.FileType != "exe" || file.Architecture != "" || file.MetadataString("stub_architecture") != "x86" ||
+		file.NormalizedVersion != "2.4.1" || file.Publisher != "" || file.MetadataString("installer_type") != "nsis" {
+		t.Errorf("processed file = %+v", file)
+	}
+
+	p.Start()
+	p.Done()
+	p.Wait()
+	if stats := p.Stats(); !stats.StartTime.Equal(time.Time(clock)) || !stats.EndTime.Equal(time.Time(clock)) {
+		t.Errorf("stats times = %v, %v, want the clock's", stats.StartTime, stats.EndTime)
+	}
+}
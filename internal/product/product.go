@@ -0,0 +1,341 @@
+package product
+
+import (
+	"encoding/json"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/arch"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+	"github.com/deploymenttheory/go-app-index/internal/version"
+)
+
+// KeyKind names the identifier that groups a product's artifacts
+type KeyKind string
+
+const (
+	// KeyBundleID groups macOS artifacts by CFBundleIdentifier
+	KeyBundleID KeyKind = "bundle_id"
+	// KeyUpgradeCode groups MSI packages by UpgradeCode
+	KeyUpgradeCode KeyKind = "upgrade_code"
+	// KeyDebPackage groups Debian packages by package name
+	KeyDebPackage KeyKind = "deb_package"
+	// KeyRPMPackage groups RPM packages by package name
+	KeyRPMPackage KeyKind = "rpm_package"
+	// KeyName groups anything else by normalized name and publisher
+	KeyName KeyKind = "name"
+)
+
+// Product is every known version of one piece of software
+type Product struct {
+	ID        string         `json:"id"` // "<key_kind>:<key>"
+	KeyKind   KeyKind        `json:"key_kind"`
+	Key       string         `json:"key"`
+	Name      string         `json:"name"`
+	Publisher string         `json:"publisher,omitempty"`
+	Scheme    version.Scheme `json:"version_scheme"`
+	Releases  []Release      `json:"releases"` // Newest first
+	Latest    []Release      `json:"latest"`   // Newest release per platform and architecture
+}
+
+// Release is one version of a product for one platform and architecture
+type Release struct {
+	Version      string     `json:"version,omitempty"`
+	Platform     string     `json:"platform"`
+	Architecture string     `json:"architecture,omitempty"`
+	Artifacts    []Artifact `json:"artifacts"`
+}
+
+// Artifact is an installer file that delivers a release
+type Artifact struct {
+	Filename      string    `json:"filename"`
+	SourceURL     string    `json:"source_url"`
+	SHA3Hash      string    `json:"sha3_hash"`
+	FileType      string    `json:"file_type"`
+	FileSizeBytes int64     `json:"file_size_bytes"`
+	IsSigned      bool      `json:"is_signed,omitempty"`
+	DiscoveredAt  time.Time `json:"discovered_at"`
+}
+
+// Output is the document written by Export
+type Output struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Products    []Product `json:"products"`
+}
+
+// Group groups files into products, most specific identifier first: bundle
+// ID, MSI UpgradeCode, deb or rpm package name, then normalized name and
+// publisher. Products are sorted by name and ID.
+func Group(files []types.ProcessedFile) []Product {
+	products := make(map[string]*Product)
+	var order []string
+
+	for _, file := range files {
+		kind, key := productKey(file)
+		id := string(kind) + ":" + key
+
+		p, ok := products[id]
+		if !ok {
+			p = &Product{
+				ID:      id,
+				KeyKind: kind,
+				Key:     key,
+				Scheme:  schemeFor(kind),
+			}
+			products[id] = p
+			order = append(order, id)
+		}
+		if p.Name == "" {
+			p.Name = productName(file)
+		}
+		if p.Publisher == "" {
+			p.Publisher = file.Publisher
+		}
+		p.add(file)
+	}
+
+	result := make([]Product, 0, len(order))
+	for _, id := range order {
+		p := products[id]
+		p.sortReleases()
+		p.Latest = latest(p.Releases)
+		result = append(result, *p)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if !strings.EqualFold(result[i].Name, result[j].Name) {
+			return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// Export writes products as an indented JSON document
+func Export(w io.Writer, products []Product) error {
+	if products == nil {
+		products = make([]Product, 0)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(Output{GeneratedAt: time.Now(), Products: products})
+}
+
+// add files an artifact under the release for its version, platform and
+// architecture
+func (p *Product) add(file types.ProcessedFile) {
+	v := fileVersion(file)
+	arch := architecture(file)
+	artifact := Artifact{
+		Filename:      file.Filename,
+		SourceURL:     file.SourceURL,
+		SHA3Hash:      file.SHA3Hash,
+		FileType:      file.FileType,
+		FileSizeBytes: file.FileSizeBytes,
+		IsSigned:      file.IsSigned,
+		DiscoveredAt:  file.DiscoveredAt,
+	}
+
+	for i := range p.Releases {
+		r := &p.Releases[i]
+		if r.Platform == file.Platform && r.Architecture == arch && sameVersion(p.Scheme, r.Version, v) {
+			r.Artifacts = append(r.Artifacts, artifact)
+			return
+		}
+	}
+	p.Releases = append(p.Releases, Release{
+		Version:      v,
+		Platform:     file.Platform,
+		Architecture: arch,
+		Artifacts:    []Artifact{artifact},
+	})
+}
+
+// sortReleases orders releases newest first, with unversioned releases
+// last, then by platform and architecture
+func (p *Product) sortReleases() {
+	sort.SliceStable(p.Releases, func(i, j int) bool {
+		a, b := p.Releases[i], p.Releases[j]
+		if c := compareVersions(p.Scheme, a.Version, b.Version); c != 0 {
+			return c > 0
+		}
+		if a.Platform != b.Platform {
+			return a.Platform < b.Platform
+		}
+		return a.Architecture < b.Architecture
+	})
+}
+
+// latest picks the first (newest) release for each platform and
+// architecture from releases sorted newest first
+func latest(releases []Release) []Release {
+	seen := make(map[string]bool)
+	var result []Release
+	for _, r := range releases {
+		key := r.Platform + "/" + r.Architecture
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, r)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Platform != result[j].Platform {
+			return result[i].Platform < result[j].Platform
+		}
+		return result[i].Architecture < result[j].Architecture
+	})
+	return result
+}
+
+// compareVersions compares versions with unknown versions oldest
+func compareVersions(scheme version.Scheme, a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return -1
+	case b == "":
+		return 1
+	}
+	return version.Compare(scheme, a, b)
+}
+
+func sameVersion(scheme version.Scheme, a, b string) bool {
+	if a == "" || b == "" {
+		return a == b
+	}
+	return version.Compare(scheme, a, b) == 0
+}
+
+// schemeFor returns the version ordering for a product key
+func schemeFor(kind KeyKind) version.Scheme {
+	switch kind {
+	case KeyDebPackage:
+		return version.Debian
+	case KeyRPMPackage:
+		return version.RPM
+	default:
+		return version.Generic
+	}
+}
+
+// productKey returns the most specific identifier known for a file
+func productKey(file types.ProcessedFile) (KeyKind, string) {
+	if id := file.MetadataString("bundle_identifier"); id != "" {
+		return KeyBundleID, id
+	}
+	if code := file.MetadataString("upgrade_code"); code != "" {
+		return KeyUpgradeCode, strings.ToUpper(code)
+	}
+	if name := file.MetadataString("package_name"); name != "" {
+		switch file.FileType {
+		case "deb":
+			return KeyDebPackage, name
+		case "rpm":
+			return KeyRPMPackage, name
+		}
+	}
+
+	key := normalizeName(productName(file))
+	if publisher := normalizePublisher(file.Publisher); publisher != "" {
+		key += "@" + publisher
+	}
+	return KeyName, key
+}
+
+// productName returns the name the analyzers found for a file, falling
+// back to one derived from its filename
+func productName(file types.ProcessedFile) string {
+	for _, key := range []string{"product_name", "name", "package_name"} {
+		if name := file.MetadataString(key); name != "" {
+			return name
+		}
+	}
+	if name := strings.Join(nameTokens(file.Filename), " "); name != "" {
+		return name
+	}
+	return file.Filename
+}
+
+// fileVersion returns a file's version, preferring the normalized one.
+// Files indexed before versions were normalized fall back to the raw
+// version, with RPM versions qualified by their epoch and release so the
+// comparator sees the full EVR.
+func fileVersion(file types.ProcessedFile) string {
+	if file.NormalizedVersion != "" {
+		return file.NormalizedVersion
+	}
+	if file.FileType == "rpm" {
+		if v := file.MetadataString("package_version"); v != "" {
+			return version.RPMEVR(file.MetadataString("package_epoch"), v, file.MetadataString("package_release"))
+		}
+	}
+	if file.Version != "" {
+		return file.Version
+	}
+	if file.Release != nil {
+		return file.Release.Version
+	}
+	return ""
+}
+
+// architecture returns the CPU architecture a file targets, if known.
+// Files indexed before architectures were detected fall back to the
+// architecture their package or filename names.
+func architecture(file types.ProcessedFile) string {
+	if file.Architecture != "" {
+		return file.Architecture
+	}
+	if a := arch.Normalize(file.MetadataString("package_arch")); a != "" {
+		return a
+	}
+	return arch.FromFilename(file.Filename)
+}
+
+var (
+	archiveExtensions = regexp.MustCompile(`(?i)(\.tar)?\.[a-z0-9]{1,8}$`)
+	tokenSeparators   = regexp.MustCompile(`[^A-Za-z0-9]+`)
+	versionToken      = regexp.MustCompile(`^[vV]?[0-9]+([a-zA-Z]{1,2}[0-9]*)?$`)
+	// targetToken matches words naming the OS, distribution or CPU a
+	// download is built for, such as fedora36 or macOSArm64
+	targetToken = regexp.MustCompile(`(?i)^(win|windows|mac|macos|osx|darwin|linux|debian|ubuntu|fedora|fc|el|rhel|centos|opensuse|suse|sles)[0-9]*(arm64|aarch64|x64|x86|amd64|intel|universal)?$`)
+)
+
+// noiseTokens are filename words that describe the download rather than
+// the product
+var noiseTokens = map[string]bool{
+	"setup": true, "installer": true, "install": true, "latest": true, "release": true,
+	"x86": true, "x64": true, "amd64": true, "arm64": true, "aarch64": true,
+	"i386": true, "i686": true, "universal": true, "noarch": true,
+	"bionic": true, "focal": true, "jammy": true, "noble": true,
+	"buster": true, "bullseye": true, "bookworm": true, "trixie": true,
+}
+
+// nameTokens splits a filename into the words naming its product
+func nameTokens(filename string) []string {
+	stem := archiveExtensions.ReplaceAllString(path.Base(filename), "")
+	var tokens []string
+	for _, token := range tokenSeparators.Split(stem, -1) {
+		if token == "" || versionToken.MatchString(token) || targetToken.MatchString(token) || noiseTokens[strings.ToLower(token)] {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// normalizeName lowercases a product name and collapses punctuation
+func normalizeName(name string) string {
+	return strings.Trim(tokenSeparators.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// normalizePublisher lowercases a publisher name and drops punctuation and
+// legal-entity suffixes, so "Acme, Inc." and "ACME Inc" match
+func normalizePublisher(publisher string) string {
+	words := types.TrimLegalSuffixes(tokenSeparators.Split(strings.ToLower(publisher), -1))
+	return strings.Trim(strings.Join(words, "-"), "-")
+}
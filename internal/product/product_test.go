@@ -0,0 +1,205 @@
+package product
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// file returns an installer with metadata given as key, value pairs
+func file(filename, platform, arch, version string, metadata ...string) types.ProcessedFile {
+	f := types.ProcessedFile{
+		Filename:         filename,
+		SourceURL:        "https://example.com/" + filename,
+		SHA3Hash:         filename + "@" + version,
+		Platform:         platform,
+		Architecture:     arch,
+		Version:          version,
+		ExtendedMetadata: make(map[string]interface{}),
+	}
+	for i := 0; i+1 < len(metadata); i += 2 {
+		f.ExtendedMetadata[metadata[i]] = metadata[i+1]
+	}
+	return f
+}
+
+// published sets a file's publisher
+func published(f types.ProcessedFile, publisher string) types.ProcessedFile {
+	f.Publisher = publisher
+	return f
+}
+
+// typed sets a file's type
+func typed(f types.ProcessedFile, fileType string) types.ProcessedFile {
+	f.FileType = fileType
+	return f
+}
+
+// ids returns the IDs of products
+func ids(products []Product) []string {
+	var ids []string
+	for _, p := range products {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+// versions returns the versions of releases
+func versions(releases []Release) []string {
+	var versions []string
+	for _, r := range releases {
+		versions = append(versions, r.Version)
+	}
+	return versions
+}
+
+func TestGroupKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []types.ProcessedFile
+		want  []string
+	}{
+		{
+			"similar names stay apart",
+			[]types.ProcessedFile{file("AdiumX_1.0.dmg", "macos", "", "1.0"), file("Adium_1.5.dmg", "macos", "", "1.5")},
+			[]string{"name:adium", "name:adiumx"},
+		},
+		{
+			"renamed product shares its bundle ID",
+			[]types.ProcessedFile{
+				file("AdiumX_1.0.dmg", "macos", "", "1.0", "bundle_identifier", "com.adiumX.adiumX"),
+				file("Adium_1.5.dmg", "macos", "", "1.5", "bundle_identifier", "com.adiumX.adiumX"),
+			},
+			[]string{"bundle_id:com.adiumX.adiumX"},
+		},
+		{
+			"bundle ID beats a name match",
+			[]types.ProcessedFile{
+				file("Tool.dmg", "macos", "", "1.0", "product_name", "Tool", "bundle_identifier", "com.example.tool"),
+				file("Tool.zip", "macos", "", "1.1", "product_name", "Tool"),
+			},
+			[]string{"bundle_id:com.example.tool", "name:tool"},
+		},
+		{
+			"upgrade code ignores case",
+			[]types.ProcessedFile{
+				file("tool-1.0.msi", "windows", "x64", "1.0", "upgrade_code", "{a1b2c3d4-0000-0000-0000-00000000abcd}"),
+				file("tool-2.0.msi", "windows", "x64", "2.0", "upgrade_code", "{A1B2C3D4-0000-0000-0000-00000000ABCD}"),
+			},
+			[]string{"upgrade_code:{A1B2C3D4-0000-0000-0000-00000000ABCD}"},
+		},
+		{
+			"publisher legal suffixes",
+			[]types.ProcessedFile{
+				published(file("tool-setup-1.0.exe", "windows", "x64", "1.0"), "Acme, Inc."),
+				published(file("Tool_2.0_x64.exe", "windows", "x64", "2.0"), "ACME Inc"),
+				file("tool-3.0.exe", "windows", "x64", "3.0"),
+			},
+			[]string{"name:tool", "name:tool@acme"},
+		},
+		{
+			"package names by format",
+			[]types.ProcessedFile{
+				typed(file("tool_1.0_amd64.deb", "linux", "x64", "1.0", "package_name", "tool"), "deb"),
+				typed(file("tool-1.0.x86_64.rpm", "linux", "x64", "1.0", "package_name", "tool"), "rpm"),
+			},
+			[]string{"deb_package:tool", "rpm_package:tool"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ids(Group(tt.files)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Group() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupReleaseOrder(t *testing.T) {
+	deb := func(v string) types.ProcessedFile {
+		return typed(file("tool_"+v+"_amd64.deb", "linux", "x64", v, "package_name", "tool"), "deb")
+	}
+	// Unnormalized RPMs are ordered by the EVR built from their metadata
+	rpm := func(epoch, v, release string) types.ProcessedFile {
+		return typed(file("tool-"+v+"-"+release+".x86_64.rpm", "linux", "x64", "",
+			"package_name", "tool", "package_epoch", epoch, "package_version", v, "package_release", release), "rpm")
+	}
+
+	tests := []struct {
+		name  string
+		files []types.ProcessedFile
+		want  []string
+	}{
+		{
+			"deb epochs",
+			[]types.ProcessedFile{deb("2.0"), deb("1:0.9"), deb("1:1.0"), deb("1.0~rc1"), deb("1.0")},
+			[]string{"1:1.0", "1:0.9", "2.0", "1.0", "1.0~rc1"},
+		},
+		{
+			"rpm epoch, version and release",
+			[]types.ProcessedFile{rpm("", "2.0", "1"), rpm("1", "1.0", "1"), rpm("1", "1.0", "10"), rpm("0", "2.0", "2")},
+			[]string{"1:1.0-10", "1:1.0-1", "2.0-2", "2.0-1"},
+		},
+		{
+			"unversioned last",
+			[]types.ProcessedFile{
+				file("tool.exe", "windows", "x64", ""),
+				file("tool-1.9.exe", "windows", "x64", "1.9"),
+				file("tool-1.10.exe", "windows", "x64", "1.10"),
+				file("tool-2.0b1.exe", "windows", "x64", "2.0b1"),
+			},
+			[]string{"2.0b1", "1.10", "1.9", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products := Group(tt.files)
+			if len(products) != 1 {
+				t.Fatalf("Group() = %v, want one product", ids(products))
+			}
+			if got := versions(products[0].Releases); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("release versions = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupLatest(t *testing.T) {
+	files := []types.ProcessedFile{
+		file("tool-1.0-x64.msi", "windows", "x64", "1.0"),
+		file("tool-2.0-x64.msi", "windows", "x64", "2.0"),
+		file("tool-2.0-x64.exe", "windows", "x64", "2.0.0"),
+		file("tool-1.5-arm64.msi", "windows", "arm64", "1.5"),
+		file("tool-1.8.dmg", "macos", "universal", "1.8"),
+		file("tool-1.9.dmg", "macos", "universal", ""),
+		file("tool.AppImage", "linux", "", ""),
+	}
+	products := Group(files)
+	if len(products) != 1 {
+		t.Fatalf("Group() = %v, want one product", ids(products))
+	}
+	p := products[0]
+
+	type target struct{ platform, arch, version string }
+	var got []target
+	for _, r := range p.Latest {
+		got = append(got, target{r.Platform, r.Architecture, r.Version})
+	}
+	want := []target{
+		{"linux", "", ""},
+		{"macos", "universal", "1.8"},
+		{"windows", "arm64", "1.5"},
+		{"windows", "x64", "2.0"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Latest = %v, want %v", got, want)
+	}
+	// Equal versions of the same target are one release
+	if n := len(p.Latest[3].Artifacts); n != 2 {
+		t.Errorf("windows/x64 2.0 has %d artifacts, want 2", n)
+	}
+	if len(p.Releases) != 6 {
+		t.Errorf("%d releases, want 6", len(p.Releases))
+	}
+}
@@ -0,0 +1,329 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// scanChunkSize is how much of a file is searched at a time
+const scanChunkSize = 1024 * 1024
+
+// Engine applies detection rules to analyzed files
+type Engine struct {
+	rules []Rule
+}
+
+// Len returns the number of rules
+func (e *Engine) Len() int {
+	return len(e.rules)
+}
+
+// Match returns the names of the rules that match a file, in rule order
+func (e *Engine) Match(filePath string, file *types.ProcessedFile) ([]string, error) {
+	matched, err := e.match(filePath, file)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(matched))
+	for i, r := range matched {
+		names[i] = r.Name
+	}
+	return names, nil
+}
+
+// Apply evaluates the rules against a file and applies the actions of those
+// that match. Every rule sees the file as the analyzers left it. Where
+// several matching rules override the same field, the first one wins.
+func (e *Engine) Apply(filePath string, file *types.ProcessedFile) error {
+	matched, err := e.match(filePath, file)
+	if err != nil {
+		return err
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+
+	overridden := make(map[string]bool)
+	override := func(field string, r *Rule, set func()) {
+		if overridden[field] {
+			return
+		}
+		overridden[field] = true
+		set()
+		if file.Provenance == nil {
+			file.Provenance = make(map[string]types.FieldSource)
+		}
+		file.Provenance[field] = types.FieldSource{Analyzer: "rule:" + r.Name, Confidence: 1}
+	}
+
+	file.MatchedRules = nil
+	tags := file.Tags
+	for _, r := range matched {
+		file.MatchedRules = append(file.MatchedRules, r.Name)
+		tags = append(tags, r.Tags...)
+
+		if r.Set.FileType != "" {
+			override("file_type", r, func() { file.FileType = r.Set.FileType })
+		}
+		if r.Set.Platform != "" {
+			override("platform", r, func() { file.Platform = r.Set.Platform })
+		}
+		if r.Set.IsInstaller != nil {
+			override("is_installer", r, func() { file.IsInstaller = *r.Set.IsInstaller })
+		}
+
+		if r.Confidence != 0 {
+			if file.ConfidenceFactors == nil {
+				file.ConfidenceFactors = make(map[string]float64)
+			}
+			file.ConfidenceFactors["rule:"+r.Name] = r.Confidence
+			file.DetectionScore = clampScore(file.DetectionScore + r.Confidence)
+		}
+	}
+	file.Tags = sortedUnique(tags)
+
+	return nil
+}
+
+// match returns the rules that match a file
+func (e *Engine) match(filePath string, file *types.ProcessedFile) ([]*Rule, error) {
+	// Check fields first so the file is only searched for rules that can
+	// still match
+	var candidates []*Rule
+	var patterns []*pattern
+	for i := range e.rules {
+		r := &e.rules[i]
+		if !r.matchFields(file) {
+			continue
+		}
+		candidates = append(candidates, r)
+		patterns = append(patterns, r.patterns...)
+	}
+	if len(patterns) == 0 {
+		return candidates, nil
+	}
+
+	found, err := scanFile(filePath, patterns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply rules to %s: %w", filePath, err)
+	}
+
+	var matched []*Rule
+	for _, r := range candidates {
+		if r.matchStrings(found) {
+			matched = append(matched, r)
+		}
+	}
+	return matched, nil
+}
+
+// matchFields reports whether every field condition holds
+func (r *Rule) matchFields(file *types.ProcessedFile) bool {
+	for key, glob := range r.Fields {
+		value := fieldValue(file, key)
+		if value == "" {
+			return false
+		}
+		if ok, _ := path.Match(strings.ToLower(glob), strings.ToLower(value)); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// matchStrings reports whether the rule's strings match as its condition
+// requires
+func (r *Rule) matchStrings(found map[*pattern]bool) bool {
+	if len(r.patterns) == 0 {
+		return true
+	}
+	all := strings.EqualFold(r.Condition, "all")
+	for _, p := range r.patterns {
+		if found[p] && !all {
+			return true
+		}
+		if !found[p] && all {
+			return false
+		}
+	}
+	return all
+}
+
+// fieldValue returns a file field, or an extended metadata value, by name
+func fieldValue(file *types.ProcessedFile, key string) string {
+	switch key {
+	case "filename":
+		return file.Filename
+	case "file_type":
+		return file.FileType
+	case "platform":
+		return file.Platform
+	case "architecture":
+		return file.Architecture
+	case "publisher":
+		return file.Publisher
+	case "version":
+		return file.Version
+	case "website_domain":
+		return file.WebsiteDomain
+	}
+	if v, ok := file.ExtendedMetadata[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// scanFile searches a file for patterns and reports which were found
+func scanFile(filePath string, patterns []*pattern) (map[*pattern]bool, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[*pattern]bool)
+	var floating []*pattern
+	for _, p := range patterns {
+		if p.offset == nil {
+			floating = append(floating, p)
+			continue
+		}
+		ok, err := p.matchAt(f, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		found[p] = ok
+	}
+	if len(floating) == 0 {
+		return found, nil
+	}
+
+	// Search the file a chunk at a time, carrying over enough of each chunk
+	// to find matches that span two
+	maxLen := 0
+	for _, p := range floating {
+		for _, seq := range p.sequences {
+			maxLen = max(maxLen, len(seq.bytes))
+		}
+	}
+	buf := make([]byte, 0, scanChunkSize+maxLen)
+	var lowered []byte
+	for {
+		n, err := io.ReadFull(f, buf[len(buf):len(buf)+scanChunkSize])
+		buf = buf[:len(buf)+n]
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+
+		lowered = lowered[:0]
+		for _, p := range floating {
+			if found[p] {
+				continue
+			}
+			for _, seq := range p.sequences {
+				data := buf
+				if seq.nocase {
+					if len(lowered) == 0 {
+						lowered = appendLower(lowered, buf)
+					}
+					data = lowered
+				}
+				if seq.find(data) {
+					found[p] = true
+					break
+				}
+			}
+		}
+
+		if err != nil {
+			break
+		}
+		keep := min(maxLen-1, len(buf))
+		copy(buf, buf[len(buf)-keep:])
+		buf = buf[:keep]
+	}
+
+	return found, nil
+}
+
+// matchAt checks a pattern at its fixed offset
+func (p *pattern) matchAt(r io.ReaderAt, size int64) (bool, error) {
+	offset := *p.offset
+	if offset < 0 {
+		offset += size
+	}
+	for _, seq := range p.sequences {
+		if offset < 0 || offset+int64(len(seq.bytes)) > size {
+			continue
+		}
+		data := make([]byte, len(seq.bytes))
+		if _, err := r.ReadAt(data, offset); err != nil && err != io.EOF {
+			return false, err
+		}
+		if seq.nocase {
+			data = appendLower(data[:0], data)
+		}
+		if seq.matches(data) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// find reports whether the sequence occurs in data
+func (s *sequence) find(data []byte) bool {
+	anchor := byte(s.bytes[s.anchor])
+	for from := s.anchor; from < len(data); {
+		i := bytes.IndexByte(data[from:], anchor)
+		if i < 0 {
+			return false
+		}
+		start := from + i - s.anchor
+		if start+len(s.bytes) > len(data) {
+			return false
+		}
+		if s.matches(data[start:]) {
+			return true
+		}
+		from += i + 1
+	}
+	return false
+}
+
+// matches reports whether data starts with the sequence
+func (s *sequence) matches(data []byte) bool {
+	if len(data) < len(s.bytes) {
+		return false
+	}
+	for i, b := range s.bytes {
+		if b >= 0 && data[i] != byte(b) {
+			return false
+		}
+	}
+	return true
+}
+
+func appendLower(dst, src []byte) []byte {
+	for _, b := range src {
+		dst = append(dst, lowerASCII(b))
+	}
+	return dst
+}
+
+// clampScore keeps a detection score between 0 and 1
+func clampScore(score float64) float64 {
+	score = math.Round(score*10000) / 10000
+	return math.Max(0, math.Min(1, score))
+}
@@ -0,0 +1,189 @@
+package rules
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+func int64p(n int64) *int64 { return &n }
+
+func boolp(b bool) *bool { return &b }
+
+// writeFile writes data to a file in a temporary directory
+func writeFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "setup.exe")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newEngine(t *testing.T, rules ...Rule) *Engine {
+	t.Helper()
+	engine, err := New(rules)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return engine
+}
+
+func TestMatch(t *testing.T) {
+	content := append([]byte("MZ\x90\x00 header Nullsoft Install System v3.08 "), encodeUTF16LE("AdwareSDK")...)
+	content = append(content, "... trailer END"...)
+	file := &types.ProcessedFile{
+		Filename:         "Setup-x64.exe",
+		FileType:         "exe",
+		Publisher:        "Example Corp",
+		ExtendedMetadata: map[string]interface{}{"installer_type": "nsis", "empty": nil},
+	}
+
+	tests := []struct {
+		name string
+		rule Rule
+		want bool
+	}{
+		{"text", Rule{Strings: []Pattern{{Text: "Nullsoft Install"}}}, true},
+		{"text is case sensitive", Rule{Strings: []Pattern{{Text: "nullsoft install"}}}, false},
+		{"nocase", Rule{Strings: []Pattern{{Text: "NULLSOFT install", NoCase: true}}}, true},
+		{"utf16 by default", Rule{Strings: []Pattern{{Text: "AdwareSDK"}}}, true},
+		{"utf16 only", Rule{Strings: []Pattern{{Text: "AdwareSDK", Encoding: "utf16"}}}, true},
+		{"ascii only skips utf16", Rule{Strings: []Pattern{{Text: "AdwareSDK", Encoding: "ascii"}}}, false},
+		{"utf16 nocase", Rule{Strings: []Pattern{{Text: "adwaresdk", Encoding: "utf16", NoCase: true}}}, true},
+		{"hex", Rule{Strings: []Pattern{{Hex: "4D 5A 90 00"}}}, true},
+		{"hex wildcard", Rule{Strings: []Pattern{{Hex: "4D ?? 90"}}}, true},
+		{"hex leading wildcard", Rule{Strings: []Pattern{{Hex: "?? 5A 90"}}}, true},
+		{"hex mismatch", Rule{Strings: []Pattern{{Hex: "4D 5A 00"}}}, false},
+		{"offset", Rule{Strings: []Pattern{{Hex: "90 00", Offset: int64p(2)}}}, true},
+		{"wrong offset", Rule{Strings: []Pattern{{Hex: "90 00", Offset: int64p(3)}}}, false},
+		{"negative offset", Rule{Strings: []Pattern{{Text: "END", Offset: int64p(-3)}}}, true},
+		{"offset past the end", Rule{Strings: []Pattern{{Text: "END", Offset: int64p(-2)}}}, false},
+		{"offset before the start", Rule{Strings: []Pattern{{Hex: "4D", Offset: int64p(-1000)}}}, false},
+		{"any", Rule{Strings: []Pattern{{Text: "missing"}, {Text: "trailer"}}}, true},
+		{"all", Rule{Strings: []Pattern{{Text: "missing"}, {Text: "trailer"}}, Condition: "all"}, false},
+		{"all found", Rule{Strings: []Pattern{{Text: "header"}, {Text: "trailer"}}, Condition: "ALL"}, true},
+		{"field glob", Rule{Fields: map[string]string{"filename": "setup-*.EXE"}}, true},
+		{"field mismatch", Rule{Fields: map[string]string{"file_type": "msi"}}, false},
+		{"metadata field", Rule{Fields: map[string]string{"installer_type": "nsis"}}, true},
+		{"missing field", Rule{Fields: map[string]string{"architecture": "*"}}, false},
+		{"nil metadata", Rule{Fields: map[string]string{"empty": "*"}}, false},
+		{"fields and strings", Rule{Fields: map[string]string{"publisher": "example*"}, Strings: []Pattern{{Text: "Nullsoft"}}}, true},
+		{"fields gate strings", Rule{Fields: map[string]string{"publisher": "other*"}, Strings: []Pattern{{Text: "Nullsoft"}}}, false},
+	}
+
+	path := writeFile(t, content)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.Name = "rule"
+			names, err := newEngine(t, tt.rule).Match(path, file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(names) == 1; got != tt.want {
+				t.Errorf("Match() = %v, want match %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchAcrossChunks(t *testing.T) {
+	// Each needle straddles the boundary between two chunks
+	content := bytes.Repeat([]byte{0}, 3*scanChunkSize)
+	copy(content[scanChunkSize-4:], "SPLITTEXT")
+	copy(content[2*scanChunkSize-1:], []byte{0xCA, 0xFE, 0xBA, 0xBE})
+	path := writeFile(t, content)
+
+	engine := newEngine(t,
+		Rule{Name: "text", Strings: []Pattern{{Text: "splittext", NoCase: true, Encoding: "ascii"}}},
+		Rule{Name: "hex", Strings: []Pattern{{Hex: "CA FE ?? BE"}}},
+		Rule{Name: "absent", Strings: []Pattern{{Text: "absent"}}},
+	)
+	names, err := engine.Match(path, &types.ProcessedFile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"text", "hex"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Match() = %v, want %v", names, want)
+	}
+}
+
+func TestMatchMissingFile(t *testing.T) {
+	engine := newEngine(t, Rule{Name: "text", Strings: []Pattern{{Text: "x"}}})
+	if _, err := engine.Match(filepath.Join(t.TempDir(), "missing"), &types.ProcessedFile{}); err == nil {
+		t.Error("Match() of a missing file succeeded")
+	}
+	// Rules without strings never read the file
+	engine = newEngine(t, Rule{Name: "fields", Fields: map[string]string{"file_type": "exe"}})
+	if names, err := engine.Match(filepath.Join(t.TempDir(), "missing"), &types.ProcessedFile{FileType: "exe"}); err != nil || len(names) != 1 {
+		t.Errorf("Match() = %v, %v", names, err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	path := writeFile(t, []byte("bootstrapper with BundleSDK inside"))
+	engine := newEngine(t,
+		Rule{
+			Name:       "bundleware",
+			Strings:    []Pattern{{Text: "BundleSDK"}},
+			Tags:       []string{"bundleware", "adware"},
+			Set:        Overrides{IsInstaller: boolp(false)},
+			Confidence: -0.3,
+		},
+		Rule{
+			Name:       "bootstrapper",
+			Strings:    []Pattern{{Text: "bootstrapper"}},
+			Tags:       []string{"bootstrapper", "adware", ""},
+			Set:        Overrides{FileType: "bootstrapper", IsInstaller: boolp(true)},
+			Confidence: 0.5,
+		},
+		Rule{Name: "unmatched", Strings: []Pattern{{Text: "nothing"}}, Tags: []string{"never"}},
+	)
+	file := &types.ProcessedFile{
+		FileType:       "exe",
+		IsInstaller:    true,
+		DetectionScore: 0.9,
+		Tags:           []string{"existing"},
+		MatchedRules:   []string{"stale"},
+	}
+	if err := engine.Apply(path, file); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"bundleware", "bootstrapper"}; !reflect.DeepEqual(file.MatchedRules, want) {
+		t.Errorf("MatchedRules = %v, want %v", file.MatchedRules, want)
+	}
+	if want := []string{"adware", "bootstrapper", "bundleware", "existing"}; !reflect.DeepEqual(file.Tags, want) {
+		t.Errorf("Tags = %v, want %v", file.Tags, want)
+	}
+	// The first matching rule wins each field
+	if file.FileType != "bootstrapper" || file.IsInstaller {
+		t.Errorf("FileType = %q, IsInstaller = %v", file.FileType, file.IsInstaller)
+	}
+	if got := file.Provenance["is_installer"].Analyzer; got != "rule:bundleware" {
+		t.Errorf("is_installer provenance = %q", got)
+	}
+	if got := file.Provenance["file_type"].Analyzer; got != "rule:bootstrapper" {
+		t.Errorf("file_type provenance = %q", got)
+	}
+	// 0.9 - 0.3 + 0.5, clamped
+	if file.DetectionScore != 1 || file.ConfidenceFactors["rule:bundleware"] != -0.3 || file.ConfidenceFactors["rule:bootstrapper"] != 0.5 {
+		t.Errorf("DetectionScore = %v, ConfidenceFactors = %v", file.DetectionScore, file.ConfidenceFactors)
+	}
+}
+
+func TestApplyWithoutMatches(t *testing.T) {
+	path := writeFile(t, []byte("plain"))
+	engine := newEngine(t, Rule{Name: "unmatched", Strings: []Pattern{{Text: "nothing"}}, Tags: []string{"never"}})
+	file := &types.ProcessedFile{Tags: []string{"existing"}, DetectionScore: 0.5}
+	if err := engine.Apply(path, file); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(file.Tags, []string{"existing"}) || file.MatchedRules != nil || file.Provenance != nil || file.DetectionScore != 0.5 {
+		t.Errorf("Apply() without matches changed the file: %+v", file)
+	}
+}
@@ -0,0 +1,258 @@
+package rules
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule is a declarative detection rule. It matches a file when every field
+// condition holds and its strings match as its condition requires; a rule
+// without strings matches on its fields alone.
+type Rule struct {
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description"`
+	Strings     []Pattern         `yaml:"strings"`
+	Condition   string            `yaml:"condition"` // any (default) or all of the strings
+	Fields      map[string]string `yaml:"fields"`    // Field or metadata key to a case-insensitive glob
+
+	// Actions applied when the rule matches
+	Tags       []string  `yaml:"tags"`
+	Set        Overrides `yaml:"set"`
+	Confidence float64   `yaml:"confidence"` // Added to the detection score
+
+	patterns []*pattern
+}
+
+// Pattern is a string or byte sequence to look for in a file
+type Pattern struct {
+	Text     string `yaml:"text"`
+	Hex      string `yaml:"hex"`      // Hex bytes, with ?? for any byte, e.g. "4D 5A ?? 00"
+	Encoding string `yaml:"encoding"` // For text: ascii, utf16 or both (default)
+	NoCase   bool   `yaml:"nocase"`   // For text: ignore ASCII case
+	Offset   *int64 `yaml:"offset"`   // Match only at this offset; negative counts back from the end
+}
+
+// Overrides are the detection results a matching rule replaces
+type Overrides struct {
+	FileType    string `yaml:"file_type"`
+	Platform    string `yaml:"platform"`
+	IsInstaller *bool  `yaml:"is_installer"`
+}
+
+// ruleFile is the layout of a rule file
+type ruleFile struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// pattern is a compiled Pattern; it matches if any of its sequences do
+type pattern struct {
+	sequences []*sequence
+	offset    *int64
+}
+
+// sequence is a byte sequence where -1 matches any byte
+type sequence struct {
+	bytes  []int16
+	nocase bool
+	anchor int // Index of the first fixed byte, used to find candidates
+}
+
+// Load reads rules from files and directories. Directories contribute
+// their .yaml and .yml files in name order.
+func Load(paths ...string) (*Engine, error) {
+	var files []string
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rules: %w", err)
+		}
+		if !info.IsDir() {
+			files = append(files, root)
+			continue
+		}
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rules: %w", err)
+		}
+		for _, entry := range entries {
+			ext := strings.ToLower(filepath.Ext(entry.Name()))
+			if !entry.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				files = append(files, filepath.Join(root, entry.Name()))
+			}
+		}
+	}
+
+	var rules []Rule
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rules: %w", err)
+		}
+		var parsed ruleFile
+		if err := yaml.Unmarshal(data, &parsed); err != nil {
+			return nil, fmt.Errorf("failed to parse rule file %s: %w", file, err)
+		}
+		rules = append(rules, parsed.Rules...)
+	}
+
+	return New(rules)
+}
+
+// New compiles rules into an engine. Rules are applied in order.
+func New(rules []Rule) (*Engine, error) {
+	names := make(map[string]bool)
+	for i := range rules {
+		r := &rules[i]
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d has no name", i+1)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("duplicate rule %q", r.Name)
+		}
+		names[r.Name] = true
+
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+	}
+	return &Engine{rules: rules}, nil
+}
+
+// compile checks a rule and compiles its patterns
+func (r *Rule) compile() error {
+	switch strings.ToLower(r.Condition) {
+	case "", "any", "all":
+	default:
+		return fmt.Errorf("unknown condition %q", r.Condition)
+	}
+	if len(r.Strings) == 0 && len(r.Fields) == 0 {
+		return errors.New("rule has neither strings nor fields to match")
+	}
+	for key, glob := range r.Fields {
+		if _, err := path.Match(strings.ToLower(glob), ""); err != nil {
+			return fmt.Errorf("field %s: %w", key, err)
+		}
+	}
+
+	r.patterns = nil
+	for i, p := range r.Strings {
+		compiled, err := p.compile()
+		if err != nil {
+			return fmt.Errorf("string %d: %w", i+1, err)
+		}
+		r.patterns = append(r.patterns, compiled)
+	}
+	return nil
+}
+
+// compile converts a pattern to the byte sequences it matches
+func (p Pattern) compile() (*pattern, error) {
+	compiled := &pattern{offset: p.Offset}
+
+	switch {
+	case p.Text != "" && p.Hex != "":
+		return nil, errors.New("set either text or hex, not both")
+	case p.Hex != "":
+		seq, err := parseHex(p.Hex)
+		if err != nil {
+			return nil, err
+		}
+		compiled.sequences = append(compiled.sequences, seq)
+	case p.Text != "":
+		encoding := strings.ToLower(p.Encoding)
+		if encoding == "" || encoding == "both" || encoding == "ascii" {
+			compiled.sequences = append(compiled.sequences, textSequence([]byte(p.Text), p.NoCase))
+		}
+		if encoding == "" || encoding == "both" || encoding == "utf16" {
+			compiled.sequences = append(compiled.sequences, textSequence(encodeUTF16LE(p.Text), p.NoCase))
+		}
+		if len(compiled.sequences) == 0 {
+			return nil, fmt.Errorf("unknown encoding %q", p.Encoding)
+		}
+	default:
+		return nil, errors.New("set text or hex")
+	}
+
+	return compiled, nil
+}
+
+// parseHex parses hex bytes such as "4D 5A ?? 00", where ?? matches any byte
+func parseHex(s string) (*sequence, error) {
+	digits := strings.Join(strings.Fields(s), "")
+	if len(digits)%2 != 0 {
+		return nil, fmt.Errorf("hex %q has an odd number of digits", s)
+	}
+
+	seq := &sequence{anchor: -1}
+	for i := 0; i < len(digits); i += 2 {
+		pair := digits[i : i+2]
+		if pair == "??" {
+			seq.bytes = append(seq.bytes, -1)
+			continue
+		}
+		b, err := hex.DecodeString(pair)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex byte %q", pair)
+		}
+		if seq.anchor < 0 {
+			seq.anchor = len(seq.bytes)
+		}
+		seq.bytes = append(seq.bytes, int16(b[0]))
+	}
+	if seq.anchor < 0 {
+		return nil, fmt.Errorf("hex %q has no fixed bytes", s)
+	}
+	return seq, nil
+}
+
+// textSequence builds a sequence matching text exactly, or ignoring ASCII
+// case
+func textSequence(text []byte, nocase bool) *sequence {
+	seq := &sequence{nocase: nocase}
+	for _, b := range text {
+		if nocase {
+			b = lowerASCII(b)
+		}
+		seq.bytes = append(seq.bytes, int16(b))
+	}
+	return seq
+}
+
+func encodeUTF16LE(s string) []byte {
+	chars := utf16.Encode([]rune(s))
+	b := make([]byte, 0, 2*len(chars))
+	for _, c := range chars {
+		b = append(b, byte(c), byte(c>>8))
+	}
+	return b
+}
+
+func lowerASCII(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// sortedUnique returns values sorted, without duplicates or empty values
+func sortedUnique(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
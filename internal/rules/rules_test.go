@@ -0,0 +1,98 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		err   string
+	}{
+		{"no name", []Rule{{Strings: []Pattern{{Text: "x"}}}}, "has no name"},
+		{"duplicate", []Rule{{Name: "a", Strings: []Pattern{{Text: "x"}}}, {Name: "a", Strings: []Pattern{{Text: "y"}}}}, "duplicate"},
+		{"nothing to match", []Rule{{Name: "a", Tags: []string{"t"}}}, "neither strings nor fields"},
+		{"condition", []Rule{{Name: "a", Strings: []Pattern{{Text: "x"}}, Condition: "most"}}, "unknown condition"},
+		{"bad glob", []Rule{{Name: "a", Fields: map[string]string{"filename": "[a"}}}, "field filename"},
+		{"text and hex", []Rule{{Name: "a", Strings: []Pattern{{Text: "x", Hex: "00"}}}}, "not both"},
+		{"empty pattern", []Rule{{Name: "a", Strings: []Pattern{{}}}}, "set text or hex"},
+		{"encoding", []Rule{{Name: "a", Strings: []Pattern{{Text: "x", Encoding: "ebcdic"}}}}, "unknown encoding"},
+		{"odd hex", []Rule{{Name: "a", Strings: []Pattern{{Hex: "4D 5"}}}}, "odd number"},
+		{"invalid hex", []Rule{{Name: "a", Strings: []Pattern{{Hex: "4D ZZ"}}}}, "invalid hex byte"},
+		{"only wildcards", []Rule{{Name: "a", Strings: []Pattern{{Hex: "?? ??"}}}}, "no fixed bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.rules)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("New() error = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseHex(t *testing.T) {
+	seq, err := parseHex("?? 4d5A ?? 00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int16{-1, 0x4D, 0x5A, -1, 0x00}
+	if len(seq.bytes) != len(want) || seq.anchor != 1 {
+		t.Fatalf("parseHex() = %v anchor %d", seq.bytes, seq.anchor)
+	}
+	for i := range want {
+		if seq.bytes[i] != want[i] {
+			t.Errorf("byte %d = %d, want %d", i, seq.bytes[i], want[i])
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"b.yml": "rules:\n  - name: second\n    fields: {file_type: msi}\n",
+		"a.yaml": "rules:\n  - name: first\n    strings:\n      - text: Nullsoft\n        nocase: true\n" +
+			"      - hex: 4D 5A\n        offset: 0\n    condition: all\n    tags: [nsis]\n    set: {is_installer: true}\n",
+		"notes.txt": "not a rule file",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	extra := filepath.Join(t.TempDir(), "extra.rules")
+	if err := os.WriteFile(extra, []byte("rules:\n  - name: third\n    fields: {platform: linux}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine, err := Load(dir, extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Directory entries in name order, then files as given
+	var names []string
+	for _, r := range engine.rules {
+		names = append(names, r.Name)
+	}
+	if strings.Join(names, ",") != "first,second,third" {
+		t.Errorf("rules = %v", names)
+	}
+	first := engine.rules[0]
+	if len(first.patterns) != 2 || first.Strings[1].Offset == nil || *first.Strings[1].Offset != 0 ||
+		first.Set.IsInstaller == nil || !*first.Set.IsInstaller {
+		t.Errorf("first rule = %+v", first)
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Load() of a missing path succeeded")
+	}
+	bad := filepath.Join(t.TempDir(), "bad.yaml")
+	os.WriteFile(bad, []byte("rules: [name: x"), 0644)
+	if _, err := Load(bad); err == nil || !strings.Contains(err.Error(), "bad.yaml") {
+		t.Errorf("Load() of invalid YAML error = %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package sbom
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// cdxDocument is a CycloneDX 1.5 BOM
+type cdxDocument struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []*cdxComponent `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies,omitempty"`
+}
+
+type cdxMetadata struct {
+	Timestamp string        `json:"timestamp"`
+	Tools     cdxTools      `json:"tools"`
+	Component *cdxComponent `json:"component,omitempty"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	BOMRef     string          `json:"bom-ref,omitempty"`
+	Type       string          `json:"type"`
+	Supplier   *cdxSupplier    `json:"supplier,omitempty"`
+	Name       string          `json:"name"`
+	Version    string          `json:"version,omitempty"`
+	Hashes     []cdxHash       `json:"hashes,omitempty"`
+	Licenses   []cdxLicense    `json:"licenses,omitempty"`
+	PURL       string          `json:"purl,omitempty"`
+	ExtRefs    []cdxReference  `json:"externalReferences,omitempty"`
+	Properties []cdxProperty   `json:"properties,omitempty"`
+	Components []*cdxComponent `json:"components,omitempty"`
+}
+
+type cdxSupplier struct {
+	Name string `json:"name"`
+}
+
+type cdxHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+// cdxLicense is either a license or an SPDX expression
+type cdxLicense struct {
+	License    *cdxLicenseID `json:"license,omitempty"`
+	Expression string        `json:"expression,omitempty"`
+}
+
+type cdxLicenseID struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type cdxReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// cdxTypes maps component kinds to CycloneDX component types
+var cdxTypes = map[string]string{
+	kindInstaller:  "application",
+	kindFile:       "file",
+	kindPackage:    "library",
+	kindDependency: "library",
+}
+
+// CycloneDX writes a CycloneDX 1.5 JSON document describing files. A
+// document for a single installer describes it in its metadata; otherwise
+// every installer is a top-level component.
+func CycloneDX(w io.Writer, files []types.ProcessedFile, created time.Time) error {
+	doc := cdxDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + documentID(files),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: formatTime(created),
+			Tools: cdxTools{Components: []cdxComponent{
+				{Type: "application", Name: ToolName},
+			}},
+		},
+		Components: make([]*cdxComponent, 0),
+	}
+
+	// Dependencies are shared between installers, so each is listed once
+	dependencies := make(map[string]bool)
+	for i, file := range files {
+		root := installerComponent(file)
+		component := cdxFromComponent(root, installerRef(file, i))
+
+		if len(files) == 1 {
+			doc.Metadata.Component = component
+			doc.Components = append(doc.Components, component.Components...)
+			component.Components = nil
+		} else {
+			doc.Components = append(doc.Components, component)
+		}
+
+		var dependsOn []string
+		for _, dep := range root.dependsOn {
+			dependsOn = append(dependsOn, dep.purl)
+			if !dependencies[dep.purl] {
+				dependencies[dep.purl] = true
+				doc.Components = append(doc.Components, &cdxComponent{
+					BOMRef: dep.purl, Type: cdxTypes[dep.kind], Name: dep.name, PURL: dep.purl,
+				})
+			}
+		}
+		if len(dependsOn) > 0 {
+			doc.Dependencies = append(doc.Dependencies, cdxDependency{Ref: component.BOMRef, DependsOn: dependsOn})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(doc)
+}
+
+// cdxFromComponent converts a component and its children
+func cdxFromComponent(c *component, ref string) *cdxComponent {
+	out := &cdxComponent{
+		BOMRef:  ref,
+		Type:    cdxTypes[c.kind],
+		Name:    c.name,
+		Version: c.version,
+		PURL:    c.purl,
+	}
+	if c.supplier != "" {
+		out.Supplier = &cdxSupplier{Name: c.supplier}
+	}
+	for _, h := range c.hashes {
+		out.Hashes = append(out.Hashes, cdxHash{Algorithm: h.algorithm, Content: h.value})
+	}
+	if c.license != "" {
+		out.Licenses = []cdxLicense{cdxLicenseFor(c.license)}
+	}
+	if c.downloadURL != "" {
+		out.ExtRefs = append(out.ExtRefs, cdxReference{Type: "distribution", URL: c.downloadURL})
+	}
+	if c.filename != "" && c.filename != c.name {
+		out.Properties = append(out.Properties, cdxProperty{Name: ToolName + ":filename", Value: c.filename})
+	}
+
+	for i, child := range c.children {
+		out.Components = append(out.Components, cdxFromComponent(child, childRef(ref, i)))
+	}
+	return out
+}
+
+// cdxLicenseFor expresses a license as an SPDX identifier or expression
+// where possible, and by name otherwise
+func cdxLicenseFor(license string) cdxLicense {
+	expression, ok := spdxExpression(license)
+	switch {
+	case ok && spdxLicenseIDs[expression]:
+		return cdxLicense{License: &cdxLicenseID{ID: expression}}
+	case ok:
+		return cdxLicense{Expression: expression}
+	default:
+		return cdxLicense{License: &cdxLicenseID{Name: license}}
+	}
+}
@@ -0,0 +1,379 @@
+package sbom
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+	"github.com/deploymenttheory/go-app-index/internal/version"
+)
+
+// ToolName identifies the generator in SBOM documents
+const ToolName = "installer-scraper"
+
+// Format is an SBOM document format
+type Format string
+
+const (
+	FormatCycloneDX Format = "cyclonedx" // CycloneDX 1.5 JSON
+	FormatSPDX      Format = "spdx"      // SPDX 2.3 JSON
+)
+
+// Component kinds
+const (
+	kindInstaller  = "installer"  // An indexed installer file
+	kindFile       = "file"       // A file inside an archive
+	kindPackage    = "package"    // A component package of a bundle
+	kindDependency = "dependency" // A package the installer depends on
+)
+
+// component is an installer, or a part or dependency of one, in a form
+// both document formats are generated from
+type component struct {
+	kind        string
+	name        string
+	version     string
+	purl        string
+	supplier    string
+	license     string
+	filename    string
+	downloadURL string
+	hashes      []hash
+	children    []*component
+	dependsOn   []*component
+}
+
+// hash is a file digest. Algorithms are named as CycloneDX names them.
+type hash struct {
+	algorithm string
+	value     string
+}
+
+// Extension returns the file extension conventionally used for a format
+func (f Format) Extension() string {
+	if f == FormatSPDX {
+		return ".spdx.json"
+	}
+	return ".cdx.json"
+}
+
+// ParseFormat reads a format name
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case FormatCycloneDX, "cdx":
+		return FormatCycloneDX, nil
+	case FormatSPDX:
+		return FormatSPDX, nil
+	default:
+		return "", fmt.Errorf("unknown SBOM format %q (cyclonedx or spdx)", name)
+	}
+}
+
+// installerComponent builds the component tree for an indexed installer:
+// the files an archive holds, the component packages of a bundle, and the
+// packages it depends on
+func installerComponent(file types.ProcessedFile) *component {
+	root := &component{
+		kind:        kindInstaller,
+		name:        componentName(file),
+		version:     componentVersion(file),
+		supplier:    supplier(file),
+		license:     file.MetadataString("license"),
+		filename:    file.Filename,
+		downloadURL: file.SourceURL,
+	}
+	if file.SHA3Hash != "" {
+		root.hashes = append(root.hashes, hash{"SHA3-256", file.SHA3Hash})
+	}
+	if sum := file.SHA256(); sum != "" {
+		root.hashes = append(root.hashes, hash{"SHA-256", sum})
+	}
+	root.purl = installerPURL(file, root)
+
+	for _, name := range file.MetadataStrings("installer_files") {
+		root.children = append(root.children, &component{
+			kind:     kindFile,
+			name:     path.Base(name),
+			filename: name,
+			purl:     newPURL("generic", "", path.Base(name), "", nil),
+		})
+	}
+	if file.FileType == "pkg" {
+		for _, id := range file.MetadataStrings("package_ids") {
+			if id == root.name {
+				continue
+			}
+			root.children = append(root.children, &component{
+				kind: kindPackage,
+				name: id,
+				purl: newPURL("generic", "", id, "", nil),
+			})
+		}
+	}
+	for _, name := range file.MetadataStrings("dependencies") {
+		root.dependsOn = append(root.dependsOn, &component{
+			kind: kindDependency,
+			name: name,
+			purl: dependencyPURL(file, name),
+		})
+	}
+
+	return root
+}
+
+// PackageURL returns the package URL identifying an installer
+func PackageURL(file types.ProcessedFile) string {
+	return installerPURL(file, &component{name: componentName(file), version: componentVersion(file)})
+}
+
+// componentName returns the package or product name the analyzers found,
+// falling back to the filename
+func componentName(file types.ProcessedFile) string {
+	for _, key := range []string{"package_name", "product_name", "name"} {
+		if name := file.MetadataString(key); name != "" {
+			return name
+		}
+	}
+	return file.Filename
+}
+
+// componentVersion returns the package's own version string, so that PURLs
+// match the ones package managers use
+func componentVersion(file types.ProcessedFile) string {
+	if file.FileType == "rpm" {
+		// The epoch is a PURL qualifier rather than part of the version
+		if v := file.MetadataString("package_version"); v != "" {
+			return version.RPMEVR("", v, file.MetadataString("package_release"))
+		}
+	}
+	if file.Version != "" {
+		return file.Version
+	}
+	return file.NormalizedVersion
+}
+
+// supplier returns the publisher of an installer
+func supplier(file types.ProcessedFile) string {
+	if file.Publisher != "" {
+		return file.Publisher
+	}
+	for _, key := range []string{"publisher", "vendor", "company", "maintainer"} {
+		if name := file.MetadataString(key); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// debArchitectures maps normalized architectures to Debian's names
+var debArchitectures = map[string]string{
+	"x64":    "amd64",
+	"x86":    "i386",
+	"arm64":  "arm64",
+	"arm":    "armhf",
+	"noarch": "all",
+}
+
+// rpmNamespaces maps platforms to the distribution vendor used as the
+// namespace of rpm PURLs
+var rpmNamespaces = map[string]string{
+	"linux-fedora":    "fedora",
+	"linux-rhel":      "redhat",
+	"linux-centos":    "centos",
+	"linux-rocky":     "rocky",
+	"linux-almalinux": "almalinux",
+	"linux-amazon":    "amzn",
+	"linux-suse":      "opensuse",
+}
+
+// installerPURL returns the package URL of an installer. deb and rpm
+// packages get their package manager's type; anything else is generic and
+// qualified by its checksum and download URL.
+func installerPURL(file types.ProcessedFile, c *component) string {
+	switch file.FileType {
+	case "deb":
+		namespace := "debian"
+		if file.Platform == "linux-ubuntu" {
+			namespace = "ubuntu"
+		}
+		qualifiers := map[string]string{"arch": debArchitectures[file.Architecture]}
+		return newPURL("deb", namespace, c.name, c.version, qualifiers)
+	case "rpm":
+		qualifiers := map[string]string{"arch": file.MetadataString("package_arch")}
+		if epoch := file.MetadataString("package_epoch"); epoch != "" && epoch != "0" {
+			qualifiers["epoch"] = epoch
+		}
+		return newPURL("rpm", rpmNamespaces[file.Platform], c.name, c.version, qualifiers)
+	}
+
+	qualifiers := map[string]string{"download_url": file.SourceURL}
+	if file.SHA3Hash != "" {
+		qualifiers["checksum"] = "sha3-256:" + file.SHA3Hash
+	}
+	return newPURL("generic", "", c.name, c.version, qualifiers)
+}
+
+// dependencyPURL returns the package URL of a dependency, which is
+// resolved by the same package manager as the installer
+func dependencyPURL(file types.ProcessedFile, name string) string {
+	switch file.FileType {
+	case "deb":
+		namespace := "debian"
+		if file.Platform == "linux-ubuntu" {
+			namespace = "ubuntu"
+		}
+		return newPURL("deb", namespace, name, "", nil)
+	case "rpm":
+		return newPURL("rpm", rpmNamespaces[file.Platform], name, "", nil)
+	}
+	return newPURL("generic", "", name, "", nil)
+}
+
+// newPURL formats a package URL. Empty qualifiers are left out and the
+// rest are sorted by key, as the PURL specification requires.
+func newPURL(purlType, namespace, name, version string, qualifiers map[string]string) string {
+	var b strings.Builder
+	b.WriteString("pkg:" + purlType + "/")
+	if namespace != "" {
+		b.WriteString(purlEscape(namespace) + "/")
+	}
+	b.WriteString(purlEscape(name))
+	if version != "" {
+		b.WriteString("@" + purlEscape(version))
+	}
+
+	var keys []string
+	for key, value := range qualifiers {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		if i == 0 {
+			b.WriteString("?")
+		} else {
+			b.WriteString("&")
+		}
+		b.WriteString(key + "=" + purlEscape(qualifiers[key]))
+	}
+	return b.String()
+}
+
+// purlEscape percent-encodes a PURL component, keeping the characters the
+// specification allows unencoded
+func purlEscape(s string) string {
+	return strings.NewReplacer("+", "%2B", "@", "%40", "&", "%26").Replace(url.PathEscape(s))
+}
+
+// documentID derives a stable identifier for a document from the hashes
+// of the installers it describes, so exporting the same index twice gives
+// the same document
+func documentID(files []types.ProcessedFile) string {
+	h := sha1.New()
+	for _, file := range files {
+		h.Write([]byte(file.SHA3Hash + "\n"))
+	}
+	sum := h.Sum(nil)
+
+	// Format as a name-based (version 5) UUID
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// installerRef identifies an installer within a document by its hash
+func installerRef(file types.ProcessedFile, i int) string {
+	if file.SHA3Hash != "" {
+		return file.SHA3Hash
+	}
+	return fmt.Sprintf("installer-%d", i+1)
+}
+
+// childRef identifies a component inside another
+func childRef(parent string, i int) string {
+	return fmt.Sprintf("%s/%d", parent, i+1)
+}
+
+// documentName names a document after its only installer, or the index
+func documentName(files []types.ProcessedFile) string {
+	if len(files) == 1 {
+		return files[0].Filename
+	}
+	return "installer-index"
+}
+
+// formatTime formats a creation time as both formats require
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// spdxLicenseIDs are the SPDX license identifiers recognized in license
+// fields. Anything else is kept as a named license.
+var spdxLicenseIDs = map[string]bool{
+	"0BSD": true, "AGPL-3.0-only": true, "AGPL-3.0-or-later": true, "Apache-1.1": true,
+	"Apache-2.0": true, "Artistic-1.0": true, "Artistic-2.0": true, "BSD-2-Clause": true,
+	"BSD-3-Clause": true, "BSL-1.0": true, "CC0-1.0": true, "CC-BY-4.0": true,
+	"CC-BY-SA-4.0": true, "CDDL-1.0": true, "EPL-1.0": true, "EPL-2.0": true,
+	"GPL-1.0-or-later": true, "GPL-2.0-only": true, "GPL-2.0-or-later": true,
+	"GPL-3.0-only": true, "GPL-3.0-or-later": true, "ISC": true, "LGPL-2.0-only": true,
+	"LGPL-2.0-or-later": true, "LGPL-2.1-only": true, "LGPL-2.1-or-later": true,
+	"LGPL-3.0-only": true, "LGPL-3.0-or-later": true, "MIT": true, "MIT-0": true,
+	"MPL-1.1": true, "MPL-2.0": true, "MS-PL": true, "MS-RL": true, "OFL-1.1": true,
+	"OpenSSL": true, "PHP-3.01": true, "PostgreSQL": true, "PSF-2.0": true,
+	"Python-2.0": true, "Ruby": true, "Unlicense": true, "UPL-1.0": true, "Vim": true,
+	"W3C": true, "WTFPL": true, "X11": true, "Zlib": true, "curl": true,
+}
+
+// licenseTokens splits a license expression into identifiers, operators
+// and parentheses
+var licenseTokens = regexp.MustCompile(`\(|\)|[^\s()]+`)
+
+// spdxExpression returns a license as an SPDX license expression, and
+// whether it is one: every identifier must be a known SPDX license,
+// combined with AND, OR and WITH
+func spdxExpression(license string) (string, bool) {
+	tokens := licenseTokens.FindAllString(license, -1)
+	if len(tokens) == 0 {
+		return "", false
+	}
+	for i, token := range tokens {
+		switch strings.ToUpper(token) {
+		case "AND", "OR", "WITH":
+			tokens[i] = strings.ToUpper(token)
+		case "(", ")":
+		default:
+			if !spdxLicenseIDs[token] && (i == 0 || tokens[i-1] != "WITH") {
+				return "", false
+			}
+		}
+	}
+
+	var b strings.Builder
+	for i, token := range tokens {
+		if i > 0 && token != ")" && tokens[i-1] != "(" {
+			b.WriteString(" ")
+		}
+		b.WriteString(token)
+	}
+	return b.String(), true
+}
+
+// Write writes an SBOM document describing files in the given format
+func Write(w io.Writer, format Format, files []types.ProcessedFile, created time.Time) error {
+	switch format {
+	case FormatSPDX:
+		return SPDX(w, files, created)
+	case FormatCycloneDX:
+		return CycloneDX(w, files, created)
+	default:
+		return fmt.Errorf("unknown SBOM format %q", format)
+	}
+}
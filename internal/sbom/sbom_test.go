@@ -0,0 +1,218 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+var created = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// fixtures returns one installer of each kind the documents treat differently
+func fixtures() []types.ProcessedFile {
+	return []types.ProcessedFile{
+		{
+			Filename: "tool-2.4.1-x64.msi", SourceURL: "https://downloads.example.com/tool-2.4.1-x64.msi",
+			SHA3Hash: strings.Repeat("a1", 32), FileType: "msi", Platform: "windows", Architecture: "x64",
+			Version: "2.4.1", Publisher: "Example Corp <support@example.com>",
+			ExtendedMetadata: map[string]interface{}{
+				"product_name": "Example Tool", "license": "Apache-2.0 WITH LLVM-exception",
+				"sha256": strings.Repeat("0f", 32),
+			},
+		},
+		{
+			Filename: "tool_1.2.3-1_amd64.deb", SourceURL: "https://deb.example.com/pool/tool_1.2.3-1_amd64.deb",
+			SHA3Hash: strings.Repeat("b2", 32), FileType: "deb", Platform: "linux-ubuntu", Architecture: "x64",
+			Version: "1:1.2.3-1",
+			ExtendedMetadata: map[string]interface{}{
+				"package_name": "tool", "maintainer": "Jane Doe <jane@example.com>", "license": "GPL-2.0-or-later",
+				"dependencies": []interface{}{"libc6", "libssl3"},
+			},
+		},
+		{
+			Filename: "tool-1.2.3-1.el9.x86_64.rpm", SourceURL: "https://rpm.example.com/tool-1.2.3-1.el9.x86_64.rpm",
+			SHA3Hash: strings.Repeat("c3", 32), FileType: "rpm", Platform: "linux-rhel", Architecture: "x64",
+			Version: "2:1.2.3-1.el9",
+			ExtendedMetadata: map[string]interface{}{
+				"package_name": "tool", "package_epoch": "2", "package_version": "1.2.3", "package_release": "1.el9",
+				"package_arch": "x86_64", "license": "Proprietary EULA", "dependencies": []interface{}{"libc6", "openssl-libs"},
+			},
+		},
+		{
+			Filename: "tool-bundle.zip", SourceURL: "https://downloads.example.com/tool bundle+extras.zip",
+			SHA3Hash: strings.Repeat("d4", 32), FileType: "zip", Platform: "windows",
+			ExtendedMetadata: map[string]interface{}{
+				"installer_files": []interface{}{"bundle/setup.exe", "bundle/docs/readme.txt"},
+				"license":         "proprietary eula",
+			},
+		},
+		{
+			Filename: "Tool-3.1.0.pkg", SourceURL: "https://downloads.example.com/Tool-3.1.0.pkg",
+			SHA3Hash: strings.Repeat("e5", 32), FileType: "pkg", Platform: "macos", Architecture: "universal",
+			Version: "3.1.0",
+			ExtendedMetadata: map[string]interface{}{
+				"package_name": "com.example.tool",
+				"package_ids":  []interface{}{"com.example.tool", "com.example.tool.helper"},
+			},
+		},
+	}
+}
+
+func TestGolden(t *testing.T) {
+	files := fixtures()
+	tests := []struct {
+		golden string
+		format Format
+		files  []types.ProcessedFile
+	}{
+		{"index.cdx.json", FormatCycloneDX, files},
+		{"index.spdx.json", FormatSPDX, files},
+		{"single.cdx.json", FormatCycloneDX, files[1:2]},
+		{"single.spdx.json", FormatSPDX, files[1:2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.format, tt.files, created); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if !json.Valid(buf.Bytes()) {
+				t.Fatalf("Write() produced invalid JSON:\n%s", buf.Bytes())
+			}
+
+			golden := filepath.Join("testdata", "golden", tt.golden)
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("document differs from %s:\n%s", golden, buf.Bytes())
+			}
+		})
+	}
+}
+
+func TestInstallerPURL(t *testing.T) {
+	files := fixtures()
+	noarch := files[2]
+	noarch.Platform = "linux"
+	noarch.ExtendedMetadata = map[string]interface{}{"package_name": "tool-data", "package_epoch": "0", "package_version": "1.0", "package_arch": "noarch"}
+	debian := files[1]
+	debian.Platform, debian.Architecture = "linux-debian", "noarch"
+	unnamed := types.ProcessedFile{Filename: "My Tool@Home+1.exe", SourceURL: "https://example.com/a?b=c&d=e", Version: "1.0+build 5"}
+
+	tests := []struct {
+		name string
+		file types.ProcessedFile
+		want string
+	}{
+		{"generic", files[0], "pkg:generic/Example%20Tool@2.4.1?checksum=sha3-256:" + strings.Repeat("a1", 32) + "&download_url=https:%2F%2Fdownloads.example.com%2Ftool-2.4.1-x64.msi"},
+		{"ubuntu deb", files[1], "pkg:deb/ubuntu/tool@1:1.2.3-1?arch=amd64"},
+		{"debian noarch deb", debian, "pkg:deb/debian/tool@1:1.2.3-1?arch=all"},
+		{"rpm with epoch", files[2], "pkg:rpm/redhat/tool@1.2.3-1.el9?arch=x86_64&epoch=2"},
+		{"rpm without vendor or epoch", noarch, "pkg:rpm/tool-data@1.0?arch=noarch"},
+		{"escaping", unnamed, "pkg:generic/My%20Tool%40Home%2B1.exe@1.0%2Bbuild%205?download_url=https:%2F%2Fexample.com%2Fa%3Fb=c%26d=e"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PackageURL(tt.file); got != tt.want {
+				t.Errorf("PackageURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSPDXExpression(t *testing.T) {
+	tests := []struct {
+		license string
+		want    string
+		ok      bool
+	}{
+		{"MIT", "MIT", true},
+		{"MIT or Apache-2.0", "MIT OR Apache-2.0", true},
+		{"(MIT OR Apache-2.0) and Zlib", "(MIT OR Apache-2.0) AND Zlib", true},
+		{"GPL-2.0-or-later WITH Classpath-exception-2.0", "GPL-2.0-or-later WITH Classpath-exception-2.0", true},
+		{"Apache-2.0 with LLVM-exception", "Apache-2.0 WITH LLVM-exception", true},
+		{"mit", "", false},
+		{"MIT OR Proprietary", "", false},
+		{"Proprietary EULA", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := spdxExpression(tt.license)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("spdxExpression(%q) = %q, %v, want %q, %v", tt.license, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestComponentHierarchy(t *testing.T) {
+	files := fixtures()
+	tests := []struct {
+		name     string
+		file     types.ProcessedFile
+		children []string
+		purls    []string
+	}{
+		{"zip holds its files", files[3], []string{"file:setup.exe", "file:readme.txt"}, []string{"pkg:generic/setup.exe", "pkg:generic/readme.txt"}},
+		// The package named after the bundle is the bundle itself
+		{"pkg holds its other packages", files[4], []string{"package:com.example.tool.helper"}, []string{"pkg:generic/com.example.tool.helper"}},
+		{"msi has no children", files[0], nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var children, purls []string
+			for _, child := range installerComponent(tt.file).children {
+				children = append(children, child.kind+":"+child.name)
+				purls = append(purls, child.purl)
+			}
+			if !reflect.DeepEqual(children, tt.children) || !reflect.DeepEqual(purls, tt.purls) {
+				t.Errorf("children = %v %v, want %v %v", children, purls, tt.children, tt.purls)
+			}
+		})
+	}
+}
+
+func TestDocumentID(t *testing.T) {
+	files := fixtures()
+	id := documentID(files)
+	if id != documentID(fixtures()) {
+		t.Error("documentID() differs for the same files")
+	}
+	if documentID(files[:2]) == id || documentID([]types.ProcessedFile{files[1], files[0]}) == documentID(files[:2]) {
+		t.Error("documentID() is the same for different files")
+	}
+	// A version 5 UUID with the RFC 4122 variant
+	if len(id) != 36 || id[14] != '5' || !strings.ContainsRune("89ab", rune(id[19])) {
+		t.Errorf("documentID() = %s, want a version 5 UUID", id)
+	}
+
+	// Both formats carry it, so exporting twice gives the same documents
+	for _, format := range []Format{FormatCycloneDX, FormatSPDX} {
+		var first, second bytes.Buffer
+		if err := Write(&first, format, files, created); err != nil {
+			t.Fatal(err)
+		}
+		if err := Write(&second, format, fixtures(), created); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(first.Bytes(), second.Bytes()) || !strings.Contains(first.String(), id) {
+			t.Errorf("%s documents are not stable or lack the document ID", format)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// spdxNamespace prefixes the namespace URI of every generated document
+const spdxNamespace = "https://github.com/deploymenttheory/go-app-index/spdx/"
+
+// spdxNoAssertion marks a value the generator cannot state
+const spdxNoAssertion = "NOASSERTION"
+
+// spdxDocument is an SPDX 2.3 document
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+	ExtractedLicenses []spdxLicenseInfo  `json:"hasExtractedLicensingInfos,omitempty"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string         `json:"SPDXID"`
+	Name             string         `json:"name"`
+	Version          string         `json:"versionInfo,omitempty"`
+	FileName         string         `json:"packageFileName,omitempty"`
+	Supplier         string         `json:"supplier"`
+	DownloadLocation string         `json:"downloadLocation"`
+	FilesAnalyzed    bool           `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum `json:"checksums,omitempty"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	LicenseDeclared  string         `json:"licenseDeclared"`
+	CopyrightText    string         `json:"copyrightText"`
+	ExternalRefs     []spdxRef      `json:"externalRefs,omitempty"`
+	Purpose          string         `json:"primaryPackagePurpose,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"checksumValue"`
+}
+
+type spdxRef struct {
+	Category string `json:"referenceCategory"`
+	Type     string `json:"referenceType"`
+	Locator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	Element string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	Related string `json:"relatedSpdxElement"`
+}
+
+type spdxLicenseInfo struct {
+	LicenseID     string `json:"licenseId"`
+	Name          string `json:"name"`
+	ExtractedText string `json:"extractedText"`
+}
+
+// spdxPurposes maps component kinds to SPDX package purposes
+var spdxPurposes = map[string]string{
+	kindInstaller:  "INSTALL",
+	kindFile:       "FILE",
+	kindPackage:    "LIBRARY",
+	kindDependency: "LIBRARY",
+}
+
+// spdxAlgorithms maps hash algorithms to SPDX's names
+var spdxAlgorithms = map[string]string{
+	"SHA-256":  "SHA256",
+	"SHA3-256": "SHA3-256",
+}
+
+// spdxInvalidID matches characters not allowed in SPDX identifiers
+var spdxInvalidID = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// spdxWriter accumulates the packages and relationships of a document
+type spdxWriter struct {
+	doc          *spdxDocument
+	count        int
+	dependencies map[string]string // Dependency PURL to SPDX ID
+	licenseRefs  map[string]string // License text to LicenseRef ID
+}
+
+// SPDX writes an SPDX 2.3 JSON document describing files. The document
+// describes each installer, which contains its archive files or bundle
+// packages and depends on its package dependencies.
+func SPDX(w io.Writer, files []types.ProcessedFile, created time.Time) error {
+	id := documentID(files)
+	name := documentName(files)
+	writer := &spdxWriter{
+		doc: &spdxDocument{
+			SPDXVersion:       "SPDX-2.3",
+			DataLicense:       "CC0-1.0",
+			SPDXID:            "SPDXRef-DOCUMENT",
+			Name:              name,
+			DocumentNamespace: spdxNamespace + spdxInvalidID.ReplaceAllString(name, "-") + "-" + id,
+			CreationInfo: spdxCreationInfo{
+				Created:  formatTime(created),
+				Creators: []string{"Tool: " + ToolName},
+			},
+			Packages:      make([]spdxPackage, 0),
+			Relationships: make([]spdxRelationship, 0),
+		},
+		dependencies: make(map[string]string),
+		licenseRefs:  make(map[string]string),
+	}
+
+	for _, file := range files {
+		root := installerComponent(file)
+		rootID := writer.add(root)
+		writer.relate("SPDXRef-DOCUMENT", "DESCRIBES", rootID)
+
+		for _, dep := range root.dependsOn {
+			depID, ok := writer.dependencies[dep.purl]
+			if !ok {
+				depID = writer.add(dep)
+				writer.dependencies[dep.purl] = depID
+			}
+			writer.relate(rootID, "DEPENDS_ON", depID)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(writer.doc)
+}
+
+// add adds a package for a component and its children, returning its ID
+func (s *spdxWriter) add(c *component) string {
+	s.count++
+	id := fmt.Sprintf("SPDXRef-Package-%d", s.count)
+
+	pkg := spdxPackage{
+		SPDXID:           id,
+		Name:             c.name,
+		Version:          c.version,
+		Supplier:         spdxNoAssertion,
+		DownloadLocation: spdxNoAssertion,
+		LicenseConcluded: spdxNoAssertion,
+		LicenseDeclared:  spdxNoAssertion,
+		CopyrightText:    spdxNoAssertion,
+		Purpose:          spdxPurposes[c.kind],
+	}
+	if c.filename != c.name {
+		pkg.FileName = c.filename
+	}
+	if c.supplier != "" {
+		pkg.Supplier = "Organization: " + spdxContact(c.supplier)
+	}
+	if c.downloadURL != "" {
+		pkg.DownloadLocation = c.downloadURL
+	}
+	if c.license != "" {
+		pkg.LicenseDeclared = s.license(c.license)
+	}
+	for _, h := range c.hashes {
+		pkg.Checksums = append(pkg.Checksums, spdxChecksum{Algorithm: spdxAlgorithms[h.algorithm], Value: h.value})
+	}
+	if c.purl != "" {
+		pkg.ExternalRefs = []spdxRef{{Category: "PACKAGE-MANAGER", Type: "purl", Locator: c.purl}}
+	}
+	s.doc.Packages = append(s.doc.Packages, pkg)
+
+	for _, child := range c.children {
+		s.relate(id, "CONTAINS", s.add(child))
+	}
+	return id
+}
+
+// spdxContact formats a "Name <email>" contact as SPDX writes it,
+// "Name (email)"
+func spdxContact(contact string) string {
+	name, email, ok := strings.Cut(contact, "<")
+	if !ok {
+		return contact
+	}
+	return strings.TrimSpace(name) + " (" + strings.TrimSuffix(strings.TrimSpace(email), ">") + ")"
+}
+
+func (s *spdxWriter) relate(element, relationship, related string) {
+	s.doc.Relationships = append(s.doc.Relationships, spdxRelationship{
+		Element: element, Type: relationship, Related: related,
+	})
+}
+
+// license returns a license as an SPDX expression, declaring licenses
+// without an SPDX identifier as extracted licensing info
+func (s *spdxWriter) license(license string) string {
+	if expression, ok := spdxExpression(license); ok {
+		return expression
+	}
+	if ref, ok := s.licenseRefs[license]; ok {
+		return ref
+	}
+
+	ref := "LicenseRef-" + spdxInvalidID.ReplaceAllString(license, "-")
+	for _, existing := range s.licenseRefs {
+		if existing == ref {
+			ref = fmt.Sprintf("%s-%d", ref, len(s.licenseRefs)+1)
+			break
+		}
+	}
+	s.licenseRefs[license] = ref
+	s.doc.ExtractedLicenses = append(s.doc.ExtractedLicenses, spdxLicenseInfo{
+		LicenseID: ref, Name: license, ExtractedText: license,
+	})
+	return ref
+}
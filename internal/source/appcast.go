@@ -0,0 +1,112 @@
+package source
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// AppcastSource discovers macOS updates from a Sparkle appcast
+type AppcastSource struct {
+	FeedURL string
+}
+
+type appcastRSS struct {
+	Channel struct {
+		Title string        `xml:"title"`
+		Items []appcastItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type appcastItem struct {
+	Title              string           `xml:"title"`
+	PubDate            string           `xml:"pubDate"`
+	Description        string           `xml:"description"`
+	Link               string           `xml:"link"`
+	Version            string           `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle version"`
+	ShortVersionString string           `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle shortVersionString"`
+	MinimumOS          string           `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle minimumSystemVersion"`
+	ReleaseNotesLink   string           `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle releaseNotesLink"`
+	Enclosures         []appcastEnclose `xml:"enclosure"`
+}
+
+type appcastEnclose struct {
+	URL                string `xml:"url,attr"`
+	Length             int64  `xml:"length,attr"`
+	Type               string `xml:"type,attr"`
+	Version            string `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle version,attr"`
+	ShortVersionString string `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle shortVersionString,attr"`
+	EdSignature        string `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle edSignature,attr"`
+	DSASignature       string `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle dsaSignature,attr"`
+	OS                 string `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle os,attr"`
+}
+
+// Name returns a short description of the source
+func (s *AppcastSource) Name() string {
+	return "appcast:" + s.FeedURL
+}
+
+// Discover reads the appcast and returns one download per enclosure
+func (s *AppcastSource) Discover(ctx context.Context, client *http.Client) ([]types.DownloadRequest, error) {
+	body, err := fetch(ctx, client, s.FeedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var feed appcastRSS
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse appcast: %w", err)
+	}
+
+	var requests []types.DownloadRequest
+	for _, item := range feed.Channel.Items {
+		for _, enc := range item.Enclosures {
+			if enc.URL == "" {
+				continue
+			}
+
+			// Element values take precedence over legacy enclosure attributes
+			build := firstNonEmpty(item.Version, enc.Version)
+			version := firstNonEmpty(item.ShortVersionString, enc.ShortVersionString, build)
+
+			signature := enc.EdSignature
+			if signature == "" && enc.DSASignature != "" {
+				signature = "dsa:" + enc.DSASignature
+			}
+
+			requests = append(requests, types.DownloadRequest{
+				URL:      enc.URL,
+				FileName: fileNameFromURL(enc.URL),
+				Release: &types.ReleaseInfo{
+					Source:       "appcast",
+					FeedURL:      s.FeedURL,
+					Title:        strings.TrimSpace(item.Title),
+					Version:      version,
+					Build:        build,
+					ReleaseDate:  parseFeedTime(item.PubDate),
+					Notes:        strings.TrimSpace(item.Description),
+					NotesURL:     firstNonEmpty(item.ReleaseNotesLink, item.Link),
+					MinOSVersion: item.MinimumOS,
+					Signature:    signature,
+					Length:       enc.Length,
+				},
+			})
+		}
+	}
+
+	return requests, nil
+}
+
+// firstNonEmpty returns the first non-empty, trimmed value
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
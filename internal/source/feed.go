@@ -0,0 +1,172 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// FeedSource discovers installers linked from a generic RSS or Atom feed
+type FeedSource struct {
+	FeedURL        string
+	FileExtensions []string // Link extensions to queue; enclosures are always queued
+}
+
+type rssFeed struct {
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	PubDate     string `xml:"pubDate"`
+	Enclosures  []struct {
+		URL    string `xml:"url,attr"`
+		Length int64  `xml:"length,attr"`
+	} `xml:"enclosure"`
+}
+
+type atomFeed struct {
+	Entries []atomEntry `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"http://www.w3.org/2005/Atom title"`
+	Updated   string     `xml:"http://www.w3.org/2005/Atom updated"`
+	Published string     `xml:"http://www.w3.org/2005/Atom published"`
+	Summary   string     `xml:"http://www.w3.org/2005/Atom summary"`
+	Content   atomText   `xml:"http://www.w3.org/2005/Atom content"`
+	Links     []atomLink `xml:"http://www.w3.org/2005/Atom link"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Src  string `xml:"src,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Length int64  `xml:"length,attr"`
+}
+
+// Name returns a short description of the source
+func (s *FeedSource) Name() string {
+	return "feed:" + s.FeedURL
+}
+
+// Discover reads the feed and returns its installer links
+func (s *FeedSource) Discover(ctx context.Context, client *http.Client) ([]types.DownloadRequest, error) {
+	body, err := fetch(ctx, client, s.FeedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if isAtom(body) {
+		return s.parseAtom(body)
+	}
+	return s.parseRSS(body)
+}
+
+// isAtom checks whether the document root is an Atom <feed> element
+func isAtom(body []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local == "feed"
+		}
+	}
+}
+
+func (s *FeedSource) parseRSS(body []byte) ([]types.DownloadRequest, error) {
+	var feed rssFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse RSS feed: %w", err)
+	}
+
+	var requests []types.DownloadRequest
+	for _, item := range feed.Channel.Items {
+		release := types.ReleaseInfo{
+			Source:      "rss",
+			FeedURL:     s.FeedURL,
+			Title:       strings.TrimSpace(item.Title),
+			Version:     versionFromTitle(item.Title),
+			ReleaseDate: parseFeedTime(item.PubDate),
+			Notes:       strings.TrimSpace(item.Description),
+		}
+
+		for _, enc := range item.Enclosures {
+			if enc.URL == "" {
+				continue
+			}
+			rel := release
+			rel.Length = enc.Length
+			requests = append(requests, newFeedRequest(enc.URL, &rel))
+		}
+
+		link := strings.TrimSpace(item.Link)
+		if len(item.Enclosures) == 0 && link != "" && hasExtension(link, s.FileExtensions) {
+			rel := release
+			requests = append(requests, newFeedRequest(link, &rel))
+		}
+	}
+
+	return requests, nil
+}
+
+func (s *FeedSource) parseAtom(body []byte) ([]types.DownloadRequest, error) {
+	var feed atomFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse Atom feed: %w", err)
+	}
+
+	var requests []types.DownloadRequest
+	for _, entry := range feed.Entries {
+		release := types.ReleaseInfo{
+			Source:      "atom",
+			FeedURL:     s.FeedURL,
+			Title:       strings.TrimSpace(entry.Title),
+			Version:     versionFromTitle(entry.Title),
+			ReleaseDate: parseFeedTime(firstNonEmpty(entry.Published, entry.Updated)),
+			Notes:       firstNonEmpty(entry.Summary, entry.Content.Body),
+		}
+
+		for _, link := range entry.Links {
+			if link.Href == "" {
+				continue
+			}
+			if link.Rel == "alternate" || link.Rel == "" {
+				release.NotesURL = firstNonEmpty(release.NotesURL, link.Href)
+			}
+			if link.Rel != "enclosure" && !hasExtension(link.Href, s.FileExtensions) {
+				continue
+			}
+			rel := release
+			rel.Length = link.Length
+			requests = append(requests, newFeedRequest(link.Href, &rel))
+		}
+	}
+
+	return requests, nil
+}
+
+func newFeedRequest(link string, release *types.ReleaseInfo) types.DownloadRequest {
+	return types.DownloadRequest{
+		URL:      link,
+		FileName: fileNameFromURL(link),
+		Release:  release,
+	}
+}
@@ -0,0 +1,158 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+	"github.com/deploymenttheory/go-app-index/internal/urlutil"
+)
+
+const (
+	defaultGitHubAPI  = "https://api.github.com"
+	defaultGitHubHost = "api.github.com"
+)
+
+// GitHubSource discovers installers attached to GitHub Releases
+type GitHubSource struct {
+	ReleasesURL        string   // Releases API endpoint
+	Repository         string   // owner/repo, for logging
+	FileExtensions     []string // Asset extensions to queue
+	IncludePrereleases bool
+	MaxReleases        int      // Number of most recent releases to inspect
+	TokenHosts         []string // GitHub Enterprise Server API hosts that may be sent GITHUB_TOKEN
+}
+
+// githubRelease is the subset of the GitHub Releases API response we use
+type githubRelease struct {
+	TagName     string        `json:"tag_name"`
+	Name        string        `json:"name"`
+	Body        string        `json:"body"`
+	HTMLURL     string        `json:"html_url"`
+	Draft       bool          `json:"draft"`
+	Prerelease  bool          `json:"prerelease"`
+	PublishedAt string        `json:"published_at"`
+	Assets      []githubAsset `json:"assets"`
+}
+
+type githubAsset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+	Size               int64  `json:"size"`
+	ContentType        string `json:"content_type"`
+}
+
+// NewGitHubSource creates a GitHub Releases source. The target is either
+// "owner/repo" or a full releases API URL (for GitHub Enterprise or fixtures).
+func NewGitHubSource(target string, fileExtensions []string) (*GitHubSource, error) {
+	src := &GitHubSource{
+		FileExtensions: fileExtensions,
+		MaxReleases:    10,
+	}
+
+	if strings.Contains(target, "://") {
+		src.ReleasesURL = target
+		src.Repository = target
+		return src, nil
+	}
+
+	parts := strings.Split(strings.Trim(target, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid GitHub repository %q, expected owner/repo", target)
+	}
+
+	src.Repository = parts[0] + "/" + parts[1]
+	src.ReleasesURL = fmt.Sprintf("%s/repos/%s/releases", defaultGitHubAPI, src.Repository)
+	return src, nil
+}
+
+// sendsToken reports whether GITHUB_TOKEN may be sent to a releases URL:
+// only over HTTPS to api.github.com or a configured GitHub Enterprise host
+func (s *GitHubSource) sendsToken(releasesURL string) bool {
+	u, err := url.Parse(releasesURL)
+	if err != nil || u.Scheme != "https" {
+		return false
+	}
+	for _, host := range append([]string{defaultGitHubHost}, s.TokenHosts...) {
+		if urlutil.MatchDomain(host, u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Name returns a short description of the source
+func (s *GitHubSource) Name() string {
+	return "github:" + s.Repository
+}
+
+// Discover lists the repository's releases and returns their installer assets
+func (s *GitHubSource) Discover(ctx context.Context, client *http.Client) ([]types.DownloadRequest, error) {
+	releasesURL := s.ReleasesURL
+	if s.MaxReleases > 0 && !strings.Contains(releasesURL, "per_page=") {
+		sep := "?"
+		if strings.Contains(releasesURL, "?") {
+			sep = "&"
+		}
+		releasesURL = fmt.Sprintf("%s%sper_page=%d", releasesURL, sep, s.MaxReleases)
+	}
+
+	headers := map[string]string{"Accept": "application/vnd.github+json"}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" && s.sendsToken(releasesURL) {
+		headers["Authorization"] = "Bearer " + token
+	}
+
+	body, err := fetch(ctx, client, releasesURL, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []githubRelease
+	if err := json.Unmarshal(body, &releases); err != nil {
+		return nil, fmt.Errorf("failed to parse releases: %w", err)
+	}
+
+	var requests []types.DownloadRequest
+	for i, rel := range releases {
+		if s.MaxReleases > 0 && i >= s.MaxReleases {
+			break
+		}
+		if rel.Draft || (rel.Prerelease && !s.IncludePrereleases) {
+			continue
+		}
+
+		version := strings.TrimPrefix(strings.TrimPrefix(rel.TagName, "v"), "V")
+		if version == "" {
+			version = versionFromTitle(rel.Name)
+		}
+
+		for _, asset := range rel.Assets {
+			if len(s.FileExtensions) > 0 && !hasExtension(asset.Name, s.FileExtensions) {
+				continue
+			}
+
+			requests = append(requests, types.DownloadRequest{
+				URL:      asset.BrowserDownloadURL,
+				FileName: asset.Name,
+				Release: &types.ReleaseInfo{
+					Source:      "github",
+					FeedURL:     s.ReleasesURL,
+					Title:       rel.Name,
+					Version:     version,
+					ReleaseDate: parseFeedTime(rel.PublishedAt),
+					Notes:       rel.Body,
+					NotesURL:    rel.HTMLURL,
+					Length:      asset.Size,
+					Prerelease:  rel.Prerelease,
+				},
+			})
+		}
+	}
+
+	return requests, nil
+}
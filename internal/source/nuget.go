@@ -0,0 +1,104 @@
+package source
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+const chocolateyFeed = "https://community.chocolatey.org/api/v2"
+
+// NuGetSource discovers packages from a NuGet v2 (OData) feed, as served by
+// Chocolatey and most private NuGet servers
+type NuGetSource struct {
+	FeedURL string // FindPackagesById() or Packages() query URL
+}
+
+type nugetFeed struct {
+	Entries []nugetEntry `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+type nugetEntry struct {
+	Title      string          `xml:"http://www.w3.org/2005/Atom title"`
+	Updated    string          `xml:"http://www.w3.org/2005/Atom updated"`
+	Content    atomText        `xml:"http://www.w3.org/2005/Atom content"`
+	Properties nugetProperties `xml:"http://schemas.microsoft.com/ado/2007/08/dataservices/metadata properties"`
+}
+
+type nugetProperties struct {
+	ID                   string `xml:"http://schemas.microsoft.com/ado/2007/08/dataservices Id"`
+	Version              string `xml:"http://schemas.microsoft.com/ado/2007/08/dataservices Version"`
+	Title                string `xml:"http://schemas.microsoft.com/ado/2007/08/dataservices Title"`
+	Published            string `xml:"http://schemas.microsoft.com/ado/2007/08/dataservices Published"`
+	ReleaseNotes         string `xml:"http://schemas.microsoft.com/ado/2007/08/dataservices ReleaseNotes"`
+	ProjectURL           string `xml:"http://schemas.microsoft.com/ado/2007/08/dataservices ProjectUrl"`
+	PackageSize          int64  `xml:"http://schemas.microsoft.com/ado/2007/08/dataservices PackageSize"`
+	IsPrerelease         bool   `xml:"http://schemas.microsoft.com/ado/2007/08/dataservices IsPrerelease"`
+	PackageHash          string `xml:"http://schemas.microsoft.com/ado/2007/08/dataservices PackageHash"`
+	PackageHashAlgorithm string `xml:"http://schemas.microsoft.com/ado/2007/08/dataservices PackageHashAlgorithm"`
+}
+
+// NewChocolateySource creates a source for a Chocolatey community package id
+func NewChocolateySource(packageID string) *NuGetSource {
+	query := url.QueryEscape("'" + packageID + "'")
+	return &NuGetSource{
+		FeedURL: fmt.Sprintf("%s/FindPackagesById()?id=%s", chocolateyFeed, query),
+	}
+}
+
+// Name returns a short description of the source
+func (s *NuGetSource) Name() string {
+	return "nuget:" + s.FeedURL
+}
+
+// Discover reads the OData feed and returns one download per package version
+func (s *NuGetSource) Discover(ctx context.Context, client *http.Client) ([]types.DownloadRequest, error) {
+	body, err := fetch(ctx, client, s.FeedURL, map[string]string{"Accept": "application/atom+xml"})
+	if err != nil {
+		return nil, err
+	}
+
+	var feed nugetFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse NuGet feed: %w", err)
+	}
+
+	var requests []types.DownloadRequest
+	for _, entry := range feed.Entries {
+		if entry.Content.Src == "" {
+			continue
+		}
+
+		props := entry.Properties
+		id := firstNonEmpty(props.ID, entry.Title)
+
+		signature := ""
+		if props.PackageHash != "" {
+			signature = strings.ToLower(firstNonEmpty(props.PackageHashAlgorithm, "hash")) + ":" + props.PackageHash
+		}
+
+		requests = append(requests, types.DownloadRequest{
+			URL:      entry.Content.Src,
+			FileName: fmt.Sprintf("%s.%s.nupkg", id, props.Version),
+			Release: &types.ReleaseInfo{
+				Source:      "nuget",
+				FeedURL:     s.FeedURL,
+				Title:       firstNonEmpty(props.Title, id),
+				Version:     props.Version,
+				ReleaseDate: parseFeedTime(firstNonEmpty(props.Published, entry.Updated)),
+				Notes:       strings.TrimSpace(props.ReleaseNotes),
+				NotesURL:    props.ProjectURL,
+				Signature:   signature,
+				Length:      props.PackageSize,
+				Prerelease:  props.IsPrerelease,
+			},
+		})
+	}
+
+	return requests, nil
+}
@@ -0,0 +1,298 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+	"github.com/deploymenttheory/go-app-index/internal/urlutil"
+)
+
+// Source discovers installer downloads from a structured vendor feed
+type Source interface {
+	// Name returns a short description of the source for logging
+	Name() string
+
+	// Discover fetches the feed and returns the downloads it advertises
+	Discover(ctx context.Context, client *http.Client) ([]types.DownloadRequest, error)
+}
+
+// Stats holds source runner statistics
+type Stats struct {
+	SourcesRun       int
+	SourcesFailed    int
+	URLsQueued       int
+	URLsDeduplicated int // Downloads skipped because the same normalized URL was already queued
+	StartTime        time.Time
+	EndTime          time.Time
+}
+
+// Parse creates a Source from a "kind:target" specification, e.g.
+// "github:hashicorp/terraform" or "appcast:https://example.com/appcast.xml".
+// File extensions are used to select installer assets where the feed lists
+// more than just installers.
+func Parse(spec string, fileExtensions []string) (Source, error) {
+	kind, target, ok := strings.Cut(spec, ":")
+	if !ok || target == "" {
+		return nil, fmt.Errorf("invalid source %q, expected kind:target", spec)
+	}
+
+	switch strings.ToLower(kind) {
+	case "github":
+		return NewGitHubSource(target, fileExtensions)
+	case "appcast", "sparkle":
+		return &AppcastSource{FeedURL: target}, nil
+	case "rss", "atom", "feed":
+		return &FeedSource{FeedURL: target, FileExtensions: fileExtensions}, nil
+	case "nuget":
+		return &NuGetSource{FeedURL: target}, nil
+	case "chocolatey":
+		return NewChocolateySource(target), nil
+	default:
+		return nil, fmt.Errorf("unknown source kind %q", kind)
+	}
+}
+
+// Runner fetches all configured sources and queues their downloads
+type Runner struct {
+	sources       []Source
+	client        *http.Client
+	downloadQueue chan<- types.DownloadRequest
+	queued        *urlutil.Set
+
+	stats      Stats
+	statsMutex sync.RWMutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Option configures optional Runner behaviour
+type Option func(*Runner)
+
+// WithQueuedURLs shares the record of queued installer URLs with the other
+// producers feeding the same download queue, so an installer the crawler
+// also finds is only downloaded once
+func WithQueuedURLs(queued *urlutil.Set) Option {
+	return func(r *Runner) {
+		r.queued = queued
+	}
+}
+
+// NewRunner creates a new Runner
+func NewRunner(sources []Source, requestTimeout int, downloadQueue chan<- types.DownloadRequest, opts ...Option) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Runner{
+		sources:       sources,
+		client:        &http.Client{Timeout: time.Duration(requestTimeout) * time.Second},
+		downloadQueue: downloadQueue,
+		ctx:           ctx,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.queued == nil {
+		r.queued = urlutil.NewSet()
+	}
+	return r
+}
+
+// Run fetches every source in turn and queues the discovered downloads
+func (r *Runner) Run() error {
+	defer close(r.done)
+
+	r.statsMutex.Lock()
+	r.stats.StartTime = time.Now()
+	r.statsMutex.Unlock()
+
+	defer func() {
+		r.statsMutex.Lock()
+		r.stats.EndTime = time.Now()
+		r.statsMutex.Unlock()
+	}()
+
+	for _, src := range r.sources {
+		if r.ctx.Err() != nil {
+			return r.ctx.Err()
+		}
+
+		logger.Infof("Fetching source %s", src.Name())
+		requests, err := src.Discover(r.ctx, r.client)
+		if err != nil {
+			logger.Warningf("Source %s failed: %v", src.Name(), err)
+			r.incrementFailed()
+			continue
+		}
+		r.incrementRun()
+
+		logger.Infof("Source %s advertised %d downloads", src.Name(), len(requests))
+		for _, req := range requests {
+			if !r.queued.Add(req.URL) {
+				logger.Debugf("Already queued: %s, skipping", req.URL)
+				r.incrementDeduplicated()
+				continue
+			}
+			select {
+			case r.downloadQueue <- req:
+				r.incrementQueued()
+				logger.Debugf("Sent to download queue: %s", req.URL)
+			case <-r.ctx.Done():
+				return r.ctx.Err()
+			}
+		}
+	}
+
+	return nil
+}
+
+// Done returns a channel that's closed when all sources have been fetched
+func (r *Runner) Done() <-chan struct{} {
+	return r.done
+}
+
+// Stop signals the runner to stop
+func (r *Runner) Stop() {
+	r.cancel()
+}
+
+// Stats returns the current runner statistics
+func (r *Runner) Stats() Stats {
+	r.statsMutex.RLock()
+	defer r.statsMutex.RUnlock()
+	return r.stats
+}
+
+// Duration returns how long the runner has been running
+func (r *Runner) Duration() time.Duration {
+	r.statsMutex.RLock()
+	defer r.statsMutex.RUnlock()
+
+	if r.stats.StartTime.IsZero() {
+		return 0
+	}
+
+	if r.stats.EndTime.IsZero() {
+		return time.Since(r.stats.StartTime)
+	}
+
+	return r.stats.EndTime.Sub(r.stats.StartTime)
+}
+
+func (r *Runner) incrementRun() {
+	r.statsMutex.Lock()
+	r.stats.SourcesRun++
+	r.statsMutex.Unlock()
+}
+
+func (r *Runner) incrementFailed() {
+	r.statsMutex.Lock()
+	r.stats.SourcesFailed++
+	r.statsMutex.Unlock()
+}
+
+func (r *Runner) incrementDeduplicated() {
+	r.statsMutex.Lock()
+	r.stats.URLsDeduplicated++
+	r.statsMutex.Unlock()
+}
+
+func (r *Runner) incrementQueued() {
+	r.statsMutex.Lock()
+	r.stats.URLsQueued++
+	r.statsMutex.Unlock()
+}
+
+// fetch performs a GET request and returns the response body
+func fetch(ctx context.Context, client *http.Client, feedURL string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// hasExtension checks if a file name or URL path ends with one of the extensions
+func hasExtension(name string, extensions []string) bool {
+	if u, err := url.Parse(name); err == nil && u.Path != "" {
+		name = u.Path
+	}
+	lower := strings.ToLower(name)
+	for _, ext := range extensions {
+		if strings.HasSuffix(lower, strings.ToLower(ext)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileNameFromURL returns the unescaped last path element of a URL
+func fileNameFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return path.Base(u.Path)
+}
+
+var titleVersionPattern = regexp.MustCompile(`\b[vV]?([0-9]+(?:\.[0-9]+)+(?:[-+][0-9A-Za-z.]+)?)\b`)
+
+// versionFromTitle extracts a version number from a release title
+func versionFromTitle(title string) string {
+	if m := titleVersionPattern.FindStringSubmatch(title); len(m) > 1 {
+		return m[1]
+	}
+	return ""
+}
+
+// parseFeedTime parses the date formats used by RSS, Atom and OData feeds
+func parseFeedTime(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	layouts := []string{
+		time.RFC3339,
+		time.RFC1123Z,
+		time.RFC1123,
+		"Mon, 2 Jan 2006 15:04:05 -0700",
+		"Mon, 2 Jan 2006 15:04:05 MST",
+		"2 Jan 2006 15:04:05 -0700",
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02T15:04:05",
+		"2006-01-02",
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
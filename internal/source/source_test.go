@@ -0,0 +1,350 @@
+package source
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+	"github.com/deploymenttheory/go-app-index/internal/urlutil"
+)
+
+// serveFixture serves a testdata file at every path
+func serveFixture(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func discover(t *testing.T, src Source) []types.DownloadRequest {
+	t.Helper()
+	requests, err := src.Discover(context.Background(), http.DefaultClient)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	return requests
+}
+
+// want describes the fields of a discovered download a test checks
+type want struct {
+	url, fileName, version, build, minOS, signature, notesURL string
+	length                                                    int64
+	prerelease                                                bool
+}
+
+func checkRequests(t *testing.T, got []types.DownloadRequest, wants []want) {
+	t.Helper()
+	if len(got) != len(wants) {
+		for _, req := range got {
+			t.Logf("got %s", req.URL)
+		}
+		t.Fatalf("got %d requests, want %d", len(got), len(wants))
+	}
+	for i, w := range wants {
+		req := got[i]
+		if req.Release == nil {
+			t.Fatalf("request %d has no release", i)
+		}
+		rel := req.Release
+		checks := []struct {
+			field     string
+			got, want interface{}
+		}{
+			{"URL", req.URL, w.url},
+			{"FileName", req.FileName, w.fileName},
+			{"Version", rel.Version, w.version},
+			{"Build", rel.Build, w.build},
+			{"MinOSVersion", rel.MinOSVersion, w.minOS},
+			{"Signature", rel.Signature, w.signature},
+			{"NotesURL", rel.NotesURL, w.notesURL},
+			{"Length", rel.Length, w.length},
+			{"Prerelease", rel.Prerelease, w.prerelease},
+		}
+		for _, c := range checks {
+			if c.got != c.want {
+				t.Errorf("request %d %s = %v, want %v", i, c.field, c.got, c.want)
+			}
+		}
+	}
+}
+
+func TestAppcastSource(t *testing.T) {
+	server := serveFixture(t, "appcast.xml")
+	got := discover(t, &AppcastSource{FeedURL: server.URL})
+	checkRequests(t, got, []want{
+		{
+			url: "https://example.com/dl/Example-2.1.dmg", fileName: "Example-2.1.dmg",
+			version: "2.1", build: "2100", minOS: "12.0", signature: "ed25519sig",
+			notesURL: "https://example.com/notes/2.1.html", length: 1048576,
+		},
+		{
+			url: "https://example.com/dl/Example-2.0.zip", fileName: "Example-2.0.zip",
+			version: "2.0", build: "2000", signature: "dsa:dsasig",
+			notesURL: "https://example.com/releases/2.0", length: 2048,
+		},
+	})
+	if got[0].Release.Source != "appcast" || got[0].Release.FeedURL != server.URL {
+		t.Errorf("release source = %q %q", got[0].Release.Source, got[0].Release.FeedURL)
+	}
+	if date := got[1].Release.ReleaseDate; date == nil || !date.Equal(time.Date(2024, 5, 6, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("ReleaseDate = %v", date)
+	}
+}
+
+func TestFeedSourceRSS(t *testing.T) {
+	server := serveFixture(t, "rss.xml")
+	got := discover(t, &FeedSource{FeedURL: server.URL, FileExtensions: []string{".exe", ".msi"}})
+	checkRequests(t, got, []want{
+		{url: "https://example.com/files/tool-3.4.1-x64.msi", fileName: "tool-3.4.1-x64.msi", version: "3.4.1", length: 4096},
+		{url: "https://example.com/files/tool-3.4.0.exe", fileName: "tool-3.4.0.exe", version: "3.4.0"},
+	})
+	if notes := got[0].Release.Notes; notes != "Bug fixes." {
+		t.Errorf("Notes = %q", notes)
+	}
+}
+
+func TestFeedSourceAtom(t *testing.T) {
+	server := serveFixture(t, "atom.xml")
+	got := discover(t, &FeedSource{FeedURL: server.URL, FileExtensions: []string{".dmg", ".pkg"}})
+	checkRequests(t, got, []want{
+		{url: "https://example.com/files/app-1.2.3.pkg", fileName: "app-1.2.3.pkg", version: "1.2.3", notesURL: "https://example.com/releases/1.2.3", length: 512},
+		{url: "https://example.com/files/app-1.2.3-arm64.dmg", fileName: "app-1.2.3-arm64.dmg", version: "1.2.3", notesURL: "https://example.com/releases/1.2.3"},
+	})
+	// Published takes precedence over updated
+	if date := got[0].Release.ReleaseDate; date == nil || date.Day() != 31 {
+		t.Errorf("ReleaseDate = %v", date)
+	}
+}
+
+func TestNuGetSource(t *testing.T) {
+	server := serveFixture(t, "nuget.xml")
+	got := discover(t, &NuGetSource{FeedURL: server.URL})
+	checkRequests(t, got, []want{
+		{
+			url: "https://community.chocolatey.org/api/v2/package/git/2.44.0", fileName: "git.2.44.0.nupkg",
+			version: "2.44.0", signature: "sha512:abc==", notesURL: "https://git-scm.com/", length: 8192,
+		},
+	})
+	if got[0].Release.Title != "Git" || got[0].Release.Notes != "See the changelog." {
+		t.Errorf("release = %+v", got[0].Release)
+	}
+}
+
+func TestGitHubSource(t *testing.T) {
+	server := serveFixture(t, "github.json")
+	src, err := NewGitHubSource(server.URL, []string{".msi", ".exe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRequests(t, discover(t, src), []want{
+		{
+			url: "https://github.com/acme/tool/releases/download/v1.5.0/tool-1.5.0-x64.msi", fileName: "tool-1.5.0-x64.msi",
+			version: "1.5.0", notesURL: "https://github.com/acme/tool/releases/tag/v1.5.0", length: 100,
+		},
+		{
+			url: "https://github.com/acme/tool/releases/download/b/tool-setup.exe", fileName: "tool-setup.exe",
+			version: "1.4.0", length: 5,
+		},
+	})
+
+	src.IncludePrereleases = true
+	if got := discover(t, src); len(got) != 3 || !got[1].Release.Prerelease || got[1].Release.Version != "1.6.0-rc1" {
+		t.Errorf("with prereleases got %d requests", len(got))
+	}
+}
+
+func TestGitHubSendsToken(t *testing.T) {
+	tests := []struct {
+		url        string
+		tokenHosts []string
+		want       bool
+	}{
+		{"https://api.github.com/repos/acme/tool/releases", nil, true},
+		{"https://API.GitHub.com:443/repos/acme/tool/releases", nil, true},
+		{"http://api.github.com/repos/acme/tool/releases", nil, false},
+		{"https://ghe.example.com/api/v3/repos/acme/tool/releases", nil, false},
+		{"https://ghe.example.com/api/v3/repos/acme/tool/releases", []string{"ghe.example.com"}, true},
+		{"http://ghe.example.com/api/v3/repos/acme/tool/releases", []string{"ghe.example.com"}, false},
+		{"https://evil.example.com/repos/acme/tool/releases", []string{"ghe.example.com"}, false},
+		{"https://api.github.com.evil.example.com/repos/acme/tool/releases", nil, false},
+	}
+	for _, tt := range tests {
+		src := &GitHubSource{TokenHosts: tt.tokenHosts}
+		if got := src.sendsToken(tt.url); got != tt.want {
+			t.Errorf("sendsToken(%q) with hosts %v = %v, want %v", tt.url, tt.tokenHosts, got, tt.want)
+		}
+	}
+}
+
+func TestGitHubTokenHosts(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "secret")
+	var auth string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	src, err := NewGitHubSource(server.URL, []string{".msi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Discover(context.Background(), server.Client()); err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if auth != "" {
+		t.Errorf("unlisted host got Authorization %q", auth)
+	}
+
+	src.TokenHosts = []string{"127.0.0.1"}
+	if _, err := src.Discover(context.Background(), server.Client()); err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("listed host got Authorization %q, want %q", auth, "Bearer secret")
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		name    string
+		wantErr bool
+	}{
+		{"github:acme/tool", "github:acme/tool", false},
+		{"github:https://ghe.example.com/api/v3/repos/a/b/releases", "github:https://ghe.example.com/api/v3/repos/a/b/releases", false},
+		{"sparkle:https://example.com/appcast.xml", "appcast:https://example.com/appcast.xml", false},
+		{"atom:https://example.com/feed", "feed:https://example.com/feed", false},
+		{"nuget:https://nuget.example.com/Packages()", "nuget:https://nuget.example.com/Packages()", false},
+		{"chocolatey:git", "nuget:https://community.chocolatey.org/api/v2/FindPackagesById()?id=%27git%27", false},
+		{"github:acme", "", true},
+		{"github:", "", true},
+		{"ftp:example.com", "", true},
+		{"nokind", "", true},
+	}
+	for _, tt := range tests {
+		src, err := Parse(tt.spec, nil)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if err == nil && src.Name() != tt.name {
+			t.Errorf("Parse(%q).Name() = %q, want %q", tt.spec, src.Name(), tt.name)
+		}
+	}
+}
+
+func TestVersionFromTitle(t *testing.T) {
+	tests := map[string]string{
+		"Version 2.1":            "2.1",
+		"Tool v3.4.1 released":   "3.4.1",
+		"Release 1.0.0-beta.2":   "1.0.0-beta.2",
+		"Build 10.2+20240101 ok": "10.2+20240101",
+		"Announcement":           "",
+		"Update 7":               "",
+	}
+	for title, want := range tests {
+		if got := versionFromTitle(title); got != want {
+			t.Errorf("versionFromTitle(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestParseFeedTime(t *testing.T) {
+	want := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  *time.Time
+	}{
+		{"2024-03-01T12:00:00Z", &want},
+		{"2024-03-01T14:00:00+02:00", &want},
+		{"Fri, 01 Mar 2024 12:00:00 +0000", &want},
+		{"Fri, 1 Mar 2024 12:00:00 +0000", &want},
+		{"Fri, 01 Mar 2024 12:00:00 GMT", &want},
+		{"1 Mar 2024 12:00:00 +0000", &want},
+		{"2024-03-01T12:00:00", &want},
+		{"2024-03-01T12:00:00.000", &want},
+		{"", nil},
+		{"yesterday", nil},
+	}
+	for _, tt := range tests {
+		got := parseFeedTime(tt.value)
+		switch {
+		case tt.want == nil && got != nil:
+			t.Errorf("parseFeedTime(%q) = %v, want nil", tt.value, got)
+		case tt.want != nil && (got == nil || !got.Equal(*tt.want)):
+			t.Errorf("parseFeedTime(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestHasExtension(t *testing.T) {
+	extensions := []string{".exe", ".MSI"}
+	tests := map[string]bool{
+		"setup.exe":                           true,
+		"SETUP.EXE":                           true,
+		"tool.msi":                            true,
+		"https://example.com/a/setup.exe?x=1": true,
+		"https://example.com/setup.exe.html":  false,
+		"readme.txt":                          false,
+	}
+	for name, want := range tests {
+		if got := hasExtension(name, extensions); got != want {
+			t.Errorf("hasExtension(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+// staticSource returns a fixed list of downloads
+type staticSource []types.DownloadRequest
+
+func (s staticSource) Name() string { return "static" }
+
+func (s staticSource) Discover(ctx context.Context, client *http.Client) ([]types.DownloadRequest, error) {
+	return s, nil
+}
+
+func TestRunnerSkipsQueuedURLs(t *testing.T) {
+	queued := urlutil.NewSet()
+	// Already sent to the downloader by another producer
+	queued.Add("https://example.com/tool.exe")
+
+	downloads := make(chan types.DownloadRequest, 10)
+	runner := NewRunner([]Source{
+		staticSource{
+			{URL: "https://EXAMPLE.com/tool.exe?utm_source=rss"},
+			{URL: "https://example.com/tool.msi"},
+		},
+		staticSource{
+			{URL: "https://example.com:443/tool.msi#download"},
+			{URL: "https://example.com/tool.pkg"},
+		},
+	}, 5, downloads, WithQueuedURLs(queued))
+	if err := runner.Run(); err != nil {
+		t.Fatal(err)
+	}
+	close(downloads)
+
+	var got []string
+	for req := range downloads {
+		got = append(got, req.URL)
+	}
+	want := []string{"https://example.com/tool.msi", "https://example.com/tool.pkg"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("queued %v, want %v", got, want)
+	}
+	if stats := runner.Stats(); stats.URLsQueued != 2 || stats.URLsDeduplicated != 2 || stats.SourcesRun != 2 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
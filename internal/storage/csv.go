@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// csvColumns are the columns written by CSVStorage. Extended metadata,
+// release details and vulnerabilities are not kept; use a JSON or SQL
+// backend for those.
+var csvColumns = []string{
+	"sha3_hash",
+	"filename",
+	"source_url",
+	"website_domain",
+	"discovered_at",
+	"file_size_bytes",
+	"platform",
+	"file_type",
+	"version",
+	"publisher",
+	"is_signed",
+	"is_installer",
+	"detection_score",
+	"normalized_version",
+	"version_source",
+	"version_confidence",
+	"architecture",
+	"tags", // Separated by semicolons
+}
+
+// CSVStorage implements the Storage interface as a CSV file with one row
+// per processed file, for loading into spreadsheets
+type CSVStorage struct {
+	filePath  string
+	file      *os.File
+	writer    *csv.Writer
+	header    []string // Columns of the file being appended to
+	files     []types.ProcessedFile
+	hashIndex map[string]bool
+	urlIndex  map[string]bool
+	startTime time.Time
+	mutex     sync.RWMutex
+}
+
+func init() {
+	Register("csv", func(uri string) (Storage, error) { return NewCSV(location(uri)) })
+}
+
+// NewCSV opens (creating if needed) the CSV file at filePath
+func NewCSV(filePath string) (*CSVStorage, error) {
+	s := &CSVStorage{
+		filePath:  filePath,
+		hashIndex: make(map[string]bool),
+		urlIndex:  make(map[string]bool),
+		startTime: time.Now(),
+	}
+
+	if err := s.loadExistingData(); err != nil {
+		return nil, fmt.Errorf("failed to load existing data: %w", err)
+	}
+
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	s.file = file
+	s.writer = csv.NewWriter(file)
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.Size() == 0 {
+		s.header = csvColumns
+		s.writer.Write(csvColumns)
+		s.writer.Flush()
+		if err := s.writer.Error(); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write header: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// loadExistingData reads the rows already in the file
+func (s *CSVStorage) loadExistingData() error {
+	file, err := os.Open(s.filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.header = header
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		installer := parseCSVRecord(record, columns)
+		s.files = append(s.files, installer)
+		s.hashIndex[installer.SHA3Hash] = true
+		s.urlIndex[installer.SourceURL] = true
+	}
+
+	logger.Infof("Loaded %d existing installers from %s", len(s.files), s.filePath)
+	return nil
+}
+
+// parseCSVRecord reads a row using the header's column positions, so files
+// written with a different column order still load
+func parseCSVRecord(record []string, columns map[string]int) types.ProcessedFile {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	size, _ := strconv.ParseInt(field("file_size_bytes"), 10, 64)
+	score, _ := strconv.ParseFloat(field("detection_score"), 64)
+	signed, _ := strconv.ParseBool(field("is_signed"))
+	installer, _ := strconv.ParseBool(field("is_installer"))
+	versionConfidence, _ := strconv.ParseFloat(field("version_confidence"), 64)
+
+	return types.ProcessedFile{
+		SHA3Hash:       field("sha3_hash"),
+		Filename:       field("filename"),
+		SourceURL:      field("source_url"),
+		WebsiteDomain:  field("website_domain"),
+		DiscoveredAt:   parseTime(field("discovered_at")),
+		FileSizeBytes:  size,
+		Platform:       field("platform"),
+		FileType:       field("file_type"),
+		Version:        field("version"),
+		Publisher:      field("publisher"),
+		IsSigned:       signed,
+		IsInstaller:    installer,
+		DetectionScore: score,
+
+		NormalizedVersion: field("normalized_version"),
+		VersionSource:     field("version_source"),
+		VersionConfidence: versionConfidence,
+		Architecture:      field("architecture"),
+		Tags:              splitTags(field("tags")),
+	}
+}
+
+// splitTags reads the tags column
+func splitTags(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ";")
+}
+
+// csvRecord formats a file as a row with the given columns. Files written
+// by older versions keep their header, so columns they lack are dropped.
+func csvRecord(file types.ProcessedFile, header []string) []string {
+	values := map[string]string{
+		"sha3_hash":          file.SHA3Hash,
+		"filename":           file.Filename,
+		"source_url":         file.SourceURL,
+		"website_domain":     file.WebsiteDomain,
+		"discovered_at":      formatTime(file.DiscoveredAt),
+		"file_size_bytes":    strconv.FormatInt(file.FileSizeBytes, 10),
+		"platform":           file.Platform,
+		"file_type":          file.FileType,
+		"version":            file.Version,
+		"publisher":          file.Publisher,
+		"is_signed":          strconv.FormatBool(file.IsSigned),
+		"is_installer":       strconv.FormatBool(file.IsInstaller),
+		"detection_score":    strconv.FormatFloat(file.DetectionScore, 'f', -1, 64),
+		"normalized_version": file.NormalizedVersion,
+		"version_source":     file.VersionSource,
+		"version_confidence": strconv.FormatFloat(file.VersionConfidence, 'f', -1, 64),
+		"architecture":       file.Architecture,
+		"tags":               strings.Join(file.Tags, ";"),
+	}
+
+	record := make([]string, len(header))
+	for i, column := range header {
+		record[i] = values[column]
+	}
+	return record
+}
+
+// Store appends a processed file's metadata as a new row
+func (s *CSVStorage) Store(file types.ProcessedFile) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Check if we already have this file by hash or URL
+	if s.hashIndex[file.SHA3Hash] || s.urlIndex[file.SourceURL] {
+		return nil
+	}
+
+	s.writer.Write(csvRecord(file, s.header))
+	s.writer.Flush()
+	if err := s.writer.Error(); err != nil {
+		return fmt.Errorf("failed to append to %s: %w", s.filePath, err)
+	}
+
+	s.files = append(s.files, file)
+	s.hashIndex[file.SHA3Hash] = true
+	s.urlIndex[file.SourceURL] = true
+	return nil
+}
+
+// Close finalizes the storage
+func (s *CSVStorage) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats := computeStats(s.files)
+	logger.Infof("Closing storage with %d files stored", stats.FilesStored)
+	logger.Infof("Files by platform: %v", stats.FilesByPlatform)
+	logger.Infof("Files by type: %v", stats.FilesByType)
+	logger.Infof("Files by architecture: %v", stats.FilesByArchitecture)
+
+	return s.file.Close()
+}
+
+// Stats returns storage statistics
+func (s *CSVStorage) Stats() types.StorageStats {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	stats := computeStats(s.files)
+	stats.StartTime = s.startTime
+	return stats
+}
+
+// Get returns the file with the given SHA3 hash
+func (s *CSVStorage) Get(hash string) (types.ProcessedFile, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	file, ok := findFile(s.files, func(f types.ProcessedFile) bool { return f.SHA3Hash == hash })
+	return file, ok, nil
+}
+
+// FindByURL returns the file downloaded from the given URL
+func (s *CSVStorage) FindByURL(url string) (types.ProcessedFile, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	file, ok := findFile(s.files, func(f types.ProcessedFile) bool { return f.SourceURL == url })
+	return file, ok, nil
+}
+
+// Iterate calls fn for every stored file matching filter, in file order
+func (s *CSVStorage) Iterate(filter Filter, fn func(types.ProcessedFile) error) error {
+	s.mutex.RLock()
+	files := make([]types.ProcessedFile, len(s.files))
+	copy(files, s.files)
+	s.mutex.RUnlock()
+
+	return iterateFiles(files, filter, fn)
+}
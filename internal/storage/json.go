@@ -1,11 +1,13 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -15,42 +17,132 @@ import (
 
 // JSONOutput represents the JSON output structure
 type JSONOutput struct {
-	LastUpdated time.Time             `json:"last_updated"`
-	Stats       types.StorageStats    `json:"stats"`
-	Installers  []types.ProcessedFile `json:"installers"`
+	SchemaVersion int                   `json:"schema_version"`
+	LastUpdated   time.Time             `json:"last_updated"`
+	Stats         types.StorageStats    `json:"stats"`
+	Runs          []types.RunRecord     `json:"runs"`
+	Installers    []types.ProcessedFile `json:"installers"`
 }
 
-// JSONStorage implements the Storage interface using a JSON file
+// defaultJSONBackups is the number of .bak.N copies JSONStorage keeps
+const defaultJSONBackups = 3
+
+// JSONStorage implements the Storage interface using a JSON file. The file
+// is locked while open, replaced atomically on every write, and the
+// previous version is rotated into .bak.N backups once per session.
 type JSONStorage struct {
-	filePath  string
-	data      JSONOutput
-	hashIndex map[string]bool
-	urlIndex  map[string]bool
-	mutex     sync.RWMutex
+	filePath    string
+	data        JSONOutput
+	hashIndex   map[string]bool
+	urlIndex    map[string]bool
+	mutex       sync.RWMutex
+	backups     int
+	recovery    bool
+	lockTimeout time.Duration
+	lock        *fileLock
+	rotated     bool // Backups already rotated this session
+	dirty       bool // Changed since loading
+	closed      bool
+	startTime   time.Time
+}
+
+// JSONOption configures a JSONStorage
+type JSONOption func(*JSONStorage)
+
+// WithBackups sets how many .bak.N copies of the previous file to keep; 0 disables backups
+func WithBackups(n int) JSONOption {
+	return func(s *JSONStorage) {
+		s.backups = n
+	}
+}
+
+// WithRecovery salvages installers from a damaged file, and from its newest
+// readable backup, instead of refusing to open it
+func WithRecovery() JSONOption {
+	return func(s *JSONStorage) {
+		s.recovery = true
+	}
+}
+
+// WithLockTimeout waits up to d for another process holding the file's lock
+func WithLockTimeout(d time.Duration) JSONOption {
+	return func(s *JSONStorage) {
+		s.lockTimeout = d
+	}
+}
+
+func init() {
+	Register("json", openJSON)
+}
+
+// openJSON opens a json:// URI. The query may set backups=N, recover=true
+// and lock_timeout=<duration>.
+func openJSON(uri string) (Storage, error) {
+	var opts []JSONOption
+	query := uriOptions(uri)
+	if value := query.Get("backups"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid backups option %q", value)
+		}
+		opts = append(opts, WithBackups(n))
+	}
+	if value := query.Get("recover"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recover option %q", value)
+		}
+		if enabled {
+			opts = append(opts, WithRecovery())
+		}
+	}
+	if value := query.Get("lock_timeout"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lock_timeout option %q", value)
+		}
+		opts = append(opts, WithLockTimeout(timeout))
+	}
+
+	return New(location(uri), opts...)
 }
 
 // New creates a new JSONStorage
-func New(filePath string) (*JSONStorage, error) {
+func New(filePath string, opts ...JSONOption) (*JSONStorage, error) {
 	storage := &JSONStorage{
 		filePath:  filePath,
 		hashIndex: make(map[string]bool),
 		urlIndex:  make(map[string]bool),
+		backups:   defaultJSONBackups,
+		startTime: time.Now(),
 		data: JSONOutput{
-			LastUpdated: time.Now(),
-			Stats: types.StorageStats{
-				LastUpdatedAt:   time.Now(),
-				FilesByPlatform: make(map[string]int),
-				FilesByType:     make(map[string]int),
-			},
-			Installers: make([]types.ProcessedFile, 0),
+			SchemaVersion: CurrentSchemaVersion,
+			LastUpdated:   time.Now(),
+			Runs:          make([]types.RunRecord, 0),
+			Installers:    make([]types.ProcessedFile, 0),
 		},
 	}
+	storage.refreshStats()
+	for _, opt := range opts {
+		opt(storage)
+	}
+
+	lock, err := acquireLock(filePath, storage.lockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	storage.lock = lock
 
 	// Try to load existing data
 	if _, err := os.Stat(filePath); err == nil {
 		err = storage.loadExistingData()
+		if err != nil && storage.recovery {
+			logger.Warningf("Failed to load %s (%v); recovering what can be salvaged", filePath, err)
+			err = storage.recoverData()
+		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to load existing data: %w", err)
+			lock.Release()
+			return nil, fmt.Errorf("failed to load existing data (open with ?recover=true to salvage it): %w", err)
 		}
 	}
 
@@ -62,6 +154,12 @@ func (s *JSONStorage) Store(file types.ProcessedFile) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	// The lock is released at Close, so writing afterwards could clobber
+	// another process's changes
+	if s.closed {
+		return fmt.Errorf("storage %s is closed", s.filePath)
+	}
+
 	// Check if we already have this file by hash or URL
 	if s.hashIndex[file.SHA3Hash] || s.urlIndex[file.SourceURL] {
 		return nil
@@ -72,70 +170,60 @@ func (s *JSONStorage) Store(file types.ProcessedFile) error {
 	s.hashIndex[file.SHA3Hash] = true
 	s.urlIndex[file.SourceURL] = true
 
-	// Update basic stats
-	s.data.Stats.FilesStored++
-	s.data.Stats.UniqueHashes = len(s.hashIndex)
 	s.data.LastUpdated = time.Now()
-	s.data.Stats.LastUpdatedAt = time.Now()
-
-	// Update enhanced stats
-	s.updateEnhancedStats(file)
+	s.dirty = true
 
 	// Write to file
 	return s.saveToFile()
 }
 
-// updateEnhancedStats updates statistics related to enhanced metadata
-func (s *JSONStorage) updateEnhancedStats(file types.ProcessedFile) {
-	// Update platform stats
-	if file.Platform != "" {
-		if s.data.Stats.FilesByPlatform == nil {
-			s.data.Stats.FilesByPlatform = make(map[string]int)
-		}
-		s.data.Stats.FilesByPlatform[file.Platform]++
-	}
-
-	// Update file type stats
-	if file.FileType != "" {
-		if s.data.Stats.FilesByType == nil {
-			s.data.Stats.FilesByType = make(map[string]int)
-		}
-		s.data.Stats.FilesByType[file.FileType]++
-	}
+// refreshStats derives the stats from the installer list, covering this
+// session up to now
+func (s *JSONStorage) refreshStats() {
+	stats := computeStats(s.data.Installers)
+	stats.StartTime = s.startTime
+	stats.EndTime = stats.LastUpdatedAt
+	s.data.Stats = stats
+}
 
-	// Update signed installer count
-	if file.IsSigned {
-		s.data.Stats.SignedInstallerCount++
-	}
+// RecordRun adds a finished run to the file's run history
+func (s *JSONStorage) RecordRun(run types.RunRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	// Update versioned file count
-	if file.Version != "" {
-		s.data.Stats.VersionedFileCount++
+	if s.closed {
+		return fmt.Errorf("storage %s is closed", s.filePath)
 	}
-
-	// Update average detection score
-	totalScore := s.data.Stats.AvgDetectionScore * float64(s.data.Stats.FilesStored-1)
-	totalScore += file.DetectionScore
-	s.data.Stats.AvgDetectionScore = totalScore / float64(s.data.Stats.FilesStored)
+	s.data.Runs = append(s.data.Runs, run)
+	s.dirty = true
+	return nil
 }
 
-// Close finalizes the storage
+// Close finalizes the storage and releases its lock. The file is only
+// rewritten if something changed.
 func (s *JSONStorage) Close() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	// Sort the installers
-	s.sortInstallers()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	defer s.lock.Release()
+
+	if !s.dirty {
+		return nil
+	}
 
-	// Update the last updated timestamp
+	// Update the last updated timestamp and stats
 	s.data.LastUpdated = time.Now()
-	s.data.Stats.LastUpdatedAt = time.Now()
-	s.data.Stats.EndTime = time.Now()
+	s.refreshStats()
 
 	// Log summary of data
 	logger.Infof("Closing storage with %d files stored", s.data.Stats.FilesStored)
 	logger.Infof("Files by platform: %v", s.data.Stats.FilesByPlatform)
 	logger.Infof("Files by type: %v", s.data.Stats.FilesByType)
+	logger.Infof("Files by architecture: %v", s.data.Stats.FilesByArchitecture)
 	logger.Infof("Signed installer count: %d", s.data.Stats.SignedInstallerCount)
 	logger.Infof("Versioned file count: %d", s.data.Stats.VersionedFileCount)
 	logger.Infof("Average detection score: %.2f", s.data.Stats.AvgDetectionScore)
@@ -164,89 +252,232 @@ func (s *JSONStorage) loadExistingData() error {
 		return err
 	}
 
-	var output JSONOutput
-	if err := json.Unmarshal(data, &output); err != nil {
+	output, version, err := decodeJSONOutput(data)
+	if err != nil {
 		return err
 	}
-
-	// Initialize maps if not present (for backward compatibility)
-	if output.Stats.FilesByPlatform == nil {
-		output.Stats.FilesByPlatform = make(map[string]int)
-	}
-	if output.Stats.FilesByType == nil {
-		output.Stats.FilesByType = make(map[string]int)
+	if version != CurrentSchemaVersion {
+		// Written back in the current format with the next save
+		logger.Infof("Upgrading %s from schema version %d to %d", s.filePath, version, CurrentSchemaVersion)
+		output.SchemaVersion = CurrentSchemaVersion
 	}
 
+	s.useData(output)
+	logger.Infof("Loaded %d existing installers from %s", len(output.Installers), s.filePath)
+	return nil
+}
+
+// useData indexes loaded data and rebuilds its stats
+func (s *JSONStorage) useData(output JSONOutput) {
 	// Add existing installers to our indexes
 	for _, installer := range output.Installers {
 		s.hashIndex[installer.SHA3Hash] = true
 		s.urlIndex[installer.SourceURL] = true
+	}
+	if output.Runs == nil {
+		output.Runs = make([]types.RunRecord, 0)
+	}
 
-		// Rebuild platform and type stats
-		if installer.Platform != "" {
-			output.Stats.FilesByPlatform[installer.Platform]++
-		}
-		if installer.FileType != "" {
-			output.Stats.FilesByType[installer.FileType]++
+	s.data = output
+	s.refreshStats()
+}
+
+// recoverData salvages the installers that can still be read from a
+// damaged file and adds any others found in its newest readable backup.
+// The repaired data is written back on Close; the damaged file itself is
+// kept as the first backup.
+func (s *JSONStorage) recoverData() error {
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		return err
+	}
+
+	output := salvageJSONOutput(data)
+	logger.Infof("Salvaged %d installers from %s", len(output.Installers), s.filePath)
+
+	hashes := make(map[string]bool)
+	urls := make(map[string]bool)
+	for _, installer := range output.Installers {
+		hashes[installer.SHA3Hash] = true
+		urls[installer.SourceURL] = true
+	}
+
+	for n := 1; ; n++ {
+		data, err := os.ReadFile(backupPath(s.filePath, n))
+		if os.IsNotExist(err) {
+			break
 		}
-		if installer.IsSigned {
-			output.Stats.SignedInstallerCount++
+		if err != nil {
+			continue
 		}
-		if installer.Version != "" {
-			output.Stats.VersionedFileCount++
+		backup, _, err := decodeJSONOutput(data)
+		if err != nil {
+			continue
 		}
-	}
 
-	// Calculate average detection score if we have files and the score isn't already set
-	if output.Stats.FilesStored > 0 && output.Stats.AvgDetectionScore == 0 {
-		var totalScore float64
-		var countWithScore int
-		for _, installer := range output.Installers {
-			if installer.DetectionScore > 0 {
-				totalScore += installer.DetectionScore
-				countWithScore++
+		added := 0
+		for _, installer := range backup.Installers {
+			if hashes[installer.SHA3Hash] || urls[installer.SourceURL] {
+				continue
 			}
+			hashes[installer.SHA3Hash] = true
+			urls[installer.SourceURL] = true
+			output.Installers = append(output.Installers, installer)
+			added++
 		}
-		if countWithScore > 0 {
-			output.Stats.AvgDetectionScore = totalScore / float64(countWithScore)
-		}
+		logger.Infof("Recovered %d more installers from %s", added, backupPath(s.filePath, n))
+		break
 	}
 
-	s.data = output
-	s.data.Stats.LastUpdatedAt = time.Now()
-
-	logger.Infof("Loaded %d existing installers from %s", len(output.Installers), s.filePath)
+	if output.Installers == nil {
+		output.Installers = make([]types.ProcessedFile, 0)
+	}
+	output.SchemaVersion = CurrentSchemaVersion
+	s.useData(output)
+	s.dirty = true
 	return nil
 }
 
-// saveToFile saves the current data to the JSON file
+// salvageJSONOutput decodes as much of a possibly truncated JSON output
+// document as it can, keeping every installer read before the damage.
+// Stats are not salvaged; they are rebuilt from the installers.
+func salvageJSONOutput(data []byte) JSONOutput {
+	var output JSONOutput
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return output
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return output
+		}
+
+		switch token {
+		case "installers":
+			if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+				return output
+			}
+			for decoder.More() {
+				var installer types.ProcessedFile
+				if err := decoder.Decode(&installer); err != nil {
+					return output
+				}
+				output.Installers = append(output.Installers, installer)
+			}
+			if _, err := decoder.Token(); err != nil {
+				return output
+			}
+		case "last_updated":
+			if err := decoder.Decode(&output.LastUpdated); err != nil {
+				return output
+			}
+		case "runs":
+			if err := decoder.Decode(&output.Runs); err != nil {
+				return output
+			}
+		default:
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return output
+			}
+		}
+	}
+	return output
+}
+
+// saveToFile saves the current data to the JSON file, replacing it
+// atomically so a crash never leaves a partial file
 func (s *JSONStorage) saveToFile() error {
-	// Sort the installers
-	s.sortInstallers()
+	// Another process may own the file if the lock was lost
+	if err := s.lock.Check(); err != nil {
+		return fmt.Errorf("not writing %s: %w", s.filePath, err)
+	}
 
-	// Create the file
-	file, err := os.Create(s.filePath)
-	if err != nil {
-		return err
+	// Keep the file as it was before this session
+	if !s.rotated {
+		if err := rotateBackups(s.filePath, s.backups); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", s.filePath, err)
+		}
+		s.rotated = true
 	}
-	defer file.Close()
 
-	// Marshal to JSON
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
+	// Sort the installers and derive stats from them
+	s.sortInstallers()
+	s.refreshStats()
 
-	return encoder.Encode(s.data)
+	return writeFileAtomic(s.filePath, func(w io.Writer) error {
+		// Marshal to JSON
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(s.data)
+	})
 }
 
 // sortInstallers sorts the installers by domain and filename
 func (s *JSONStorage) sortInstallers() {
-	sort.Slice(s.data.Installers, func(i, j int) bool {
+	sortInstallers(s.data.Installers)
+}
+
+// sortInstallers sorts installers by domain and filename
+func sortInstallers(installers []types.ProcessedFile) {
+	sort.Slice(installers, func(i, j int) bool {
 		// Sort by domain first
-		if s.data.Installers[i].WebsiteDomain != s.data.Installers[j].WebsiteDomain {
-			return s.data.Installers[i].WebsiteDomain < s.data.Installers[j].WebsiteDomain
+		if installers[i].WebsiteDomain != installers[j].WebsiteDomain {
+			return installers[i].WebsiteDomain < installers[j].WebsiteDomain
 		}
 
 		// Then by filename
-		return s.data.Installers[i].Filename < s.data.Installers[j].Filename
+		return installers[i].Filename < installers[j].Filename
 	})
 }
+
+// Get returns the file with the given SHA3 hash
+func (s *JSONStorage) Get(hash string) (types.ProcessedFile, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	file, ok := findFile(s.data.Installers, func(f types.ProcessedFile) bool { return f.SHA3Hash == hash })
+	return file, ok, nil
+}
+
+// FindByURL returns the file downloaded from the given URL
+func (s *JSONStorage) FindByURL(url string) (types.ProcessedFile, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	file, ok := findFile(s.data.Installers, func(f types.ProcessedFile) bool { return f.SourceURL == url })
+	return file, ok, nil
+}
+
+// Iterate calls fn for every stored file matching filter
+func (s *JSONStorage) Iterate(filter Filter, fn func(types.ProcessedFile) error) error {
+	// Iterate over a snapshot so fn may call back into the storage
+	s.mutex.RLock()
+	installers := make([]types.ProcessedFile, len(s.data.Installers))
+	copy(installers, s.data.Installers)
+	s.mutex.RUnlock()
+
+	return iterateFiles(installers, filter, fn)
+}
+
+// ExportJSON writes installers and stats in the JSON output format
+func ExportJSON(w io.Writer, installers []types.ProcessedFile, stats types.StorageStats) error {
+	sortInstallers(installers)
+
+	output := JSONOutput{
+		SchemaVersion: CurrentSchemaVersion,
+		LastUpdated:   time.Now(),
+		Stats:         stats,
+		Runs:          make([]types.RunRecord, 0),
+		Installers:    installers,
+	}
+	if output.Installers == nil {
+		output.Installers = make([]types.ProcessedFile, 0)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
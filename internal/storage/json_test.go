@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+func newJSON(t *testing.T, path string, opts ...JSONOption) *JSONStorage {
+	t.Helper()
+	store, err := New(path, opts...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return store
+}
+
+func readJSONOutput(t *testing.T, path string) JSONOutput {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output JSONOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		t.Fatalf("%s is not valid JSON: %v", path, err)
+	}
+	return output
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installers.json")
+	store := newJSON(t, path)
+
+	want := []types.ProcessedFile{sampleFile(1), sampleFile(2)}
+	for _, file := range want {
+		if err := store.Store(file); err != nil {
+			t.Fatal(err)
+		}
+		// Every Store leaves a complete document behind
+		if output := readJSONOutput(t, path); output.SchemaVersion != CurrentSchemaVersion {
+			t.Errorf("schema_version = %d", output.SchemaVersion)
+		}
+	}
+	if err := store.RecordRun(types.RunRecord{StartURL: "https://example.com/"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	output := readJSONOutput(t, path)
+	if !reflect.DeepEqual(output.Installers, want) {
+		t.Errorf("installers =\n%+v\nwant\n%+v", output.Installers, want)
+	}
+	if len(output.Runs) != 1 || output.Stats.FilesStored != 2 || output.Stats.SignedInstallerCount != 1 {
+		t.Errorf("runs %d, stats %+v", len(output.Runs), output.Stats)
+	}
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".installers.json.tmp-*"))
+	if len(matches) != 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+
+	store = newJSON(t, path)
+	defer store.Close()
+	if file, ok, err := store.FindByURL(want[1].SourceURL); err != nil || !ok || !reflect.DeepEqual(file, want[1]) {
+		t.Errorf("FindByURL() = %+v, %v, %v", file, ok, err)
+	}
+}
+
+func TestJSONRejectsWritesAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installers.json")
+	store := newJSON(t, path)
+	if err := store.Store(sampleFile(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process now holds the file
+	other := newJSON(t, path)
+	defer other.Close()
+	if err := other.Store(sampleFile(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Store(sampleFile(3)); err == nil {
+		t.Error("Store() after Close succeeded")
+	}
+	if err := store.RecordRun(types.RunRecord{}); err == nil {
+		t.Error("RecordRun() after Close succeeded")
+	}
+	if err := store.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+	if output := readJSONOutput(t, path); len(output.Installers) != 2 {
+		t.Errorf("file has %d installers, want the other process's 2", len(output.Installers))
+	}
+	if _, err := os.Stat(path + ".lock"); err != nil {
+		t.Errorf("second Close() released the other process's lock: %v", err)
+	}
+}
+
+func TestJSONRotatesBackupsOncePerSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installers.json")
+	for session := 1; session <= 4; session++ {
+		store := newJSON(t, path, WithBackups(2))
+		for n := 0; n < 2; n++ {
+			if err := store.Store(sampleFile(session*10 + n)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := store.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// .bak.1 is the file as the last session found it, .bak.2 the one before
+	for n, want := range map[int]int{1: 6, 2: 4} {
+		if output := readJSONOutput(t, backupPath(path, n)); len(output.Installers) != want {
+			t.Errorf("%s has %d installers, want %d", backupPath(path, n), len(output.Installers), want)
+		}
+	}
+	if _, err := os.Stat(backupPath(path, 3)); !os.IsNotExist(err) {
+		t.Errorf("more backups than requested: %v", err)
+	}
+}
+
+func TestJSONRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installers.json")
+	store := newJSON(t, path)
+	for n := 1; n <= 3; n++ {
+		if err := store.Store(sampleFile(n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	// A backup with one more installer, and the file cut off mid-way
+	// through its third installer
+	store = newJSON(t, path)
+	store.Store(sampleFile(4))
+	store.Close()
+	data, _ := os.ReadFile(path)
+	cut := bytes.Index(data, []byte(`"filename": "tool-3.msi"`)) + 10
+	if err := os.WriteFile(path, data[:cut], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if store, err := New(path); err == nil {
+		store.Close()
+		t.Fatal("New() opened a damaged file without recovery")
+	}
+
+	store = newJSON(t, path, WithRecovery())
+	var names []string
+	store.Iterate(Filter{}, func(file types.ProcessedFile) error {
+		names = append(names, file.Filename)
+		return nil
+	})
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// The first two are salvaged and the third comes from the backup; the
+	// fourth was only ever in the damaged file
+	if want := []string{"tool-1.msi", "tool-2.msi", "tool-3.msi"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("recovered %v, want %v", names, want)
+	}
+	if output := readJSONOutput(t, path); len(output.Installers) != 3 {
+		t.Errorf("repaired file has %d installers, want 3", len(output.Installers))
+	}
+}
@@ -0,0 +1,422 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+const (
+	// jsonlSyncEvery is the number of appended lines between fsyncs
+	jsonlSyncEvery = 100
+	// jsonlSyncInterval bounds how long an appended line waits for an fsync
+	jsonlSyncInterval = time.Second
+	// jsonlMaxLine is the longest line the reader accepts
+	jsonlMaxLine = 16 * 1024 * 1024
+)
+
+// JSONLStorage implements the Storage interface as an append-only JSON
+// Lines file with one processed file per line. Only the hash and URL
+// indexes are kept in memory; reads stream from the file, and full
+// statistics are computed at Close.
+//
+// Lines are buffered and fsynced in batches, so a killed process loses at
+// most the last batch and leaves a valid prefix; a truncated final line is
+// cut off the next time the file is opened.
+type JSONLStorage struct {
+	filePath  string
+	file      *os.File
+	writer    *bufio.Writer
+	size      int64            // Bytes written, including buffered ones
+	hashIndex map[string]int64 // SHA3 hash -> line offset
+	urlIndex  map[string]int64 // Source URL -> line offset
+	unsynced  int
+	stats     types.StorageStats
+	closed    bool
+	mutex     sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func init() {
+	Register("jsonl", func(uri string) (Storage, error) { return NewJSONL(location(uri)) })
+}
+
+// NewJSONL opens (creating if needed) the JSON Lines file at filePath
+func NewJSONL(filePath string) (*JSONLStorage, error) {
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+
+	s := &JSONLStorage{
+		filePath:  filePath,
+		file:      file,
+		hashIndex: make(map[string]int64),
+		urlIndex:  make(map[string]int64),
+		stats: types.StorageStats{
+			StartTime:     time.Now(),
+			LastUpdatedAt: time.Now(),
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	if err := s.loadExistingData(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to load existing data: %w", err)
+	}
+
+	if _, err := file.Seek(s.size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	s.writer = bufio.NewWriter(file)
+
+	go s.syncLoop()
+
+	return s, nil
+}
+
+// loadExistingData indexes the lines already in the file and cuts off a
+// partially written final line
+func (s *JSONLStorage) loadExistingData() error {
+	validEnd, err := scanJSONL(s.file, func(installer types.ProcessedFile, offset int64) error {
+		s.index(installer, offset)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > validEnd {
+		logger.Warningf("Discarding %d bytes of incomplete data at the end of %s", info.Size()-validEnd, s.filePath)
+		if err := s.file.Truncate(validEnd); err != nil {
+			return fmt.Errorf("failed to truncate incomplete line: %w", err)
+		}
+	}
+	s.size = validEnd
+
+	if len(s.hashIndex) > 0 {
+		logger.Infof("Loaded %d existing installers from %s", len(s.hashIndex), s.filePath)
+	}
+	return nil
+}
+
+// scanJSONL calls fn for every installer in r with the offset of its line
+// and returns the offset just past the last complete line. Only the final
+// line may be invalid, as left by an interrupted write; anything invalid
+// before it is reported as corruption.
+func scanJSONL(r io.ReaderAt, fn func(installer types.ProcessedFile, offset int64) error) (int64, error) {
+	reader := bufio.NewReaderSize(io.NewSectionReader(r, 0, 1<<62), 64*1024)
+
+	var offset, validEnd int64
+	var badLine int64 = -1
+	line := 0
+	for {
+		data, err := readLine(reader)
+		if len(data) == 0 && err != nil {
+			if err == io.EOF {
+				break
+			}
+			return validEnd, err
+		}
+		line++
+		start := offset
+		offset += int64(len(data))
+
+		complete := data[len(data)-1] == '\n'
+		trimmed := bytes.TrimSpace(data)
+		if badLine >= 0 {
+			return validEnd, fmt.Errorf("line %d: invalid JSON", badLine)
+		}
+		if len(trimmed) == 0 {
+			if complete {
+				validEnd = offset
+			}
+			continue
+		}
+
+		var installer types.ProcessedFile
+		if !complete || json.Unmarshal(trimmed, &installer) != nil {
+			badLine = int64(line)
+			continue
+		}
+		if err := fn(installer, start); err != nil {
+			return validEnd, err
+		}
+		validEnd = offset
+	}
+
+	return validEnd, nil
+}
+
+// readLine reads up to and including the next newline, allowing lines
+// longer than the reader's buffer
+func readLine(reader *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+		if len(line) > jsonlMaxLine {
+			return line, fmt.Errorf("line longer than %d bytes", jsonlMaxLine)
+		}
+	}
+}
+
+func (s *JSONLStorage) index(file types.ProcessedFile, offset int64) {
+	if _, ok := s.hashIndex[file.SHA3Hash]; !ok {
+		s.hashIndex[file.SHA3Hash] = offset
+	}
+	if _, ok := s.urlIndex[file.SourceURL]; !ok {
+		s.urlIndex[file.SourceURL] = offset
+	}
+}
+
+// Store appends a processed file's metadata as a new line
+func (s *JSONLStorage) Store(file types.ProcessedFile) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("storage %s is closed", s.filePath)
+	}
+
+	// Check if we already have this file by hash or URL
+	_, knownHash := s.hashIndex[file.SHA3Hash]
+	_, knownURL := s.urlIndex[file.SourceURL]
+	if knownHash || knownURL {
+		return nil
+	}
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", file.SourceURL, err)
+	}
+	data = append(data, '\n')
+	if _, err := s.writer.Write(data); err != nil {
+		return fmt.Errorf("failed to append to %s: %w", s.filePath, err)
+	}
+
+	s.index(file, s.size)
+	s.size += int64(len(data))
+	s.stats.FilesStored++
+	s.stats.UniqueHashes = len(s.hashIndex)
+	s.stats.LastUpdatedAt = time.Now()
+
+	s.unsynced++
+	if s.unsynced >= jsonlSyncEvery {
+		return s.syncLocked()
+	}
+	return nil
+}
+
+// syncLoop fsyncs partial batches periodically
+func (s *JSONLStorage) syncLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(jsonlSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mutex.Lock()
+			if err := s.syncLocked(); err != nil {
+				logger.Errorf("Failed to sync %s: %v", s.filePath, err)
+			}
+			s.mutex.Unlock()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// syncLocked flushes buffered lines and fsyncs them to disk
+func (s *JSONLStorage) syncLocked() error {
+	if s.unsynced == 0 {
+		return nil
+	}
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.unsynced = 0
+	return nil
+}
+
+// Close syncs outstanding lines, computes statistics over the whole file
+// and closes it
+func (s *JSONLStorage) Close() error {
+	close(s.stop)
+	<-s.done
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+	syncErr := s.syncLocked()
+
+	builder := newStatsBuilder()
+	if _, err := scanJSONL(io.NewSectionReader(s.file, 0, s.size), func(installer types.ProcessedFile, _ int64) error {
+		builder.add(installer)
+		return nil
+	}); err != nil && syncErr == nil {
+		syncErr = fmt.Errorf("failed to compute stats: %w", err)
+	}
+
+	stats := builder.result()
+	stats.StartTime = s.stats.StartTime
+	stats.EndTime = time.Now()
+	s.stats = stats
+
+	logger.Infof("Closing storage with %d files stored", stats.FilesStored)
+	logger.Infof("Files by platform: %v", stats.FilesByPlatform)
+	logger.Infof("Files by type: %v", stats.FilesByType)
+	logger.Infof("Files by architecture: %v", stats.FilesByArchitecture)
+
+	if err := s.file.Close(); err != nil && syncErr == nil {
+		syncErr = err
+	}
+	return syncErr
+}
+
+// Stats returns storage statistics. While the storage is open only the
+// counts are kept up to date; the full statistics are computed at Close.
+func (s *JSONLStorage) Stats() types.StorageStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats
+}
+
+// Contains reports whether a file with the hash or source URL is stored
+func (s *JSONLStorage) Contains(hash, url string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, found := s.hashIndex[hash]
+	if !found {
+		_, found = s.urlIndex[url]
+	}
+	return found, nil
+}
+
+// Get returns the file with the given SHA3 hash
+func (s *JSONLStorage) Get(hash string) (types.ProcessedFile, bool, error) {
+	return s.readIndexed(s.hashIndex, hash)
+}
+
+// FindByURL returns the file downloaded from the given URL
+func (s *JSONLStorage) FindByURL(url string) (types.ProcessedFile, bool, error) {
+	return s.readIndexed(s.urlIndex, url)
+}
+
+// readIndexed reads the line an index points key at
+func (s *JSONLStorage) readIndexed(index map[string]int64, key string) (types.ProcessedFile, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	offset, ok := index[key]
+	if !ok {
+		return types.ProcessedFile{}, false, nil
+	}
+	if err := s.writer.Flush(); err != nil {
+		return types.ProcessedFile{}, false, err
+	}
+
+	data, err := readLine(bufio.NewReader(io.NewSectionReader(s.file, offset, s.size-offset)))
+	if err != nil && err != io.EOF {
+		return types.ProcessedFile{}, false, err
+	}
+
+	var installer types.ProcessedFile
+	if err := json.Unmarshal(data, &installer); err != nil {
+		return types.ProcessedFile{}, false, fmt.Errorf("failed to read %s at offset %d: %w", s.filePath, offset, err)
+	}
+	return installer, true, nil
+}
+
+// Iterate calls fn for every stored file matching filter, in file order.
+// Files stored while iterating are not visited.
+func (s *JSONLStorage) Iterate(filter Filter, fn func(types.ProcessedFile) error) error {
+	s.mutex.Lock()
+	if err := s.writer.Flush(); err != nil {
+		s.mutex.Unlock()
+		return err
+	}
+	size := s.size
+	s.mutex.Unlock()
+
+	_, err := scanJSONL(io.NewSectionReader(s.file, 0, size), func(installer types.ProcessedFile, _ int64) error {
+		if !filter.Matches(installer) {
+			return nil
+		}
+		return fn(installer)
+	})
+	if errors.Is(err, ErrStopIteration) {
+		return nil
+	}
+	return err
+}
+
+// Compact merges JSON Lines files into a single installers.json document
+// at output, keeping the first occurrence of each hash or URL. The output
+// is written to a temporary file and renamed into place.
+func Compact(inputs []string, output string) (types.StorageStats, error) {
+	var installers []types.ProcessedFile
+	hashes := make(map[string]bool)
+	urls := make(map[string]bool)
+
+	for _, input := range inputs {
+		file, err := os.Open(input)
+		if err != nil {
+			return types.StorageStats{}, err
+		}
+
+		read := 0
+		validEnd, err := scanJSONL(file, func(installer types.ProcessedFile, _ int64) error {
+			read++
+			if hashes[installer.SHA3Hash] || urls[installer.SourceURL] {
+				return nil
+			}
+			hashes[installer.SHA3Hash] = true
+			urls[installer.SourceURL] = true
+			installers = append(installers, installer)
+			return nil
+		})
+		info, statErr := file.Stat()
+		file.Close()
+		if err != nil {
+			return types.StorageStats{}, fmt.Errorf("failed to read %s: %w", input, err)
+		}
+		if statErr == nil && info.Size() > validEnd {
+			logger.Warningf("Ignoring incomplete line at the end of %s", input)
+		}
+		logger.Infof("Read %d installers from %s", read, input)
+	}
+
+	stats := computeStats(installers)
+	err := writeFileAtomic(output, func(w io.Writer) error {
+		return ExportJSON(w, installers, stats)
+	})
+	return stats, err
+}
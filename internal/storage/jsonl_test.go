@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+func openJSONL(t *testing.T, path string) *JSONLStorage {
+	t.Helper()
+	store, err := NewJSONL(path)
+	if err != nil {
+		t.Fatalf("NewJSONL() error = %v", err)
+	}
+	return store
+}
+
+func TestJSONLRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installers.jsonl")
+	store := openJSONL(t, path)
+
+	want := []types.ProcessedFile{sampleFile(1), sampleFile(2)}
+	for _, file := range want {
+		if err := store.Store(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Same hash from another URL, and the same URL again, are both skipped
+	mirror := sampleFile(1)
+	mirror.SourceURL = "https://mirror.example.org/tool-1.msi"
+	for _, file := range []types.ProcessedFile{mirror, sampleFile(2)} {
+		if err := store.Store(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Buffered lines are readable before they are synced
+	if file, ok, err := store.Get(want[1].SHA3Hash); err != nil || !ok || !reflect.DeepEqual(file, want[1]) {
+		t.Errorf("Get() before sync = %+v, %v, %v", file, ok, err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := store.Stats(); stats.FilesStored != 2 || stats.FilesByType["msi"] != 2 || stats.EndTime.IsZero() {
+		t.Errorf("Stats() after Close = %+v", stats)
+	}
+	if err := store.Store(sampleFile(3)); err == nil {
+		t.Error("Store() after Close succeeded")
+	}
+
+	store = openJSONL(t, path)
+	defer store.Close()
+
+	var got []types.ProcessedFile
+	if err := store.Iterate(Filter{}, func(file types.ProcessedFile) error {
+		got = append(got, file)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Iterate() after reopening =\n%+v\nwant\n%+v", got, want)
+	}
+	if file, ok, err := store.FindByURL(want[0].SourceURL); err != nil || !ok || !reflect.DeepEqual(file, want[0]) {
+		t.Errorf("FindByURL() = %+v, %v, %v", file, ok, err)
+	}
+	if _, ok, err := store.FindByURL(mirror.SourceURL); ok || err != nil {
+		t.Errorf("FindByURL(mirror) = %v, %v, want not found", ok, err)
+	}
+}
+
+func TestJSONLRecoversTruncatedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installers.jsonl")
+	line, _ := json.Marshal(sampleFile(1))
+	partial, _ := json.Marshal(sampleFile(2))
+
+	tests := []struct {
+		name string
+		tail string
+	}{
+		{"partial line", string(partial[:len(partial)/2])},
+		{"complete JSON without newline", string(partial)},
+		{"blank lines", "\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, append(append(line, '\n'), tt.tail...), 0644); err != nil {
+				t.Fatal(err)
+			}
+			store := openJSONL(t, path)
+			if err := store.Store(sampleFile(3)); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// The incomplete line is cut off and new lines follow the valid prefix
+			data, _ := os.ReadFile(path)
+			var lines []string
+			for _, l := range strings.Split(string(data), "\n") {
+				if l != "" {
+					lines = append(lines, l)
+				}
+			}
+			if len(lines) != 2 || lines[0] != string(line) || !strings.Contains(lines[1], sampleFile(3).SHA3Hash) {
+				t.Errorf("file after recovery =\n%s", data)
+			}
+		})
+	}
+}
+
+func TestJSONLRejectsCorruption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installers.jsonl")
+	line, _ := json.Marshal(sampleFile(1))
+	data := "{not json}\n" + string(line) + "\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if store, err := NewJSONL(path); err == nil {
+		store.Close()
+		t.Fatal("NewJSONL() opened a file with a corrupt line before the last")
+	}
+	if got, _ := os.ReadFile(path); string(got) != data {
+		t.Error("a corrupt file was modified")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, files ...types.ProcessedFile) string {
+		var lines []byte
+		for _, file := range files {
+			line, _ := json.Marshal(file)
+			lines = append(append(lines, line...), '\n')
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, lines, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	renamed := sampleFile(1)
+	renamed.Filename = "renamed.msi"
+	other := sampleFile(3)
+	other.WebsiteDomain = "downloads.example.com"
+	first := write("a.jsonl", sampleFile(2), sampleFile(1))
+	second := write("b.jsonl", renamed, other)
+	// An interrupted final line is ignored
+	f, _ := os.OpenFile(second, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"filename":"cut`)
+	f.Close()
+
+	output := filepath.Join(dir, "installers.json")
+	stats, err := Compact([]string{first, second}, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.FilesStored != 3 {
+		t.Errorf("Compact() stats.FilesStored = %d, want 3", stats.FilesStored)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc JSONOutput
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range doc.Installers {
+		names = append(names, file.Filename)
+	}
+	// The first occurrence wins, sorted by domain then filename
+	want := []string{"tool-3.msi", "tool-1.msi", "tool-2.msi"}
+	if doc.SchemaVersion != CurrentSchemaVersion || !reflect.DeepEqual(names, want) {
+		t.Errorf("compacted schema %d installers %v, want %d %v", doc.SchemaVersion, names, CurrentSchemaVersion, want)
+	}
+
+	if _, err := Compact([]string{filepath.Join(dir, "missing.jsonl")}, output); err == nil {
+		t.Error("Compact() of a missing input succeeded")
+	}
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// lockRetryInterval is how often a waiting process retries the lock
+const lockRetryInterval = 500 * time.Millisecond
+
+// errLockHeld is returned by lockFile when another process holds the lock
+var errLockHeld = errors.New("lock is held")
+
+// lockInfo is written into a lock file to identify its owner
+type lockInfo struct {
+	PID       int       `json:"pid"`
+	Hostname  string    `json:"hostname"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// fileLock is an exclusive lock on path.lock, held through an OS advisory
+// lock (flock, or LockFileEx on Windows) so that it is released by the
+// operating system if its owner dies. The lock file names its owner for
+// error messages.
+type fileLock struct {
+	path     string
+	file     *os.File
+	lost     error // Set once the lock file was removed or replaced
+	released bool
+	mutex    sync.Mutex
+}
+
+// acquireLock takes the lock for path, waiting up to timeout for another
+// process to release it
+func acquireLock(path string, timeout time.Duration) (*fileLock, error) {
+	lockPath := path + ".lock"
+	hostname, _ := os.Hostname()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		file, err := openLocked(lockPath)
+		if err == nil {
+			info := lockInfo{PID: os.Getpid(), Hostname: hostname, CreatedAt: time.Now()}
+			if err := writeLockInfo(file, info); err != nil {
+				unlockFile(file)
+				file.Close()
+				return nil, fmt.Errorf("failed to write lock file: %w", err)
+			}
+			return &fileLock{path: lockPath, file: file}, nil
+		}
+		if !errors.Is(err, errLockHeld) {
+			return nil, fmt.Errorf("failed to lock %s: %w", lockPath, err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is locked by %s", path, lockOwner(lockPath))
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// openLocked opens and locks the lock file. An owner removes the file
+// before unlocking it, so a lock taken on a file that is no longer at
+// lockPath is dropped and retried on the new one.
+func openLocked(lockPath string) (*os.File, error) {
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := lockFile(file); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if isLockFile(file, lockPath) {
+			return file, nil
+		}
+		unlockFile(file)
+		file.Close()
+	}
+}
+
+// writeLockInfo replaces the contents of a locked lock file
+func writeLockInfo(file *os.File, info lockInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	_, err = file.WriteAt(append(data, '\n'), 0)
+	return err
+}
+
+// lockOwner describes the owner named in a lock file
+func lockOwner(lockPath string) string {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return "another process"
+	}
+	var info lockInfo
+	if err := json.Unmarshal(data, &info); err != nil || info.PID == 0 {
+		return "another process"
+	}
+	return fmt.Sprintf("pid %d on %s since %s", info.PID, info.Hostname, info.CreatedAt.Format(time.RFC3339))
+}
+
+// isLockFile reports whether file is still the file at lockPath
+func isLockFile(file *os.File, lockPath string) bool {
+	held, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(lockPath)
+	return err == nil && os.SameFile(held, current)
+}
+
+// Check returns an error once the lock can no longer be relied on: it was
+// released, or its file was removed or replaced, after which another
+// process may take the lock
+func (l *fileLock) Check() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.released {
+		return fmt.Errorf("lock %s was released", l.path)
+	}
+	if l.lost == nil && !isLockFile(l.file, l.path) {
+		l.lost = fmt.Errorf("lock file %s was removed or replaced; another process may be writing", l.path)
+	}
+	return l.lost
+}
+
+// Release removes the lock file, unless it was replaced, and unlocks it
+func (l *fileLock) Release() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.released {
+		return nil
+	}
+	l.released = true
+
+	// Removed while still locked, so a process waiting on this file
+	// notices it is gone. A lock file that cannot be removed is harmless.
+	if isLockFile(l.file, l.path) {
+		os.Remove(l.path)
+	}
+	unlockFile(l.file)
+	return l.file.Close()
+}
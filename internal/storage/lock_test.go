@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+func readLockInfo(t *testing.T, lockPath string) lockInfo {
+	t.Helper()
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info lockInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+// TestLockHelperProcess holds the lock named by the environment until
+// killed; it is run by TestLockReleasedWhenOwnerDies
+func TestLockHelperProcess(t *testing.T) {
+	path := os.Getenv("STORAGE_LOCK_HELPER_PATH")
+	if path == "" {
+		return
+	}
+	if _, err := acquireLock(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout.WriteString("locked\n")
+	time.Sleep(time.Minute)
+}
+
+func TestLockExcludesOtherOwners(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installers.json")
+	lock, err := acquireLock(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := readLockInfo(t, path+".lock"); info.PID != os.Getpid() {
+		t.Errorf("lock file = %+v", info)
+	}
+
+	start := time.Now()
+	_, err = acquireLock(path, lockRetryInterval)
+	if err == nil {
+		t.Fatal("acquired a lock that is held")
+	}
+	if time.Since(start) < lockRetryInterval {
+		t.Error("acquireLock() did not wait for the timeout")
+	}
+	if !strings.Contains(err.Error(), "pid") {
+		t.Errorf("acquireLock() error = %v, want the owner named", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file still exists after Release: %v", err)
+	}
+	if err := lock.Check(); err == nil {
+		t.Error("Check() of a released lock succeeded")
+	}
+	lock, err = acquireLock(path, 0)
+	if err != nil {
+		t.Fatalf("acquireLock() after Release error = %v", err)
+	}
+	lock.Release()
+}
+
+func TestLockIgnoresLeftoverFile(t *testing.T) {
+	// A lock file left by a crashed process is not locked by anyone
+	path := filepath.Join(t.TempDir(), "installers.json")
+	if err := os.WriteFile(path+".lock", []byte(`{"pid": 1, "hostname": "gone"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := acquireLock(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+	if info := readLockInfo(t, path+".lock"); info.PID != os.Getpid() {
+		t.Errorf("lock file = %+v", info)
+	}
+}
+
+func TestLockReleasedWhenOwnerDies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installers.json")
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(executable, "-test.run=^TestLockHelperProcess$")
+	cmd.Env = append(os.Environ(), "STORAGE_LOCK_HELPER_PATH="+path)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+	if line, _ := bufio.NewReader(stdout).ReadString('\n'); line != "locked\n" {
+		t.Fatalf("helper process printed %q", line)
+	}
+
+	if _, err := acquireLock(path, 0); err == nil {
+		t.Fatal("acquired a lock held by another process")
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	lock, err := acquireLock(path, 0)
+	if err != nil {
+		t.Fatalf("acquireLock() after the owner died error = %v", err)
+	}
+	lock.Release()
+}
+
+func TestLockHasOneWinner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installers.json")
+
+	var wg sync.WaitGroup
+	locks := make(chan *fileLock, 8)
+	for i := 0; i < cap(locks); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if lock, err := acquireLock(path, 0); err == nil {
+				locks <- lock
+			}
+		}()
+	}
+	wg.Wait()
+	close(locks)
+
+	var won []*fileLock
+	for lock := range locks {
+		won = append(won, lock)
+	}
+	if len(won) != 1 {
+		t.Fatalf("%d owners acquired the lock, want 1", len(won))
+	}
+	won[0].Release()
+}
+
+func TestLockLostWhenFileReplaced(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installers.json")
+	lock, err := acquireLock(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Check(); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	// Someone deleted the lock file, letting another owner in
+	if err := os.Remove(path + ".lock"); err != nil {
+		t.Fatal(err)
+	}
+	other, err := acquireLock(path, 0)
+	if err != nil {
+		t.Fatalf("acquireLock() after the file was removed error = %v", err)
+	}
+	defer other.Release()
+
+	if err := lock.Check(); err == nil {
+		t.Error("Check() succeeded after the lock file was replaced")
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if info := readLockInfo(t, path+".lock"); info.PID != os.Getpid() {
+		t.Errorf("Release() removed the new owner's lock file: %+v", info)
+	}
+	if err := other.Check(); err != nil {
+		t.Errorf("new owner Check() error = %v", err)
+	}
+}
+
+func TestJSONStopsWritingWhenLockLost(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installers.json")
+	store, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Store(sampleFile(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(path + ".lock")
+	if err := store.Store(sampleFile(2)); err == nil {
+		t.Error("Store() succeeded after the lock was lost")
+	}
+	if err := store.RecordRun(types.RunRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err == nil {
+		t.Error("Close() wrote the file after the lock was lost")
+	}
+
+	reopened, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if stats := reopened.Stats(); stats.FilesStored != 1 {
+		t.Errorf("file holds %d installers, want the 1 stored before the lock was lost", stats.FilesStored)
+	}
+}
@@ -0,0 +1,325 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// S3Storage implements the Storage interface on an object in an
+// S3-compatible bucket. The object is downloaded into a local file backend
+// when opened and uploaded again on Close; the backend is chosen from the
+// key's extension as for plain paths.
+type S3Storage struct {
+	client   *s3Client
+	bucket   string
+	key      string
+	tempDir  string
+	tempPath string
+	local    Storage
+}
+
+func init() {
+	Register("s3", func(uri string) (Storage, error) { return NewS3(uri) })
+}
+
+// NewS3 opens the object named by an s3://bucket/key URI. The endpoint and
+// region can be given as ?endpoint=...&region=... query parameters, or with
+// AWS_ENDPOINT_URL and AWS_REGION. Credentials are read from
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN; without
+// them requests are sent unsigned.
+func NewS3(uri string) (*S3Storage, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 URI: %w", err)
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || key == "" {
+		return nil, fmt.Errorf("invalid S3 URI %q (expected s3://bucket/key)", uri)
+	}
+
+	client, err := newS3Client(u.Query().Get("endpoint"), u.Query().Get("region"))
+	if err != nil {
+		return nil, err
+	}
+
+	tempDir, err := os.MkdirTemp("", "installer-scraper-s3-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	s := &S3Storage{
+		client:   client,
+		bucket:   u.Host,
+		key:      key,
+		tempDir:  tempDir,
+		tempPath: filepath.Join(tempDir, path.Base(key)),
+	}
+
+	if err := s.download(); err != nil {
+		os.RemoveAll(tempDir)
+		return nil, err
+	}
+
+	s.local, err = Open(s.tempPath)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// download fetches the existing object, if any, into the temp file
+func (s *S3Storage) download() error {
+	resp, err := s.client.do(http.MethodGet, s.bucket, s.key, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch s3://%s/%s: %w", s.bucket, s.key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		logger.Infof("s3://%s/%s does not exist yet and will be created", s.bucket, s.key)
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch s3://%s/%s: %s", s.bucket, s.key, s3Error(resp))
+	}
+
+	file, err := os.Create(s.tempPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to fetch s3://%s/%s: %w", s.bucket, s.key, err)
+	}
+	return file.Close()
+}
+
+// Store saves a processed file's metadata locally until Close
+func (s *S3Storage) Store(file types.ProcessedFile) error {
+	return s.local.Store(file)
+}
+
+// Close finalizes the local copy and uploads it
+func (s *S3Storage) Close() error {
+	defer os.RemoveAll(s.tempDir)
+
+	if err := s.local.Close(); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(s.tempPath)
+	if os.IsNotExist(err) {
+		// Nothing was stored in a new object
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.do(http.MethodPut, s.bucket, s.key, data)
+	if err != nil {
+		return fmt.Errorf("failed to upload s3://%s/%s: %w", s.bucket, s.key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to upload s3://%s/%s: %s", s.bucket, s.key, s3Error(resp))
+	}
+
+	logger.Infof("Uploaded %d bytes to s3://%s/%s", len(data), s.bucket, s.key)
+	return nil
+}
+
+// RecordRun adds the run to the object's history if its format keeps one
+func (s *S3Storage) RecordRun(run types.RunRecord) error {
+	if recorder, ok := s.local.(RunRecorder); ok {
+		return recorder.RecordRun(run)
+	}
+	return nil
+}
+
+// Stats returns storage statistics
+func (s *S3Storage) Stats() types.StorageStats {
+	return s.local.Stats()
+}
+
+// Contains reports whether the local copy has the file
+func (s *S3Storage) Contains(hash, url string) (bool, error) {
+	return IsStored(s.local, hash, url)
+}
+
+// Get returns the file with the given SHA3 hash
+func (s *S3Storage) Get(hash string) (types.ProcessedFile, bool, error) {
+	return s.local.Get(hash)
+}
+
+// FindByURL returns the file downloaded from the given URL
+func (s *S3Storage) FindByURL(url string) (types.ProcessedFile, bool, error) {
+	return s.local.FindByURL(url)
+}
+
+// Iterate calls fn for every stored file matching filter
+func (s *S3Storage) Iterate(filter Filter, fn func(types.ProcessedFile) error) error {
+	return s.local.Iterate(filter, fn)
+}
+
+// s3Client sends path-style requests signed with AWS Signature Version 4
+type s3Client struct {
+	endpoint     *url.URL
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	httpClient   *http.Client
+}
+
+func newS3Client(endpoint, region string) (*s3Client, error) {
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+
+	return &s3Client{
+		endpoint:     u,
+		region:       region,
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		httpClient:   &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// do sends a request for bucket/key with an optional body
+func (c *s3Client) do(method, bucket, key string, body []byte) (*http.Response, error) {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bucket + "/" + key
+	u.RawPath = awsEscapePath(u.Path)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+
+	if c.accessKey != "" && c.secretKey != "" {
+		c.sign(req, body, time.Now().UTC())
+	}
+
+	return c.httpClient.Do(req)
+}
+
+// sign adds the SigV4 headers to req
+func (c *s3Client) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsEscapePath(req.URL.Path),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+// awsEscapePath percent-encodes everything but unreserved characters and
+// the path separators, as SigV4 requires
+func awsEscapePath(p string) string {
+	var b strings.Builder
+	for _, c := range []byte(p) {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Error summarizes an error response
+func s3Error(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if len(body) == 0 {
+		return resp.Status
+	}
+	return resp.Status + ": " + strings.TrimSpace(string(body))
+}
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// CurrentSchemaVersion is the JSON output schema version this build writes.
+// The schema is published in docs/schema/installers.schema.json.
+const CurrentSchemaVersion = 2
+
+// migration upgrades a decoded JSON output document from one schema version
+// to the next
+type migration struct {
+	description string
+	apply       func(doc map[string]interface{}) error
+}
+
+// schemaMigrations[n] upgrades a document from version n to n+1. Documents
+// written before schema_version existed are version 0.
+var schemaMigrations = []migration{
+	{
+		description: "rename PascalCase stats fields to snake_case",
+		apply: func(doc map[string]interface{}) error {
+			stats, ok := doc["stats"].(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			for key, value := range stats {
+				snake := snakeCase(key)
+				if snake == key {
+					continue
+				}
+				delete(stats, key)
+				if _, exists := stats[snake]; !exists {
+					stats[snake] = value
+				}
+			}
+			return nil
+		},
+	},
+	{
+		description: "add the runs history",
+		apply: func(doc map[string]interface{}) error {
+			if _, ok := doc["runs"]; !ok {
+				doc["runs"] = []interface{}{}
+			}
+			return nil
+		},
+	},
+}
+
+// decodeJSONOutput parses a JSON output document of any supported schema
+// version, upgrading it to CurrentSchemaVersion. It returns the version the
+// document was written with.
+func decodeJSONOutput(data []byte) (JSONOutput, int, error) {
+	// Numbers are kept as written so large sizes survive the round trip
+	var doc map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return JSONOutput{}, 0, err
+	}
+
+	version, err := migrateDocument(doc)
+	if err != nil {
+		return JSONOutput{}, version, err
+	}
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return JSONOutput{}, version, err
+	}
+	var output JSONOutput
+	if err := json.Unmarshal(migrated, &output); err != nil {
+		return JSONOutput{}, version, err
+	}
+	return output, version, nil
+}
+
+// migrateDocument applies the migrations a document needs in place and
+// returns its original version
+func migrateDocument(doc map[string]interface{}) (int, error) {
+	version := 0
+	if value, ok := doc["schema_version"]; ok {
+		number, ok := value.(json.Number)
+		parsed, err := number.Int64()
+		if !ok || err != nil || parsed < 0 {
+			return 0, fmt.Errorf("invalid schema_version %v", value)
+		}
+		version = int(parsed)
+	}
+	if version > CurrentSchemaVersion {
+		return version, fmt.Errorf("schema version %d is newer than this build supports (%d)", version, CurrentSchemaVersion)
+	}
+
+	for v := version; v < CurrentSchemaVersion; v++ {
+		if err := schemaMigrations[v].apply(doc); err != nil {
+			return version, fmt.Errorf("failed to migrate from schema version %d (%s): %w", v, schemaMigrations[v].description, err)
+		}
+		doc["schema_version"] = v + 1
+	}
+	return version, nil
+}
+
+// MigrateFile upgrades the JSON output file at path to the current schema
+// version in place, returning the version it had. Files already current
+// are left untouched unless force is set.
+func MigrateFile(path string, force bool) (int, error) {
+	lock, err := acquireLock(path, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer lock.Release()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	output, version, err := decodeJSONOutput(data)
+	if err != nil {
+		return version, err
+	}
+	if version == CurrentSchemaVersion && !force {
+		return version, nil
+	}
+
+	if output.Installers == nil {
+		output.Installers = make([]types.ProcessedFile, 0)
+	}
+	if output.Runs == nil {
+		output.Runs = make([]types.RunRecord, 0)
+	}
+	output.SchemaVersion = CurrentSchemaVersion
+	output.Stats = migratedStats(output)
+	if err := lock.Check(); err != nil {
+		return version, fmt.Errorf("not writing %s: %w", path, err)
+	}
+	err = writeFileAtomic(path, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(output)
+	})
+	if err != nil {
+		return version, err
+	}
+
+	logger.Debugf("Migrated %s from schema version %d to %d", path, version, CurrentSchemaVersion)
+	return version, nil
+}
+
+// migratedStats recomputes a migrated file's stats from its installers.
+// Files that never recorded when their crawl started or ended get the
+// earliest discovery time and the last update time instead.
+func migratedStats(output JSONOutput) types.StorageStats {
+	stats := computeStats(output.Installers)
+	stats.LastUpdatedAt = output.Stats.LastUpdatedAt
+	stats.StartTime = output.Stats.StartTime
+	stats.EndTime = output.Stats.EndTime
+
+	if stats.StartTime.IsZero() {
+		for _, installer := range output.Installers {
+			if !installer.DiscoveredAt.IsZero() && (stats.StartTime.IsZero() || installer.DiscoveredAt.Before(stats.StartTime)) {
+				stats.StartTime = installer.DiscoveredAt
+			}
+		}
+	}
+	if stats.EndTime.IsZero() {
+		stats.EndTime = output.LastUpdated
+	}
+	if stats.LastUpdatedAt.IsZero() {
+		stats.LastUpdatedAt = output.LastUpdated
+	}
+	return stats
+}
+
+// snakeCase converts a PascalCase or camelCase name to snake_case, keeping
+// runs of capitals such as "ID" together
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			previousLower := i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]))
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])
+			if previousLower || nextLower {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
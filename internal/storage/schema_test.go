@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// copyFixture copies a testdata file into a temporary directory
+func copyFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "installers.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDecodeJSONOutputMigrates(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		version  int
+		filename string
+		platform string
+		size     int64
+		start    time.Time
+	}{
+		{"schema-v0.json", 0, "legacy-setup.exe", "windows", 9007199254740993, time.Date(2023, 4, 1, 9, 0, 0, 0, time.UTC)},
+		{"schema-v1.json", 1, "App-2.0.dmg", "macos", 2048, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			output, version, err := decodeJSONOutput(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != tt.version {
+				t.Errorf("version = %d, want %d", version, tt.version)
+			}
+			if output.SchemaVersion != CurrentSchemaVersion {
+				t.Errorf("SchemaVersion = %d, want %d", output.SchemaVersion, CurrentSchemaVersion)
+			}
+			if output.Runs == nil {
+				t.Error("Runs is nil after migration")
+			}
+			if len(output.Installers) != 1 || output.Installers[0].Filename != tt.filename || output.Installers[0].FileSizeBytes != tt.size {
+				t.Errorf("Installers = %+v", output.Installers)
+			}
+			// Stats written under the old field names are still read
+			if output.Stats.FilesStored != 1 || output.Stats.FilesByPlatform[tt.platform] != 1 || !output.Stats.StartTime.Equal(tt.start) {
+				t.Errorf("Stats = %+v", output.Stats)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONOutputRejects(t *testing.T) {
+	tests := map[string]string{
+		"newer version":    `{"schema_version": 99, "installers": []}`,
+		"negative version": `{"schema_version": -1, "installers": []}`,
+		"version string":   `{"schema_version": "2", "installers": []}`,
+		"not an object":    `[]`,
+		"truncated":        `{"schema_version": 2, "installers": [`,
+	}
+	for name, doc := range tests {
+		if _, _, err := decodeJSONOutput([]byte(doc)); err == nil {
+			t.Errorf("%s: decodeJSONOutput() succeeded", name)
+		}
+	}
+}
+
+func TestMigrateFile(t *testing.T) {
+	path := copyFixture(t, "schema-v0.json")
+
+	version, err := MigrateFile(path, false)
+	if err != nil || version != 0 {
+		t.Fatalf("MigrateFile() = %d, %v, want 0", version, err)
+	}
+	output := readJSONOutput(t, path)
+	if output.SchemaVersion != CurrentSchemaVersion || len(output.Installers) != 1 {
+		t.Fatalf("migrated file = %+v", output)
+	}
+	// Stats are rebuilt, keeping the recorded start and falling back to
+	// the last update for the end
+	stats := output.Stats
+	if stats.FilesByType["exe"] != 1 || stats.VersionedFileCount != 1 ||
+		!stats.StartTime.Equal(time.Date(2023, 4, 1, 9, 0, 0, 0, time.UTC)) ||
+		!stats.EndTime.Equal(time.Date(2023, 4, 2, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("migrated stats = %+v", stats)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "9007199254740993") || strings.Contains(string(data), "FilesStored") {
+		t.Errorf("migrated file lost precision or kept old field names:\n%s", data)
+	}
+
+	// A current file is left alone unless forced
+	info, _ := os.Stat(path)
+	old := info.ModTime().Add(-time.Hour)
+	os.Chtimes(path, old, old)
+	if version, err := MigrateFile(path, false); err != nil || version != CurrentSchemaVersion {
+		t.Errorf("MigrateFile(current) = %d, %v", version, err)
+	}
+	if info, _ := os.Stat(path); !info.ModTime().Equal(old) {
+		t.Error("MigrateFile() rewrote a current file")
+	}
+	if _, err := MigrateFile(path, true); err != nil {
+		t.Errorf("MigrateFile(force) error = %v", err)
+	}
+	if info, _ := os.Stat(path); info.ModTime().Equal(old) {
+		t.Error("MigrateFile(force) did not rewrite the file")
+	}
+}
+
+func TestNewUpgradesOldFile(t *testing.T) {
+	path := copyFixture(t, "schema-v1.json")
+	store := newJSON(t, path)
+	if file, ok, _ := store.Get("bbbb"); !ok || !file.IsSigned {
+		t.Errorf("Get() = %+v, %v", file, ok)
+	}
+	if err := store.Store(sampleFile(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if output := readJSONOutput(t, path); output.SchemaVersion != CurrentSchemaVersion || len(output.Installers) != 2 {
+		t.Errorf("upgraded file schema %d with %d installers", output.SchemaVersion, len(output.Installers))
+	}
+	// The old version is kept as a backup
+	if output := readJSONOutput(t, backupPath(path, 1)); output.SchemaVersion != 1 {
+		t.Errorf("backup schema = %d, want 1", output.SchemaVersion)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"FilesStored":          "files_stored",
+		"AvgDetectionScore":    "avg_detection_score",
+		"SignedInstallerCount": "signed_installer_count",
+		"SHA3Hash":             "sha3_hash",
+		"FileID":               "file_id",
+		"already_snake":        "already_snake",
+		"camelCase":            "camel_case",
+	}
+	for in, want := range tests {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
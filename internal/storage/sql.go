@@ -0,0 +1,853 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/logger"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+const (
+	// sqlBatchSize is the number of files written per transaction
+	sqlBatchSize = 100
+	// sqlFlushInterval bounds how long a partial batch waits before it is written
+	sqlFlushInterval = 2 * time.Second
+	// sqlQueryBatchSize is the number of file ids bound per child-table
+	// query, well under SQLite's and PostgreSQL's parameter limits
+	sqlQueryBatchSize = 500
+)
+
+// sqlDialect holds what differs between the SQL databases SQLStorage supports
+type sqlDialect struct {
+	name     string
+	idColumn string // Auto-incrementing primary key column definition
+	numbered bool   // Placeholders are $1, $2, ... rather than ?
+}
+
+// rebind rewrites ? placeholders for the dialect
+func (d sqlDialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// migrations are applied in order; a database records the highest version
+// it has applied in schema_migrations. {{id}} is replaced with the
+// dialect's primary key column definition.
+var migrations = []string{
+	// 1: initial schema
+	`CREATE TABLE files (
+		id              {{id}},
+		filename        TEXT    NOT NULL,
+		file_size_bytes BIGINT  NOT NULL DEFAULT 0,
+		platform        TEXT    NOT NULL DEFAULT '',
+		file_type       TEXT    NOT NULL DEFAULT '',
+		detection_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+		is_installer    INTEGER NOT NULL DEFAULT 0,
+		version         TEXT    NOT NULL DEFAULT '',
+		publisher       TEXT    NOT NULL DEFAULT '',
+		is_signed       INTEGER NOT NULL DEFAULT 0,
+		discovered_at   TEXT    NOT NULL
+	);
+	CREATE INDEX idx_files_platform ON files(platform);
+	CREATE INDEX idx_files_file_type ON files(file_type);
+
+	CREATE TABLE hashes (
+		file_id   INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+		algorithm TEXT    NOT NULL,
+		value     TEXT    NOT NULL,
+		PRIMARY KEY (file_id, algorithm)
+	);
+	CREATE UNIQUE INDEX idx_hashes_value ON hashes(algorithm, value);
+
+	CREATE TABLE crawl_runs (
+		id           {{id}},
+		started_at   TEXT    NOT NULL,
+		ended_at     TEXT,
+		files_stored INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE sources (
+		id            {{id}},
+		file_id       INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+		url           TEXT    NOT NULL,
+		domain        TEXT    NOT NULL DEFAULT '',
+		discovered_at TEXT    NOT NULL,
+		run_id        INTEGER REFERENCES crawl_runs(id)
+	);
+	CREATE UNIQUE INDEX idx_sources_url ON sources(url);
+	CREATE INDEX idx_sources_domain ON sources(domain);
+	CREATE INDEX idx_sources_file ON sources(file_id);
+
+	CREATE TABLE metadata (
+		file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+		key     TEXT    NOT NULL,
+		value   TEXT    NOT NULL, -- JSON encoded
+		PRIMARY KEY (file_id, key)
+	);
+
+	CREATE TABLE releases (
+		file_id        INTEGER PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+		source         TEXT    NOT NULL,
+		feed_url       TEXT    NOT NULL DEFAULT '',
+		title          TEXT    NOT NULL DEFAULT '',
+		version        TEXT    NOT NULL DEFAULT '',
+		build          TEXT    NOT NULL DEFAULT '',
+		release_date   TEXT,
+		notes          TEXT    NOT NULL DEFAULT '',
+		notes_url      TEXT    NOT NULL DEFAULT '',
+		min_os_version TEXT    NOT NULL DEFAULT '',
+		signature      TEXT    NOT NULL DEFAULT '',
+		length         BIGINT  NOT NULL DEFAULT 0,
+		prerelease     INTEGER NOT NULL DEFAULT 0
+	);`,
+
+	// 2: run history
+	`ALTER TABLE crawl_runs ADD COLUMN start_url TEXT NOT NULL DEFAULT '';
+	ALTER TABLE crawl_runs ADD COLUMN sources TEXT NOT NULL DEFAULT '[]'; -- JSON encoded
+	ALTER TABLE crawl_runs ADD COLUMN config_hash TEXT NOT NULL DEFAULT '';
+	ALTER TABLE crawl_runs ADD COLUMN interrupted INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE crawl_runs ADD COLUMN urls_visited INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE crawl_runs ADD COLUMN urls_skipped INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE crawl_runs ADD COLUMN files_found INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE crawl_runs ADD COLUMN files_downloaded INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE crawl_runs ADD COLUMN files_processed INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE crawl_runs ADD COLUMN bytes_downloaded BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE crawl_runs ADD COLUMN errors INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE crawl_runs ADD COLUMN files_new INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE crawl_runs ADD COLUMN files_existing INTEGER NOT NULL DEFAULT 0;`,
+
+	// 3: normalized versions
+	`ALTER TABLE files ADD COLUMN normalized_version TEXT NOT NULL DEFAULT '';
+	ALTER TABLE files ADD COLUMN version_source TEXT NOT NULL DEFAULT '';
+	ALTER TABLE files ADD COLUMN version_confidence DOUBLE PRECISION NOT NULL DEFAULT 0;`,
+
+	// 4: architecture
+	`ALTER TABLE files ADD COLUMN architecture TEXT NOT NULL DEFAULT '';
+	CREATE INDEX idx_files_architecture ON files(architecture);`,
+
+	// 5: analysis provenance
+	`ALTER TABLE files ADD COLUMN provenance TEXT NOT NULL DEFAULT '{}'; -- JSON encoded
+	ALTER TABLE files ADD COLUMN conflicts TEXT NOT NULL DEFAULT '[]'; -- JSON encoded`,
+
+	// 6: detection score breakdown
+	`ALTER TABLE files ADD COLUMN confidence_factors TEXT NOT NULL DEFAULT '{}'; -- JSON encoded`,
+
+	// 7: detection rule matches
+	`ALTER TABLE files ADD COLUMN matched_rules TEXT NOT NULL DEFAULT '[]'; -- JSON encoded
+	ALTER TABLE files ADD COLUMN tags TEXT NOT NULL DEFAULT '[]'; -- JSON encoded`,
+
+	// 8: known vulnerabilities
+	`ALTER TABLE files ADD COLUMN vulnerabilities TEXT NOT NULL DEFAULT '[]'; -- JSON encoded`,
+}
+
+// SQLStorage implements the Storage interface on a SQL database with a
+// normalized schema. Writes are buffered and committed in batches.
+type SQLStorage struct {
+	name    string // Database location used in log messages
+	db      *sql.DB
+	dialect sqlDialect
+	runID   int64
+
+	pending   []types.ProcessedFile
+	hashIndex map[string]bool
+	urlIndex  map[string]bool
+	stored    int
+	startTime time.Time
+	mutex     sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newSQLStorage brings the database schema up to date and starts the
+// background flusher
+func newSQLStorage(db *sql.DB, dialect sqlDialect, name string) (*SQLStorage, error) {
+	s := &SQLStorage{
+		name:      name,
+		db:        db,
+		dialect:   dialect,
+		hashIndex: make(map[string]bool),
+		urlIndex:  make(map[string]bool),
+		startTime: time.Now(),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.loadIndexes(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to load existing data: %w", err)
+	}
+
+	go s.flushLoop()
+
+	logger.Infof("Opened %s storage %s with %d existing installers", dialect.name, name, len(s.hashIndex))
+	return s, nil
+}
+
+func (s *SQLStorage) exec(q interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, query string, args ...interface{}) (sql.Result, error) {
+	return q.Exec(s.dialect.rebind(query), args...)
+}
+
+func (s *SQLStorage) queryRow(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, query string, args ...interface{}) *sql.Row {
+	return q.QueryRow(s.dialect.rebind(query), args...)
+}
+
+// migrate applies any migrations the database has not seen yet
+func (s *SQLStorage) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	var current int
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)", current, len(migrations))
+	}
+
+	for version := current + 1; version <= len(migrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(strings.ReplaceAll(migrations[version-1], "{{id}}", s.dialect.idColumn)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %w", version, err)
+		}
+		if _, err := s.exec(tx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version, formatTime(time.Now())); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", version, err)
+		}
+		logger.Debugf("Applied storage migration %d", version)
+	}
+
+	return nil
+}
+
+// loadIndexes reads the known hashes and URLs used for deduplication
+func (s *SQLStorage) loadIndexes() error {
+	rows, err := s.db.Query(`SELECT value FROM hashes WHERE algorithm = 'sha3-256'`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			rows.Close()
+			return err
+		}
+		s.hashIndex[hash] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = s.db.Query(`SELECT url FROM sources`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return err
+		}
+		s.urlIndex[url] = true
+	}
+	return rows.Err()
+}
+
+// Store queues a processed file's metadata for the next batch. A file whose
+// hash is already known gains the new URL as an additional source.
+func (s *SQLStorage) Store(file types.ProcessedFile) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.urlIndex[file.SourceURL] {
+		return nil
+	}
+
+	// The crawl run is recorded with the first file, so opening the
+	// database only to read it does not add a run
+	if err := s.ensureRunLocked(); err != nil {
+		return err
+	}
+	s.urlIndex[file.SourceURL] = true
+
+	if !s.hashIndex[file.SHA3Hash] {
+		s.hashIndex[file.SHA3Hash] = true
+		s.stored++
+	}
+
+	s.pending = append(s.pending, file)
+	if len(s.pending) >= sqlBatchSize {
+		return s.flushLocked()
+	}
+	return nil
+}
+
+// ensureRunLocked creates this session's crawl_runs row if it does not exist yet
+func (s *SQLStorage) ensureRunLocked() error {
+	if s.runID != 0 {
+		return nil
+	}
+	err := s.queryRow(s.db, `INSERT INTO crawl_runs (started_at) VALUES (?) RETURNING id`, formatTime(s.startTime)).Scan(&s.runID)
+	if err != nil {
+		return fmt.Errorf("failed to record crawl run: %w", err)
+	}
+	return nil
+}
+
+// RecordRun fills in this session's crawl_runs row with the run's summary
+func (s *SQLStorage) RecordRun(run types.RunRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.flushLocked(); err != nil {
+		return err
+	}
+	if err := s.ensureRunLocked(); err != nil {
+		return err
+	}
+
+	sources, err := json.Marshal(run.Sources)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(s.db, `UPDATE crawl_runs SET started_at = ?, ended_at = ?, start_url = ?, sources = ?,
+		config_hash = ?, interrupted = ?, urls_visited = ?, urls_skipped = ?, files_found = ?, files_downloaded = ?,
+		files_processed = ?, bytes_downloaded = ?, errors = ?, files_new = ?, files_existing = ? WHERE id = ?`,
+		formatTime(run.StartTime), formatTime(run.EndTime), run.StartURL, string(sources),
+		run.ConfigHash, boolInt(run.Interrupted), run.URLsVisited, run.URLsSkipped, run.FilesFound, run.FilesDownloaded,
+		run.FilesProcessed, run.BytesDownloaded, run.Errors, run.FilesNew, run.FilesExisting, s.runID)
+	if err != nil {
+		return fmt.Errorf("failed to record crawl run: %w", err)
+	}
+	return nil
+}
+
+// flushLoop writes partial batches periodically
+func (s *SQLStorage) flushLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(sqlFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mutex.Lock()
+			if err := s.flushLocked(); err != nil {
+				logger.Errorf("Failed to write to %s: %v", s.name, err)
+			}
+			s.mutex.Unlock()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// flushLocked writes all pending files in one transaction
+func (s *SQLStorage) flushLocked() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	for _, file := range s.pending {
+		if err := s.insertFile(tx, file); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to store %s: %w", file.SourceURL, err)
+		}
+	}
+
+	if _, err := s.exec(tx, `UPDATE crawl_runs SET files_stored = ? WHERE id = ?`, s.stored, s.runID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update crawl run: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit batch: %w", err)
+	}
+
+	logger.Debugf("Wrote %d files to %s", len(s.pending), s.name)
+	s.pending = s.pending[:0]
+	return nil
+}
+
+// insertFile writes a file and its related rows, or only a new source row
+// when the file's hash is already stored
+func (s *SQLStorage) insertFile(tx *sql.Tx, file types.ProcessedFile) error {
+	var fileID int64
+	err := s.queryRow(tx, `SELECT file_id FROM hashes WHERE algorithm = 'sha3-256' AND value = ?`, file.SHA3Hash).Scan(&fileID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		analysis, err := encodeAnalysis(file)
+		if err != nil {
+			return err
+		}
+		err = s.queryRow(tx, `INSERT INTO files
+			(filename, file_size_bytes, platform, file_type, detection_score, is_installer, version, publisher, is_signed, discovered_at,
+			normalized_version, version_source, version_confidence, architecture, provenance, conflicts, confidence_factors,
+			matched_rules, tags, vulnerabilities)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+			file.Filename, file.FileSizeBytes, file.Platform, file.FileType, file.DetectionScore,
+			boolInt(file.IsInstaller), file.Version, file.Publisher, boolInt(file.IsSigned), formatTime(file.DiscoveredAt),
+			file.NormalizedVersion, file.VersionSource, file.VersionConfidence, file.Architecture,
+			analysis.provenance, analysis.conflicts, analysis.factors, analysis.matchedRules, analysis.tags,
+			analysis.vulnerabilities).Scan(&fileID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.exec(tx, `INSERT INTO hashes (file_id, algorithm, value) VALUES (?, 'sha3-256', ?)`, fileID, file.SHA3Hash); err != nil {
+			return err
+		}
+		for key, value := range file.ExtendedMetadata {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				continue
+			}
+			if _, err := s.exec(tx, `INSERT INTO metadata (file_id, key, value) VALUES (?, ?, ?)`, fileID, key, string(encoded)); err != nil {
+				return err
+			}
+		}
+		if r := file.Release; r != nil {
+			var releaseDate interface{}
+			if r.ReleaseDate != nil {
+				releaseDate = formatTime(*r.ReleaseDate)
+			}
+			if _, err := s.exec(tx, `INSERT INTO releases
+				(file_id, source, feed_url, title, version, build, release_date, notes, notes_url, min_os_version, signature, length, prerelease)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				fileID, r.Source, r.FeedURL, r.Title, r.Version, r.Build, releaseDate, r.Notes, r.NotesURL,
+				r.MinOSVersion, r.Signature, r.Length, boolInt(r.Prerelease)); err != nil {
+				return err
+			}
+		}
+	case err != nil:
+		return err
+	}
+
+	_, err = s.exec(tx, `INSERT INTO sources (file_id, url, domain, discovered_at, run_id) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (url) DO NOTHING`,
+		fileID, file.SourceURL, file.WebsiteDomain, formatTime(file.DiscoveredAt), s.runID)
+	return err
+}
+
+// analysisColumns holds the JSON encoded analysis columns of a file
+type analysisColumns struct {
+	provenance      string
+	conflicts       string
+	factors         string
+	matchedRules    string
+	tags            string
+	vulnerabilities string
+}
+
+// encodeAnalysis JSON encodes a file's field provenance, conflicts,
+// confidence factors, rule matches and vulnerabilities. Missing values are encoded as empty
+// objects and arrays, matching the column defaults.
+func encodeAnalysis(file types.ProcessedFile) (analysisColumns, error) {
+	var columns analysisColumns
+	fields := []struct {
+		name  string
+		value interface{}
+		empty string
+		dst   *string
+	}{
+		{"provenance", file.Provenance, "{}", &columns.provenance},
+		{"conflicts", file.Conflicts, "[]", &columns.conflicts},
+		{"confidence factors", file.ConfidenceFactors, "{}", &columns.factors},
+		{"matched rules", file.MatchedRules, "[]", &columns.matchedRules},
+		{"tags", file.Tags, "[]", &columns.tags},
+		{"vulnerabilities", file.Vulnerabilities, "[]", &columns.vulnerabilities},
+	}
+	for _, field := range fields {
+		encoded, err := json.Marshal(field.value)
+		if err != nil {
+			return analysisColumns{}, fmt.Errorf("failed to encode %s: %w", field.name, err)
+		}
+		*field.dst = string(encoded)
+		if *field.dst == "null" {
+			*field.dst = field.empty
+		}
+	}
+	return columns, nil
+}
+
+// Close writes any pending files, records the end of the crawl run and
+// closes the database
+func (s *SQLStorage) Close() error {
+	close(s.stop)
+	<-s.done
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	flushErr := s.flushLocked()
+	if s.runID != 0 {
+		if _, err := s.exec(s.db, `UPDATE crawl_runs SET ended_at = COALESCE(ended_at, ?), files_stored = ? WHERE id = ?`,
+			formatTime(time.Now()), s.stored, s.runID); err != nil && flushErr == nil {
+			flushErr = fmt.Errorf("failed to update crawl run: %w", err)
+		}
+	}
+
+	stats, err := s.statsLocked()
+	if err == nil {
+		logger.Infof("Closing storage with %d files stored", stats.FilesStored)
+		logger.Infof("Files by platform: %v", stats.FilesByPlatform)
+		logger.Infof("Files by type: %v", stats.FilesByType)
+		logger.Infof("Files by architecture: %v", stats.FilesByArchitecture)
+	}
+
+	if err := s.db.Close(); err != nil && flushErr == nil {
+		flushErr = err
+	}
+	return flushErr
+}
+
+// Stats returns storage statistics computed from the database
+func (s *SQLStorage) Stats() types.StorageStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.flushLocked(); err != nil {
+		logger.Errorf("Failed to write to %s: %v", s.name, err)
+	}
+	stats, err := s.statsLocked()
+	if err != nil {
+		logger.Errorf("Failed to read storage stats: %v", err)
+	}
+	return stats
+}
+
+func (s *SQLStorage) statsLocked() (types.StorageStats, error) {
+	stats := types.StorageStats{
+		LastUpdatedAt:       time.Now(),
+		StartTime:           s.startTime,
+		FilesByPlatform:     make(map[string]int),
+		FilesByType:         make(map[string]int),
+		FilesByArchitecture: make(map[string]int),
+	}
+
+	err := s.db.QueryRow(`SELECT COUNT(*), COALESCE(AVG(detection_score), 0),
+		COALESCE(SUM(is_signed), 0), COALESCE(SUM(CASE WHEN version != '' THEN 1 ELSE 0 END), 0) FROM files`).
+		Scan(&stats.FilesStored, &stats.AvgDetectionScore, &stats.SignedInstallerCount, &stats.VersionedFileCount)
+	if err != nil {
+		return stats, err
+	}
+	stats.UniqueHashes = stats.FilesStored
+
+	breakdowns := map[string]map[string]int{
+		"platform":     stats.FilesByPlatform,
+		"file_type":    stats.FilesByType,
+		"architecture": stats.FilesByArchitecture,
+	}
+	for column, counts := range breakdowns {
+		rows, err := s.db.Query(`SELECT ` + column + `, COUNT(*) FROM files WHERE ` + column + ` != '' GROUP BY ` + column)
+		if err != nil {
+			return stats, err
+		}
+		for rows.Next() {
+			var key string
+			var count int
+			if err := rows.Scan(&key, &count); err != nil {
+				rows.Close()
+				return stats, err
+			}
+			counts[key] = count
+		}
+		rows.Close()
+	}
+
+	return stats, nil
+}
+
+// fileColumns selects a file with its hash and first-seen source
+const fileColumns = `SELECT f.id, f.filename, f.file_size_bytes, f.platform, f.file_type, f.detection_score,
+		f.is_installer, f.version, f.publisher, f.is_signed, f.discovered_at, h.value,
+		COALESCE(src.url, ''), COALESCE(src.domain, ''), f.normalized_version, f.version_source, f.version_confidence,
+		f.architecture, f.provenance, f.conflicts, f.confidence_factors, f.matched_rules, f.tags,
+		f.vulnerabilities
+	FROM files f
+	JOIN hashes h ON h.file_id = f.id AND h.algorithm = 'sha3-256'
+	LEFT JOIN sources src ON src.id = (SELECT MIN(id) FROM sources WHERE file_id = f.id)`
+
+// Contains reports whether a file with the hash or source URL is stored,
+// including files still waiting for the next batch
+func (s *SQLStorage) Contains(hash, url string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.hashIndex[hash] || s.urlIndex[url], nil
+}
+
+// Get returns the file with the given SHA3 hash
+func (s *SQLStorage) Get(hash string) (types.ProcessedFile, bool, error) {
+	files, err := s.query(fileColumns+` WHERE h.value = ?`, hash)
+	if err != nil || len(files) == 0 {
+		return types.ProcessedFile{}, false, err
+	}
+	return files[0], true, nil
+}
+
+// FindByURL returns the file downloaded from the given URL
+func (s *SQLStorage) FindByURL(url string) (types.ProcessedFile, bool, error) {
+	files, err := s.query(fileColumns+` WHERE f.id = (SELECT file_id FROM sources WHERE url = ?)`, url)
+	if err != nil || len(files) == 0 {
+		return types.ProcessedFile{}, false, err
+	}
+	file := files[0]
+	file.SourceURL = url
+	return file, true, nil
+}
+
+// Iterate calls fn for every stored file matching filter, in insertion order.
+// The conditions pushed into the query compare like Filter.Matches does.
+func (s *SQLStorage) Iterate(filter Filter, fn func(types.ProcessedFile) error) error {
+	var conditions []string
+	var args []interface{}
+	if filter.Platform != "" {
+		conditions = append(conditions, "LOWER(f.platform) = LOWER(?)")
+		args = append(args, filter.Platform)
+	}
+	if filter.FileType != "" {
+		conditions = append(conditions, "LOWER(f.file_type) = LOWER(?)")
+		args = append(args, filter.FileType)
+	}
+	if filter.Architecture != "" {
+		conditions = append(conditions, "LOWER(f.architecture) = LOWER(?)")
+		args = append(args, filter.Architecture)
+	}
+	if filter.BelowScore > 0 {
+		conditions = append(conditions, "f.detection_score < ?")
+		args = append(args, filter.BelowScore)
+	}
+	if filter.Tag != "" {
+		// Narrows the candidates; Matches compares the tags exactly
+		tag, _ := json.Marshal(strings.ToLower(filter.Tag))
+		conditions = append(conditions, "LOWER(f.tags) LIKE ?")
+		args = append(args, "%"+string(tag)+"%")
+	}
+	if filter.Domain != "" {
+		// The file's website domain is that of its first source
+		conditions = append(conditions, "LOWER(src.domain) = LOWER(?)")
+		args = append(args, filter.Domain)
+	}
+
+	query := fileColumns
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY f.id"
+
+	files, err := s.query(query, args...)
+	if err != nil {
+		return err
+	}
+	return iterateFiles(files, filter, fn)
+}
+
+// query flushes pending writes and loads the files a query selects,
+// together with their metadata and releases
+func (s *SQLStorage) query(query string, args ...interface{}) ([]types.ProcessedFile, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.flushLocked(); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(s.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []types.ProcessedFile
+	byID := make(map[int64]int)
+	for rows.Next() {
+		var id, isInstaller, isSigned int64
+		var discoveredAt, provenance, conflicts, factors, matchedRules, tags, vulnerabilities string
+		var file types.ProcessedFile
+		if err := rows.Scan(&id, &file.Filename, &file.FileSizeBytes, &file.Platform, &file.FileType,
+			&file.DetectionScore, &isInstaller, &file.Version, &file.Publisher, &isSigned,
+			&discoveredAt, &file.SHA3Hash, &file.SourceURL, &file.WebsiteDomain,
+			&file.NormalizedVersion, &file.VersionSource, &file.VersionConfidence, &file.Architecture,
+			&provenance, &conflicts, &factors, &matchedRules, &tags, &vulnerabilities); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		// Files stored before provenance was recorded decode to empty values
+		if json.Unmarshal([]byte(provenance), &file.Provenance) != nil || len(file.Provenance) == 0 {
+			file.Provenance = nil
+		}
+		if json.Unmarshal([]byte(conflicts), &file.Conflicts) != nil || len(file.Conflicts) == 0 {
+			file.Conflicts = nil
+		}
+		if json.Unmarshal([]byte(factors), &file.ConfidenceFactors) != nil || len(file.ConfidenceFactors) == 0 {
+			file.ConfidenceFactors = nil
+		}
+		if json.Unmarshal([]byte(matchedRules), &file.MatchedRules) != nil || len(file.MatchedRules) == 0 {
+			file.MatchedRules = nil
+		}
+		if json.Unmarshal([]byte(tags), &file.Tags) != nil || len(file.Tags) == 0 {
+			file.Tags = nil
+		}
+		if json.Unmarshal([]byte(vulnerabilities), &file.Vulnerabilities) != nil || len(file.Vulnerabilities) == 0 {
+			file.Vulnerabilities = nil
+		}
+		file.IsInstaller = isInstaller != 0
+		file.IsSigned = isSigned != 0
+		file.DiscoveredAt = parseTime(discoveredAt)
+		byID[id] = len(files)
+		files = append(files, file)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return files, nil
+	}
+	if err := s.loadMetadata(files, byID); err != nil {
+		return nil, err
+	}
+	if err := s.loadReleases(files, byID); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// forEachIDBatch runs query once per batch of the file ids in byID, with
+// the query's single ? replaced by the batch's placeholders, so child rows
+// are only read for the files being returned
+func (s *SQLStorage) forEachIDBatch(byID map[int64]int, query string, fn func(*sql.Rows) error) error {
+	ids := make([]int64, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for start := 0; start < len(ids); start += sqlQueryBatchSize {
+		end := min(start+sqlQueryBatchSize, len(ids))
+		args := make([]interface{}, 0, end-start)
+		for _, id := range ids[start:end] {
+			args = append(args, id)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+
+		rows, err := s.db.Query(s.dialect.rebind(strings.Replace(query, "?", placeholders, 1)), args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			if err := fn(rows); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLStorage) loadMetadata(files []types.ProcessedFile, byID map[int64]int) error {
+	return s.forEachIDBatch(byID, `SELECT file_id, key, value FROM metadata WHERE file_id IN (?)`, func(rows *sql.Rows) error {
+		var id int64
+		var key, encoded string
+		if err := rows.Scan(&id, &key, &encoded); err != nil {
+			return err
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(encoded), &value); err != nil {
+			return nil
+		}
+		i := byID[id]
+		if files[i].ExtendedMetadata == nil {
+			files[i].ExtendedMetadata = make(map[string]interface{})
+		}
+		files[i].ExtendedMetadata[key] = value
+		return nil
+	})
+}
+
+func (s *SQLStorage) loadReleases(files []types.ProcessedFile, byID map[int64]int) error {
+	return s.forEachIDBatch(byID, `SELECT file_id, source, feed_url, title, version, build, release_date, notes,
+		notes_url, min_os_version, signature, length, prerelease FROM releases WHERE file_id IN (?)`, func(rows *sql.Rows) error {
+		var id, prerelease int64
+		var releaseDate sql.NullString
+		r := &types.ReleaseInfo{}
+		if err := rows.Scan(&id, &r.Source, &r.FeedURL, &r.Title, &r.Version, &r.Build, &releaseDate, &r.Notes,
+			&r.NotesURL, &r.MinOSVersion, &r.Signature, &r.Length, &prerelease); err != nil {
+			return err
+		}
+		r.Prerelease = prerelease != 0
+		if releaseDate.Valid {
+			t := parseTime(releaseDate.String)
+			r.ReleaseDate = &t
+		}
+		files[byID[id]].Release = r
+		return nil
+	})
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(value string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, value)
+	return t
+}
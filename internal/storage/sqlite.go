@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver
+)
+
+var sqliteDialect = sqlDialect{
+	name:     "SQLite",
+	idColumn: "INTEGER PRIMARY KEY",
+}
+
+func init() {
+	opener := func(uri string) (Storage, error) { return NewSQLite(location(uri)) }
+	Register("sqlite", opener)
+	Register("sqlite3", opener)
+}
+
+// NewSQLite opens (creating if needed) the SQLite database at path and
+// brings its schema up to date
+func NewSQLite(path string) (*SQLStorage, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// SQLite allows a single writer; one connection avoids lock contention
+	db.SetMaxOpenConns(1)
+
+	return newSQLStorage(db, sqliteDialect, path)
+}
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// sampleFile returns a distinct file with every stored field populated
+func sampleFile(n int) types.ProcessedFile {
+	released := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return types.ProcessedFile{
+		Filename:          fmt.Sprintf("tool-%d.msi", n),
+		SourceURL:         fmt.Sprintf("https://example.com/files/tool-%d.msi", n),
+		WebsiteDomain:     "example.com",
+		DiscoveredAt:      time.Date(2024, 6, 1, 8, 0, n%60, 0, time.UTC),
+		SHA3Hash:          fmt.Sprintf("%064x", n),
+		FileSizeBytes:     int64(1024 + n),
+		Platform:          "windows",
+		FileType:          "msi",
+		Architecture:      "x64",
+		DetectionScore:    0.9,
+		IsInstaller:       true,
+		Version:           fmt.Sprintf("1.%d", n),
+		Publisher:         "Example Corp",
+		IsSigned:          n%2 == 0,
+		ExtendedMetadata:  map[string]interface{}{"product_code": fmt.Sprintf("{%d}", n), "languages": []interface{}{"en-US"}},
+		ConfidenceFactors: map[string]float64{"base": 0.3, "magic_match": 0.6},
+		NormalizedVersion: fmt.Sprintf("1.%d.0", n),
+		VersionSource:     "package",
+		VersionConfidence: 0.95,
+		Release: &types.ReleaseInfo{
+			Source: "github", FeedURL: "https://api.github.com/repos/example/tool/releases", Version: fmt.Sprintf("1.%d", n),
+			ReleaseDate: &released, NotesURL: "https://example.com/notes", Length: int64(1024 + n),
+		},
+		Provenance: map[string]types.FieldSource{"version": {Analyzer: "msi", Confidence: 0.95}},
+		Conflicts: []types.FieldConflict{{Field: "publisher", Values: []types.FieldValue{
+			{Value: "Example Corp", FieldSource: types.FieldSource{Analyzer: "msi", Confidence: 0.9}},
+			{Value: "Example", FieldSource: types.FieldSource{Analyzer: "pe", Confidence: 0.6}},
+		}}},
+		MatchedRules:    []string{"vendor-example"},
+		Tags:            []string{"approved"},
+		Vulnerabilities: []types.Vulnerability{{ID: "CVE-2024-0001", Severity: "high", Score: 7.5, Source: "osv", MatchedBy: "pkg:generic/tool"}},
+	}
+}
+
+func openSQLite(t *testing.T, path string) *SQLStorage {
+	t.Helper()
+	store, err := NewSQLite(path)
+	if err != nil {
+		t.Fatalf("NewSQLite() error = %v", err)
+	}
+	return store
+}
+
+func TestSQLiteRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installers.db")
+	store := openSQLite(t, path)
+
+	want := []types.ProcessedFile{sampleFile(1), sampleFile(2)}
+	want[1].Release = nil
+	want[1].ExtendedMetadata = nil
+	for _, file := range want {
+		if err := store.Store(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The same content from a second URL only adds a source
+	mirror := sampleFile(1)
+	mirror.SourceURL = "https://mirror.example.org/tool-1.msi"
+	if err := store.Store(mirror); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store = openSQLite(t, path)
+	defer store.Close()
+
+	var got []types.ProcessedFile
+	if err := store.Iterate(Filter{}, func(file types.ProcessedFile) error {
+		got = append(got, file)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Iterate() after reopening =\n%+v\nwant\n%+v", got, want)
+	}
+
+	file, ok, err := store.Get(want[0].SHA3Hash)
+	if err != nil || !ok || !reflect.DeepEqual(file, want[0]) {
+		t.Errorf("Get() = %+v, %v, %v", file, ok, err)
+	}
+	file, ok, err = store.FindByURL(mirror.SourceURL)
+	if err != nil || !ok || file.SHA3Hash != want[0].SHA3Hash || file.SourceURL != mirror.SourceURL {
+		t.Errorf("FindByURL(mirror) = %+v, %v, %v", file, ok, err)
+	}
+	if _, ok, err := store.Get("missing"); ok || err != nil {
+		t.Errorf("Get(missing) = %v, %v", ok, err)
+	}
+	if stats := store.Stats(); stats.FilesStored != 2 || stats.SignedInstallerCount != 1 || stats.FilesByPlatform["windows"] != 2 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestSQLiteIterateFilters(t *testing.T) {
+	store := openSQLite(t, filepath.Join(t.TempDir(), "installers.db"))
+	defer store.Close()
+
+	linux := sampleFile(2)
+	linux.Platform, linux.FileType, linux.Architecture, linux.Tags = "linux", "deb", "arm64", nil
+	for _, file := range []types.ProcessedFile{sampleFile(1), linux} {
+		if err := store.Store(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"all", Filter{}, []string{"tool-1.msi", "tool-2.msi"}},
+		{"platform", Filter{Platform: "linux"}, []string{"tool-2.msi"}},
+		{"file type", Filter{FileType: "msi"}, []string{"tool-1.msi"}},
+		{"architecture", Filter{Architecture: "arm64"}, []string{"tool-2.msi"}},
+		{"tag", Filter{Tag: "APPROVED"}, []string{"tool-1.msi"}},
+		{"domain", Filter{Domain: "example.com"}, []string{"tool-1.msi", "tool-2.msi"}},
+		{"other domain", Filter{Domain: "example.org"}, nil},
+		{"below score", Filter{BelowScore: 0.5}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			if err := store.Iterate(tt.filter, func(file types.ProcessedFile) error {
+				got = append(got, file.Filename)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Iterate(%+v) = %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+
+	var seen int
+	if err := store.Iterate(Filter{}, func(types.ProcessedFile) error {
+		seen++
+		return ErrStopIteration
+	}); err != nil || seen != 1 {
+		t.Errorf("Iterate stopped after %d files with error %v", seen, err)
+	}
+}
+
+func TestSQLiteLoadsChildRowsInBatches(t *testing.T) {
+	store := openSQLite(t, filepath.Join(t.TempDir(), "installers.db"))
+	defer store.Close()
+
+	count := sqlQueryBatchSize + 10
+	for n := 0; n < count; n++ {
+		if err := store.Store(sampleFile(n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var loaded int
+	if err := store.Iterate(Filter{}, func(file types.ProcessedFile) error {
+		if file.Release == nil || file.ExtendedMetadata["product_code"] == nil {
+			t.Errorf("%s is missing its release or metadata", file.Filename)
+		}
+		loaded++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if loaded != count {
+		t.Errorf("Iterate() returned %d files, want %d", loaded, count)
+	}
+
+	// A single file only reads its own child rows
+	file, ok, err := store.Get(sampleFile(sqlQueryBatchSize + 5).SHA3Hash)
+	if err != nil || !ok || file.ExtendedMetadata["product_code"] != fmt.Sprintf("{%d}", sqlQueryBatchSize+5) {
+		t.Errorf("Get() = %+v, %v, %v", file.ExtendedMetadata, ok, err)
+	}
+}
+
+func TestSQLiteIsStoredKeepsBatch(t *testing.T) {
+	store := openSQLite(t, filepath.Join(t.TempDir(), "installers.db"))
+	defer store.Close()
+
+	file := sampleFile(1)
+	if err := store.Store(file); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		hash, url string
+		want      bool
+	}{
+		{file.SHA3Hash, "https://example.com/other.msi", true},
+		{"unknown", file.SourceURL, true},
+		{"unknown", "https://example.com/other.msi", false},
+	}
+	for _, tt := range tests {
+		if got, err := IsStored(store, tt.hash, tt.url); got != tt.want || err != nil {
+			t.Errorf("IsStored(%q, %q) = %v, %v, want %v", tt.hash, tt.url, got, err, tt.want)
+		}
+	}
+	// The lookups are answered from the index rather than by writing the batch
+	store.mutex.Lock()
+	pending := len(store.pending)
+	store.mutex.Unlock()
+	if pending != 1 {
+		t.Errorf("%d files pending after IsStored, want 1", pending)
+	}
+}
+
+func TestSQLiteMigratesOldDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installers.db")
+
+	// A database written by the first schema version
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup := []string{
+		`CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, applied_at TEXT NOT NULL)`,
+		strings.ReplaceAll(migrations[0], "{{id}}", sqliteDialect.idColumn),
+		`INSERT INTO schema_migrations (version, applied_at) VALUES (1, '2024-01-01T00:00:00Z')`,
+		`INSERT INTO files (filename, file_size_bytes, platform, file_type, detection_score, is_installer, version, publisher, is_signed, discovered_at)
+			VALUES ('old.exe', 10, 'windows', 'exe', 0.5, 1, '1.0', 'Old Corp', 0, '2023-01-01T00:00:00Z')`,
+		`INSERT INTO hashes (file_id, algorithm, value) VALUES (1, 'sha3-256', 'oldhash')`,
+		`INSERT INTO sources (file_id, url, domain, discovered_at) VALUES (1, 'https://old.example.com/old.exe', 'old.example.com', '2023-01-01T00:00:00Z')`,
+	}
+	for _, statement := range setup {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+	db.Close()
+
+	store := openSQLite(t, path)
+	var version int
+	if err := store.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil || version != len(migrations) {
+		t.Errorf("schema version = %d, %v, want %d", version, err, len(migrations))
+	}
+
+	file, ok, err := store.FindByURL("https://old.example.com/old.exe")
+	if err != nil || !ok {
+		t.Fatalf("FindByURL() = %v, %v", ok, err)
+	}
+	if file.Filename != "old.exe" || file.SHA3Hash != "oldhash" || file.Architecture != "" || file.Tags != nil || file.Provenance != nil {
+		t.Errorf("migrated file = %+v", file)
+	}
+	// The upgraded schema accepts new files
+	if err := store.Store(sampleFile(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A database from a newer build is refused rather than downgraded
+	db, err = sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, '2030-01-01T00:00:00Z')`, len(migrations)+1); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if store, err := NewSQLite(path); err == nil {
+		store.Close()
+		t.Error("NewSQLite() opened a database with a newer schema")
+	}
+}
@@ -1,6 +1,14 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/deploymenttheory/go-app-index/internal/types"
@@ -16,20 +24,270 @@ type Storage interface {
 
 	// Stats returns storage statistics
 	Stats() types.StorageStats
+
+	// Get returns the file with the given SHA3 hash
+	Get(hash string) (types.ProcessedFile, bool, error)
+
+	// FindByURL returns the file downloaded from the given URL
+	FindByURL(url string) (types.ProcessedFile, bool, error)
+
+	// Iterate calls fn for every stored file matching filter. Returning
+	// ErrStopIteration from fn ends the iteration without an error.
+	Iterate(filter Filter, fn func(types.ProcessedFile) error) error
+}
+
+// RunRecorder is implemented by backends that keep a history of runs
+type RunRecorder interface {
+	// RecordRun adds a finished run to the history
+	RecordRun(run types.RunRecord) error
+}
+
+// Indexer is implemented by backends that can tell whether a file is
+// stored from an in-memory index, without reading or flushing anything
+type Indexer interface {
+	// Contains reports whether a file with the hash or source URL is stored
+	Contains(hash, url string) (bool, error)
+}
+
+// IsStored reports whether store has a file with the hash or source URL,
+// from its index when it keeps one
+func IsStored(store Storage, hash, url string) (bool, error) {
+	if indexer, ok := store.(Indexer); ok {
+		return indexer.Contains(hash, url)
+	}
+	if _, found, err := store.Get(hash); found || err != nil {
+		return found, err
+	}
+	_, found, err := store.FindByURL(url)
+	return found, err
+}
+
+// ErrStopIteration can be returned from an Iterate callback to stop early
+var ErrStopIteration = errors.New("stop iteration")
+
+// Filter selects stored files; empty fields match everything
+type Filter struct {
+	Platform     string    // e.g. windows, macos, linux
+	FileType     string    // e.g. msi, pkg, deb
+	Architecture string    // e.g. x64, arm64, universal
+	Domain       string    // Website domain the file was found on
+	Since        time.Time // Only files discovered at or after this time
+	BelowScore   float64   // Only files with a lower detection score; zero disables
+	Tag          string    // Only files a detection rule gave this tag
+}
+
+// Matches reports whether file is selected by the filter
+func (f Filter) Matches(file types.ProcessedFile) bool {
+	if f.Platform != "" && !strings.EqualFold(file.Platform, f.Platform) {
+		return false
+	}
+	if f.FileType != "" && !strings.EqualFold(file.FileType, f.FileType) {
+		return false
+	}
+	if f.Architecture != "" && !strings.EqualFold(file.Architecture, f.Architecture) {
+		return false
+	}
+	if f.Domain != "" && !strings.EqualFold(file.WebsiteDomain, f.Domain) {
+		return false
+	}
+	if !f.Since.IsZero() && file.DiscoveredAt.Before(f.Since) {
+		return false
+	}
+	if f.BelowScore > 0 && file.DetectionScore >= f.BelowScore {
+		return false
+	}
+	if f.Tag != "" && !slices.ContainsFunc(file.Tags, func(tag string) bool { return strings.EqualFold(tag, f.Tag) }) {
+		return false
+	}
+	return true
+}
+
+// Opener opens a storage backend from its URI
+type Opener func(uri string) (Storage, error)
+
+var (
+	registry      = make(map[string]Opener)
+	registryMutex sync.RWMutex
+)
+
+// Register makes a storage backend available under a URI scheme. Backends
+// register themselves from init.
+func Register(scheme string, opener Opener) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	registry[strings.ToLower(scheme)] = opener
+}
+
+// Schemes returns the registered URI schemes in sorted order
+func Schemes() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	schemes := make([]string, 0, len(registry))
+	for scheme := range registry {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Open opens the storage backend selected by uri's scheme, e.g.
+// "sqlite://installers.db". A plain path picks the backend from its
+// extension and defaults to JSON.
+func Open(uri string) (Storage, error) {
+	scheme, _, found := strings.Cut(uri, "://")
+	if !found {
+		scheme = schemeForPath(uri)
+		uri = scheme + "://" + uri
+	}
+
+	registryMutex.RLock()
+	opener, ok := registry[strings.ToLower(scheme)]
+	registryMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported storage scheme %q (expected one of %s)", scheme, strings.Join(Schemes(), ", "))
+	}
+
+	return opener(uri)
+}
+
+// OpenAll opens every uri and combines them with a Tee when there is more
+// than one
+func OpenAll(uris []string) (Storage, error) {
+	if len(uris) == 0 {
+		return nil, fmt.Errorf("no storage backend configured")
+	}
+	if len(uris) == 1 {
+		return Open(uris[0])
+	}
+
+	stores := make([]Storage, 0, len(uris))
+	for _, uri := range uris {
+		store, err := Open(uri)
+		if err != nil {
+			for _, opened := range stores {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("failed to open %s: %w", uri, err)
+		}
+		stores = append(stores, store)
+	}
+	return NewTee(stores...), nil
+}
+
+// schemeForPath picks a backend for a plain file path
+func schemeForPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return "jsonl"
+	case ".csv":
+		return "csv"
+	case ".db", ".sqlite", ".sqlite3":
+		return "sqlite"
+	default:
+		return "json"
+	}
+}
+
+// location returns the path in a file backend's URI, without the scheme
+// and any query options
+func location(uri string) string {
+	if _, rest, found := strings.Cut(uri, "://"); found {
+		uri = rest
+	}
+	path, _, _ := strings.Cut(uri, "?")
+	return path
+}
+
+// uriOptions returns the query options of a file backend's URI
+func uriOptions(uri string) url.Values {
+	_, query, _ := strings.Cut(uri, "?")
+	values, _ := url.ParseQuery(query)
+	return values
+}
+
+// findFile returns the first file for which match is true
+func findFile(files []types.ProcessedFile, match func(types.ProcessedFile) bool) (types.ProcessedFile, bool) {
+	for _, file := range files {
+		if match(file) {
+			return file, true
+		}
+	}
+	return types.ProcessedFile{}, false
+}
+
+// iterateFiles calls fn for every file matching filter
+func iterateFiles(files []types.ProcessedFile, filter Filter, fn func(types.ProcessedFile) error) error {
+	for _, file := range files {
+		if !filter.Matches(file) {
+			continue
+		}
+		if err := fn(file); err != nil {
+			if errors.Is(err, ErrStopIteration) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// computeStats derives storage statistics from a list of files
+func computeStats(files []types.ProcessedFile) types.StorageStats {
+	builder := newStatsBuilder()
+	for _, file := range files {
+		builder.add(file)
+	}
+	return builder.result()
+}
+
+// statsBuilder accumulates storage statistics one file at a time, for
+// backends that stream their contents
+type statsBuilder struct {
+	stats      types.StorageStats
+	hashes     map[string]bool
+	totalScore float64
+}
+
+func newStatsBuilder() *statsBuilder {
+	return &statsBuilder{
+		stats: types.StorageStats{
+			FilesByPlatform:     make(map[string]int),
+			FilesByType:         make(map[string]int),
+			FilesByArchitecture: make(map[string]int),
+		},
+		hashes: make(map[string]bool),
+	}
+}
+
+func (b *statsBuilder) add(file types.ProcessedFile) {
+	b.stats.FilesStored++
+	b.hashes[file.SHA3Hash] = true
+	if file.Platform != "" {
+		b.stats.FilesByPlatform[file.Platform]++
+	}
+	if file.FileType != "" {
+		b.stats.FilesByType[file.FileType]++
+	}
+	if file.Architecture != "" {
+		b.stats.FilesByArchitecture[file.Architecture]++
+	}
+	if file.IsSigned {
+		b.stats.SignedInstallerCount++
+	}
+	if file.Version != "" {
+		b.stats.VersionedFileCount++
+	}
+	b.totalScore += file.DetectionScore
 }
 
-// StorageStats holds storage statistics
-type StorageStats struct {
-	FilesStored   int       `json:"files_stored"`
-	UniqueHashes  int       `json:"unique_hashes"`
-	LastUpdatedAt time.Time `json:"last_updated_at"`
-	StartTime     time.Time `json:"start_time,omitempty"`
-	EndTime       time.Time `json:"end_time,omitempty"`
-
-	// Enhanced stats
-	FilesByPlatform      map[string]int `json:"files_by_platform,omitempty"`
-	FilesByType          map[string]int `json:"files_by_type,omitempty"`
-	AvgDetectionScore    float64        `json:"avg_detection_score,omitempty"`
-	SignedInstallerCount int            `json:"signed_installer_count,omitempty"`
-	VersionedFileCount   int            `json:"versioned_file_count,omitempty"`
+func (b *statsBuilder) result() types.StorageStats {
+	stats := b.stats
+	stats.LastUpdatedAt = time.Now()
+	stats.UniqueHashes = len(b.hashes)
+	if stats.FilesStored > 0 {
+		stats.AvgDetectionScore = b.totalScore / float64(stats.FilesStored)
+	}
+	return stats
 }
@@ -0,0 +1,330 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+func TestOpenSelectsBackend(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		uri  string
+		want Storage
+	}{
+		{filepath.Join(dir, "a.json"), &JSONStorage{}},
+		{filepath.Join(dir, "b"), &JSONStorage{}},
+		{filepath.Join(dir, "c.jsonl"), &JSONLStorage{}},
+		{filepath.Join(dir, "d.NDJSON"), &JSONLStorage{}},
+		{filepath.Join(dir, "e.csv"), &CSVStorage{}},
+		{filepath.Join(dir, "f.sqlite3"), &SQLStorage{}},
+		{"jsonl://" + filepath.Join(dir, "g.txt"), &JSONLStorage{}},
+		{"SQLITE://" + filepath.Join(dir, "h.db"), &SQLStorage{}},
+		{"json://" + filepath.Join(dir, "i.json") + "?backups=2", &JSONStorage{}},
+	}
+	for _, tt := range tests {
+		store, err := Open(tt.uri)
+		if err != nil {
+			t.Errorf("Open(%q) error = %v", tt.uri, err)
+			continue
+		}
+		if reflect.TypeOf(store) != reflect.TypeOf(tt.want) {
+			t.Errorf("Open(%q) = %T, want %T", tt.uri, store, tt.want)
+		}
+		if err := store.Close(); err != nil {
+			t.Errorf("Close(%q) error = %v", tt.uri, err)
+		}
+	}
+
+	if _, err := Open("ftp://example.com/installers.json"); err == nil || !strings.Contains(err.Error(), "sqlite") {
+		t.Errorf("Open(ftp) error = %v, want the supported schemes listed", err)
+	}
+}
+
+func TestLocationAndOptions(t *testing.T) {
+	tests := []struct {
+		uri, path, backups string
+	}{
+		{"out/installers.json", "out/installers.json", ""},
+		{"json://out/installers.json?backups=3", "out/installers.json", "3"},
+		{"sqlite:///var/lib/index.db", "/var/lib/index.db", ""},
+	}
+	for _, tt := range tests {
+		if got := location(tt.uri); got != tt.path {
+			t.Errorf("location(%q) = %q, want %q", tt.uri, got, tt.path)
+		}
+		if got := uriOptions(tt.uri).Get("backups"); got != tt.backups {
+			t.Errorf("uriOptions(%q) backups = %q, want %q", tt.uri, got, tt.backups)
+		}
+	}
+}
+
+func TestOpenAllWritesEveryBackend(t *testing.T) {
+	dir := t.TempDir()
+	uris := []string{filepath.Join(dir, "index.jsonl"), filepath.Join(dir, "index.csv"), filepath.Join(dir, "index.db")}
+	store, err := OpenAll(uris)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.(*Tee); !ok {
+		t.Fatalf("OpenAll() = %T, want *Tee", store)
+	}
+	for n := 1; n <= 2; n++ {
+		if err := store.Store(sampleFile(n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, uri := range uris {
+		reopened, err := Open(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count := 0
+		if err := reopened.Iterate(Filter{}, func(types.ProcessedFile) error {
+			count++
+			return nil
+		}); err != nil || count != 2 {
+			t.Errorf("%s holds %d files (%v), want 2", uri, count, err)
+		}
+		reopened.Close()
+	}
+
+	if _, err := OpenAll(nil); err == nil {
+		t.Error("OpenAll(nil) succeeded")
+	}
+	// A backend that fails to open closes the ones already opened
+	if _, err := OpenAll([]string{filepath.Join(dir, "other.jsonl"), "ftp://x/y"}); err == nil {
+		t.Error("OpenAll() with an unsupported scheme succeeded")
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installers.csv")
+	store, err := NewCSV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := sampleFile(1)
+	file.Tags = []string{"approved", "signed"}
+	if err := store.Store(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Store(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = NewCSV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	got, ok, err := store.FindByURL(file.SourceURL)
+	if err != nil || !ok {
+		t.Fatalf("FindByURL() = %v, %v", ok, err)
+	}
+	// The CSV columns only keep the flat fields
+	want := types.ProcessedFile{
+		Filename: file.Filename, SourceURL: file.SourceURL, WebsiteDomain: file.WebsiteDomain,
+		DiscoveredAt: file.DiscoveredAt, SHA3Hash: file.SHA3Hash, FileSizeBytes: file.FileSizeBytes,
+		Platform: file.Platform, FileType: file.FileType, Architecture: file.Architecture,
+		DetectionScore: file.DetectionScore, IsInstaller: file.IsInstaller, Version: file.Version,
+		Publisher: file.Publisher, IsSigned: file.IsSigned, NormalizedVersion: file.NormalizedVersion,
+		VersionSource: file.VersionSource, VersionConfidence: file.VersionConfidence, Tags: file.Tags,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindByURL() =\n%+v\nwant\n%+v", got, want)
+	}
+	if stats := store.Stats(); stats.FilesStored != 1 {
+		t.Errorf("Stats().FilesStored = %d, want 1", stats.FilesStored)
+	}
+}
+
+func TestCSVKeepsOlderHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "installers.csv")
+	old := "filename,sha3_hash,source_url,platform\nold.exe,oldhash,https://example.com/old.exe,windows\n"
+	if err := os.WriteFile(path, []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewCSV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file, ok, _ := store.Get("oldhash"); !ok || file.Filename != "old.exe" || file.Platform != "windows" {
+		t.Errorf("Get(oldhash) = %+v, %v", file, ok)
+	}
+	if err := store.Store(sampleFile(1)); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	data, _ := os.ReadFile(path)
+	want := old + "tool-1.msi," + sampleFile(1).SHA3Hash + ",https://example.com/files/tool-1.msi,windows\n"
+	if string(data) != want {
+		t.Errorf("file =\n%s\nwant\n%s", data, want)
+	}
+}
+
+// fakeBucket is an in-memory S3 endpoint for path-style GET and PUT requests
+type fakeBucket struct {
+	objects map[string][]byte
+	auth    []string
+	mutex   sync.Mutex
+}
+
+func (b *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.auth = append(b.auth, r.Header.Get("Authorization"))
+
+	switch r.Method {
+	case http.MethodGet:
+		data, ok := b.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		b.objects[r.URL.Path] = data
+	default:
+		http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3RoundTrip(t *testing.T) {
+	bucket := &fakeBucket{objects: make(map[string][]byte)}
+	server := httptest.NewServer(bucket)
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	uri := "s3://index/nightly/installers.jsonl?endpoint=" + server.URL
+
+	store, err := Open(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Store(sampleFile(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(bucket.objects["/index/nightly/installers.jsonl"]) == 0 {
+		t.Fatalf("nothing uploaded, objects = %v", bucket.objects)
+	}
+	for _, auth := range bucket.auth {
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+			t.Errorf("Authorization = %q", auth)
+		}
+	}
+
+	store, err = Open(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, ok, err := store.Get(sampleFile(1).SHA3Hash); !ok || err != nil {
+		t.Errorf("Get() after download = %v, %v", ok, err)
+	}
+
+	if _, err := NewS3("s3://bucket-only"); err == nil {
+		t.Error("NewS3() without a key succeeded")
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	file := sampleFile(1)
+	tests := []struct {
+		filter Filter
+		want   bool
+	}{
+		{Filter{}, true},
+		{Filter{Platform: "WINDOWS", FileType: "msi", Architecture: "X64"}, true},
+		{Filter{Platform: "macos"}, false},
+		{Filter{Domain: "Example.com"}, true},
+		{Filter{Domain: "example.org"}, false},
+		{Filter{Since: file.DiscoveredAt}, true},
+		{Filter{Since: file.DiscoveredAt.Add(1)}, false},
+		{Filter{BelowScore: 0.95}, true},
+		{Filter{BelowScore: 0.9}, false},
+		{Filter{Tag: "Approved"}, true},
+		{Filter{Tag: "beta"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Matches(file); got != tt.want {
+			t.Errorf("%+v.Matches() = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestFilterAcrossBackends(t *testing.T) {
+	// Values differ in case from the filters, and the second file was
+	// found again on another site
+	files := []types.ProcessedFile{sampleFile(1), sampleFile(2), sampleFile(3)}
+	files[0].Platform, files[0].FileType, files[0].Architecture, files[0].WebsiteDomain = "Windows", "MSI", "X64", "Example.COM"
+	files[1].Platform, files[1].Architecture, files[1].WebsiteDomain = "macos", "arm64", "downloads.example.org"
+	files[2].FileType, files[2].WebsiteDomain = "exe", "example.com"
+	mirror := files[1]
+	mirror.SourceURL, mirror.WebsiteDomain = "https://mirror.example.net/tool-2.msi", "mirror.example.net"
+
+	tests := []struct {
+		filter Filter
+		want   []string
+	}{
+		{Filter{}, []string{"tool-1.msi", "tool-2.msi", "tool-3.msi"}},
+		{Filter{Platform: "windows"}, []string{"tool-1.msi", "tool-3.msi"}},
+		{Filter{Platform: "MacOS", Architecture: "ARM64"}, []string{"tool-2.msi"}},
+		{Filter{FileType: "msi"}, []string{"tool-1.msi", "tool-2.msi"}},
+		{Filter{FileType: "EXE", Architecture: "x64"}, []string{"tool-3.msi"}},
+		{Filter{Domain: "example.com"}, []string{"tool-1.msi", "tool-3.msi"}},
+		{Filter{Domain: "DOWNLOADS.example.org"}, []string{"tool-2.msi"}},
+		// Only the site a file was first found on is its website domain
+		{Filter{Domain: "mirror.example.net"}, nil},
+	}
+
+	dir := t.TempDir()
+	for _, uri := range []string{"index.json", "index.jsonl", "index.csv", "index.db"} {
+		store, err := Open(filepath.Join(dir, uri))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range append(files, mirror) {
+			if err := store.Store(file); err != nil {
+				t.Fatalf("%s: Store() error = %v", uri, err)
+			}
+		}
+		for _, tt := range tests {
+			var got []string
+			if err := store.Iterate(tt.filter, func(file types.ProcessedFile) error {
+				got = append(got, file.Filename)
+				return nil
+			}); err != nil {
+				t.Fatalf("%s: Iterate() error = %v", uri, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: Iterate(%+v) = %v, want %v", uri, tt.filter, got, tt.want)
+			}
+		}
+		store.Close()
+	}
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+)
+
+// Tee implements the Storage interface by writing to several backends at
+// once. Reads and stats are served by the first backend.
+type Tee struct {
+	stores []Storage
+}
+
+// NewTee creates a Tee over stores; the first is the primary for reads
+func NewTee(stores ...Storage) *Tee {
+	return &Tee{stores: stores}
+}
+
+// Store saves a processed file's metadata to every backend. A failing
+// backend does not stop the others from receiving the file.
+func (t *Tee) Store(file types.ProcessedFile) error {
+	var errs []error
+	for i, store := range t.stores {
+		if err := store.Store(file); err != nil {
+			errs = append(errs, fmt.Errorf("storage %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every backend
+func (t *Tee) Close() error {
+	var errs []error
+	for i, store := range t.stores {
+		if err := store.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("storage %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RecordRun adds the run to every backend that keeps a run history
+func (t *Tee) RecordRun(run types.RunRecord) error {
+	var errs []error
+	for i, store := range t.stores {
+		if recorder, ok := store.(RunRecorder); ok {
+			if err := recorder.RecordRun(run); err != nil {
+				errs = append(errs, fmt.Errorf("storage %d: %w", i, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stats returns the primary backend's statistics
+func (t *Tee) Stats() types.StorageStats {
+	return t.stores[0].Stats()
+}
+
+// Contains reports whether the primary backend has the file
+func (t *Tee) Contains(hash, url string) (bool, error) {
+	return IsStored(t.stores[0], hash, url)
+}
+
+// Get returns the file with the given SHA3 hash from the primary backend
+func (t *Tee) Get(hash string) (types.ProcessedFile, bool, error) {
+	return t.stores[0].Get(hash)
+}
+
+// FindByURL returns the file downloaded from the given URL from the primary backend
+func (t *Tee) FindByURL(url string) (types.ProcessedFile, bool, error) {
+	return t.stores[0].FindByURL(url)
+}
+
+// Iterate iterates over the primary backend
+func (t *Tee) Iterate(filter Filter, fn func(types.ProcessedFile) error) error {
+	return t.stores[0].Iterate(filter, fn)
+}
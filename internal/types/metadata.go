@@ -0,0 +1,88 @@
+package types
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MetadataString returns an extended metadata value as a string. Numbers
+// decoded from JSON are formatted without a fraction.
+func (f ProcessedFile) MetadataString(key string) string {
+	switch v := f.ExtendedMetadata[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// MetadataStrings returns the non-empty values of an extended metadata
+// list, as stored by an analyzer or decoded from JSON
+func (f ProcessedFile) MetadataStrings(key string) []string {
+	var values []string
+	switch v := f.ExtendedMetadata[key].(type) {
+	case []string:
+		values = v
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var nonEmpty []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			nonEmpty = append(nonEmpty, value)
+		}
+	}
+	return nonEmpty
+}
+
+// SHA256 returns the file's SHA-256 digest as lowercase hex, or "" if the
+// analyzers did not record one. Analyzers store it as hex, or as raw bytes
+// that decode from JSON as base64.
+func (f ProcessedFile) SHA256() string {
+	switch v := f.ExtendedMetadata["sha256"].(type) {
+	case string:
+		if len(v) == 64 {
+			if _, err := hex.DecodeString(v); err == nil {
+				return strings.ToLower(v)
+			}
+		}
+		if raw, err := base64.StdEncoding.DecodeString(v); err == nil && len(raw) == 32 {
+			return hex.EncodeToString(raw)
+		}
+	case []byte:
+		if len(v) == 32 {
+			return hex.EncodeToString(v)
+		}
+	}
+	return ""
+}
+
+// legalSuffixes are legal-entity words such as "Inc" or "GmbH" that
+// publisher names are compared without
+var legalSuffixes = map[string]bool{
+	"inc": true, "llc": true, "ltd": true, "limited": true, "corp": true,
+	"corporation": true, "co": true, "gmbh": true, "ag": true, "sa": true,
+	"bv": true, "plc": true, "pty": true,
+}
+
+// TrimLegalSuffixes drops trailing empty and legal-entity words from a
+// publisher name split into words, so "Acme, Inc." reduces to "Acme".
+// Words are compared case-insensitively.
+func TrimLegalSuffixes(words []string) []string {
+	for len(words) > 0 && (words[len(words)-1] == "" || legalSuffixes[strings.ToLower(words[len(words)-1])]) {
+		words = words[:len(words)-1]
+	}
+	return words
+}
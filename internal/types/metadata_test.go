@@ -0,0 +1,99 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMetadataString(t *testing.T) {
+	file := ProcessedFile{ExtendedMetadata: map[string]interface{}{
+		"name":    "  Tool  ",
+		"size":    float64(1048576),
+		"ratio":   0.5,
+		"enabled": true,
+		"missing": nil,
+	}}
+	tests := map[string]string{
+		"name":    "Tool",
+		"size":    "1048576",
+		"ratio":   "0.5",
+		"enabled": "true",
+		"missing": "",
+		"absent":  "",
+	}
+	for key, want := range tests {
+		if got := file.MetadataString(key); got != want {
+			t.Errorf("MetadataString(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestMetadataStrings(t *testing.T) {
+	file := ProcessedFile{ExtendedMetadata: map[string]interface{}{
+		"analyzed": []string{"a", " ", " b "},
+		"decoded":  []interface{}{"c", 1.0, "", "d"},
+		"scalar":   "e",
+	}}
+	tests := map[string][]string{
+		"analyzed": {"a", "b"},
+		"decoded":  {"c", "d"},
+		"scalar":   nil,
+		"absent":   nil,
+	}
+	for key, want := range tests {
+		if got := file.MetadataStrings(key); !reflect.DeepEqual(got, want) {
+			t.Errorf("MetadataStrings(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestSHA256(t *testing.T) {
+	const digest = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	raw := []byte{
+		0x9f, 0x86, 0xd0, 0x81, 0x88, 0x4c, 0x7d, 0x65, 0x9a, 0x2f, 0xea, 0xa0, 0xc5, 0x5a, 0xd0, 0x15,
+		0xa3, 0xbf, 0x4f, 0x1b, 0x2b, 0x0b, 0x82, 0x2c, 0xd1, 0x5d, 0x6c, 0x15, 0xb0, 0xf0, 0x0a, 0x08,
+	}
+	// Raw bytes round-trip through JSON as base64
+	encoded, _ := json.Marshal(raw)
+	var base64 string
+	json.Unmarshal(encoded, &base64)
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"hex", digest, digest},
+		{"upper hex", "9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08", digest},
+		{"bytes", raw, digest},
+		{"base64", base64, digest},
+		{"short bytes", raw[:16], ""},
+		{"not a digest", "not-a-digest", ""},
+		{"missing", nil, ""},
+	}
+	for _, tt := range tests {
+		file := ProcessedFile{ExtendedMetadata: map[string]interface{}{"sha256": tt.value}}
+		if got := file.SHA256(); got != tt.want {
+			t.Errorf("%s: SHA256() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTrimLegalSuffixes(t *testing.T) {
+	tests := []struct {
+		words, want []string
+	}{
+		{[]string{"Acme", "Inc", ""}, []string{"Acme"}},
+		{[]string{"Example", "Software", "GmbH"}, []string{"Example", "Software"}},
+		{[]string{"acme", "co", "LTD"}, []string{"acme"}},
+		{[]string{"Inc", "Tools"}, []string{"Inc", "Tools"}},
+		{[]string{"Corp"}, []string{}},
+		{nil, nil},
+	}
+	for _, tt := range tests {
+		if got := TrimLegalSuffixes(tt.words); len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("TrimLegalSuffixes(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
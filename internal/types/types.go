@@ -15,6 +15,7 @@ type ProcessedFile struct {
 	FileSizeBytes int64     `json:"file_size_bytes"`
 	Platform      string    `json:"platform"`
 	FileType      string    `json:"file_type"`
+	Architecture  string    `json:"architecture,omitempty"` // x86, x64, arm64, arm, universal or noarch
 
 	// Enhanced fields
 	DetectionScore   float64                `json:"detection_score,omitempty"`
@@ -23,6 +24,88 @@ type ProcessedFile struct {
 	Publisher        string                 `json:"publisher,omitempty"`
 	IsSigned         bool                   `json:"is_signed,omitempty"`
 	ExtendedMetadata map[string]interface{} `json:"extended_metadata,omitempty"`
+
+	// Each confidence factor's contribution to DetectionScore, such as
+	// {"base": 0.3, "magic_match": 0.2, "parsed_structure": 0.25}
+	ConfidenceFactors map[string]float64 `json:"confidence_factors,omitempty"`
+
+	// Normalized version, where it was taken from (feed, package, bundle,
+	// resource, filename or content) and how far it is trusted (0.0-1.0)
+	NormalizedVersion string  `json:"normalized_version,omitempty"`
+	VersionSource     string  `json:"version_source,omitempty"`
+	VersionConfidence float64 `json:"version_confidence,omitempty"`
+
+	// Release metadata supplied by a vendor feed, if the file was discovered through one
+	Release *ReleaseInfo `json:"release,omitempty"`
+
+	// Which analyzer supplied each analyzed field, and the fields analyzers
+	// disagreed on
+	Provenance map[string]FieldSource `json:"provenance,omitempty"`
+	Conflicts  []FieldConflict        `json:"conflicts,omitempty"`
+
+	// Detection rules that matched the file and the tags they applied
+	MatchedRules []string `json:"matched_rules,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+
+	// Known vulnerabilities of the installed version, from an offline
+	// advisory database
+	Vulnerabilities []Vulnerability `json:"vulnerabilities,omitempty"`
+}
+
+// Vulnerability is an advisory that affects a file's version
+type Vulnerability struct {
+	ID        string   `json:"id"`                 // Advisory ID, such as CVE-2022-37434 or DSA-5218-1
+	Aliases   []string `json:"aliases,omitempty"`  // Other IDs of the same vulnerability
+	Summary   string   `json:"summary,omitempty"`  // Short description
+	Severity  string   `json:"severity"`           // critical, high, medium, low or unknown
+	Score     float64  `json:"score,omitempty"`    // CVSS base score, if known
+	FixedIn   string   `json:"fixed_in,omitempty"` // First version without the vulnerability, if known
+	Source    string   `json:"source"`             // Advisory format: osv or nvd
+	MatchedBy string   `json:"matched_by"`         // Package URL or CPE the advisory matched
+}
+
+// FieldSource records the analyzer that supplied a field and its confidence
+type FieldSource struct {
+	Analyzer   string  `json:"analyzer"`
+	Confidence float64 `json:"confidence"`
+}
+
+// FieldConflict records a field that analyzers reported different values
+// for. The first value is the one that was kept.
+type FieldConflict struct {
+	Field  string       `json:"field"`
+	Values []FieldValue `json:"values"`
+}
+
+// FieldValue is one analyzer's value for a conflicting field
+type FieldValue struct {
+	Value interface{} `json:"value"`
+	FieldSource
+}
+
+// ReleaseInfo holds release metadata published by a vendor feed
+type ReleaseInfo struct {
+	Source       string     `json:"source"`                   // Feed kind (github, appcast, rss, atom, nuget)
+	FeedURL      string     `json:"feed_url,omitempty"`       // URL of the feed the release was read from
+	Title        string     `json:"title,omitempty"`          // Release title
+	Version      string     `json:"version,omitempty"`        // Human readable version
+	Build        string     `json:"build,omitempty"`          // Build number (e.g. sparkle:version)
+	ReleaseDate  *time.Time `json:"release_date,omitempty"`   // Publication date
+	Notes        string     `json:"notes,omitempty"`          // Release notes body
+	NotesURL     string     `json:"notes_url,omitempty"`      // Link to release notes
+	MinOSVersion string     `json:"min_os_version,omitempty"` // Minimum supported OS version
+	Signature    string     `json:"signature,omitempty"`      // Feed-provided signature (e.g. Sparkle edSignature)
+	Length       int64      `json:"length,omitempty"`         // Advertised download size in bytes
+	Prerelease   bool       `json:"prerelease,omitempty"`     // Whether the release is marked as a prerelease
+}
+
+// DownloadRequest is a URL queued for download together with anything
+// already known about it
+type DownloadRequest struct {
+	URL      string
+	FileName string       // Optional file name hint when the URL does not carry one
+	Release  *ReleaseInfo // Optional feed metadata
+	Detected bool         // Recognised as an installer from the response, whatever its name
 }
 
 // StorageStats holds storage statistics
@@ -36,7 +119,28 @@ type StorageStats struct {
 	// Enhanced stats
 	FilesByPlatform      map[string]int `json:"files_by_platform,omitempty"`
 	FilesByType          map[string]int `json:"files_by_type,omitempty"`
+	FilesByArchitecture  map[string]int `json:"files_by_architecture,omitempty"`
 	AvgDetectionScore    float64        `json:"avg_detection_score,omitempty"`
 	SignedInstallerCount int            `json:"signed_installer_count,omitempty"`
 	VersionedFileCount   int            `json:"versioned_file_count,omitempty"`
 }
+
+// RunRecord summarizes one scraper run for the index's run history
+type RunRecord struct {
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	StartURL    string    `json:"start_url,omitempty"`
+	Sources     []string  `json:"sources,omitempty"`
+	ConfigHash  string    `json:"config_hash"`           // SHA-256 of the effective configuration
+	Interrupted bool      `json:"interrupted,omitempty"` // Stopped by a signal before finishing
+
+	URLsVisited     int   `json:"urls_visited"`
+	URLsSkipped     int   `json:"urls_skipped"`
+	FilesFound      int   `json:"files_found"`
+	FilesDownloaded int   `json:"files_downloaded"`
+	FilesProcessed  int   `json:"files_processed"`
+	BytesDownloaded int64 `json:"bytes_downloaded"`
+	Errors          int   `json:"errors"`
+	FilesNew        int   `json:"files_new"`      // Processed files not already in the index
+	FilesExisting   int   `json:"files_existing"` // Processed files the index already had
+}
@@ -0,0 +1,132 @@
+package urlutil
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+// MatchDomain reports whether host matches a domain glob such as
+// "example.com", "*.example.com" or "*". Matching is case-insensitive and
+// ignores any port on host.
+func MatchDomain(pattern, host string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	host = strings.ToLower(Hostname(host))
+
+	if pattern == "" {
+		return false
+	}
+	if pattern == "*" || pattern == host {
+		return true
+	}
+
+	matched, err := path.Match(pattern, host)
+	return err == nil && matched
+}
+
+// Hostname strips any port from a host[:port] string
+func Hostname(host string) string {
+	if u, err := url.Parse("//" + host); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return host
+}
+
+// Host returns the lower-cased hostname of a URL, or "" if it cannot be parsed
+func Host(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// trackingParams are query parameters that identify a campaign or click
+// rather than a resource. utm_* parameters are matched by prefix.
+var trackingParams = map[string]bool{
+	"gclid":   true,
+	"dclid":   true,
+	"fbclid":  true,
+	"msclkid": true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_ga":     true,
+	"_gl":     true,
+	"ref_src": true,
+}
+
+// Normalize returns a canonical form of an absolute URL for deduplication:
+// the scheme and host are lower-cased, default ports, fragments and
+// tracking parameters are removed, an empty path becomes "/" and the
+// remaining query parameters are sorted
+func Normalize(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			lower := strings.ToLower(key)
+			if strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+				query.Del(key)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
+
+// Set is a concurrency-safe set of URLs, compared in normalized form. It
+// lets every producer feeding the download queue share one record of
+// what has been queued.
+type Set struct {
+	urls  map[string]bool
+	mutex sync.Mutex
+}
+
+// NewSet creates an empty URL set
+func NewSet() *Set {
+	return &Set{urls: make(map[string]bool)}
+}
+
+// Add records a URL and reports whether it was not already in the set.
+// URLs that cannot be normalized are compared as given.
+func (s *Set) Add(rawURL string) bool {
+	key, err := Normalize(rawURL)
+	if err != nil {
+		key = rawURL
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.urls[key] {
+		return false
+	}
+	s.urls[key] = true
+	return true
+}
@@ -0,0 +1,106 @@
+package urlutil
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://Example.COM/Download/App.exe", "https://example.com/Download/App.exe"},
+		{"HTTPS://example.com", "https://example.com/"},
+		{"http://example.com:80/a", "http://example.com/a"},
+		{"https://example.com:443/a", "https://example.com/a"},
+		{"https://example.com:8443/a", "https://example.com:8443/a"},
+		{"http://example.com:443/a", "http://example.com:443/a"},
+		{"https://example.com/a#section", "https://example.com/a"},
+		{"https://example.com/a?", "https://example.com/a"},
+		{"https://example.com/a?b=2&a=1", "https://example.com/a?a=1&b=2"},
+		{"https://example.com/a?utm_source=x&UTM_Medium=y&id=3", "https://example.com/a?id=3"},
+		{"https://example.com/a?gclid=1&fbclid=2&_ga=3", "https://example.com/a"},
+		{"https://[2001:DB8::1]:443/a", "https://[2001:db8::1]/a"},
+		{"  https://example.com/a  ", "https://example.com/a"},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.in)
+		if err != nil {
+			t.Errorf("Normalize(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if _, err := Normalize("http://[::1"); err == nil {
+		t.Error("Normalize of an unparseable URL succeeded")
+	}
+}
+
+func TestMatchDomain(t *testing.T) {
+	tests := []struct {
+		pattern, host string
+		want          bool
+	}{
+		{"example.com", "example.com", true},
+		{"example.com", "EXAMPLE.com:8080", true},
+		{"*.example.com", "dl.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*", "anything.test", true},
+		{"", "example.com", false},
+		{"example.com", "example.org", false},
+	}
+	for _, tt := range tests {
+		if got := MatchDomain(tt.pattern, tt.host); got != tt.want {
+			t.Errorf("MatchDomain(%q, %q) = %v, want %v", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	s := NewSet()
+	steps := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/app.exe", true},
+		{"https://EXAMPLE.com:443/app.exe#top", false},
+		{"https://example.com/app.exe?utm_source=feed", false},
+		{"https://example.com/app.msi", true},
+		{"http://example.com/app.exe", true},
+		{"http://[::1", true},
+		{"http://[::1", false},
+	}
+	for _, step := range steps {
+		if got := s.Add(step.url); got != step.want {
+			t.Errorf("Add(%q) = %v, want %v", step.url, got, step.want)
+		}
+	}
+}
+
+func TestSetConcurrent(t *testing.T) {
+	s := NewSet()
+	var wg sync.WaitGroup
+	added := make(chan bool, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			added <- s.Add("https://example.com/app.exe")
+		}()
+	}
+	wg.Wait()
+	close(added)
+
+	count := 0
+	for ok := range added {
+		if ok {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("%d concurrent Adds reported a new URL, want 1", count)
+	}
+}
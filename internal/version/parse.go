@@ -0,0 +1,218 @@
+package version
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Kind is the format a version string was recognized as
+type Kind string
+
+const (
+	KindWindows Kind = "windows" // Four-part numeric, as in PE version resources
+	KindSemver  Kind = "semver"  // major.minor.patch with optional prerelease and build
+	KindDebian  Kind = "debian"  // dpkg [epoch:]upstream[-revision]
+	KindRPM     Kind = "rpm"     // RPM [epoch:]version[-release]
+	KindApple   Kind = "apple"   // CFBundleShortVersionString, with CFBundleVersion as the build
+	KindDate    Kind = "date"    // Calendar versions such as 2024.05.01 or 20240501
+	KindGeneric Kind = "generic" // Anything else that starts with a number
+)
+
+// Version is a parsed version string
+type Version struct {
+	Raw        string // As found
+	Normalized string // Canonical form, comparable under Scheme
+	Kind       Kind
+	Build      string // Build number reported alongside the version, if any
+}
+
+// Scheme returns the ordering that applies to the version
+func (v Version) Scheme() Scheme {
+	switch v.Kind {
+	case KindDebian:
+		return Debian
+	case KindRPM:
+		return RPM
+	default:
+		return Generic
+	}
+}
+
+// Source says where a version string was found
+type Source string
+
+const (
+	SourceFeed     Source = "feed"     // Vendor release feed
+	SourcePackage  Source = "package"  // Package metadata: MSI Property table, deb control, RPM header, PackageInfo
+	SourceBundle   Source = "bundle"   // Apple Info.plist
+	SourceResource Source = "resource" // PE version resource
+	SourceFilename Source = "filename" // The download's filename
+	SourceContent  Source = "content"  // A pattern found in the file's contents
+)
+
+// sourceConfidence is how far a version from each source is trusted
+var sourceConfidence = map[Source]float64{
+	SourceFeed:     0.95,
+	SourcePackage:  0.9,
+	SourceBundle:   0.9,
+	SourceResource: 0.85,
+	SourceFilename: 0.6,
+	SourceContent:  0.3,
+}
+
+// Confidence scores a version read from source between 0 and 1. Versions
+// in no recognized format are trusted less.
+func Confidence(source Source, v Version) float64 {
+	confidence, ok := sourceConfidence[source]
+	if !ok {
+		confidence = sourceConfidence[SourceContent]
+	}
+	if v.Kind == KindGeneric {
+		confidence -= 0.1
+	}
+	return confidence
+}
+
+// maxLength bounds the length of a version string
+const maxLength = 64
+
+var (
+	versionPrefix  = regexp.MustCompile(`(?i)^(version|ver\.?)\s*`)
+	commaSeparated = regexp.MustCompile(`^\d+(\s*,\s*\d+){1,3}$`)
+	datePattern    = regexp.MustCompile(`^((?:19|20)\d{2})([-._]?)(0[1-9]|1[0-2])([-._]?)(0[1-9]|[12]\d|3[01])$`)
+	windowsPattern = regexp.MustCompile(`^\d+\.\d+\.\d+\.\d+$`)
+	semverPattern  = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+	genericPattern = regexp.MustCompile(`^\d+(\.\d+)*[0-9A-Za-z.+~-]*$`)
+	debianPattern  = regexp.MustCompile(`^(\d+:)?\d[0-9A-Za-z.+~:-]*$`)
+	rpmPattern     = regexp.MustCompile(`^(\d+:)?[0-9A-Za-z._+~^]+(-[0-9A-Za-z._+~^]+)?$`)
+)
+
+// Parse recognizes a date, Windows four-part, semantic or generic version.
+// A leading "v" or "Version" is dropped, as are the spaces in the
+// comma-separated form some version resources use ("1, 2, 0, 4").
+func Parse(raw string) (Version, bool) {
+	s := clean(raw)
+	if s == "" || len(s) > maxLength {
+		return Version{}, false
+	}
+	if commaSeparated.MatchString(s) {
+		s = strings.Join(strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }), ".")
+	}
+
+	switch {
+	case datePattern.MatchString(s):
+		m := datePattern.FindStringSubmatch(s)
+		// Separators must match, so 2024-0501 is not a date
+		if m[2] == m[4] {
+			return Version{Raw: raw, Normalized: m[1] + "." + m[3] + "." + m[5], Kind: KindDate}, true
+		}
+	case windowsPattern.MatchString(s):
+		return Version{Raw: raw, Normalized: trimZeros(s), Kind: KindWindows}, true
+	case semverPattern.MatchString(s):
+		return Version{Raw: raw, Normalized: s, Kind: KindSemver}, true
+	}
+
+	if genericPattern.MatchString(s) {
+		return Version{Raw: raw, Normalized: s, Kind: KindGeneric}, true
+	}
+	return Version{}, false
+}
+
+// ParseDebian parses a dpkg version. A zero epoch is dropped.
+func ParseDebian(raw string) (Version, bool) {
+	s := strings.TrimSpace(raw)
+	if len(s) > maxLength || !debianPattern.MatchString(s) {
+		return Version{}, false
+	}
+	return Version{Raw: raw, Normalized: strings.TrimPrefix(s, "0:"), Kind: KindDebian}, true
+}
+
+// ParseRPM parses an RPM [epoch:]version[-release] string. A zero epoch is
+// dropped.
+func ParseRPM(raw string) (Version, bool) {
+	s := strings.TrimSpace(raw)
+	if len(s) > maxLength || !rpmPattern.MatchString(s) {
+		return Version{}, false
+	}
+	return Version{Raw: raw, Normalized: strings.TrimPrefix(s, "0:"), Kind: KindRPM}, true
+}
+
+// ParseApple parses an app's CFBundleShortVersionString, keeping its
+// CFBundleVersion as the build. Bundles without a usable short version
+// fall back to the bundle version, which is often a plain build counter.
+func ParseApple(shortVersion, bundleVersion string) (Version, bool) {
+	short, shortOK := Parse(shortVersion)
+	build := strings.TrimSpace(bundleVersion)
+	if shortOK {
+		v := Version{Raw: shortVersion, Normalized: short.Normalized, Kind: KindApple}
+		if build != short.Normalized && build != strings.TrimSpace(shortVersion) {
+			v.Build = build
+		}
+		return v, true
+	}
+
+	if bundle, ok := Parse(bundleVersion); ok {
+		return Version{Raw: bundleVersion, Normalized: bundle.Normalized, Kind: KindApple}, true
+	}
+	return Version{}, false
+}
+
+var (
+	archiveExtension = regexp.MustCompile(`(?i)(\.tar)?\.[a-z][a-z0-9]{0,7}$`)
+	filenameVersion  = regexp.MustCompile(`(?:^|[^0-9A-Za-z])[vV]?(\d+(?:\.\d+)+(?:[-.~]?(?:alpha|beta|rc|pre|preview|a|b)\.?\d*)?)(?:$|[^0-9A-Za-z.])`)
+	filenameDate     = regexp.MustCompile(`(?:^|[^0-9A-Za-z])((?:19|20)\d{2}[-.]?(?:0[1-9]|1[0-2])[-.]?(?:0[1-9]|[12]\d|3[01]))(?:$|[^0-9A-Za-z])`)
+)
+
+// FromFilename finds the version in a download's filename, such as 1.7.4 in
+// terraform_1.7.4_linux_amd64.zip. Only dotted and date versions are
+// recognized; a bare number is too likely to be a build or architecture.
+func FromFilename(filename string) (Version, bool) {
+	stem := archiveExtension.ReplaceAllString(path.Base(filename), "")
+	for _, pattern := range []*regexp.Regexp{filenameVersion, filenameDate} {
+		if m := pattern.FindStringSubmatch(stem); m != nil {
+			return Parse(m[1])
+		}
+	}
+	return Version{}, false
+}
+
+// textPatterns find versions in file contents, most specific first. A "v"
+// prefix only counts at the start of a word, so TLSv1.2 is not a version.
+var textPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\bversion[\s="':]+v?(\d+\.\d+(?:\.\d+){0,2})\b`),
+	regexp.MustCompile(`(?i)(?:^|[^a-z0-9])v(\d+\.\d+(?:\.\d+){0,2})\b`),
+	regexp.MustCompile(`(?:^|[^0-9.])(\d+\.\d+\.\d+(?:\.\d+)?)(?:$|[^0-9.])`),
+}
+
+// FromText finds a version string in file contents
+func FromText(content []byte) (Version, bool) {
+	for _, pattern := range textPatterns {
+		if m := pattern.FindSubmatch(content); m != nil {
+			return Parse(string(m[1]))
+		}
+	}
+	return Version{}, false
+}
+
+// clean trims a version and drops "v" and "Version" prefixes
+func clean(raw string) string {
+	s := strings.TrimSpace(raw)
+	s = versionPrefix.ReplaceAllString(s, "")
+	if len(s) > 1 && (s[0] == 'v' || s[0] == 'V') && isDigit(s[1]) {
+		s = s[1:]
+	}
+	return s
+}
+
+// trimZeros drops leading zeros from each dotted component
+func trimZeros(s string) string {
+	parts := strings.Split(s, ".")
+	for i, part := range parts {
+		if n, err := strconv.ParseUint(part, 10, 64); err == nil {
+			parts[i] = strconv.FormatUint(n, 10)
+		}
+	}
+	return strings.Join(parts, ".")
+}
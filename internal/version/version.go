@@ -0,0 +1,422 @@
+package version
+
+import (
+	"strings"
+)
+
+// Scheme identifies a version ordering
+type Scheme string
+
+const (
+	// Generic orders semantic versions and dotted numeric versions such as
+	// Windows' four-part file versions
+	Generic Scheme = "generic"
+	// Debian orders dpkg versions ([epoch:]upstream[-revision])
+	Debian Scheme = "debian"
+	// RPM orders RPM epoch:version-release strings
+	RPM Scheme = "rpm"
+)
+
+// Compare returns -1, 0 or +1 as a is older than, equal to or newer than b
+// under scheme. Unknown schemes use Generic.
+func Compare(scheme Scheme, a, b string) int {
+	switch scheme {
+	case Debian:
+		return CompareDebian(a, b)
+	case RPM:
+		return CompareRPM(a, b)
+	default:
+		return CompareGeneric(a, b)
+	}
+}
+
+// prereleaseTags are words that mark a version as coming before the
+// release it is attached to, as in 2.0b3 or 1.4rc1
+var prereleaseTags = map[string]bool{
+	"a": true, "alpha": true, "b": true, "beta": true, "rc": true,
+	"pre": true, "preview": true, "dev": true, "snapshot": true,
+}
+
+// CompareGeneric compares semantic versions and dotted versions of any
+// length. A leading "v" and "+build" metadata are ignored, missing
+// components count as zero (so 1.2 equals 1.2.0.0), and a "-prerelease"
+// suffix or a trailing tag like "b2" sorts before the release.
+func CompareGeneric(a, b string) int {
+	aCore, aPre := splitGeneric(a)
+	bCore, bPre := splitGeneric(b)
+
+	aParts := strings.Split(aCore, ".")
+	bParts := strings.Split(bCore, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		aPart, bPart := "0", "0"
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+		if c := compareComponent(aPart, bPart); c != 0 {
+			return c
+		}
+	}
+
+	return comparePrerelease(aPre, bPre)
+}
+
+// splitGeneric trims a generic version and splits it into its dotted core
+// and prerelease
+func splitGeneric(v string) (string, string) {
+	v = strings.TrimSpace(v)
+	if len(v) > 1 && (v[0] == 'v' || v[0] == 'V') && isDigit(v[1]) {
+		v = v[1:]
+	}
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		return v[:i], v[i+1:]
+	}
+	return v, ""
+}
+
+// compareComponent compares one dotted component by its alternating runs
+// of digits and letters. Digits compare numerically and letters
+// lexically; a component that runs out first is older unless the other
+// continues with a prerelease tag.
+func compareComponent(a, b string) int {
+	for {
+		if a == "" || b == "" {
+			switch {
+			case a == "" && b == "":
+				return 0
+			case a == "":
+				return -componentTail(b)
+			default:
+				return componentTail(a)
+			}
+		}
+
+		var aRun, bRun string
+		aRun, a = nextRun(a)
+		bRun, b = nextRun(b)
+
+		aNum, bNum := isDigit(aRun[0]), isDigit(bRun[0])
+		switch {
+		case aNum && bNum:
+			if c := compareNumeric(aRun, bRun); c != 0 {
+				return c
+			}
+		case aNum:
+			return 1
+		case bNum:
+			return -1
+		default:
+			if c := strings.Compare(strings.ToLower(aRun), strings.ToLower(bRun)); c != 0 {
+				return c
+			}
+		}
+	}
+}
+
+// componentTail reports how the remainder of a component compares with
+// nothing: -1 for a prerelease tag, +1 for anything else
+func componentTail(rest string) int {
+	run, _ := nextRun(rest)
+	if prereleaseTags[strings.ToLower(run)] {
+		return -1
+	}
+	return 1
+}
+
+// nextRun splits off the leading run of digits or non-digits
+func nextRun(s string) (string, string) {
+	digit := isDigit(s[0])
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digit {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// comparePrerelease compares semver prerelease strings; no prerelease is
+// newer than any prerelease
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	aIDs := strings.Split(a, ".")
+	bIDs := strings.Split(b, ".")
+	for i := 0; i < len(aIDs) && i < len(bIDs); i++ {
+		aNum, bNum := isNumeric(aIDs[i]), isNumeric(bIDs[i])
+		var c int
+		switch {
+		case aNum && bNum:
+			c = compareNumeric(aIDs[i], bIDs[i])
+		case aNum:
+			c = -1
+		case bNum:
+			c = 1
+		default:
+			c = strings.Compare(aIDs[i], bIDs[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(aIDs), len(bIDs))
+}
+
+// CompareDebian compares versions the way dpkg does: by epoch, then the
+// upstream version, then the Debian revision. A tilde sorts before
+// anything, so 1.0~rc1 is older than 1.0.
+func CompareDebian(a, b string) int {
+	aEpoch, aUpstream, aRevision := splitEVR(a)
+	bEpoch, bUpstream, bRevision := splitEVR(b)
+
+	if c := compareNumeric(aEpoch, bEpoch); c != 0 {
+		return c
+	}
+	if c := debianCompare(aUpstream, bUpstream); c != 0 {
+		return c
+	}
+	return debianCompare(aRevision, bRevision)
+}
+
+// debianCompare is dpkg's verrevcmp
+func debianCompare(a, b string) int {
+	for a != "" || b != "" {
+		// Compare the non-digit prefixes character by character
+		for (a != "" && !isDigit(a[0])) || (b != "" && !isDigit(b[0])) {
+			ac, bc := debianOrder(a), debianOrder(b)
+			if ac != bc {
+				return compareInts(ac, bc)
+			}
+			a, b = a[1:], b[1:]
+		}
+
+		var aDigits, bDigits string
+		aDigits, a = leadingDigits(a)
+		bDigits, b = leadingDigits(b)
+		if c := compareNumeric(aDigits, bDigits); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// debianOrder weights the first character of s: the end of the string and
+// digits are 0, tilde sorts first, letters before other characters
+func debianOrder(s string) int {
+	if s == "" {
+		return 0
+	}
+	c := s[0]
+	switch {
+	case isDigit(c):
+		return 0
+	case isLetter(c):
+		return int(c)
+	case c == '~':
+		return -1
+	default:
+		return int(c) + 256
+	}
+}
+
+// CompareRPM compares epoch:version-release strings the way rpm does
+func CompareRPM(a, b string) int {
+	aEpoch, aVersion, aRelease := splitEVR(a)
+	bEpoch, bVersion, bRelease := splitEVR(b)
+
+	if c := compareNumeric(aEpoch, bEpoch); c != 0 {
+		return c
+	}
+	if c := rpmCompare(aVersion, bVersion); c != 0 {
+		return c
+	}
+	// A missing release matches any release
+	if aRelease == "" || bRelease == "" {
+		return 0
+	}
+	return rpmCompare(aRelease, bRelease)
+}
+
+// RPMEVR assembles an RPM [epoch:]version[-release] string, leaving out a
+// zero or empty epoch and an empty release
+func RPMEVR(epoch, version, release string) string {
+	evr := version
+	if epoch != "" && epoch != "0" {
+		evr = epoch + ":" + evr
+	}
+	if release != "" {
+		evr += "-" + release
+	}
+	return evr
+}
+
+// rpmCompare is rpm's rpmvercmp. Separators only split segments; tilde
+// sorts before everything and caret after the base version but before
+// anything longer.
+func rpmCompare(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	for a != "" || b != "" {
+		a = strings.TrimLeftFunc(a, isRPMSeparator)
+		b = strings.TrimLeftFunc(b, isRPMSeparator)
+
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			switch {
+			case a == "":
+				return -1
+			case b == "":
+				return 1
+			case !strings.HasPrefix(a, "^"):
+				return 1
+			case !strings.HasPrefix(b, "^"):
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		if a == "" || b == "" {
+			break
+		}
+
+		var aSeg, bSeg string
+		numeric := isDigit(a[0])
+		if numeric {
+			aSeg, a = leadingDigits(a)
+			bSeg, b = leadingDigits(b)
+		} else {
+			aSeg, a = leadingLetters(a)
+			bSeg, b = leadingLetters(b)
+		}
+
+		// Segments of different kinds: numeric is newer
+		if bSeg == "" {
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+
+		var c int
+		if numeric {
+			c = compareNumeric(aSeg, bSeg)
+		} else {
+			c = strings.Compare(aSeg, bSeg)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	default:
+		return 1
+	}
+}
+
+// isRPMSeparator reports whether r only separates segments, which is
+// anything but an ASCII letter or digit, tilde or caret
+func isRPMSeparator(r rune) bool {
+	if r < 128 && (isDigit(byte(r)) || isLetter(byte(r))) {
+		return false
+	}
+	return r != '~' && r != '^'
+}
+
+// splitEVR splits [epoch:]version[-release] into its parts, with a
+// missing epoch reading as 0
+func splitEVR(v string) (epoch, version, release string) {
+	v = strings.TrimSpace(v)
+	epoch = "0"
+	if i := strings.IndexByte(v, ':'); i >= 0 && isNumeric(v[:i]) {
+		epoch, v = v[:i], v[i+1:]
+	}
+	if i := strings.LastIndexByte(v, '-'); i >= 0 {
+		return epoch, v[:i], v[i+1:]
+	}
+	return epoch, v, ""
+}
+
+// compareNumeric compares two strings of digits of any length
+func compareNumeric(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if c := compareInts(len(a), len(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func leadingDigits(s string) (string, string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func leadingLetters(s string) (string, string) {
+	i := 0
+	for i < len(s) && isLetter(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,220 @@
+package version
+
+import "testing"
+
+func TestRPMEVR(t *testing.T) {
+	tests := []struct {
+		epoch, version, release, want string
+	}{
+		{"", "1.2.3", "", "1.2.3"},
+		{"0", "1.2.3", "4.el9", "1.2.3-4.el9"},
+		{"2", "1.2.3", "", "2:1.2.3"},
+		{"1", "7.4", "1.fc39", "1:7.4-1.fc39"},
+	}
+	for _, tt := range tests {
+		if got := RPMEVR(tt.epoch, tt.version, tt.release); got != tt.want {
+			t.Errorf("RPMEVR(%q, %q, %q) = %q, want %q", tt.epoch, tt.version, tt.release, got, tt.want)
+		}
+	}
+}
+
+// compareTests are ordered pairs; each is also checked reversed
+type compareTest struct {
+	a, b string
+	want int
+}
+
+func checkCompare(t *testing.T, name string, compare func(a, b string) int, tests []compareTest) {
+	t.Helper()
+	for _, tt := range tests {
+		if got := compare(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s(%q, %q) = %d, want %d", name, tt.a, tt.b, got, tt.want)
+		}
+		if got := compare(tt.b, tt.a); got != -tt.want {
+			t.Errorf("%s(%q, %q) = %d, want %d", name, tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestCompareGeneric(t *testing.T) {
+	checkCompare(t, "CompareGeneric", CompareGeneric, []compareTest{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0.0", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2.3+build.7", "1.2.3+build.9", 0},
+		{"1.2.3", "1.2.4", -1},
+		{"1.10", "1.9", 1},
+		{"10.0.19041.1", "10.0.19041.10", -1},
+		{"1.02", "1.2", 0},
+		{"2.0.0-rc.1", "2.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-beta.11", "1.0.0-rc.1", -1},
+		{"2.0b3", "2.0", -1},
+		{"2.0b3", "2.0b10", -1},
+		{"1.4rc1", "1.4", -1},
+		{"1.4RC1", "1.4rc1", 0},
+		{"1.4a", "1.4", -1},
+		{"1.4p1", "1.4", 1},
+		{"1.4p1", "1.5", -1},
+		{"2024.05.01", "2024.10.01", -1},
+		{"99999999999999999999.1", "99999999999999999998.9", 1},
+	})
+}
+
+func TestCompareDebian(t *testing.T) {
+	checkCompare(t, "CompareDebian", CompareDebian, []compareTest{
+		{"1.0-1", "1.0-1", 0},
+		{"0:1.0-1", "1.0-1", 0},
+		{"1.0", "1.0-0", 0},
+		{"1.0-1", "1.0-2", -1},
+		{"1.0-10", "1.0-9", 1},
+		{"1:0.9", "2.0", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~~", "1.0~", -1},
+		{"1.0~rc1-1", "1.0-1", -1},
+		{"1.0", "1.0a", -1},
+		{"1.0a", "1.0+", -1},
+		{"1.0+dfsg-1", "1.0-1", 1},
+		{"2.36-9+deb12u4", "2.36-9+deb12u10", -1},
+		{"1.2.3-1ubuntu1", "1.2.3-1", 1},
+		{"1.2.3-1ubuntu0.1", "1.2.3-1ubuntu1", -1},
+	})
+}
+
+func TestCompareRPM(t *testing.T) {
+	checkCompare(t, "CompareRPM", CompareRPM, []compareTest{
+		{"1.0-1", "1.0-1", 0},
+		{"0:1.0-1", "1.0-1", 0},
+		{"1.0", "1.0-7.el9", 0},
+		{"1.0-1.el9", "1.0-2.el9", -1},
+		{"1:1.0", "2.0", 1},
+		{"1.0.010", "1.0.9", 1},
+		{"1.0a", "1.0", 1},
+		{"1.0", "1.0.1", -1},
+		{"1.0a", "1.0.1", -1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0^git1", "1.0", 1},
+		{"1.0^git1", "1.0.1", -1},
+		{"1.0^git1", "1.0~rc1", 1},
+		{"1.0_1", "1.0.1", 0},
+		{"2.5-1.fc39", "2.5-1.fc40", -1},
+		{"a", "1", -1},
+	})
+}
+
+func TestCompareDispatchesOnScheme(t *testing.T) {
+	tests := []struct {
+		scheme Scheme
+		a, b   string
+		want   int
+	}{
+		// A tilde is a prerelease to dpkg and rpm but not to semver
+		{Debian, "1.0~rc1", "1.0", -1},
+		{RPM, "1.0~rc1", "1.0", -1},
+		{Generic, "2.0.0-rc.1", "2.0.0", -1},
+		{"unknown", "1.10", "1.9", 1},
+	}
+	for _, tt := range tests {
+		if got := Compare(tt.scheme, tt.a, tt.b); got != tt.want {
+			t.Errorf("Compare(%s, %q, %q) = %d, want %d", tt.scheme, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw        string
+		normalized string
+		kind       Kind
+	}{
+		{"1.2.3", "1.2.3", KindSemver},
+		{"v2.0.0-rc.1+build.5", "2.0.0-rc.1+build.5", KindSemver},
+		{"Version 10.0.19041.0001", "10.0.19041.1", KindWindows},
+		{"1, 2, 0, 4", "1.2.0.4", KindWindows},
+		{"2024-05-01", "2024.05.01", KindDate},
+		{"20240501", "2024.05.01", KindDate},
+		{"1.4rc1", "1.4rc1", KindGeneric},
+		{"7", "7", KindGeneric},
+	}
+	for _, tt := range tests {
+		v, ok := Parse(tt.raw)
+		if !ok || v.Normalized != tt.normalized || v.Kind != tt.kind || v.Raw != tt.raw {
+			t.Errorf("Parse(%q) = %+v, %v, want %q %s", tt.raw, v, ok, tt.normalized, tt.kind)
+		}
+	}
+	for _, raw := range []string{"", "latest", "v", "1 2", "1." + string(make([]byte, maxLength))} {
+		if v, ok := Parse(raw); ok {
+			t.Errorf("Parse(%q) = %+v, want no version", raw, v)
+		}
+	}
+}
+
+func TestParsePackageVersions(t *testing.T) {
+	if v, ok := ParseDebian("0:2.36-9+deb12u4"); !ok || v.Normalized != "2.36-9+deb12u4" || v.Scheme() != Debian {
+		t.Errorf("ParseDebian() = %+v, %v", v, ok)
+	}
+	if v, ok := ParseRPM("0:1.0^git1-2.fc39"); !ok || v.Normalized != "1.0^git1-2.fc39" || v.Scheme() != RPM {
+		t.Errorf("ParseRPM() = %+v, %v", v, ok)
+	}
+	if _, ok := ParseDebian("debian-1.0"); ok {
+		t.Error("ParseDebian() accepted a version starting with a letter")
+	}
+	if v, ok := ParseApple("2.1", "2104"); !ok || v.Normalized != "2.1" || v.Build != "2104" || v.Scheme() != Generic {
+		t.Errorf("ParseApple() = %+v, %v", v, ok)
+	}
+	if v, ok := ParseApple("2.1", "2.1"); !ok || v.Build != "" {
+		t.Errorf("ParseApple() with a matching build = %+v, %v", v, ok)
+	}
+	if v, ok := ParseApple("", "512"); !ok || v.Normalized != "512" {
+		t.Errorf("ParseApple() falling back to the bundle version = %+v, %v", v, ok)
+	}
+}
+
+func TestFromFilename(t *testing.T) {
+	tests := map[string]string{
+		"terraform_1.7.4_linux_amd64.zip": "1.7.4",
+		"Firefox Setup 125.0.1.exe":       "125.0.1",
+		"tool-v2.0b3-x64.msi":             "2.0b3",
+		"backup-2024-05-01.tar.gz":        "2024.05.01",
+		"setup_x64_12.exe":                "",
+		"TLSv1.2-helper.dmg":              "",
+	}
+	for filename, want := range tests {
+		v, ok := FromFilename(filename)
+		if ok != (want != "") || v.Normalized != want {
+			t.Errorf("FromFilename(%q) = %q, %v, want %q", filename, v.Normalized, ok, want)
+		}
+	}
+}
+
+func TestFromText(t *testing.T) {
+	tests := map[string]string{
+		`<assemblyIdentity version="6.1.0.0"/>`: "6.1.0.0",
+		"Tool v3.2 (build 77)":                  "3.2",
+		"uses TLSv1.2 and ships 4.5.6":          "4.5.6",
+		"no version here":                       "",
+	}
+	for content, want := range tests {
+		v, ok := FromText([]byte(content))
+		if ok != (want != "") || v.Normalized != want {
+			t.Errorf("FromText(%q) = %q, %v, want %q", content, v.Normalized, ok, want)
+		}
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	semver, _ := Parse("1.2.3")
+	generic, _ := Parse("1.4rc1")
+	if got := Confidence(SourcePackage, semver); got != 0.9 {
+		t.Errorf("Confidence(package, semver) = %v", got)
+	}
+	if Confidence(SourceFilename, generic) >= Confidence(SourceFilename, semver) {
+		t.Error("a generic version is trusted as much as a semantic one")
+	}
+	if Confidence("unknown", semver) != Confidence(SourceContent, semver) {
+		t.Error("an unknown source is not trusted like content")
+	}
+}
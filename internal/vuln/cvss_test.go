@@ -0,0 +1,69 @@
+package vuln
+
+import "testing"
+
+func TestCVSS3Score(t *testing.T) {
+	tests := []struct {
+		vector string
+		score  float64
+		ok     bool
+	}{
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 9.8, true},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H", 10, true},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:U/C:H/I:H/A:H", 8.8, true},
+		{"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:U/C:H/I:N/A:N", 6.5, true},
+		{"CVSS:3.1/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:H/A:H", 7.8, true},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", 6.1, true},
+		{"CVSS:3.1/AV:N/AC:H/PR:H/UI:R/S:C/C:L/I:N/A:N", 2.6, true},
+		{"CVSS:3.1/AV:P/AC:H/PR:H/UI:R/S:U/C:L/I:N/A:N", 1.6, true},
+		{"CVSS:3.0/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 9.8, true},
+		// Metric order does not matter
+		{"CVSS:3.1/C:H/I:H/A:H/AV:N/AC:L/PR:N/UI:N/S:U", 9.8, true},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N", 0, true},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H", 0, false},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:X/C:H/I:H/A:H", 0, false},
+		{"CVSS:3.1/AV:N/AC:L/PR:X/UI:N/S:U/C:H/I:H/A:H", 0, false},
+		{"AV:N/AC:L/Au:N/C:P/I:P/A:P", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		score, ok := cvss3Score(tt.vector)
+		if score != tt.score || ok != tt.ok {
+			t.Errorf("cvss3Score(%q) = %v, %v, want %v, %v", tt.vector, score, ok, tt.score, tt.ok)
+		}
+	}
+}
+
+func TestRoundUp(t *testing.T) {
+	tests := map[float64]float64{
+		4.0:      4.0,
+		4.02:     4.1,
+		4.000001: 4.0, // Floating point noise is not rounded up
+		9.81:     9.9,
+		0:        0,
+	}
+	for in, want := range tests {
+		if got := roundUp(in); got != want {
+			t.Errorf("roundUp(%v) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestSeverityForScore(t *testing.T) {
+	tests := map[float64]string{
+		10:  SeverityCritical,
+		9:   SeverityCritical,
+		8.9: SeverityHigh,
+		7:   SeverityHigh,
+		6.9: SeverityMedium,
+		4:   SeverityMedium,
+		3.9: SeverityLow,
+		0.1: SeverityLow,
+		0:   SeverityUnknown,
+	}
+	for score, want := range tests {
+		if got := severityForScore(score); got != want {
+			t.Errorf("severityForScore(%v) = %q, want %q", score, got, want)
+		}
+	}
+}
@@ -0,0 +1,487 @@
+package vuln
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Severities, from most to least severe
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+	SeverityUnknown  = "unknown"
+)
+
+// severityRanks orders severities for sorting and thresholds
+var severityRanks = map[string]int{
+	SeverityCritical: 4,
+	SeverityHigh:     3,
+	SeverityMedium:   2,
+	SeverityLow:      1,
+	SeverityUnknown:  0,
+}
+
+// Database is an offline vulnerability database built from OSV advisories
+// and NVD CVE records
+type Database struct {
+	packages   map[string][]*affectedPackage // By ecosystem, including any release, and name, or by package URL
+	releases   map[string][]string           // Ecosystems with a release, such as "Debian:12", by lowercase base
+	products   map[string][]*cpeRule         // By CPE vendor and product
+	advisories int
+}
+
+// advisory is the part of an advisory reported with a match
+type advisory struct {
+	id       string
+	aliases  []string
+	summary  string
+	severity string
+	score    float64
+	source   string
+}
+
+// affectedPackage is an OSV package and the versions of it an advisory
+// affects
+type affectedPackage struct {
+	advisory  *advisory
+	ecosystem string // OSV ecosystem, including any release
+	ranges    []osvRange
+	versions  []string
+}
+
+// cpeRule is an NVD CPE match: a product and the versions of it a CVE
+// affects
+type cpeRule struct {
+	advisory       *advisory
+	vendor         string
+	product        string
+	version        string // Exact version, or empty for a range
+	startIncluding string
+	startExcluding string
+	endIncluding   string
+	endExcluding   string
+}
+
+// Load reads advisories from OSV JSON files, NVD CVE API 2.0 JSON files,
+// OSV zip exports, and directories holding any of these
+func Load(paths ...string) (*Database, error) {
+	db := &Database{
+		packages: make(map[string][]*affectedPackage),
+		releases: make(map[string][]string),
+		products: make(map[string][]*cpeRule),
+	}
+
+	for _, root := range paths {
+		var files []string
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			if !entry.IsDir() && (ext == ".json" || ext == ".zip" || path == root) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vulnerability database: %w", err)
+		}
+		sort.Strings(files)
+
+		for _, file := range files {
+			if err := db.loadFile(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return db, nil
+}
+
+// Len returns the number of advisories loaded
+func (db *Database) Len() int {
+	return db.advisories
+}
+
+// loadFile reads a JSON document, or every JSON document in a zip file
+func (db *Database) loadFile(path string) error {
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		archive, err := zip.OpenReader(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		defer archive.Close()
+
+		for _, entry := range archive.File {
+			if entry.FileInfo().IsDir() || !strings.EqualFold(filepath.Ext(entry.Name), ".json") {
+				continue
+			}
+			r, err := entry.Open()
+			if err != nil {
+				return fmt.Errorf("failed to read %s in %s: %w", entry.Name, path, err)
+			}
+			data, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				return fmt.Errorf("failed to read %s in %s: %w", entry.Name, path, err)
+			}
+			if err := db.addDocument(data); err != nil {
+				return fmt.Errorf("%s in %s: %w", entry.Name, path, err)
+			}
+		}
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := db.addDocument(data); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// addDocument adds the advisories in an OSV record, a list of OSV records,
+// or an NVD CVE API response
+func (db *Database) addDocument(data []byte) error {
+	data = []byte(strings.TrimSpace(string(data)))
+	if len(data) > 0 && data[0] == '[' {
+		var records []osvRecord
+		if err := json.Unmarshal(data, &records); err != nil {
+			return fmt.Errorf("invalid OSV records: %w", err)
+		}
+		for _, record := range records {
+			db.addOSV(record)
+		}
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	switch {
+	case fields["vulnerabilities"] != nil:
+		var response nvdResponse
+		if err := json.Unmarshal(data, &response); err != nil {
+			return fmt.Errorf("invalid NVD data: %w", err)
+		}
+		for _, item := range response.Vulnerabilities {
+			db.addNVD(item.CVE)
+		}
+	case fields["CVE_Items"] != nil:
+		return errors.New("NVD 1.1 data feeds are not supported; use the CVE API 2.0 format")
+	case fields["id"] != nil:
+		var record osvRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("invalid OSV record: %w", err)
+		}
+		db.addOSV(record)
+	default:
+		return errors.New("neither an OSV record nor NVD data")
+	}
+	return nil
+}
+
+// osvRecord is an advisory in the OSV schema
+type osvRecord struct {
+	ID        string   `json:"id"`
+	Aliases   []string `json:"aliases"`
+	Summary   string   `json:"summary"`
+	Details   string   `json:"details"`
+	Withdrawn string   `json:"withdrawn"`
+	Severity  []struct {
+		Type  string `json:"type"`
+		Score string `json:"score"`
+	} `json:"severity"`
+	Affected []struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+			PURL      string `json:"purl"`
+		} `json:"package"`
+		Ranges           []osvRange       `json:"ranges"`
+		Versions         []string         `json:"versions"`
+		DatabaseSpecific databaseSpecific `json:"database_specific"`
+	} `json:"affected"`
+	DatabaseSpecific databaseSpecific `json:"database_specific"`
+}
+
+type osvRange struct {
+	Type   string     `json:"type"` // ECOSYSTEM, SEMVER or GIT
+	Events []osvEvent `json:"events"`
+}
+
+type osvEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+}
+
+// databaseSpecific holds the severity some OSV sources, such as GitHub,
+// report outside the severity list
+type databaseSpecific struct {
+	Severity string `json:"severity"`
+}
+
+func (s *databaseSpecific) UnmarshalJSON(data []byte) error {
+	var fields struct {
+		Severity interface{} `json:"severity"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil // Free-form; anything unexpected is ignored
+	}
+	if severity, ok := fields.Severity.(string); ok {
+		s.Severity = severity
+	}
+	return nil
+}
+
+// addOSV indexes an OSV advisory by the ecosystem and name, and the package
+// URL, of every package it affects. Ecosystems keep their release, so that
+// an advisory for "Debian:11" is not applied to Debian 12 packages.
+func (db *Database) addOSV(record osvRecord) {
+	if record.ID == "" || record.Withdrawn != "" || len(record.Affected) == 0 {
+		return
+	}
+
+	adv := &advisory{
+		id:       record.ID,
+		aliases:  record.Aliases,
+		summary:  summarize(record.Summary, record.Details),
+		severity: SeverityUnknown,
+		source:   "osv",
+	}
+	for _, severity := range record.Severity {
+		switch severity.Type {
+		case "CVSS_V3":
+			if score, ok := cvss3Score(severity.Score); ok && score > adv.score {
+				adv.score = score
+				adv.severity = severityForScore(score)
+			}
+		case "Ubuntu":
+			if adv.score == 0 {
+				adv.severity = normalizeSeverity(severity.Score)
+			}
+		}
+	}
+	if adv.severity == SeverityUnknown {
+		adv.severity = normalizeSeverity(record.DatabaseSpecific.Severity)
+	}
+
+	for _, affected := range record.Affected {
+		ecosystem := affected.Package.Ecosystem
+		pkg := &affectedPackage{advisory: adv, ecosystem: ecosystem, ranges: affected.Ranges, versions: affected.Versions}
+		if adv.severity == SeverityUnknown {
+			// Some sources only rate severity per package; use the
+			// rating of the first that has one for the advisory
+			adv.severity = normalizeSeverity(affected.DatabaseSpecific.Severity)
+		}
+
+		if affected.Package.Name != "" {
+			key := packageKey(ecosystem, affected.Package.Name)
+			db.packages[key] = append(db.packages[key], pkg)
+			if base, release := splitEcosystem(ecosystem); release != "" {
+				base = strings.ToLower(base)
+				if !containsString(db.releases[base], ecosystem) {
+					db.releases[base] = append(db.releases[base], ecosystem)
+				}
+			}
+		}
+		if key := purlKey(affected.Package.PURL); key != "" {
+			db.packages[key] = append(db.packages[key], pkg)
+		}
+	}
+	db.advisories++
+}
+
+// nvdResponse is a page of the NVD CVE API 2.0
+type nvdResponse struct {
+	Vulnerabilities []struct {
+		CVE nvdCVE `json:"cve"`
+	} `json:"vulnerabilities"`
+}
+
+type nvdCVE struct {
+	ID           string `json:"id"`
+	VulnStatus   string `json:"vulnStatus"`
+	Descriptions []struct {
+		Lang  string `json:"lang"`
+		Value string `json:"value"`
+	} `json:"descriptions"`
+	Metrics struct {
+		V31 []nvdMetric `json:"cvssMetricV31"`
+		V30 []nvdMetric `json:"cvssMetricV30"`
+		V2  []nvdMetric `json:"cvssMetricV2"`
+	} `json:"metrics"`
+	Configurations []struct {
+		Nodes []struct {
+			Negate   bool `json:"negate"`
+			CPEMatch []struct {
+				Vulnerable            bool   `json:"vulnerable"`
+				Criteria              string `json:"criteria"`
+				VersionStartIncluding string `json:"versionStartIncluding"`
+				VersionStartExcluding string `json:"versionStartExcluding"`
+				VersionEndIncluding   string `json:"versionEndIncluding"`
+				VersionEndExcluding   string `json:"versionEndExcluding"`
+			} `json:"cpeMatch"`
+		} `json:"nodes"`
+	} `json:"configurations"`
+}
+
+type nvdMetric struct {
+	Type     string `json:"type"` // Primary or Secondary
+	CVSSData struct {
+		BaseScore    float64 `json:"baseScore"`
+		BaseSeverity string  `json:"baseSeverity"`
+	} `json:"cvssData"`
+	BaseSeverity string `json:"baseSeverity"` // CVSS v2 reports severity here
+}
+
+// addNVD indexes a CVE by the vendor and product of every application it
+// names as vulnerable
+func (db *Database) addNVD(cve nvdCVE) {
+	if cve.ID == "" || cve.VulnStatus == "Rejected" {
+		return
+	}
+
+	adv := &advisory{id: cve.ID, severity: SeverityUnknown, source: "nvd"}
+	for _, description := range cve.Descriptions {
+		if description.Lang == "en" {
+			adv.summary = summarize("", description.Value)
+			break
+		}
+	}
+	for _, metrics := range [][]nvdMetric{cve.Metrics.V31, cve.Metrics.V30, cve.Metrics.V2} {
+		if metric, ok := primaryMetric(metrics); ok {
+			adv.score = metric.CVSSData.BaseScore
+			adv.severity = normalizeSeverity(metric.CVSSData.BaseSeverity)
+			if adv.severity == SeverityUnknown {
+				adv.severity = normalizeSeverity(metric.BaseSeverity)
+			}
+			if adv.severity == SeverityUnknown {
+				adv.severity = severityForScore(adv.score)
+			}
+			break
+		}
+	}
+
+	indexed := false
+	for _, config := range cve.Configurations {
+		for _, node := range config.Nodes {
+			if node.Negate {
+				continue
+			}
+			for _, match := range node.CPEMatch {
+				cpe := strings.Split(match.Criteria, ":")
+				if !match.Vulnerable || len(cpe) < 6 || cpe[2] != "a" {
+					continue
+				}
+				rule := &cpeRule{
+					advisory:       adv,
+					vendor:         unescapeCPE(cpe[3]),
+					product:        unescapeCPE(cpe[4]),
+					startIncluding: match.VersionStartIncluding,
+					startExcluding: match.VersionStartExcluding,
+					endIncluding:   match.VersionEndIncluding,
+					endExcluding:   match.VersionEndExcluding,
+				}
+				if v := unescapeCPE(cpe[5]); v != "*" && v != "-" {
+					rule.version = v
+				}
+				key := rule.vendor + ":" + rule.product
+				db.products[key] = append(db.products[key], rule)
+				indexed = true
+			}
+		}
+	}
+	if indexed {
+		db.advisories++
+	}
+}
+
+// primaryMetric returns the metric from the NVD itself, or the first one
+func primaryMetric(metrics []nvdMetric) (nvdMetric, bool) {
+	for _, metric := range metrics {
+		if metric.Type == "Primary" {
+			return metric, true
+		}
+	}
+	if len(metrics) > 0 {
+		return metrics[0], true
+	}
+	return nvdMetric{}, false
+}
+
+// normalizeSeverity maps the severity names advisory sources use to ours
+func normalizeSeverity(severity string) string {
+	switch strings.ToLower(strings.TrimSpace(severity)) {
+	case "critical":
+		return SeverityCritical
+	case "high", "important":
+		return SeverityHigh
+	case "medium", "moderate":
+		return SeverityMedium
+	case "low", "negligible":
+		return SeverityLow
+	default:
+		return SeverityUnknown
+	}
+}
+
+// splitEcosystem splits an OSV ecosystem into its base and release, so
+// "Debian:12" and "Red Hat:enterprise_linux:9::appstream" give "Debian"
+// and "12", and "Red Hat" and "enterprise_linux:9::appstream"
+func splitEcosystem(ecosystem string) (base, release string) {
+	base, release, _ = strings.Cut(ecosystem, ":")
+	return base, release
+}
+
+// packageKey indexes packages by ecosystem and name
+func packageKey(ecosystem, name string) string {
+	return strings.ToLower(ecosystem) + "|" + strings.ToLower(name)
+}
+
+// purlKey reduces a package URL to its type, namespace and name
+func purlKey(purl string) string {
+	rest, ok := strings.CutPrefix(purl, "pkg:")
+	if !ok {
+		return ""
+	}
+	if i := strings.IndexAny(rest, "@?#"); i >= 0 {
+		rest = rest[:i]
+	}
+	return "purl|" + strings.ToLower(rest)
+}
+
+// unescapeCPE removes the backslashes CPE 2.3 escapes punctuation with
+func unescapeCPE(s string) string {
+	return strings.ReplaceAll(s, `\`, "")
+}
+
+// summarize returns an advisory's summary, or the start of its details
+func summarize(summary, details string) string {
+	if summary = strings.TrimSpace(summary); summary != "" {
+		return summary
+	}
+	details = strings.Join(strings.Fields(details), " ")
+	if len(details) > 200 {
+		cut := strings.LastIndex(details[:200], " ")
+		if cut < 100 {
+			cut = 200
+		}
+		details = details[:cut] + "..."
+	}
+	return details
+}
@@ -0,0 +1,509 @@
+package vuln
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/deploymenttheory/go-app-index/internal/sbom"
+	"github.com/deploymenttheory/go-app-index/internal/types"
+	"github.com/deploymenttheory/go-app-index/internal/version"
+)
+
+// debEcosystems and rpmEcosystems are the OSV ecosystems whose advisories
+// apply to packages built for each platform
+var (
+	debEcosystems = map[string][]string{
+		"linux-debian": {"Debian"},
+		"linux-ubuntu": {"Ubuntu"},
+	}
+	rpmEcosystems = map[string][]string{
+		"linux-rhel":      {"Red Hat"},
+		"linux-rocky":     {"Rocky Linux"},
+		"linux-almalinux": {"AlmaLinux"},
+		"linux-suse":      {"SUSE", "openSUSE"},
+	}
+)
+
+// debianRelease, ubuntuRelease and elRelease find the release a package
+// was built for in its version: "+deb12u1" for Debian, "0ubuntu0.22.04.1"
+// or "~22.04" for Ubuntu, and "el9" in an RPM release
+var (
+	debianRelease = regexp.MustCompile(`[+~]deb(\d+)u\d`)
+	ubuntuRelease = regexp.MustCompile(`(?:ubuntu\d+\.|~)(\d{2}\.\d{2})(?:\.|$)`)
+	elRelease     = regexp.MustCompile(`(?:^|\.)el(\d+)(?:[._]|$)`)
+)
+
+// identity is what a file is matched by
+type identity struct {
+	scheme   version.Scheme
+	version  string
+	packages []packageRef
+	releases map[string]string // Distribution release by lowercase ecosystem base, where known
+	cpes     []string          // CPE vendor:product candidates
+}
+
+// packageRef is a package name or URL a file is matched by
+type packageRef struct {
+	key       string // Key into Database.packages
+	ecosystem string // Ecosystem without a release; empty for package URLs
+	name      string
+	matchedBy string
+}
+
+// Match returns the vulnerabilities in the database that affect a file,
+// most severe first. Files are matched by package name within the OSV
+// ecosystem of their distribution, by package URL, and by CPE vendor and
+// product generated from their publisher and product name. Files without
+// a version match nothing.
+func (db *Database) Match(file types.ProcessedFile) []types.Vulnerability {
+	id := identify(file)
+	if id.version == "" {
+		return nil
+	}
+
+	var matches []types.Vulnerability
+	seen := make(map[string]bool)
+	record := func(adv *advisory, fixedIn, matchedBy string) {
+		if seen[adv.id] {
+			return
+		}
+		for _, alias := range adv.aliases {
+			if seen[alias] {
+				return
+			}
+		}
+		seen[adv.id] = true
+		for _, alias := range adv.aliases {
+			seen[alias] = true
+		}
+		matches = append(matches, types.Vulnerability{
+			ID:        adv.id,
+			Aliases:   adv.aliases,
+			Summary:   adv.summary,
+			Severity:  adv.severity,
+			Score:     adv.score,
+			FixedIn:   fixedIn,
+			Source:    adv.source,
+			MatchedBy: matchedBy,
+		})
+	}
+
+	// OSV advisories come first so that they win over the NVD records
+	// they alias, as they name the distribution's fixed version
+	for _, ref := range id.packages {
+		db.matchPackage(id, ref, record)
+	}
+	for _, key := range id.cpes {
+		for _, rule := range db.products[key] {
+			if affected, fixedIn := rule.affects(id.version); affected {
+				cpe := fmt.Sprintf("cpe:2.3:a:%s:%s:%s:*:*:*:*:*:*:*", rule.vendor, rule.product, id.version)
+				record(rule.advisory, fixedIn, cpe)
+			}
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if severityRanks[a.Severity] != severityRanks[b.Severity] {
+			return severityRanks[a.Severity] > severityRanks[b.Severity]
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.ID < b.ID
+	})
+	return matches
+}
+
+// matchPackage records the OSV advisories that affect a package. Entries
+// for a distribution release apply to files built for that release; when
+// a file's release is unknown, an advisory only matches if the file is
+// affected under every release it lists the package for.
+func (db *Database) matchPackage(id identity, ref packageRef, record func(*advisory, string, string)) {
+	entries := db.packages[ref.key]
+	if ref.ecosystem != "" {
+		for _, ecosystem := range db.releases[strings.ToLower(ref.ecosystem)] {
+			entries = append(entries, db.packages[packageKey(ecosystem, ref.name)]...)
+		}
+	}
+
+	// Group entries by advisory, in the order they were loaded
+	var advisories []*advisory
+	byAdvisory := make(map[*advisory][]*affectedPackage)
+	for _, pkg := range entries {
+		if byAdvisory[pkg.advisory] == nil {
+			advisories = append(advisories, pkg.advisory)
+		}
+		byAdvisory[pkg.advisory] = append(byAdvisory[pkg.advisory], pkg)
+	}
+
+	for _, adv := range advisories {
+		var unknown []*affectedPackage
+		matched := false
+		for _, pkg := range byAdvisory[adv] {
+			base, release := splitEcosystem(pkg.ecosystem)
+			fileRelease := id.releases[strings.ToLower(base)]
+			switch {
+			case release == "":
+			case fileRelease == "":
+				unknown = append(unknown, pkg)
+				continue
+			case !sameRelease(release, fileRelease):
+				continue
+			}
+			if affected, fixedIn := pkg.affects(id.scheme, id.version); affected {
+				var ecosystems []string
+				if release != "" {
+					ecosystems = []string{pkg.ecosystem}
+				}
+				record(adv, fixedIn, ref.describe(ecosystems))
+				matched = true
+				break
+			}
+		}
+		if matched || len(unknown) == 0 {
+			continue
+		}
+
+		// Fixed versions differ between releases, so one is only
+		// reported if every release names the same
+		var ecosystems, fixed []string
+		for _, pkg := range unknown {
+			affected, fixedIn := pkg.affects(id.scheme, id.version)
+			if !affected {
+				ecosystems = nil
+				break
+			}
+			ecosystems = uniqueStrings(append(ecosystems, pkg.ecosystem)...)
+			fixed = append(fixed, fixedIn)
+		}
+		if len(ecosystems) == 0 {
+			continue
+		}
+		fixedIn := fixed[0]
+		for _, f := range fixed[1:] {
+			if f != fixedIn {
+				fixedIn = ""
+			}
+		}
+		record(adv, fixedIn, ref.describe(ecosystems))
+	}
+}
+
+// describe says how a file matched through ref, naming the releases of the
+// ecosystems the advisory listed it under
+func (ref packageRef) describe(ecosystems []string) string {
+	switch {
+	case len(ecosystems) == 0:
+		return ref.matchedBy
+	case ref.ecosystem == "":
+		return ref.matchedBy + " (" + strings.Join(ecosystems, ", ") + ")"
+	}
+	described := make([]string, len(ecosystems))
+	for i, ecosystem := range ecosystems {
+		described[i] = ecosystem + "/" + ref.name
+	}
+	return strings.Join(described, ", ")
+}
+
+// sameRelease reports whether an OSV ecosystem release is the one a file
+// was built for. Ecosystems may qualify the release further, as in
+// "22.04:LTS" or "enterprise_linux:9::appstream".
+func sameRelease(release, fileRelease string) bool {
+	release, fileRelease = strings.ToLower(release), strings.ToLower(fileRelease)
+	return release == fileRelease || strings.HasPrefix(release, fileRelease+":")
+}
+
+// AtLeast reports whether a severity is at least as severe as threshold
+func AtLeast(severity, threshold string) bool {
+	return severityRanks[severity] >= severityRanks[threshold]
+}
+
+// ParseSeverity validates a severity name
+func ParseSeverity(s string) (string, error) {
+	severity := strings.ToLower(strings.TrimSpace(s))
+	if _, ok := severityRanks[severity]; !ok {
+		return "", fmt.Errorf("unknown severity %q (want critical, high, medium, low or unknown)", s)
+	}
+	return severity, nil
+}
+
+// identify works out the names, version and version ordering a file is
+// matched by
+func identify(file types.ProcessedFile) identity {
+	id := identity{scheme: version.Generic, version: fileVersion(file), releases: fileReleases(file)}
+	addPackage := func(ref packageRef) {
+		for _, existing := range id.packages {
+			if existing.key == ref.key {
+				return
+			}
+		}
+		id.packages = append(id.packages, ref)
+	}
+
+	// Distribution packages are matched by binary and source package name
+	// within their distribution's ecosystem
+	var ecosystems []string
+	names := []string{file.MetadataString("package_name")}
+	switch file.FileType {
+	case "deb":
+		id.scheme = version.Debian
+		ecosystems = debEcosystems[file.Platform]
+		if ecosystems == nil {
+			ecosystems = []string{"Debian", "Ubuntu"}
+		}
+		names = append(names, file.MetadataString("source_package"))
+	case "rpm":
+		id.scheme = version.RPM
+		ecosystems = rpmEcosystems[file.Platform]
+		names = append(names, sourceRPMName(file.MetadataString("package_sourcerpm")))
+	}
+	for _, ecosystem := range ecosystems {
+		for _, name := range names {
+			if name != "" {
+				addPackage(packageRef{key: packageKey(ecosystem, name), ecosystem: ecosystem, name: name, matchedBy: ecosystem + "/" + name})
+			}
+		}
+	}
+
+	if purl := sbom.PackageURL(file); purl != "" {
+		addPackage(packageRef{key: purlKey(purl), matchedBy: purl})
+	}
+
+	// Distribution packages carry distribution version numbers, which
+	// NVD's upstream version ranges do not apply to
+	if file.FileType != "deb" && file.FileType != "rpm" {
+		id.cpes = cpeCandidates(publisher(file), productName(file))
+	}
+	return id
+}
+
+// fileReleases returns the distribution release a package was built for,
+// by lowercase OSV ecosystem, as far as its version tells
+func fileReleases(file types.ProcessedFile) map[string]string {
+	releases := make(map[string]string)
+	switch file.FileType {
+	case "deb":
+		v := file.MetadataString("version")
+		if m := debianRelease.FindStringSubmatch(v); m != nil {
+			releases["debian"] = m[1]
+		}
+		if strings.Contains(v, "ubuntu") {
+			if m := ubuntuRelease.FindStringSubmatch(v); m != nil {
+				releases["ubuntu"] = m[1]
+			}
+		}
+	case "rpm":
+		if m := elRelease.FindStringSubmatch(file.MetadataString("package_release")); m != nil {
+			releases["red hat"] = "enterprise_linux:" + m[1]
+			releases["rocky linux"] = m[1]
+			releases["almalinux"] = m[1]
+		}
+	}
+	return releases
+}
+
+// affects reports whether an OSV package entry covers v, and the version
+// that fixes it
+func (p *affectedPackage) affects(scheme version.Scheme, v string) (bool, string) {
+	for _, listed := range p.versions {
+		if version.Compare(scheme, listed, v) == 0 {
+			return true, ""
+		}
+	}
+
+	for _, r := range p.ranges {
+		rangeScheme := scheme
+		switch r.Type {
+		case "ECOSYSTEM":
+		case "SEMVER":
+			rangeScheme = version.Generic
+		default:
+			continue // GIT ranges name commits, not versions
+		}
+
+		// Events apply in version order; "0" introduces from the start
+		affected := false
+		for _, event := range sortEvents(rangeScheme, r.Events) {
+			switch {
+			case event.Introduced != "":
+				if event.Introduced == "0" || version.Compare(rangeScheme, v, event.Introduced) >= 0 {
+					affected = true
+				}
+			case event.Fixed != "":
+				if affected && version.Compare(rangeScheme, v, event.Fixed) < 0 {
+					return true, event.Fixed
+				}
+				affected = false
+			case event.LastAffected != "":
+				if affected && version.Compare(rangeScheme, v, event.LastAffected) <= 0 {
+					return true, ""
+				}
+				affected = false
+			}
+		}
+		if affected {
+			return true, ""
+		}
+	}
+	return false, ""
+}
+
+// sortEvents orders range events by version, with "0" first
+func sortEvents(scheme version.Scheme, events []osvEvent) []osvEvent {
+	sorted := append([]osvEvent(nil), events...)
+	eventVersion := func(e osvEvent) string {
+		return e.Introduced + e.Fixed + e.LastAffected
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := eventVersion(sorted[i]), eventVersion(sorted[j])
+		if a == "0" || b == "0" {
+			return a == "0" && b != "0"
+		}
+		return version.Compare(scheme, a, b) < 0
+	})
+	return sorted
+}
+
+// affects reports whether an NVD CPE match covers v, and the version that
+// fixes it
+func (r *cpeRule) affects(v string) (bool, string) {
+	if r.version != "" {
+		return version.CompareGeneric(v, r.version) == 0, ""
+	}
+	if r.startIncluding != "" && version.CompareGeneric(v, r.startIncluding) < 0 {
+		return false, ""
+	}
+	if r.startExcluding != "" && version.CompareGeneric(v, r.startExcluding) <= 0 {
+		return false, ""
+	}
+	if r.endIncluding != "" && version.CompareGeneric(v, r.endIncluding) > 0 {
+		return false, ""
+	}
+	if r.endExcluding != "" && version.CompareGeneric(v, r.endExcluding) >= 0 {
+		return false, ""
+	}
+	return true, r.endExcluding
+}
+
+// cpeSeparators reduces names to CPE's vendor and product conventions:
+// lowercase words joined by underscores
+var cpeSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// cpeCandidates returns the CPE vendor:product pairs a publisher and
+// product may be listed under. "Mozilla Corporation" and "Mozilla Firefox"
+// give mozilla:firefox among others.
+func cpeCandidates(publisher, product string) []string {
+	vendorWords := types.TrimLegalSuffixes(cpeWords(publisher))
+	productWords := cpeWords(product)
+	if len(vendorWords) == 0 || len(productWords) == 0 {
+		return nil
+	}
+
+	vendors := uniqueStrings(
+		strings.Join(vendorWords, "_"),
+		strings.Join(vendorWords, ""),
+		vendorWords[0],
+	)
+	products := []string{strings.Join(productWords, "_"), strings.Join(productWords, "")}
+	for _, vendor := range vendors {
+		if rest, ok := strings.CutPrefix(strings.Join(productWords, "_"), vendor+"_"); ok {
+			products = append(products, rest, strings.ReplaceAll(rest, "_", ""))
+		}
+	}
+	products = uniqueStrings(products...)
+
+	var candidates []string
+	for _, vendor := range vendors {
+		for _, product := range products {
+			candidates = append(candidates, vendor+":"+product)
+		}
+	}
+	return candidates
+}
+
+func cpeWords(s string) []string {
+	return strings.Fields(cpeSeparators.ReplaceAllString(strings.ToLower(s), " "))
+}
+
+func uniqueStrings(values ...string) []string {
+	var unique []string
+	for _, v := range values {
+		if v != "" && !containsString(unique, v) {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceRPMName returns the package name of a source RPM filename such as
+// "zlib-1.2.11-40.el9.src.rpm"
+func sourceRPMName(sourceRPM string) string {
+	name := strings.TrimSuffix(sourceRPM, ".src.rpm")
+	if name == sourceRPM {
+		return ""
+	}
+	// Drop the release, then the version
+	for i := 0; i < 2; i++ {
+		cut := strings.LastIndex(name, "-")
+		if cut <= 0 {
+			return ""
+		}
+		name = name[:cut]
+	}
+	return name
+}
+
+// fileVersion returns the version a file is matched at: the full EVR for
+// RPMs, the package version for Debian packages, and the normalized
+// version otherwise
+func fileVersion(file types.ProcessedFile) string {
+	switch file.FileType {
+	case "rpm":
+		if v := file.MetadataString("package_version"); v != "" {
+			return version.RPMEVR(file.MetadataString("package_epoch"), v, file.MetadataString("package_release"))
+		}
+	case "deb":
+		if v := file.MetadataString("version"); v != "" {
+			return v
+		}
+	}
+	if file.NormalizedVersion != "" {
+		return file.NormalizedVersion
+	}
+	return file.Version
+}
+
+// publisher returns who published a file
+func publisher(file types.ProcessedFile) string {
+	if file.Publisher != "" {
+		return file.Publisher
+	}
+	for _, key := range []string{"company", "vendor"} {
+		if v := file.MetadataString(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// productName returns the product name the analyzers found for a file
+func productName(file types.ProcessedFile) string {
+	for _, key := range []string{"product_name", "name", "package_name"} {
+		if name := file.MetadataString(key); name != "" {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,238 @@
+package vuln
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/deploymenttheory/go-app-index/internal/types"
+	"github.com/deploymenttheory/go-app-index/internal/version"
+)
+
+// loadRecords loads a database from a JSON document
+func loadRecords(t *testing.T, doc string) *Database {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "advisories.json")
+	if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return db
+}
+
+func TestAffectedPackageRanges(t *testing.T) {
+	introduced := func(v string) osvEvent { return osvEvent{Introduced: v} }
+	fixed := func(v string) osvEvent { return osvEvent{Fixed: v} }
+	lastAffected := func(v string) osvEvent { return osvEvent{LastAffected: v} }
+	ecosystem := func(events ...osvEvent) osvRange { return osvRange{Type: "ECOSYSTEM", Events: events} }
+
+	tests := []struct {
+		name     string
+		pkg      affectedPackage
+		scheme   version.Scheme
+		version  string
+		affected bool
+		fixedIn  string
+	}{
+		{"before fix", affectedPackage{ranges: []osvRange{ecosystem(introduced("0"), fixed("1.2"))}}, version.Generic, "1.1", true, "1.2"},
+		{"at fix", affectedPackage{ranges: []osvRange{ecosystem(introduced("0"), fixed("1.2"))}}, version.Generic, "1.2", false, ""},
+		{"before introduced", affectedPackage{ranges: []osvRange{ecosystem(introduced("1.0"), fixed("1.2"))}}, version.Generic, "0.9", false, ""},
+		{"at introduced", affectedPackage{ranges: []osvRange{ecosystem(introduced("1.0"), fixed("1.2"))}}, version.Generic, "1.0", true, "1.2"},
+		{"between ranges", affectedPackage{ranges: []osvRange{ecosystem(introduced("1.0"), fixed("1.2"), introduced("2.0"), fixed("2.1"))}}, version.Generic, "1.5", false, ""},
+		{"second range", affectedPackage{ranges: []osvRange{ecosystem(introduced("1.0"), fixed("1.2"), introduced("2.0"), fixed("2.1"))}}, version.Generic, "2.0.5", true, "2.1"},
+		{"events out of order", affectedPackage{ranges: []osvRange{ecosystem(fixed("1.2"), introduced("1.0"))}}, version.Generic, "1.1", true, "1.2"},
+		{"last affected", affectedPackage{ranges: []osvRange{ecosystem(introduced("0"), lastAffected("1.3"))}}, version.Generic, "1.3", true, ""},
+		{"after last affected", affectedPackage{ranges: []osvRange{ecosystem(introduced("0"), lastAffected("1.3"))}}, version.Generic, "1.3.1", false, ""},
+		{"never fixed", affectedPackage{ranges: []osvRange{ecosystem(introduced("5.0"))}}, version.Generic, "7.2", true, ""},
+		{"git ranges are skipped", affectedPackage{ranges: []osvRange{{Type: "GIT", Events: []osvEvent{introduced("0"), fixed("abc123")}}}}, version.Generic, "1.0", false, ""},
+		{"listed version", affectedPackage{versions: []string{"1.0.0", "1.0.1"}}, version.Generic, "1.0.1", true, ""},
+		{"unlisted version", affectedPackage{versions: []string{"1.0.0", "1.0.1"}}, version.Generic, "1.0.2", false, ""},
+		{"dpkg ordering", affectedPackage{ranges: []osvRange{ecosystem(introduced("0"), fixed("1.1.1n-0+deb11u4"))}}, version.Debian, "1.1.1n-0+deb11u3", true, "1.1.1n-0+deb11u4"},
+		{"dpkg tilde", affectedPackage{ranges: []osvRange{ecosystem(introduced("0"), fixed("2.0"))}}, version.Debian, "2.0~rc1", true, "2.0"},
+		{"dpkg epoch", affectedPackage{ranges: []osvRange{ecosystem(introduced("0"), fixed("1:1.0"))}}, version.Debian, "9.0", true, "1:1.0"},
+		{"rpm ordering", affectedPackage{ranges: []osvRange{ecosystem(introduced("0"), fixed("0:1.2.11-40.el9"))}}, version.RPM, "0:1.2.11-39.el9", true, "0:1.2.11-40.el9"},
+		// SEMVER ranges compare generically even for distribution packages
+		{"semver range", affectedPackage{ranges: []osvRange{{Type: "SEMVER", Events: []osvEvent{introduced("0"), fixed("2.0.0")}}}}, version.Debian, "1.9.0", true, "2.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			affected, fixedIn := tt.pkg.affects(tt.scheme, tt.version)
+			if affected != tt.affected || fixedIn != tt.fixedIn {
+				t.Errorf("affects(%q) = %v, %q, want %v, %q", tt.version, affected, fixedIn, tt.affected, tt.fixedIn)
+			}
+		})
+	}
+}
+
+func TestCPERuleAffects(t *testing.T) {
+	tests := []struct {
+		rule     cpeRule
+		version  string
+		affected bool
+		fixedIn  string
+	}{
+		{cpeRule{version: "1.2.3"}, "1.2.3", true, ""},
+		{cpeRule{version: "1.2.3"}, "1.2.4", false, ""},
+		{cpeRule{endExcluding: "124.0.1"}, "124.0", true, "124.0.1"},
+		{cpeRule{endExcluding: "124.0.1"}, "124.0.1", false, ""},
+		{cpeRule{endIncluding: "2.0"}, "2.0", true, ""},
+		{cpeRule{startIncluding: "2.0", endExcluding: "2.5"}, "1.9", false, ""},
+		{cpeRule{startExcluding: "2.0", endExcluding: "2.5"}, "2.0", false, ""},
+		{cpeRule{startExcluding: "2.0", endExcluding: "2.5"}, "2.0.1", true, "2.5"},
+	}
+	for _, tt := range tests {
+		affected, fixedIn := tt.rule.affects(tt.version)
+		if affected != tt.affected || fixedIn != tt.fixedIn {
+			t.Errorf("%+v affects(%q) = %v, %q, want %v, %q", tt.rule, tt.version, affected, fixedIn, tt.affected, tt.fixedIn)
+		}
+	}
+}
+
+// releaseAdvisories list openssl per Debian and Ubuntu release
+const releaseAdvisories = `[
+  {"id": "DSA-1", "affected": [
+    {"package": {"ecosystem": "Debian:11", "name": "openssl"}, "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "1.6-1"}]}]},
+    {"package": {"ecosystem": "Debian:12", "name": "openssl"}, "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "1.7-1"}]}]}]},
+  {"id": "DSA-2", "affected": [
+    {"package": {"ecosystem": "Debian:11", "name": "openssl"}, "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "1.6-1"}]}]},
+    {"package": {"ecosystem": "Debian:12", "name": "openssl"}, "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "1.4-1"}]}]}]},
+  {"id": "DSA-3", "affected": [
+    {"package": {"ecosystem": "Debian:11", "name": "openssl"}, "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "9.0-1"}]}]}]},
+  {"id": "DSA-4", "affected": [
+    {"package": {"ecosystem": "Debian", "name": "openssl"}, "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "2.0-1"}]}]}]},
+  {"id": "USN-1", "affected": [
+    {"package": {"ecosystem": "Ubuntu:22.04:LTS", "name": "openssl"}, "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "1.6-0ubuntu0.22.04.1"}]}]},
+    {"package": {"ecosystem": "Ubuntu:20.04:LTS", "name": "openssl"}, "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "1.2-0ubuntu0.20.04.1"}]}]}]}
+]`
+
+func TestMatchByRelease(t *testing.T) {
+	db := loadRecords(t, releaseAdvisories)
+	deb := func(platform, v string) types.ProcessedFile {
+		return types.ProcessedFile{
+			FileType:         "deb",
+			Platform:         platform,
+			ExtendedMetadata: map[string]interface{}{"package_name": "openssl", "version": v},
+		}
+	}
+
+	tests := []struct {
+		name string
+		file types.ProcessedFile
+		want map[string][2]string // Advisory ID to fixed in and matched by
+	}{
+		{
+			name: "debian 12",
+			file: deb("linux-debian", "1.5-1+deb12u1"),
+			want: map[string][2]string{
+				"DSA-1": {"1.7-1", "Debian:12/openssl"},
+				"DSA-4": {"2.0-1", "Debian/openssl"},
+			},
+		},
+		{
+			name: "debian 11",
+			file: deb("linux-debian", "1.5-1+deb11u1"),
+			want: map[string][2]string{
+				"DSA-1": {"1.6-1", "Debian:11/openssl"},
+				"DSA-2": {"1.6-1", "Debian:11/openssl"},
+				"DSA-3": {"9.0-1", "Debian:11/openssl"},
+				"DSA-4": {"2.0-1", "Debian/openssl"},
+			},
+		},
+		{
+			// Only advisories affecting every release they list match,
+			// and fixed versions that differ are not reported
+			name: "unknown release",
+			file: deb("linux-debian", "1.5-1"),
+			want: map[string][2]string{
+				"DSA-1": {"", "Debian:11/openssl, Debian:12/openssl"},
+				"DSA-3": {"9.0-1", "Debian:11/openssl"},
+				"DSA-4": {"2.0-1", "Debian/openssl"},
+			},
+		},
+		{
+			name: "ubuntu 22.04",
+			file: deb("linux-ubuntu", "1.5-0ubuntu0.22.04.1"),
+			want: map[string][2]string{
+				"USN-1": {"1.6-0ubuntu0.22.04.1", "Ubuntu:22.04:LTS/openssl"},
+			},
+		},
+		{
+			name: "ubuntu 20.04",
+			file: deb("linux-ubuntu", "1.5-0ubuntu0.20.04.1"),
+			want: map[string][2]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string][2]string)
+			for _, match := range db.Match(tt.file) {
+				got[match.ID] = [2]string{match.FixedIn, match.MatchedBy}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileReleases(t *testing.T) {
+	tests := []struct {
+		file types.ProcessedFile
+		want map[string]string
+	}{
+		{types.ProcessedFile{FileType: "deb", ExtendedMetadata: map[string]interface{}{"version": "3.0.11-1~deb12u2"}}, map[string]string{"debian": "12"}},
+		{types.ProcessedFile{FileType: "deb", ExtendedMetadata: map[string]interface{}{"version": "1.1.1n-0+deb11u5"}}, map[string]string{"debian": "11"}},
+		{types.ProcessedFile{FileType: "deb", ExtendedMetadata: map[string]interface{}{"version": "3.0.2-0ubuntu1.10"}}, map[string]string{}},
+		{types.ProcessedFile{FileType: "deb", ExtendedMetadata: map[string]interface{}{"version": "2.0-1ubuntu1~20.04.1"}}, map[string]string{"ubuntu": "20.04"}},
+		{types.ProcessedFile{FileType: "deb", ExtendedMetadata: map[string]interface{}{"version": "1.0~22.04"}}, map[string]string{}},
+		{types.ProcessedFile{FileType: "rpm", ExtendedMetadata: map[string]interface{}{"package_release": "40.el9_2"}},
+			map[string]string{"red hat": "enterprise_linux:9", "rocky linux": "9", "almalinux": "9"}},
+		{types.ProcessedFile{FileType: "rpm", ExtendedMetadata: map[string]interface{}{"package_release": "1.fc40"}}, map[string]string{}},
+		{types.ProcessedFile{FileType: "exe", ExtendedMetadata: map[string]interface{}{"version": "1.0+deb12u1"}}, map[string]string{}},
+	}
+	for _, tt := range tests {
+		if got := fileReleases(tt.file); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("fileReleases(%v) = %v, want %v", tt.file.ExtendedMetadata, got, tt.want)
+		}
+	}
+}
+
+func TestSameRelease(t *testing.T) {
+	tests := []struct {
+		release, fileRelease string
+		want                 bool
+	}{
+		{"12", "12", true},
+		{"22.04:LTS", "22.04", true},
+		{"enterprise_linux:9::appstream", "enterprise_linux:9", true},
+		{"Enterprise_Linux:9", "enterprise_linux:9", true},
+		{"enterprise_linux:8::appstream", "enterprise_linux:9", false},
+		{"1", "12", false},
+		{"12", "1", false},
+	}
+	for _, tt := range tests {
+		if got := sameRelease(tt.release, tt.fileRelease); got != tt.want {
+			t.Errorf("sameRelease(%q, %q) = %v, want %v", tt.release, tt.fileRelease, got, tt.want)
+		}
+	}
+}
+
+func TestLoadIndexesEcosystemReleases(t *testing.T) {
+	db := loadRecords(t, releaseAdvisories)
+	if db.Len() != 5 {
+		t.Errorf("Len() = %d, want 5", db.Len())
+	}
+	if want := []string{"Debian:11", "Debian:12"}; !reflect.DeepEqual(db.releases["debian"], want) {
+		t.Errorf("debian releases = %v, want %v", db.releases["debian"], want)
+	}
+	if n := len(db.packages[packageKey("Debian:12", "openssl")]); n != 2 {
+		t.Errorf("%d Debian:12 entries, want 2", n)
+	}
+	if n := len(db.packages[packageKey("Debian", "openssl")]); n != 1 {
+		t.Errorf("%d Debian entries, want 1", n)
+	}
+}